@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// adbTopCategories ограничивает число категорий PSS, показываемых на панели
+const adbTopCategories = 8
+
+// AdbMemoryReader собирает данные о памяти Android-устройства через adb
+//
+// Системная память читается из /proc/meminfo устройства, а память приложений —
+// из "dumpsys meminfo" в виде PSS, что соответствует тому, как Android учитывает память приложений
+type AdbMemoryReader struct {
+	serial string
+
+	//Защищает данные последнего dumpsys meminfo: коллектор категорий опрашивается
+	//одновременно со сбором таблицы процессов
+	mu         sync.Mutex
+	pss        map[int]uint64
+	names      map[int]string
+	categories []Metric
+}
+
+func NewAdbMemoryReader(serial string) *AdbMemoryReader {
+	return &AdbMemoryReader{serial: serial}
+}
+
+func (a *AdbMemoryReader) shell(args ...string) ([]byte, error) {
+	var cmdArgs []string
+	if a.serial != "" {
+		cmdArgs = append(cmdArgs, "-s", a.serial)
+	}
+	cmdArgs = append(cmdArgs, "shell")
+	cmdArgs = append(cmdArgs, args...)
+	output, err := exec.Command("adb", cmdArgs...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка выполнения adb shell %s: %v", strings.Join(args, " "), err)
+	}
+	return output, nil
+}
+
+func (a *AdbMemoryReader) ReadSystemMemory() (SystemMemoryInfo, error) {
+	output, err := a.shell("cat", "/proc/meminfo")
+	if err != nil {
+		return SystemMemoryInfo{}, err
+	}
+	memStats, err := parseMemInfo(bytes.NewReader(output))
+	if err != nil {
+		return SystemMemoryInfo{}, err
+	}
+	return systemMemoryFromMemInfo(memStats)
+}
+
+// GetProcessList запускает "dumpsys meminfo" и запоминает PSS процессов и категорий,
+// которые затем возвращают ReadProcessMemory, ReadProcessName и коллектор категорий
+func (a *AdbMemoryReader) GetProcessList() ([]int, error) {
+	output, err := a.shell("dumpsys", "meminfo")
+	if err != nil {
+		return nil, err
+	}
+	report := parseDumpsysMeminfo(output)
+	if len(report.pss) == 0 {
+		return nil, fmt.Errorf("dumpsys meminfo не вернул данные о процессах")
+	}
+	a.mu.Lock()
+	a.pss = report.pss
+	a.names = report.names
+	a.categories = report.categories
+	a.mu.Unlock()
+
+	pids := make([]int, 0, len(a.pss))
+	for pid := range a.pss {
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+func (a *AdbMemoryReader) ReadProcessMemory(pid int) (uint64, error) {
+	a.mu.Lock()
+	pss, exists := a.pss[pid]
+	a.mu.Unlock()
+	if !exists {
+		return 0, fmt.Errorf("Процесс с pid %d не найден", pid)
+	}
+	return pss, nil
+}
+
+func (a *AdbMemoryReader) ReadProcessName(pid int) (string, error) {
+	a.mu.Lock()
+	name, exists := a.names[pid]
+	a.mu.Unlock()
+	if !exists {
+		return "", fmt.Errorf("Процесс с pid %d не найден", pid)
+	}
+	return name, nil
+}
+
+// adbCategoryCollector показывает PSS по категориям (Native, Dalvik, .so mmap и т.д.)
+// из последнего вывода dumpsys meminfo
+type adbCategoryCollector struct {
+	reader *AdbMemoryReader
+}
+
+func (c *adbCategoryCollector) Name() string {
+	return "PSS by category"
+}
+
+func (c *adbCategoryCollector) Collect() ([]Metric, error) {
+	c.reader.mu.Lock()
+	categories := c.reader.categories
+	c.reader.mu.Unlock()
+	if len(categories) > adbTopCategories {
+		categories = categories[:adbTopCategories]
+	}
+	return categories, nil
+}
+
+type dumpsysReport struct {
+	pss        map[int]uint64
+	names      map[int]string
+	categories []Metric
+}
+
+var (
+	dumpsysProcessPattern  = regexp.MustCompile(`^\s*([\d,]+)K: (.+?) \(pid (\d+)`)
+	dumpsysCategoryPattern = regexp.MustCompile(`^\s*([\d,]+)K: (.+?)\s*$`)
+)
+
+// parseDumpsysMeminfo разбирает разделы "Total PSS by process" и "Total PSS by category"
+// вывода "dumpsys meminfo"
+func parseDumpsysMeminfo(output []byte) dumpsysReport {
+	report := dumpsysReport{
+		pss:   make(map[int]uint64),
+		names: make(map[int]string),
+	}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			section = ""
+			continue
+		}
+		if strings.HasPrefix(trimmed, "Total PSS by process") {
+			section = "process"
+			continue
+		}
+		if strings.HasPrefix(trimmed, "Total PSS by category") {
+			section = "category"
+			continue
+		}
+		switch section {
+		case "process":
+			match := dumpsysProcessPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			kb, err := parseDumpsysKB(match[1])
+			if err != nil {
+				continue
+			}
+			pid, err := strconv.Atoi(match[3])
+			if err != nil {
+				continue
+			}
+			report.pss[pid] = kb * 1024
+			report.names[pid] = match[2]
+		case "category":
+			match := dumpsysCategoryPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			kb, err := parseDumpsysKB(match[1])
+			if err != nil {
+				continue
+			}
+			report.categories = append(report.categories, Metric{Name: match[2], Value: float64(kb * 1024), Unit: "bytes"})
+		}
+	}
+	return report
+}
+
+func parseDumpsysKB(value string) (uint64, error) {
+	return strconv.ParseUint(strings.ReplaceAll(value, ",", ""), 10, 64)
+}
+
+// adbFlag реализует флаг --adb, который можно указать без значения (единственное устройство)
+// или с серийным номером устройства: --adb=SERIAL
+type adbFlag struct {
+	enabled bool
+	serial  string
+}
+
+func (f *adbFlag) String() string {
+	return f.serial
+}
+
+func (f *adbFlag) Set(value string) error {
+	f.enabled = true
+	if value != "true" {
+		f.serial = value
+	}
+	return nil
+}
+
+func (f *adbFlag) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// AdminAction — действие администратора над памятью ядра, доступное из интерфейса
+// с флагом --allow-admin-actions и только после подтверждения
+type AdminAction struct {
+	//Клавиша, запускающая действие, и его имя в журнале аудита
+	Key, Name string
+	//Вопрос, который показывается перед выполнением
+	Prompt string
+	//Файл sysctl и записываемое в него значение
+	Path, Value string
+	//Выполнять sync перед действием, чтобы грязные страницы тоже могли быть освобождены
+	Sync bool
+}
+
+// adminActions — доступные действия администратора в порядке клавиш в строке подсказки
+var adminActions = []AdminAction{
+	{Key: "D", Name: "drop_caches", Prompt: "Drop page cache, dentries and inodes", Path: "/proc/sys/vm/drop_caches", Value: "3", Sync: true},
+	{Key: "C", Name: "compact_memory", Prompt: "Compact memory", Path: "/proc/sys/vm/compact_memory", Value: "1"},
+}
+
+// findAdminAction возвращает действие, запускаемое клавишей key
+func findAdminAction(key string) (AdminAction, bool) {
+	for _, action := range adminActions {
+		if action.Key == key {
+			return action, true
+		}
+	}
+	return AdminAction{}, false
+}
+
+// hugeBlockOrder — порядок блоков в /proc/buddyinfo, начиная с которого свободный блок
+// вмещает huge page (2 MB при страницах 4 KB); по ним виден результат компактизации
+const hugeBlockOrder = 9
+
+// memoryEffect — показатели памяти до и после действия администратора
+type memoryEffect struct {
+	system SystemMemoryInfo
+	//Свободная память в непрерывных блоках порядка hugeBlockOrder и выше
+	hugeFree uint64
+}
+
+func measureMemoryEffect(reader MemoryReader) (memoryEffect, error) {
+	system, err := reader.ReadSystemMemory()
+	if err != nil {
+		return memoryEffect{}, err
+	}
+	hugeFree, _ := readHugeFreeBlocks()
+	return memoryEffect{system: system, hugeFree: hugeFree}, nil
+}
+
+// readHugeFreeBlocks суммирует свободную память в блоках порядка hugeBlockOrder и выше по /proc/buddyinfo
+func readHugeFreeBlocks() (uint64, error) {
+	file, err := os.Open("/proc/buddyinfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	pageSize := uint64(os.Getpagesize())
+	var total uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Node 0, zone   Normal    100     50 ... — число свободных блоков порядков 0..10
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		for order, countStr := range fields[4:] {
+			if order < hugeBlockOrder {
+				continue
+			}
+			count, err := strconv.ParseUint(countStr, 10, 64)
+			if err != nil {
+				continue
+			}
+			total += count * pageSize << uint(order)
+		}
+	}
+	return total, scanner.Err()
+}
+
+// runAdminAction выполняет действие, записывает его в журнал аудита и возвращает описание
+// результата для строки состояния
+func runAdminAction(action AdminAction, reader MemoryReader, audit *AuditLog) (string, error) {
+	result, err := applyAdminAction(action, reader)
+	event := AuditEvent{Action: action.Name, Outcome: AuditOK, Detail: result}
+	if err != nil {
+		event.Outcome, event.Detail = AuditFailed, err.Error()
+	}
+	if auditErr := audit.Record(event); auditErr != nil && err == nil {
+		result = withAuditError(result, auditErr)
+	}
+	return result, err
+}
+
+func applyAdminAction(action AdminAction, reader MemoryReader) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("%w: действия администратора поддерживаются только в Linux", ErrUnsupportedPlatform)
+	}
+	before, err := measureMemoryEffect(reader)
+	if err != nil {
+		return "", err
+	}
+	if action.Sync {
+		syncFilesystems()
+	}
+	if err := os.WriteFile(action.Path, []byte(action.Value), 0); err != nil {
+		return "", fmt.Errorf("Не удалось записать %s: %v", action.Path, err)
+	}
+	after, err := measureMemoryEffect(reader)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: free %s, available %s, free in huge blocks %s", action.Prompt,
+		formatEffect(before.system.FreeMemory, after.system.FreeMemory),
+		formatEffect(before.system.AvailableMemory, after.system.AvailableMemory),
+		formatEffect(before.hugeFree, after.hugeFree)), nil
+}
+
+// formatEffect форматирует изменение значения: "1.00 GB -> 3.00 GB (+2.00 GB)"
+func formatEffect(before, after uint64) string {
+	sign, delta := "+", after-before
+	if after < before {
+		sign, delta = "-", before-after
+	}
+	return fmt.Sprintf("%s -> %s (%s%s)", FormatMemorySize(before), FormatMemorySize(after), sign, FormatMemorySize(delta))
+}
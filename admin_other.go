@@ -0,0 +1,6 @@
+//go:build !unix || aix
+
+package main
+
+// syncFilesystems: действия администратора выполняются только в Linux
+func syncFilesystems() {}
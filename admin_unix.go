@@ -0,0 +1,10 @@
+//go:build unix && !aix
+
+package main
+
+import "syscall"
+
+// syncFilesystems сбрасывает грязные страницы на диск, чтобы drop_caches освободил их
+func syncFilesystems() {
+	syscall.Sync()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Типы правил оповещений
+const (
+	//Срабатывает, когда прогноз исчерпания памяти короче порога
+	AlertOOMETA = "oom_eta"
+)
+
+// AlertRule — правило оповещения из конфигурационного файла
+type AlertRule struct {
+	//Имя правила, отображаемое в оповещении; по умолчанию совпадает с типом
+	Name string `json:"name"`
+	//Тип условия, например "oom_eta"
+	Type string `json:"type"`
+	//Порог срабатывания в формате, зависящем от типа: для oom_eta — длительность ("30m"),
+	//для overcommit — процент от CommitLimit ("90")
+	Threshold string `json:"threshold"`
+	//Выражение над метриками для типа expr, например "swap_used_pct > 30 AND pressure_avg10 > 5 for 2m"
+	Expr string `json:"expr,omitempty"`
+}
+
+// alertCondition проверяет условие правила на очередном замере
+type alertCondition interface {
+	//check возвращает описание ситуации и признак срабатывания условия
+	check(sample Sample, history *History) (string, bool)
+}
+
+// alertTypes сопоставляет типам правил конструкторы условий
+var alertTypes = map[string]func(threshold string) (alertCondition, error){
+	AlertOOMETA: newOOMETACondition,
+}
+
+// Alert — сработавшее оповещение
+type Alert struct {
+	Rule    string    `json:"rule"`
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
+	//Оповещение подтверждено пользователем; подтверждение снимается, когда условие перестает выполняться
+	Acknowledged bool `json:"acknowledged,omitempty"`
+	//Момент окончания заглушения, если оповещение заглушено
+	SilencedUntil *time.Time `json:"silenced_until,omitempty"`
+}
+
+// AlertEngine вычисляет правила оповещений на каждом замере и помнит, с какого момента
+// каждое из них находится в сработавшем состоянии
+type AlertEngine struct {
+	rules      []AlertRule
+	conditions []alertCondition
+	active     map[string]*Alert
+	//Заглушения правил: имя правила и момент окончания
+	silences map[string]time.Time
+}
+
+// NewAlertEngine проверяет правила из конфигурации и создает AlertEngine
+func NewAlertEngine(rules []AlertRule) (*AlertEngine, error) {
+	engine := &AlertEngine{active: make(map[string]*Alert), silences: make(map[string]time.Time)}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = rule.Type
+		}
+		newCondition, ok := alertTypes[rule.Type]
+		if !ok {
+			return nil, fmt.Errorf("Неизвестный тип правила оповещения: %q", rule.Type)
+		}
+		// Для правил-выражений аргумент конструктора — само выражение
+		argument := rule.Threshold
+		if rule.Type == AlertExpr {
+			argument = rule.Expr
+		}
+		condition, err := newCondition(argument)
+		if err != nil {
+			return nil, fmt.Errorf("Неверное правило оповещения %q: %v", rule.Name, err)
+		}
+		engine.rules = append(engine.rules, rule)
+		engine.conditions = append(engine.conditions, condition)
+	}
+	return engine, nil
+}
+
+// Evaluate проверяет все правила на новом замере и возвращает сработавшие оповещения
+// в порядке объявления правил
+func (e *AlertEngine) Evaluate(sample Sample, history *History) []Alert {
+	if e == nil {
+		return nil
+	}
+	var alerts []Alert
+	for i, rule := range e.rules {
+		message, firing := e.conditions[i].check(sample, history)
+		if !firing {
+			delete(e.active, rule.Name)
+			continue
+		}
+		alert, ok := e.active[rule.Name]
+		if !ok {
+			alert = &Alert{Rule: rule.Name, Since: sample.Time}
+			e.active[rule.Name] = alert
+		}
+		alert.Message = message
+		e.applySilence(alert, sample.Time)
+		alerts = append(alerts, *alert)
+	}
+	return alerts
+}
+
+// Active возвращает оповещения, находящиеся в сработавшем состоянии, в порядке объявления правил
+func (e *AlertEngine) Active() []Alert {
+	if e == nil {
+		return nil
+	}
+	var alerts []Alert
+	for _, rule := range e.rules {
+		if alert, ok := e.active[rule.Name]; ok {
+			alerts = append(alerts, *alert)
+		}
+	}
+	return alerts
+}
+
+// Restore восстанавливает сработавшие оповещения и заглушения из сохраненного состояния,
+// чтобы после перезапуска сохранились момент начала срабатывания и подтверждения.
+// Оповещения правил, которых больше нет в конфигурации, пропускаются; остальные снимаются
+// на первом замере, если условие уже не выполняется
+func (e *AlertEngine) Restore(alerts []Alert, silences map[string]time.Time) {
+	if e == nil {
+		return
+	}
+	for _, alert := range alerts {
+		if e.hasRule(alert.Rule) {
+			restored := alert
+			e.active[alert.Rule] = &restored
+		}
+	}
+	for rule, until := range silences {
+		if e.hasRule(rule) {
+			e.silences[rule] = until
+		}
+	}
+}
+
+// FormatAlerts форматирует список сработавших оповещений или возвращает пустую строку
+func FormatAlerts(alerts []Alert) string {
+	if len(alerts) == 0 {
+		return ""
+	}
+	var res strings.Builder
+	res.WriteString("Alerts:\n")
+	for _, alert := range alerts {
+		line := fmt.Sprintf("[%s] %s (since %s%s)", alert.Rule, alert.Message, alert.Since.Format("15:04:05"), alertMuteSuffix(alert))
+		// Подтвержденные и заглушенные оповещения не выделяются цветом
+		if !alert.Muted() {
+			line = paint(activeTheme.Critical, line)
+		}
+		res.WriteString("  " + line + "\n")
+	}
+	return res.String()
+}
+
+// alertMuteSuffix описывает подтверждение и заглушение оповещения
+func alertMuteSuffix(alert Alert) string {
+	switch {
+	case alert.SilencedUntil != nil:
+		return ", silenced until " + alert.SilencedUntil.Format("15:04:05")
+	case alert.Acknowledged:
+		return ", acknowledged"
+	}
+	return ""
+}
+
+// oomETACondition срабатывает, когда память при текущей тенденции закончится раньше порога
+type oomETACondition struct {
+	threshold time.Duration
+}
+
+func newOOMETACondition(threshold string) (alertCondition, error) {
+	d, err := time.ParseDuration(threshold)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("Порог должен быть длительностью, например \"30m\": %q", threshold)
+	}
+	return &oomETACondition{threshold: d}, nil
+}
+
+func (c *oomETACondition) check(sample Sample, history *History) (string, bool) {
+	if sample.Forecast == nil || sample.Forecast.ETA() > c.threshold {
+		return "", false
+	}
+	return fmt.Sprintf("memory exhausted in %s at current rate", formatETA(sample.Forecast.ETA())), true
+}
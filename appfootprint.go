@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// appFootprintCollector отслеживает память приложения macOS по его bundle id
+//
+// Объем памяти берется из утилиты footprint (phys_footprint — то же значение, что показывают
+// Xcode и Activity Monitor), а события о нехватке памяти — из потока "log stream"
+type appFootprintCollector struct {
+	bundleID string
+
+	mu          sync.Mutex
+	pid         int
+	events      int
+	lastEvent   string
+	logStream   *exec.Cmd
+	streamedPID int
+}
+
+func newAppFootprintCollector(bundleID string) *appFootprintCollector {
+	return &appFootprintCollector{bundleID: bundleID}
+}
+
+func (c *appFootprintCollector) Name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastEvent != "" {
+		return fmt.Sprintf("%s (last event: %s)", c.bundleID, c.lastEvent)
+	}
+	return c.bundleID
+}
+
+func (c *appFootprintCollector) Collect() ([]Metric, error) {
+	pid, err := bundlePID(c.bundleID)
+	if err != nil {
+		return nil, err
+	}
+	footprint, err := readFootprint(pid)
+	if err != nil {
+		return nil, err
+	}
+	c.watchMemoryEvents(pid)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return []Metric{
+		{Name: "pid", Value: float64(pid)},
+		{Name: "footprint", Value: float64(footprint), Unit: "bytes"},
+		{Name: "memory events", Value: float64(c.events)},
+	}, nil
+}
+
+// Close останавливает процесс "log stream"
+func (c *appFootprintCollector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.logStream != nil {
+		c.logStream.Process.Kill()
+		c.logStream.Wait()
+		c.logStream = nil
+	}
+	return nil
+}
+
+var lsappinfoPIDPattern = regexp.MustCompile(`"pid"\s*=\s*(\d+)`)
+
+// bundlePID находит PID запущенного приложения по bundle id с помощью lsappinfo
+func bundlePID(bundleID string) (int, error) {
+	output, err := exec.Command("lsappinfo", "info", "-only", "pid", bundleID).Output()
+	if err != nil {
+		return 0, fmt.Errorf("lsappinfo недоступен: %v", err)
+	}
+	match := lsappinfoPIDPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, fmt.Errorf("Приложение %s не запущено", bundleID)
+	}
+	return strconv.Atoi(match[1])
+}
+
+var footprintPattern = regexp.MustCompile(`Footprint:\s+([\d.]+)\s*([KMGT]?B)`)
+
+// readFootprint возвращает phys_footprint процесса по выводу утилиты footprint
+func readFootprint(pid int) (uint64, error) {
+	output, err := exec.Command("footprint", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, fmt.Errorf("footprint недоступен: %v", err)
+	}
+	match := footprintPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, fmt.Errorf("Неверный формат вывода footprint")
+	}
+	return parseByteSize(match[1] + match[2])
+}
+
+// watchMemoryEvents запускает "log stream" для процесса, если он еще не запущен
+// или приложение было перезапущено с другим PID
+func (c *appFootprintCollector) watchMemoryEvents(pid int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.logStream != nil && c.streamedPID == pid {
+		return
+	}
+	if c.logStream != nil {
+		c.logStream.Process.Kill()
+		c.logStream.Wait()
+		c.logStream = nil
+	}
+	predicate := fmt.Sprintf(`processID == %d AND (eventMessage CONTAINS[c] "memory" OR eventMessage CONTAINS[c] "jetsam")`, pid)
+	cmd := exec.Command("log", "stream", "--style", "ndjson", "--predicate", predicate)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	c.logStream = cmd
+	c.streamedPID = pid
+	go c.readMemoryEvents(bufio.NewScanner(stdout))
+}
+
+type logStreamEvent struct {
+	EventMessage string `json:"eventMessage"`
+}
+
+func (c *appFootprintCollector) readMemoryEvents(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		var event logStreamEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.EventMessage == "" {
+			continue
+		}
+		message := strings.TrimSpace(event.EventMessage)
+		if len(message) > 60 {
+			message = message[:57] + "..."
+		}
+		c.mu.Lock()
+		c.events++
+		c.lastEvent = message
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+)
+
+// appleSilicon — программа запущена на Mac с процессором серии M (в том числе под Rosetta)
+var appleSilicon bool
+
+func init() {
+	if runtime.GOOS != "darwin" {
+		return
+	}
+	if arm64, err := sysctlUint("hw.optional.arm64"); err != nil || arm64 != 1 {
+		return
+	}
+	appleSilicon = true
+	RegisterCollector(&unifiedMemoryCollector{})
+}
+
+// UnifiedMemory — составляющие единой памяти Apple Silicon, общей для CPU и GPU,
+// в тех же категориях, что и в Мониторе активности
+type UnifiedMemory struct {
+	//Память приложений: анонимные страницы без purgeable
+	App uint64
+	//Закрепленная память ядра и драйверов, включая буферы GPU
+	Wired uint64
+	//Место, занятое сжатыми страницами в компрессоре
+	Compressed uint64
+	//Кэш файлов
+	Cached uint64
+}
+
+// unifiedMemoryFromVmStat вычисляет составляющие единой памяти по значениям vm_stat;
+// ok равно false, если в выводе нет нужных строк (старые версии macOS)
+func unifiedMemoryFromVmStat(stats map[string]uint64, pageSize uint64) (UnifiedMemory, bool) {
+	anonymous, hasAnonymous := stats["Anonymous pages"]
+	compressed, hasCompressed := stats["occupied by compressor"]
+	if !hasAnonymous || !hasCompressed {
+		return UnifiedMemory{}, false
+	}
+	app := anonymous
+	if purgeable := stats["purgeable"]; purgeable < app {
+		app -= purgeable
+	}
+	return UnifiedMemory{
+		App:        app * pageSize,
+		Wired:      stats["wired down"] * pageSize,
+		Compressed: compressed * pageSize,
+		Cached:     (stats["File-backed pages"] + stats["purgeable"]) * pageSize,
+	}, true
+}
+
+// Used возвращает занятую память так же, как Монитор активности: приложения,
+// закрепленная память и компрессор
+func (u UnifiedMemory) Used() uint64 {
+	return u.App + u.Wired + u.Compressed
+}
+
+// Available возвращает доступную память: все, что не занято, включая кэш файлов и purgeable,
+// которые система освобождает сама. Сумма free, inactive и speculative на Apple Silicon
+// не подходит: inactive включает анонимные страницы, а кэш файлов учитывается дважды
+func (u UnifiedMemory) Available(total uint64) uint64 {
+	if used := u.Used(); used < total {
+		return total - used
+	}
+	return 0
+}
+
+// gpuMemoryPattern находит объем памяти, используемой GPU, в выводе ioreg для AGXAccelerator
+var gpuMemoryPattern = regexp.MustCompile(`"In use system memory"=(\d+)`)
+
+// readGPUMemory возвращает объем единой памяти, используемой GPU
+func readGPUMemory() (uint64, error) {
+	output, err := exec.Command("ioreg", "-r", "-d", "1", "-w", "0", "-c", "AGXAccelerator").Output()
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for _, match := range gpuMemoryPattern.FindAllStringSubmatch(string(output), -1) {
+		value, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += value
+	}
+	return total, nil
+}
+
+// readGPUWiredLimit возвращает предел памяти, которую GPU может закрепить (sysctl iogpu.wired_limit_mb);
+// 0 означает предел по умолчанию, выбранный системой
+func readGPUWiredLimit() uint64 {
+	limit, err := sysctlUint("iogpu.wired_limit_mb")
+	if err != nil {
+		return 0
+	}
+	return limit * 1024 * 1024
+}
+
+// unifiedMemoryCollector показывает на панели, из чего складывается занятая единая память
+// Apple Silicon: память приложений, закрепленная, сжатая и используемая GPU
+type unifiedMemoryCollector struct{}
+
+func (c *unifiedMemoryCollector) Name() string {
+	return "unified memory"
+}
+
+func (c *unifiedMemoryCollector) Collect() ([]Metric, error) {
+	stats, pageSize, err := readVmStat()
+	if err != nil {
+		return nil, err
+	}
+	unified, ok := unifiedMemoryFromVmStat(stats, pageSize)
+	if !ok {
+		return nil, fmt.Errorf("vm_stat не выводит анонимные и сжатые страницы")
+	}
+	metrics := []Metric{
+		{Name: "app", Value: float64(unified.App), Unit: "bytes"},
+		{Name: "wired", Value: float64(unified.Wired), Unit: "bytes"},
+		{Name: "compressed", Value: float64(unified.Compressed), Unit: "bytes"},
+		{Name: "cached", Value: float64(unified.Cached), Unit: "bytes"},
+	}
+	// Память GPU входит в wired, поэтому выводится отдельно, но не складывается с ней
+	if gpu, err := readGPUMemory(); err == nil && gpu > 0 {
+		metrics = append(metrics, Metric{Name: "gpu", Value: float64(gpu), Unit: "bytes"})
+	}
+	if limit := readGPUWiredLimit(); limit > 0 {
+		metrics = append(metrics, Metric{Name: "gpu limit", Value: float64(limit), Unit: "bytes"})
+	}
+	return metrics, nil
+}
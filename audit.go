@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Результаты действий в журнале аудита
+const (
+	AuditOK      = "ok"
+	AuditFailed  = "failed"
+	AuditSkipped = "skipped"
+)
+
+// AuditEvent — одно действие пользователя, изменяющее систему: сигнал процессу или
+// действие администратора над памятью ядра
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	//Пользователь, от имени которого работает программа, и пользователь, запустивший ее через sudo
+	User     string `json:"user"`
+	SudoUser string `json:"sudo_user,omitempty"`
+	//Действие: kill, freeze, resume, drop_caches, compact_memory
+	Action string `json:"action"`
+	//Отправленный сигнал, если действие — сигнал процессу
+	Signal string `json:"signal,omitempty"`
+	//Процесс, к которому применялось действие (0 — действие над системой)
+	PID  int    `json:"pid,omitempty"`
+	Name string `json:"name,omitempty"`
+	//Результат (ok, failed, skipped) и его описание
+	Outcome string `json:"outcome"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// AuditLog дописывает действия пользователя в файл журнала аудита в формате JSON Lines
+// и копит их до следующего замера, чтобы они ушли в приемники вместе с ним.
+// Нулевой *AuditLog ничего не записывает
+type AuditLog struct {
+	mu       sync.Mutex
+	file     *os.File
+	user     string
+	sudoUser string
+	pending  []AuditEvent
+}
+
+// OpenAuditLog открывает журнал аудита для дозаписи; пустой путь отключает файл журнала,
+// но события по-прежнему передаются в приемники
+func OpenAuditLog(path string) (*AuditLog, error) {
+	log := &AuditLog{user: strconv.Itoa(os.Getuid()), sudoUser: os.Getenv("SUDO_USER")}
+	if u, err := user.Current(); err == nil {
+		log.user = u.Username
+	}
+	if path == "" {
+		return log, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// Журнал доступен только владельцу: в нем имена процессов и пользователей
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть журнал аудита %s: %v", path, err)
+	}
+	log.file = file
+	return log, nil
+}
+
+// Record записывает событие, дополняя его временем и пользователем. Ошибка записи
+// возвращается, чтобы о ней узнал пользователь: действие к этому моменту уже выполнено
+func (l *AuditLog) Record(event AuditEvent) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event.Time = time.Now()
+	event.User, event.SudoUser = l.user, l.sudoUser
+	l.pending = append(l.pending, event)
+	if l.file == nil {
+		return nil
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Не удалось записать журнал аудита: %v", err)
+	}
+	return nil
+}
+
+// Drain возвращает события, накопленные с предыдущего вызова
+func (l *AuditLog) Drain() []AuditEvent {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := l.pending
+	l.pending = nil
+	return events
+}
+
+func (l *AuditLog) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// auditSignal записывает результат отправки сигнала процессу с описанием detail
+func (l *AuditLog) auditSignal(action, signal string, process ProcessInfo, outcome, detail string) error {
+	return l.Record(AuditEvent{Action: action, Signal: signal, PID: process.PID, Name: process.Name, Outcome: outcome, Detail: detail})
+}
+
+// withAuditError дописывает к строке состояния ошибку записи журнала аудита, если она была
+func withAuditError(status string, err error) string {
+	if err == nil {
+		return status
+	}
+	return status + " — " + err.Error()
+}
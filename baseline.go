@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Baseline — типичное потребление памяти процессами на исправной системе
+type Baseline struct {
+	Time time.Time `json:"time"`
+	//Среднее потребление памяти одним процессом с данным именем
+	Processes map[string]uint64 `json:"processes"`
+}
+
+// BaselineThresholds задают, какое отклонение от базовой линии считается значимым
+//
+// Процесс выделяется, если отклонение превышает оба порога; нулевой порог не учитывается
+type BaselineThresholds struct {
+	Percent float64
+	Bytes   uint64
+}
+
+// captureBaseline усредняет потребление памяти процессов по нескольким замерам
+func captureBaseline(reader MemoryReader, samples int, interval time.Duration) (*Baseline, error) {
+	totals := make(map[string]uint64)
+	counts := make(map[string]uint64)
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		processes, err := collectProcesses(reader, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Не удалось получить список процессов: %v", err)
+		}
+		for _, process := range processes {
+			totals[process.Name] += process.MemoryUsage
+			counts[process.Name]++
+		}
+	}
+	baseline := &Baseline{Time: time.Now(), Processes: make(map[string]uint64, len(totals))}
+	for name, total := range totals {
+		baseline.Processes[name] = total / counts[name]
+	}
+	return baseline, nil
+}
+
+// LoadBaseline читает базовую линию из файла
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось прочитать базовую линию %s: %v", path, err)
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("Неверный формат базовой линии %s: %v", path, err)
+	}
+	return &baseline, nil
+}
+
+// Save записывает базовую линию в файл, создавая каталог при необходимости
+func (b *Baseline) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// lookup возвращает типичное потребление памяти процессом с таким именем
+func (b *Baseline) lookup(process ProcessInfo) (uint64, bool) {
+	if memory, ok := b.Processes[process.Name]; ok {
+		return memory, true
+	}
+	for name, memory := range b.Processes {
+		if matchesProcessName(process.Name, name) {
+			return memory, true
+		}
+	}
+	return 0, false
+}
+
+// baselineDeviates сообщает, отклоняется ли потребление памяти процессом от базовой линии
+// больше допустимого; процессы, отсутствующие в базовой линии, сравниваются с нулем
+func baselineDeviates(process ProcessInfo, thresholds BaselineThresholds) bool {
+	if process.Baseline == nil {
+		return false
+	}
+	base := *process.Baseline
+	diff := process.MemoryUsage - base
+	if base > process.MemoryUsage {
+		diff = base - process.MemoryUsage
+	}
+	if diff == 0 || diff < thresholds.Bytes {
+		return false
+	}
+	return base == 0 || float64(diff)/float64(base)*100 >= thresholds.Percent
+}
+
+// formatBaselineChange форматирует изменение относительно базовой линии: "+150%", "-20%", "new"
+func formatBaselineChange(process ProcessInfo) string {
+	if process.Baseline == nil {
+		return "-"
+	}
+	base := *process.Baseline
+	if base == 0 {
+		return "new"
+	}
+	change := (float64(process.MemoryUsage) - float64(base)) / float64(base) * 100
+	return fmt.Sprintf("%+.0f%%", change)
+}
+
+// colorizeBaselineRows окрашивает в желтый строки процессов, заметно отклонившихся от базовой линии
+func colorizeBaselineRows(table string, processes []ProcessInfo, thresholds BaselineThresholds) string {
+	lines := strings.Split(table, "\n")
+	for i, process := range processes {
+		idx := tableHeaderLines + i
+		if idx < len(lines) && baselineDeviates(process, thresholds) {
+			lines[idx] = paint(activeTheme.Warning, lines[idx])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runBaselineCommand — подкоманда "baseline": "baseline save" записывает типичное
+// потребление памяти процессами для последующего сравнения с флагом --baseline
+func runBaselineCommand(args []string) error {
+	if len(args) == 0 || args[0] != "save" {
+		return fmt.Errorf("Использование: memory-analyzer baseline save [--samples N] [--interval d] [--output file]")
+	}
+	flags := newCommandFlags("baseline save")
+	samples := flags.Int("samples", 5, "number of `samples` to average")
+	interval := flags.Duration("interval", 2*time.Second, "`interval` between samples")
+	output := flags.String("output", defaultStatePath("baseline.json"), "baseline `file` to write")
+	flags.Parse(args[1:])
+	if *samples < 1 {
+		return fmt.Errorf("Число замеров должно быть положительным: %d", *samples)
+	}
+
+	reader, err := newLocalReader()
+	if err != nil {
+		return err
+	}
+	baseline, err := captureBaseline(reader, *samples, *interval)
+	if err != nil {
+		return err
+	}
+	if err := baseline.Save(*output); err != nil {
+		return fmt.Errorf("Не удалось сохранить базовую линию: %v", err)
+	}
+	fmt.Printf("Baseline of %d processes saved to %s\n", len(baseline.Processes), *output)
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToggleMark отмечает процесс для группового действия или снимает отметку
+func (s *ViewState) ToggleMark(process ProcessInfo) {
+	if s.Marked[process.PID] {
+		delete(s.Marked, process.PID)
+		return
+	}
+	s.Marked[process.PID] = true
+}
+
+// MarkAll отмечает все строки таблицы view, а если они уже отмечены — снимает отметки
+func (s *ViewState) MarkAll(view []ProcessInfo) {
+	all := true
+	for _, process := range view {
+		if !s.Marked[process.PID] {
+			all = false
+			break
+		}
+	}
+	for _, process := range view {
+		if all {
+			delete(s.Marked, process.PID)
+		} else {
+			s.Marked[process.PID] = true
+		}
+	}
+}
+
+// ClearMarks снимает все отметки
+func (s *ViewState) ClearMarks() {
+	s.Marked = make(map[int]bool)
+}
+
+// PruneMarks снимает отметки с завершившихся процессов, чтобы групповое действие
+// не затронуло новый процесс, получивший тот же PID
+func (s *ViewState) PruneMarks(processes []ProcessInfo) {
+	if len(s.Marked) == 0 {
+		return
+	}
+	alive := make(map[int]bool, len(processes))
+	for _, process := range processes {
+		alive[process.PID] = true
+	}
+	for pid := range s.Marked {
+		if !alive[pid] {
+			delete(s.Marked, pid)
+		}
+	}
+}
+
+// actionTargets возвращает процессы, к которым применяется действие: отмеченные строки таблицы
+// view в порядке таблицы, а если отметок нет — выделенную строку
+func (s *ViewState) actionTargets(view []ProcessInfo) []ProcessInfo {
+	var targets []ProcessInfo
+	for _, process := range view {
+		if s.Marked[process.PID] {
+			targets = append(targets, process)
+		}
+	}
+	if len(targets) == 0 && s.Selected < len(view) {
+		targets = append(targets, view[s.Selected])
+	}
+	return targets
+}
+
+// PinTargets закрепляет процессы; если все они уже закреплены, снимает закрепление
+func (s *ViewState) PinTargets(targets []ProcessInfo) {
+	pin := false
+	for _, process := range targets {
+		if !s.PinnedPIDs[process.PID] {
+			pin = true
+			break
+		}
+	}
+	for _, process := range targets {
+		if pin {
+			s.PinnedPIDs[process.PID] = true
+		} else {
+			delete(s.PinnedPIDs, process.PID)
+		}
+	}
+}
+
+// WatchTargets добавляет имена процессов в список наблюдения: процессы с этими именами,
+// в том числе запущенные позже, закрепляются в начале таблицы, как с флагом --pin
+func (s *ViewState) WatchTargets(targets []ProcessInfo) int {
+	added := 0
+	for _, process := range targets {
+		known := false
+		for _, name := range s.WatchNames {
+			if name == process.Name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			s.WatchNames = append(s.WatchNames, process.Name)
+			added++
+		}
+	}
+	return added
+}
+
+// killPrompt возвращает вопрос перед завершением процессов
+func killPrompt(targets []ProcessInfo) string {
+	names := make([]string, 0, len(targets))
+	for _, process := range targets {
+		names = append(names, fmt.Sprintf("%s (%d)", process.Name, process.PID))
+	}
+	list := strings.Join(names, ", ")
+	if len(names) > 3 {
+		list = strings.Join(names[:3], ", ") + fmt.Sprintf(" and %d more", len(names)-3)
+	}
+	return fmt.Sprintf("Send SIGTERM to %d processes: %s? Press y to confirm, any other key to cancel", len(targets), list)
+}
+
+// killProcesses отправляет SIGTERM процессам, записывает результат в журнал аудита и возвращает
+// описание результата для строки состояния. Процесс пропускается, если под его PID уже работает
+// процесс с другим именем
+func killProcesses(reader MemoryReader, targets []ProcessInfo, audit *AuditLog) string {
+	sent := 0
+	var failures []string
+	var auditErr error
+	record := func(process ProcessInfo, outcome, detail string) {
+		if err := audit.auditSignal("kill", "SIGTERM", process, outcome, detail); err != nil {
+			auditErr = err
+		}
+	}
+	for _, process := range targets {
+		if name, err := reader.ReadProcessName(process.PID); err != nil || name != process.Name {
+			failures = append(failures, fmt.Sprintf("%d: exited", process.PID))
+			record(process, AuditSkipped, "exited")
+			continue
+		}
+		if err := terminateProcess(process.PID); err != nil {
+			failures = append(failures, fmt.Sprintf("%d: %v", process.PID, err))
+			record(process, AuditFailed, err.Error())
+			continue
+		}
+		record(process, AuditOK, "")
+		sent++
+	}
+	status := fmt.Sprintf("Sent SIGTERM to %d of %d processes", sent, len(targets))
+	if len(failures) > 0 {
+		status += " (" + strings.Join(failures, ", ") + ")"
+	}
+	return withAuditError(status, auditErr)
+}
+
+// markRows помечает знаком "+" строки отмеченных процессов в таблице FormatTable
+// на месте признака закрепления, а закрепленные и отмеченные одновременно — знаком "#",
+// чтобы отметка не скрывала закрепление
+func markRows(table string, processes []ProcessInfo, marked map[int]bool) string {
+	if len(marked) == 0 {
+		return table
+	}
+	lines := strings.Split(table, "\n")
+	for i, process := range processes {
+		idx := tableHeaderLines + i
+		if idx < len(lines) && marked[process.PID] && len(lines[idx]) > tableNameOffset {
+			marker := "+"
+			if process.Pinned {
+				marker = "#"
+			}
+			lines[idx] = lines[idx][:tableNameOffset-1] + marker + lines[idx][tableNameOffset:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatMarks возвращает строку с числом отмеченных процессов и доступными групповыми действиями
+func FormatMarks(state *ViewState) string {
+	if len(state.Marked) == 0 {
+		return ""
+	}
+	if state.ReadOnly {
+		return fmt.Sprintf("Marked: %d processes (p pin, w watch, e export, Esc clear)\n", len(state.Marked))
+	}
+	return fmt.Sprintf("Marked: %d processes (p pin, w watch, z freeze, e export, K kill, Esc clear)\n", len(state.Marked))
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "fmt"
+
+// terminateProcess: SIGTERM есть только в Unix
+func terminateProcess(pid int) error {
+	return fmt.Errorf("%w: завершение процессов сигналом доступно только в Unix", ErrUnsupportedPlatform)
+}
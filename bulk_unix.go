@@ -0,0 +1,10 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// terminateProcess отправляет процессу SIGTERM
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
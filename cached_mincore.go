@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// residentPages возвращает по байту на каждую страницу файла размером size; младший бит
+// установлен у страниц, находящихся в page cache (mincore).
+//
+// Файл отображается в память только для запроса, его страницы не читаются,
+// поэтому проверка сама не заполняет кэш
+func residentPages(file *os.File, size int64) ([]byte, error) {
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось отобразить %s в память: %v", file.Name(), err)
+	}
+	defer syscall.Munmap(data)
+
+	pageSize := os.Getpagesize()
+	pages := make([]byte, (len(data)+pageSize-1)/pageSize)
+	_, _, errno := syscall.Syscall(syscall.SYS_MINCORE,
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&pages[0])))
+	if errno != 0 {
+		return nil, fmt.Errorf("mincore для %s: %v", file.Name(), errno)
+	}
+	return pages, nil
+}
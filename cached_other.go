@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// residentPages: mincore есть только в Linux, macOS и BSD
+func residentPages(file *os.File, size int64) ([]byte, error) {
+	return nil, fmt.Errorf("%w: mincore доступен только в Linux, macOS и BSD", ErrUnsupportedPlatform)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+)
+
+// capabilityChecks — проверки doctor, по которым определяются возможности сбора данных
+var capabilityChecks = map[string]string{
+	"MemAvailable":   "mem_available",
+	"smaps_rollup":   "pss",
+	"pressure (PSI)": "psi",
+	"cgroup":         "cgroups",
+	"swap counters":  "swap_io",
+	"proc connector": "proc_events",
+	"unified memory": "unified_memory",
+	"gpu":            "gpu",
+	"ebpf":           "ebpf",
+}
+
+// Capability — состояние одной возможности: ok, degraded, off или FAIL, как в doctor
+type Capability struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Capabilities — какие данные собирает анализатор на этой машине, чтобы внешние
+// потребители и веб-интерфейс не рассчитывали на поля, которых здесь не бывает
+type Capabilities struct {
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	//Возможности по именам: pss, psi, cgroups, gpu, ebpf, swap_io и другие
+	Features map[string]Capability `json:"features"`
+	//Имена коллекторов, данные которых попадают в поле collectors замеров
+	Collectors []string `json:"collectors"`
+	//Среда запуска: container, wsl или virtual machine, если она распознана
+	Environment map[string]string `json:"environment,omitempty"`
+	Checks      []DoctorCheck     `json:"checks"`
+}
+
+// newCapabilities сводит проверки doctor в матрицу возможностей
+func newCapabilities(checks []DoctorCheck) Capabilities {
+	caps := Capabilities{
+		Version:    readBuildInfo().Version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Features:   make(map[string]Capability),
+		Collectors: []string{},
+		Checks:     checks,
+	}
+	for _, check := range checks {
+		if feature, ok := capabilityChecks[check.Name]; ok {
+			caps.Features[feature] = Capability{Status: check.Status, Detail: check.Detail}
+		}
+		switch check.Name {
+		case "container", "wsl", "virtual machine":
+			if caps.Environment == nil {
+				caps.Environment = make(map[string]string)
+			}
+			caps.Environment[check.Name] = check.Detail
+		case "privileges":
+			// PSS процессов других пользователей читается только с правами root
+			if pss, ok := caps.Features["pss"]; ok && pss.Status == doctorOK && check.Status != doctorOK {
+				caps.Features["pss"] = Capability{Status: doctorDegraded, Detail: "own processes only: " + check.Detail}
+			}
+		}
+	}
+	for _, c := range collectors {
+		caps.Collectors = append(caps.Collectors, c.Name())
+	}
+	return caps
+}
+
+// capabilitiesCache — матрица возможностей демона: проверки запускают внешние программы,
+// а результат за время работы не меняется
+var capabilitiesCache struct {
+	once sync.Once
+	caps Capabilities
+	err  error
+}
+
+// handleCapabilities: GET /api/capabilities возвращает матрицу возможностей, ту же,
+// что выводит doctor --json
+func (s *APIServer) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	capabilitiesCache.once.Do(func() {
+		checks, err := doctorChecks()
+		capabilitiesCache.caps, capabilitiesCache.err = newCapabilities(checks), err
+	})
+	if capabilitiesCache.err != nil {
+		writeError(w, http.StatusInternalServerError, capabilitiesCache.err)
+		return
+	}
+	writeJSON(w, http.StatusOK, capabilitiesCache.caps)
+}
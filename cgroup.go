@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// cgroupRoot — точка монтирования иерархии cgroup
+const cgroupRoot = "/sys/fs/cgroup"
+
+// Пороги в процентах от лимита памяти cgroup, начиная с которых строка процесса окрашивается
+const (
+	limitWarningPercent  = 80
+	limitCriticalPercent = 90
+)
+
+// cgroupUnlimited — значения лимита не меньше этого в cgroup v1 означают отсутствие лимита
+const cgroupUnlimited = 1 << 62
+
+// CgroupReader реализуют источники данных, умеющие определять cgroup процесса и ее лимит памяти
+type CgroupReader interface {
+	//ReadProcessCgroup возвращает путь cgroup процесса в иерархии контроллера памяти
+	ReadProcessCgroup(pid int) (string, error)
+	//ReadCgroupMemoryLimit возвращает действующий лимит памяти cgroup
+	//с учетом родительских групп или 0, если лимит не задан
+	ReadCgroupMemoryLimit(cgroup string) (uint64, error)
+	//ReadCgroupMemoryEvents возвращает накопленные счетчики событий памяти cgroup
+	ReadCgroupMemoryEvents(cgroup string) (MemoryEvents, error)
+}
+
+// MemoryEvents — счетчики событий памяти cgroup из memory.events
+type MemoryEvents struct {
+	//Число случаев, когда cgroup достигла лимита и не смогла освободить память
+	OOM uint64 `json:"oom"`
+	//Число процессов cgroup, завершенных OOM killer
+	OOMKill uint64 `json:"oom_kill"`
+	//Число превышений memory.high, при которых процессы cgroup притормаживались
+	High uint64 `json:"high"`
+	//Число попыток превысить memory.max
+	Max uint64 `json:"max"`
+}
+
+// CgroupEvents — счетчики событий памяти cgroup и их прирост с начала наблюдения
+type CgroupEvents struct {
+	Cgroup   string       `json:"cgroup"`
+	Counters MemoryEvents `json:"counters"`
+	Delta    MemoryEvents `json:"delta"`
+}
+
+func (l *LinuxMemoryReader) ReadProcessCgroup(pid int) (string, error) {
+	file, err := os.Open(l.procPath(pid, "cgroup"))
+	if err != nil {
+		return "", processError(pid, err)
+	}
+	defer file.Close()
+
+	// Строки имеют вид "4:memory:/path" (cgroup v1) или "0::/path" (cgroup v2).
+	// В гибридном режиме контроллер памяти находится в иерархии v1
+	unified := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			unified = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller == "memory" {
+				return parts[2], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if unified == "" {
+		return "", fmt.Errorf("cgroup процесса %d не найдена", pid)
+	}
+	return unified, nil
+}
+
+func (l *LinuxMemoryReader) ReadCgroupMemoryLimit(cgroup string) (uint64, error) {
+	var limit uint64
+	for dir := path.Clean("/" + cgroup); ; dir = path.Dir(dir) {
+		if value, ok := readCgroupLimitFile(l.path(cgroupRoot), dir); ok && (limit == 0 || value < limit) {
+			limit = value
+		}
+		if dir == "/" {
+			break
+		}
+	}
+	return limit, nil
+}
+
+func (l *LinuxMemoryReader) ReadCgroupMemoryEvents(cgroup string) (MemoryEvents, error) {
+	root := l.path(cgroupRoot)
+	file, err := os.Open(filepath.Join(root, cgroup, "memory.events"))
+	if err == nil {
+		defer file.Close()
+		counters, err := parseKeyValues(file)
+		if err != nil {
+			return MemoryEvents{}, err
+		}
+		return MemoryEvents{
+			OOM:     counters["oom"],
+			OOMKill: counters["oom_kill"],
+			High:    counters["high"],
+			Max:     counters["max"],
+		}, nil
+	}
+
+	// В cgroup v1 счетчик OOM kill находится в memory.oom_control,
+	// а число упоров в лимит — в memory.failcnt; аналога memory.high нет
+	dir := filepath.Join(root, "memory", cgroup)
+	file, err = os.Open(filepath.Join(dir, "memory.oom_control"))
+	if err != nil {
+		return MemoryEvents{}, err
+	}
+	defer file.Close()
+	control, err := parseKeyValues(file)
+	if err != nil {
+		return MemoryEvents{}, err
+	}
+	events := MemoryEvents{OOMKill: control["oom_kill"]}
+	if data, err := os.ReadFile(filepath.Join(dir, "memory.failcnt")); err == nil {
+		events.Max, _ = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+	return events, nil
+}
+
+// readCgroupLimitFile читает лимит памяти одной cgroup из memory.max (v2)
+// или memory.limit_in_bytes (v1) в иерархии root; ok равно false, если лимит не задан
+func readCgroupLimitFile(root, cgroup string) (uint64, bool) {
+	candidates := []string{
+		filepath.Join(root, cgroup, "memory.max"),
+		filepath.Join(root, "memory", cgroup, "memory.limit_in_bytes"),
+	}
+	for _, candidate := range candidates {
+		data, err := os.ReadFile(candidate)
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "max" {
+			return 0, false
+		}
+		limit, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || limit >= cgroupUnlimited {
+			return 0, false
+		}
+		return limit, true
+	}
+	return 0, false
+}
+
+// cgroupLimits кэширует лимиты cgroup в пределах одного цикла сбора данных
+type cgroupLimits struct {
+	mu     sync.Mutex
+	reader CgroupReader
+	limits map[string]uint64
+}
+
+func (c *cgroupLimits) limit(cgroup string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if limit, ok := c.limits[cgroup]; ok {
+		return limit
+	}
+	limit, _ := c.reader.ReadCgroupMemoryLimit(cgroup)
+	c.limits[cgroup] = limit
+	return limit
+}
+
+// LimitPercent возвращает потребление памяти процессом в процентах от лимита его cgroup
+// или 0, если лимит не задан
+func (p ProcessInfo) LimitPercent() float64 {
+	if p.CgroupLimit == 0 {
+		return 0
+	}
+	return float64(p.MemoryUsage) / float64(p.CgroupLimit) * 100
+}
+
+// colorizeLimitRows окрашивает строки процессов, приближающихся к лимиту памяти cgroup:
+// желтым от limitWarningPercent, красным от limitCriticalPercent
+func colorizeLimitRows(table string, processes []ProcessInfo) string {
+	lines := strings.Split(table, "\n")
+	for i, process := range processes {
+		idx := tableHeaderLines + i
+		if process.CgroupLimit == 0 || idx >= len(lines) {
+			continue
+		}
+		percent := process.LimitPercent()
+		if percent >= limitCriticalPercent {
+			lines[idx] = paint(activeTheme.Critical, lines[idx])
+		} else if percent >= limitWarningPercent {
+			lines[idx] = paint(activeTheme.Warning, lines[idx])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e MemoryEvents) isZero() bool {
+	return e == MemoryEvents{}
+}
+
+// collectCgroupEvents читает счетчики событий памяти всех cgroup, в которых есть процессы
+func collectCgroupEvents(reader MemoryReader, processes []ProcessInfo) []CgroupEvents {
+	cgReader, ok := reader.(CgroupReader)
+	if !ok {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var events []CgroupEvents
+	for _, process := range processes {
+		if process.Cgroup == "" || seen[process.Cgroup] {
+			continue
+		}
+		seen[process.Cgroup] = true
+		counters, err := cgReader.ReadCgroupMemoryEvents(process.Cgroup)
+		if err != nil {
+			continue
+		}
+		events = append(events, CgroupEvents{Cgroup: process.Cgroup, Counters: counters})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Cgroup < events[j].Cgroup
+	})
+	return events
+}
+
+// applyCgroupEventDeltas накапливает прирост счетчиков событий с начала наблюдения,
+// чтобы однажды случившийся OOM kill не пропадал с экрана на следующем обновлении
+func applyCgroupEventDeltas(events []CgroupEvents, previous []CgroupEvents) {
+	prevEvents := make(map[string]CgroupEvents, len(previous))
+	for _, prev := range previous {
+		prevEvents[prev.Cgroup] = prev
+	}
+	for i := range events {
+		prev, ok := prevEvents[events[i].Cgroup]
+		if !ok {
+			continue
+		}
+		cur := events[i].Counters
+		events[i].Delta = MemoryEvents{
+			OOM:     prev.Delta.OOM + counterDelta(prev.Counters.OOM, cur.OOM),
+			OOMKill: prev.Delta.OOMKill + counterDelta(prev.Counters.OOMKill, cur.OOMKill),
+			High:    prev.Delta.High + counterDelta(prev.Counters.High, cur.High),
+			Max:     prev.Delta.Max + counterDelta(prev.Counters.Max, cur.Max),
+		}
+	}
+}
+
+// counterDelta возвращает прирост счетчика; сброс счетчика (пересоздание cgroup) дает 0
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+// FormatCgroupEvents форматирует таблицу cgroup с ненулевыми счетчиками событий памяти;
+// строки cgroup, в которых с начала наблюдения были OOM kill, выделяются красным
+func FormatCgroupEvents(events []CgroupEvents) string {
+	var rows []CgroupEvents
+	for _, event := range events {
+		if !event.Counters.isZero() {
+			rows = append(rows, event)
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	var res strings.Builder
+	res.WriteString("Cgroup Memory Events:\n")
+	header := "CGROUP                                 OOM    OOM_KILL        HIGH         MAX"
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for _, event := range rows {
+		line := fmt.Sprintf("%s  %s  %s  %s  %s", fitLeft(ellipsizeLeft(event.Cgroup, 30), 30),
+			formatEventCounter(event.Counters.OOM, event.Delta.OOM),
+			formatEventCounter(event.Counters.OOMKill, event.Delta.OOMKill),
+			formatEventCounter(event.Counters.High, event.Delta.High),
+			formatEventCounter(event.Counters.Max, event.Delta.Max))
+		if event.Delta.OOMKill > 0 {
+			line = paint(activeTheme.Critical, line)
+		}
+		res.WriteString(line + "\n")
+	}
+	return res.String()
+}
+
+// formatEventCounter форматирует счетчик с приростом, например "12 (+3)"
+func formatEventCounter(value, delta uint64) string {
+	s := strconv.FormatUint(value, 10)
+	if delta > 0 {
+		s += fmt.Sprintf(" (+%d)", delta)
+	}
+	return fitRight(s, extraColumnWidth)
+}
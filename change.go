@@ -0,0 +1,57 @@
+package main
+
+// dashboardSnapshot хранит данные, отображенные при последней отрисовке панели
+type dashboardSnapshot struct {
+	stats      SystemMemoryInfo
+	view       []ProcessInfo
+	collectors string
+	events     string
+	alerts     string
+	exited     string
+}
+
+func newDashboardSnapshot(sample Sample, config DisplayConfig, state *ViewState) *dashboardSnapshot {
+	return &dashboardSnapshot{
+		stats:      sample.System,
+		view:       visibleProcesses(sample.Processes, config, state),
+		collectors: FormatCollectors(sample.Collectors),
+		events:     FormatCgroupEvents(sample.CgroupEvents),
+		alerts:     FormatForecast(sample.Forecast) + FormatAlerts(sample.Alerts),
+		exited:     formatRecentExits(state),
+	}
+}
+
+// changedBeyond сообщает, отличаются ли новые данные от отображенных больше чем на threshold байт
+//
+// Изменение состава или порядка строк таблицы, данных коллекторов, событий cgroup, прогноза, оповещений
+// и списка завершившихся процессов всегда считается значимым
+func (s *dashboardSnapshot) changedBeyond(next *dashboardSnapshot, threshold uint64) bool {
+	stats, view := next.stats, next.view
+	if s == nil || len(s.view) != len(view) || s.collectors != next.collectors || s.events != next.events || s.alerts != next.alerts || s.exited != next.exited {
+		return true
+	}
+	if differs(s.stats.TotalMemory, stats.TotalMemory, threshold) ||
+		differs(s.stats.FreeMemory, stats.FreeMemory, threshold) ||
+		differs(s.stats.AvailableMemory, stats.AvailableMemory, threshold) ||
+		differs(s.stats.SwapTotal, stats.SwapTotal, threshold) ||
+		differs(s.stats.SwapFree, stats.SwapFree, threshold) {
+		return true
+	}
+	for i, process := range view {
+		prev := s.view[i]
+		if prev.PID != process.PID || prev.Pinned != process.Pinned {
+			return true
+		}
+		if differs(prev.MemoryUsage, process.MemoryUsage, threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+func differs(a, b, threshold uint64) bool {
+	if a > b {
+		return a-b > threshold
+	}
+	return b-a > threshold
+}
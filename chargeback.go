@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы отчета о стоимости памяти
+const (
+	ChargebackText     = "text"
+	ChargebackCSV      = "csv"
+	ChargebackMarkdown = "markdown"
+)
+
+// ChargebackEntry — потребление памяти и его стоимость для одного ключа отчета
+type ChargebackEntry struct {
+	Key string
+	//Потребление памяти в гигабайт-часах (GiB·ч)
+	GBHours float64
+	//Среднее потребление памяти за период отчета
+	Average uint64
+	Cost    float64
+}
+
+// ChargebackReport — отчет о стоимости памяти за период записи
+type ChargebackReport struct {
+	Start, End time.Time
+	By         string
+	Rate       float64
+	Entries    []ChargebackEntry
+}
+
+// chargebackKey возвращает ключ процесса для отчета: имя процесса, пользователя или значение метки ("label:team")
+func chargebackKey(process ProcessInfo, by string) string {
+	switch by {
+	case "process":
+		return process.Name
+	case "user":
+		if process.User == "" {
+			return "unknown"
+		}
+		return process.User
+	}
+	return groupKey(process, by)
+}
+
+// chargebackAccumulator интегрирует потребление памяти по времени между замерами
+//
+// Память каждого замера учитывается до следующего замера, но не дольше maxGap,
+// чтобы промежутки между окнами записи и остановки демона не оплачивались
+type chargebackAccumulator struct {
+	by       string
+	maxGap   time.Duration
+	start    time.Time
+	previous *Sample
+	lastStep time.Duration
+	byteSecs map[string]float64
+	covered  time.Duration
+}
+
+func newChargebackAccumulator(by string, maxGap time.Duration) *chargebackAccumulator {
+	return &chargebackAccumulator{by: by, maxGap: maxGap, byteSecs: make(map[string]float64)}
+}
+
+func (a *chargebackAccumulator) add(sample Sample) {
+	if a.previous == nil {
+		a.start = sample.Time
+	} else {
+		a.charge(*a.previous, sample.Time.Sub(a.previous.Time))
+	}
+	a.previous = &sample
+}
+
+// charge учитывает память замера в течение step, ограниченного maxGap
+func (a *chargebackAccumulator) charge(sample Sample, step time.Duration) {
+	if step <= 0 {
+		return
+	}
+	if step > a.maxGap {
+		step = a.maxGap
+	}
+	a.lastStep = step
+	a.covered += step
+	for _, process := range sample.Processes {
+		a.byteSecs[chargebackKey(process, a.by)] += float64(process.MemoryUsage) * step.Seconds()
+	}
+}
+
+// result возвращает отчет; последний замер учитывается с тем же шагом, что и предыдущий.
+// Ключи сортируются по убыванию стоимости
+func (a *chargebackAccumulator) result(rate float64) ChargebackReport {
+	report := ChargebackReport{By: a.by, Rate: rate}
+	if a.previous == nil {
+		return report
+	}
+	a.charge(*a.previous, a.lastStep)
+	a.previous = nil
+	report.Start = a.start
+	report.End = a.start.Add(a.covered)
+	for key, byteSecs := range a.byteSecs {
+		entry := ChargebackEntry{Key: key, GBHours: byteSecs / (1 << 30) / 3600}
+		if a.covered > 0 {
+			entry.Average = uint64(byteSecs / a.covered.Seconds())
+		}
+		entry.Cost = entry.GBHours * rate
+		report.Entries = append(report.Entries, entry)
+	}
+	sort.Slice(report.Entries, func(i, j int) bool {
+		if report.Entries[i].GBHours != report.Entries[j].GBHours {
+			return report.Entries[i].GBHours > report.Entries[j].GBHours
+		}
+		return report.Entries[i].Key < report.Entries[j].Key
+	})
+	return report
+}
+
+// WriteChargebackReport выводит отчет в формате text, csv или markdown, показывая top ключей
+func WriteChargebackReport(w io.Writer, report ChargebackReport, format string, top int) error {
+	entries := report.Entries
+	if top > 0 && len(entries) > top {
+		entries = entries[:top]
+	}
+	var total ChargebackEntry
+	for _, entry := range report.Entries {
+		total.GBHours += entry.GBHours
+		total.Cost += entry.Cost
+	}
+
+	switch format {
+	case ChargebackCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{report.By, "gb_hours", "average_bytes", "cost"})
+		for _, entry := range entries {
+			cw.Write([]string{entry.Key, strconv.FormatFloat(entry.GBHours, 'f', 4, 64),
+				strconv.FormatUint(entry.Average, 10), strconv.FormatFloat(entry.Cost, 'f', 4, 64)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case ChargebackMarkdown:
+		var res strings.Builder
+		res.WriteString(fmt.Sprintf("Memory cost %s - %s at %g per GB-hour\n\n",
+			report.Start.Format("2006-01-02 15:04"), report.End.Format("2006-01-02 15:04"), report.Rate))
+		res.WriteString(fmt.Sprintf("| %s | GB-hours | Average | Cost |\n|---|---:|---:|---:|\n", report.By))
+		for _, entry := range entries {
+			res.WriteString(fmt.Sprintf("| %s | %.2f | %s | %.2f |\n",
+				strings.ReplaceAll(entry.Key, "|", `\|`), entry.GBHours, FormatMemorySize(entry.Average), entry.Cost))
+		}
+		res.WriteString(fmt.Sprintf("| **Total** | %.2f | | %.2f |\n", total.GBHours, total.Cost))
+		_, err := io.WriteString(w, res.String())
+		return err
+	case ChargebackText:
+		var res strings.Builder
+		res.WriteString(fmt.Sprintf("Memory cost: %s - %s at %g per GB-hour\n",
+			report.Start.Format("2006-01-02 15:04:05"), report.End.Format("2006-01-02 15:04:05"), report.Rate))
+		header := fmt.Sprintf("%-22s  %10s  %10s  %10s", strings.ToUpper(report.By), "GB-HOURS", "AVERAGE", "COST")
+		res.WriteString(header + "\n")
+		res.WriteString(strings.Repeat("-", len(header)) + "\n")
+		for _, entry := range entries {
+			res.WriteString(fmt.Sprintf("%s  %10.2f  %10s  %10.2f\n", fitLeft(ellipsize(entry.Key, 22), 22), entry.GBHours, FormatMemorySize(entry.Average), entry.Cost))
+		}
+		res.WriteString(fmt.Sprintf("%-22s  %10.2f  %10s  %10.2f\n", "Total", total.GBHours, "", total.Cost))
+		_, err := io.WriteString(w, res.String())
+		return err
+	}
+	return fmt.Errorf("Неизвестный формат отчета: %s", format)
+}
+
+// runChargebackCommand — подкоманда "chargeback": стоимость памяти по записям демона
+func runChargebackCommand(args []string) error {
+	flags := newCommandFlags("chargeback")
+	rate := flags.Float64("cost-per-gb-hour", 0, "memory `cost` per GB-hour")
+	by := flags.String("by", "process", "aggregate by process, user or label:<key>")
+	format := flags.String("format", ChargebackText, "report `format`: text, csv or markdown")
+	top := flags.Int("top", 0, "number of entries to list (0 lists all)")
+	maxGap := flags.Duration("max-gap", 5*time.Minute, "longest `interval` between samples that is still charged")
+	flags.Parse(args)
+	if flags.NArg() == 0 || *rate <= 0 {
+		return fmt.Errorf("Использование: memory-analyzer chargeback --cost-per-gb-hour N [--by process|user|label:key] [--format text|csv|markdown] file...")
+	}
+	if *by != "process" && *by != "user" && !strings.HasPrefix(*by, groupByLabelPrefix) {
+		return fmt.Errorf("Неизвестный ключ отчета %q: ожидается process, user или label:<метка>", *by)
+	}
+
+	acc := newChargebackAccumulator(*by, *maxGap)
+	err := readRecordings(flags.Args(), func(sample Sample) error {
+		acc.add(sample)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return WriteChargebackReport(os.Stdout, acc.result(*rate), *format, *top)
+}
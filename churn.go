@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// churnTopNames — число самых частых имен короткоживущих процессов в показателе churn
+const churnTopNames = 3
+
+// Источники событий монитора churn
+const (
+	ChurnSourceNetlink = "proc connector"
+	ChurnSourceKqueue  = "kqueue"
+	ChurnSourcePolling = "polling"
+)
+
+// churnExitRetention — сколько хранится время завершения процесса для уточнения сводки его жизни
+const churnExitRetention = time.Minute
+
+// ChurnStats — показатель churn: запуски и завершения процессов между замерами,
+// включая процессы, которые жили меньше одного обновления и не попали ни в один замер
+type ChurnStats struct {
+	//Промежуток, за который подсчитаны события
+	Period time.Duration `json:"period"`
+	//Источник событий: ChurnSourceNetlink, ChurnSourceKqueue (точные события ядра) или ChurnSourcePolling
+	Source string `json:"source"`
+	//Число запусков и завершений процессов за промежуток
+	Started int `json:"started"`
+	Exited  int `json:"exited"`
+	//Процессы, запущенные и завершившиеся в пределах промежутка
+	ShortLived int `json:"short_lived"`
+	//Суммарный пик потребления памяти короткоживущими процессами
+	ShortLivedMemory uint64 `json:"short_lived_bytes"`
+	//Самые частые имена короткоживущих процессов
+	TopNames []string `json:"top_names,omitempty"`
+}
+
+// StartRate возвращает число запусков процессов в секунду
+func (c ChurnStats) StartRate() float64 {
+	if c.Period <= 0 {
+		return 0
+	}
+	return float64(c.Started) / c.Period.Seconds()
+}
+
+// churnProcess — процесс, запущенный после последнего снимка показателя
+type churnProcess struct {
+	name string
+	peak uint64
+}
+
+// ChurnMonitor отслеживает запуски и завершения процессов чаще, чем обновляется панель
+//
+// События поступают из proc connector Linux, если он доступен, или из опроса списка
+// процессов (pollChurn); в macOS завершения процессов сообщает kqueue. Источник событий
+// вызывает processStarted, processExecuted и processExited, а панель забирает
+// накопленный показатель методом Snapshot
+type ChurnMonitor struct {
+	mu     sync.Mutex
+	reader MemoryReader
+	events *ProcEventSource
+	exits  *ExitWatcher
+	//Время завершения недавно завершившихся процессов
+	exitTimes map[int]time.Time
+	//Процессы, известные монитору; young — запущенные после последнего снимка
+	known map[int]bool
+	young map[int]*churnProcess
+	since time.Time
+	stats ChurnStats
+	names map[string]int
+	stop  chan struct{}
+}
+
+// NewChurnMonitor создает монитор; reader используется только горутинами монитора
+// и не должен использоваться одновременно с основным циклом
+func NewChurnMonitor(reader MemoryReader) *ChurnMonitor {
+	return &ChurnMonitor{
+		reader: reader,
+		known:  make(map[int]bool),
+		young:  make(map[int]*churnProcess),
+		names:  make(map[string]int),
+
+		exitTimes: make(map[int]time.Time),
+		since:     time.Now(),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start подписывается на события proc connector, если это возможно, и запускает опрос
+// с периодом interval: полный опрос списка процессов или, при точных событиях,
+// только обновление пика памяти недавно запущенных процессов
+func (m *ChurnMonitor) Start(interval time.Duration) error {
+	// Подписка до чтения списка, чтобы не пропустить процессы, запущенные между ними
+	m.events, _ = openProcConnector()
+	if m.events == nil {
+		m.exits, _ = NewExitWatcher()
+	}
+	pids, err := m.reader.GetProcessList()
+	if err != nil {
+		if m.events != nil {
+			m.events.Close()
+		}
+		if m.exits != nil {
+			m.exits.Close()
+		}
+		return fmt.Errorf("Не удалось получить список процессов: %v", err)
+	}
+	for _, pid := range pids {
+		m.known[pid] = true
+		m.watchExit(pid)
+	}
+	if m.events != nil {
+		go m.events.Run(m)
+	}
+	if m.exits != nil {
+		go m.exits.Run(m.processExited)
+	}
+	go m.pollChurn(interval)
+	return nil
+}
+
+// Stop останавливает горутины монитора
+func (m *ChurnMonitor) Stop() {
+	close(m.stop)
+	if m.events != nil {
+		m.events.Close()
+	}
+	if m.exits != nil {
+		m.exits.Close()
+	}
+}
+
+// watchExit подписывается на завершение процесса через kqueue, если он используется;
+// процесс, успевший завершиться до подписки, сразу учитывается как завершившийся
+func (m *ChurnMonitor) watchExit(pid int) {
+	if m.exits == nil {
+		return
+	}
+	if err := m.exits.Watch(pid); errors.Is(err, syscall.ESRCH) {
+		go m.processExited(pid)
+	}
+}
+
+// source возвращает источник событий монитора
+func (m *ChurnMonitor) source() string {
+	switch {
+	case m.events != nil:
+		return ChurnSourceNetlink
+	case m.exits != nil:
+		return ChurnSourceKqueue
+	}
+	return ChurnSourcePolling
+}
+
+// startChurnMonitor запускает монитор churn с собственным локальным источником данных,
+// чтобы опрос не пересекался с основным циклом; при interval <= 0 возвращает nil
+func startChurnMonitor(interval time.Duration) (*ChurnMonitor, error) {
+	if interval <= 0 {
+		return nil, nil
+	}
+	reader, err := newLocalReader()
+	if err != nil {
+		return nil, err
+	}
+	monitor := NewChurnMonitor(reader)
+	if err := monitor.Start(interval); err != nil {
+		return nil, err
+	}
+	return monitor, nil
+}
+
+// pollChurn сравнивает списки процессов соседних опросов; у процессов, запущенных
+// после последнего снимка, на каждом опросе обновляется пик потребления памяти
+func (m *ChurnMonitor) pollChurn(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		if m.events != nil {
+			for _, pid := range m.youngPIDs() {
+				m.updateYoung(pid)
+			}
+			continue
+		}
+		pids, err := m.reader.GetProcessList()
+		if err != nil {
+			continue
+		}
+		current := make(map[int]bool, len(pids))
+		for _, pid := range pids {
+			current[pid] = true
+			if !m.isKnown(pid) {
+				m.processStarted(pid)
+			} else {
+				m.updateYoung(pid)
+			}
+		}
+		for _, pid := range m.knownPIDs() {
+			if !current[pid] {
+				m.processExited(pid)
+			}
+		}
+	}
+}
+
+func (m *ChurnMonitor) isKnown(pid int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.known[pid]
+}
+
+func (m *ChurnMonitor) youngPIDs() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pids := make([]int, 0, len(m.young))
+	for pid := range m.young {
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+func (m *ChurnMonitor) knownPIDs() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pids := make([]int, 0, len(m.known))
+	for pid := range m.known {
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// processStarted учитывает запуск процесса; имя и память читаются сразу,
+// пока процесс еще существует
+func (m *ChurnMonitor) processStarted(pid int) {
+	process := &churnProcess{}
+	process.name, _ = m.reader.ReadProcessName(pid)
+	process.peak, _ = m.reader.ReadProcessMemory(pid)
+
+	m.mu.Lock()
+	m.known[pid] = true
+	m.young[pid] = process
+	m.stats.Started++
+	m.mu.Unlock()
+	m.watchExit(pid)
+}
+
+// processExecuted перечитывает имя и память недавно запущенного процесса после смены
+// программы: при fork процесс еще носит имя родителя и делит с ним его память
+func (m *ChurnMonitor) processExecuted(pid int) {
+	name, err := m.reader.ReadProcessName(pid)
+	if err != nil {
+		return
+	}
+	mem, _ := m.reader.ReadProcessMemory(pid)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if process, ok := m.young[pid]; ok {
+		process.name, process.peak = name, mem
+	}
+}
+
+// updateYoung обновляет пик потребления памяти процесса, запущенного после последнего снимка
+func (m *ChurnMonitor) updateYoung(pid int) {
+	m.mu.Lock()
+	process, ok := m.young[pid]
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	if mem, err := m.reader.ReadProcessMemory(pid); err == nil {
+		m.mu.Lock()
+		if mem > process.peak {
+			process.peak = mem
+		}
+		m.mu.Unlock()
+	}
+}
+
+// processExited учитывает завершение процесса; процесс, запущенный после последнего
+// снимка, считается короткоживущим
+func (m *ChurnMonitor) processExited(pid int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.known[pid] {
+		return
+	}
+	delete(m.known, pid)
+	m.exitTimes[pid] = time.Now()
+	m.stats.Exited++
+	if process, ok := m.young[pid]; ok {
+		delete(m.young, pid)
+		m.stats.ShortLived++
+		m.stats.ShortLivedMemory += process.peak
+		if process.name != "" {
+			m.names[process.name]++
+		}
+	}
+}
+
+// Snapshot возвращает показатель с момента предыдущего снимка и начинает новый промежуток;
+// для nil-монитора возвращает nil
+func (m *ChurnMonitor) Snapshot(now time.Time) *ChurnStats {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	stats.Period = now.Sub(m.since)
+	stats.Source = m.source()
+	names := make([]string, 0, len(m.names))
+	for name := range m.names {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if m.names[names[i]] != m.names[names[j]] {
+			return m.names[names[i]] > m.names[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > churnTopNames {
+		names = names[:churnTopNames]
+	}
+	stats.TopNames = names
+
+	m.stats = ChurnStats{}
+	m.names = make(map[string]int)
+	m.young = make(map[int]*churnProcess)
+	m.since = now
+	for pid, exited := range m.exitTimes {
+		if now.Sub(exited) > churnExitRetention {
+			delete(m.exitTimes, pid)
+		}
+	}
+	return &stats
+}
+
+// ExitTime возвращает время завершения процесса, если монитор его зафиксировал
+func (m *ChurnMonitor) ExitTime(pid int) (time.Time, bool) {
+	if m == nil {
+		return time.Time{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	exited, ok := m.exitTimes[pid]
+	return exited, ok
+}
+
+// FormatChurn форматирует строку показателя churn для панели
+func FormatChurn(churn *ChurnStats) string {
+	if churn == nil {
+		return ""
+	}
+	line := fmt.Sprintf("Churn (%s): %.1f started/s, %d exited, %d short-lived (%s peak total)", churn.Source,
+		churn.StartRate(), churn.Exited, churn.ShortLived, FormatMemorySize(churn.ShortLivedMemory))
+	if len(churn.TopNames) > 0 {
+		line += ", mostly " + strings.Join(churn.TopNames, ", ")
+	}
+	return line + "\n"
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Collector — источник дополнительных данных, отображаемых на информационной панели
+//
+// Встроенные коллекторы регистрируются через RegisterCollector в init(),
+// внешние подключаются из конфигурации как исполняемые плагины
+type Collector interface {
+	//Name возвращает имя коллектора, под которым его данные показываются на панели
+	Name() string
+
+	//Collect собирает текущие значения метрик
+	//
+	//В случае ошибки возвращает nil и описание ошибки
+	Collect() ([]Metric, error)
+}
+
+// Metric — одно значение, полученное от коллектора
+type Metric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	//Единица измерения. Значения с единицей "bytes" форматируются как размер памяти
+	Unit string `json:"unit,omitempty"`
+}
+
+// CollectorResult — результат опроса одного коллектора
+type CollectorResult struct {
+	Name    string   `json:"name"`
+	Metrics []Metric `json:"metrics,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// PluginConfig описывает внешний коллектор в конфигурационном файле
+type PluginConfig struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+}
+
+// pluginTimeout ограничивает время ожидания ответа от внешнего плагина
+const pluginTimeout = 5 * time.Second
+
+var collectors []Collector
+
+// RegisterCollector добавляет коллектор в реестр, опрашиваемый на каждом обновлении
+func RegisterCollector(c Collector) {
+	collectors = append(collectors, c)
+}
+
+// runCollectors опрашивает все зарегистрированные коллекторы
+func runCollectors() []CollectorResult {
+	var results []CollectorResult
+	for _, c := range collectors {
+		results = append(results, runCollector(c))
+	}
+	return results
+}
+
+// runCollector опрашивает один коллектор; паника коллектора становится ошибкой
+// его результата, а остальные коллекторы опрашиваются как обычно
+func runCollector(c Collector) (result CollectorResult) {
+	defer func() {
+		if value := recover(); value != nil {
+			if result.Name == "" {
+				result.Name = fmt.Sprintf("%T", c)
+			}
+			result.Metrics, result.Error = nil, recordPanic("collector "+result.Name, value).Error()
+		}
+	}()
+	result.Name = c.Name()
+	metrics, err := c.Collect()
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Metrics = metrics
+	}
+	return result
+}
+
+// closeCollectors завершает процессы внешних плагинов
+func closeCollectors() {
+	for _, c := range collectors {
+		if closer, ok := c.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// FormatCollectors форматирует результаты коллекторов для информационной панели
+func FormatCollectors(results []CollectorResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+	var res strings.Builder
+	res.WriteString("Collectors:\n")
+	for _, result := range results {
+		if result.Error != "" {
+			res.WriteString(fmt.Sprintf("  %s: error: %s\n", result.Name, result.Error))
+			continue
+		}
+		var values []string
+		for _, metric := range result.Metrics {
+			values = append(values, fmt.Sprintf("%s %s", metric.Name, formatMetricValue(metric)))
+		}
+		res.WriteString(fmt.Sprintf("  %s: %s\n", result.Name, strings.Join(values, ", ")))
+	}
+	return res.String()
+}
+
+func formatMetricValue(metric Metric) string {
+	switch metric.Unit {
+	case "bytes":
+		if metric.Value < 0 {
+			return fmt.Sprintf("%g", metric.Value)
+		}
+		return FormatMemorySize(uint64(metric.Value))
+	case "":
+		return fmt.Sprintf("%g", metric.Value)
+	}
+	return fmt.Sprintf("%g %s", metric.Value, metric.Unit)
+}
+
+// execCollector — внешний плагин, работающий как отдельный процесс
+//
+// Протокол: на каждый опрос в stdin плагина пишется строка {"method":"collect"},
+// в ответ плагин выводит в stdout одну строку {"metrics":[...]} или {"error":"..."}.
+// Процесс запускается при первом опросе и перезапускается, если завершился
+type execCollector struct {
+	name    string
+	command []string
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+type pluginResponse struct {
+	Metrics []Metric `json:"metrics"`
+	Error   string   `json:"error"`
+}
+
+func newExecCollector(config PluginConfig) (*execCollector, error) {
+	if len(config.Command) == 0 {
+		return nil, fmt.Errorf("Не задана команда плагина %s", config.Name)
+	}
+	name := config.Name
+	if name == "" {
+		name = config.Command[0]
+	}
+	return &execCollector{name: name, command: config.Command}, nil
+}
+
+func (c *execCollector) Name() string {
+	return c.name
+}
+
+func (c *execCollector) Collect() ([]Metric, error) {
+	if c.cmd == nil {
+		if err := c.start(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := io.WriteString(c.stdin, "{\"method\":\"collect\"}\n"); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("Плагин не принимает запросы: %v", err)
+	}
+	line, err := c.readLine()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	var response pluginResponse
+	if err := json.Unmarshal(line, &response); err != nil {
+		return nil, fmt.Errorf("Неверный ответ плагина: %v", err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%s", response.Error)
+	}
+	return response.Metrics, nil
+}
+
+func (c *execCollector) start() error {
+	cmd := exec.Command(c.command[0], c.command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Не удалось запустить плагин: %v", err)
+	}
+	c.cmd = cmd
+	c.stdin = stdin
+	c.stdout = bufio.NewReader(stdout)
+	return nil
+}
+
+func (c *execCollector) readLine() ([]byte, error) {
+	type lineResult struct {
+		line []byte
+		err  error
+	}
+	done := make(chan lineResult, 1)
+	go func() {
+		line, err := c.stdout.ReadBytes('\n')
+		done <- lineResult{line, err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, fmt.Errorf("Плагин завершился: %v", res.err)
+		}
+		return res.line, nil
+	case <-time.After(pluginTimeout):
+		return nil, fmt.Errorf("Плагин не ответил за %s", pluginTimeout)
+	}
+}
+
+// Close останавливает процесс плагина
+func (c *execCollector) Close() error {
+	if c.cmd == nil {
+		return nil
+	}
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	c.cmd = nil
+	return nil
+}
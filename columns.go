@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// columnTimeout ограничивает время выполнения команды дополнительной колонки
+const columnTimeout = 5 * time.Second
+
+// ColumnConfig описывает дополнительную колонку таблицы, заполняемую внешней командой
+//
+// Если PerProcess равен false, команда запускается один раз за обновление и выводит строки
+// вида "<pid> <значение>". Иначе команда запускается для каждого отображаемого процесса,
+// а в аргументах подставляются {{pid}} и {{name}}; значением служит первая строка вывода
+type ColumnConfig struct {
+	Name       string   `json:"name"`
+	Command    []string `json:"command"`
+	PerProcess bool     `json:"per_process"`
+}
+
+// ExtraValue — значение дополнительной колонки для процесса
+type ExtraValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// fillExtraColumns заполняет дополнительные колонки процессов
+//
+// Колонки, вычисляемые для каждого процесса, заполняются только для PID из visible,
+// чтобы не запускать команды для процессов, которые не попадают на экран
+func fillExtraColumns(processes []ProcessInfo, visible []ProcessInfo, columns []ColumnConfig) {
+	if len(columns) == 0 {
+		return
+	}
+	shown := make(map[int]bool, len(visible))
+	for _, process := range visible {
+		shown[process.PID] = true
+	}
+	for _, column := range columns {
+		var values map[int]string
+		if !column.PerProcess {
+			values = runColumnCommand(column.Command)
+		}
+		for i := range processes {
+			value := ""
+			if column.PerProcess {
+				if shown[processes[i].PID] {
+					value = runProcessColumnCommand(column.Command, processes[i])
+				}
+			} else {
+				value = values[processes[i].PID]
+			}
+			// Команды колонок часто выводят аргументы процессов, поэтому значения проходят через redact
+			processes[i].Extra = append(processes[i].Extra, ExtraValue{Name: column.Name, Value: activeRedactor.Redact(value)})
+		}
+	}
+}
+
+// runColumnCommand запускает команду колонки и разбирает строки "<pid> <значение>"
+func runColumnCommand(command []string) map[int]string {
+	values := make(map[int]string)
+	output, err := runWithTimeout(command)
+	if err != nil {
+		return values
+	}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		values[pid] = strings.Join(fields[1:], " ")
+	}
+	return values
+}
+
+func runProcessColumnCommand(command []string, process ProcessInfo) string {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		arg = strings.ReplaceAll(arg, "{{pid}}", strconv.Itoa(process.PID))
+		arg = strings.ReplaceAll(arg, "{{name}}", process.Name)
+		args[i] = arg
+	}
+	output, err := runWithTimeout(args)
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(output, "\n")
+	return strings.TrimSpace(line)
+}
+
+func runWithTimeout(command []string) (string, error) {
+	if len(command) == 0 {
+		return "", fmt.Errorf("Пустая команда")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), columnTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeWindowSeparator разделяет начало и конец окна времени ("09:00..10:00")
+const timeWindowSeparator = ".."
+
+// TimeWindow — промежуток времени записи: абсолютный ("2026-10-15T09:00..2026-10-15T10:00")
+// или время суток ("09:00..10:00"), совпадающее с этим промежутком в любой день записи
+type TimeWindow struct {
+	Text       string
+	Start, End time.Time
+	//Начало и конец окна как смещения от начала суток, если окно задано временем суток
+	Daily                bool
+	DailyStart, DailyEnd time.Duration
+}
+
+// timeWindowLayouts — форматы абсолютного времени в окне
+var timeWindowLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseTimeWindow разбирает окно времени "начало..конец"
+func ParseTimeWindow(text string) (TimeWindow, error) {
+	window := TimeWindow{Text: text}
+	parts := strings.Split(text, timeWindowSeparator)
+	if len(parts) != 2 {
+		return window, fmt.Errorf("Неверное окно времени %q: ожидается начало..конец", text)
+	}
+	start, startErr := parseTimeOfDay(parts[0])
+	end, endErr := parseTimeOfDay(parts[1])
+	if startErr == nil && endErr == nil {
+		window.Daily, window.DailyStart, window.DailyEnd = true, start, end
+		return window, nil
+	}
+
+	var err error
+	if window.Start, err = parseWindowTime(parts[0]); err != nil {
+		return window, fmt.Errorf("Неверное окно времени %q: %v", text, err)
+	}
+	if window.End, err = parseWindowTime(parts[1]); err != nil {
+		return window, fmt.Errorf("Неверное окно времени %q: %v", text, err)
+	}
+	if !window.End.After(window.Start) {
+		return window, fmt.Errorf("Неверное окно времени %q: конец раньше начала", text)
+	}
+	return window, nil
+}
+
+// parseTimeOfDay разбирает время суток "15:04" или "15:04:05" как смещение от начала суток
+func parseTimeOfDay(text string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("Неверное время суток %q", text)
+}
+
+func parseWindowTime(text string) (time.Time, error) {
+	for _, layout := range timeWindowLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(text), time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Неверное время %q", text)
+}
+
+// Contains сообщает, попадает ли момент времени в окно; окно времени суток может
+// переходить через полночь ("22:00..06:00")
+func (w TimeWindow) Contains(t time.Time) bool {
+	if !w.Daily {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+	t = t.Local()
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.DailyStart <= w.DailyEnd {
+		return offset >= w.DailyStart && offset < w.DailyEnd
+	}
+	return offset >= w.DailyStart || offset < w.DailyEnd
+}
+
+// ProcessChange — изменение среднего и пикового потребления памяти процессами с одним именем между окнами
+type ProcessChange struct {
+	Name                        string
+	BeforeAverage, AfterAverage uint64
+	BeforePeak, AfterPeak       uint64
+}
+
+// Delta возвращает изменение среднего потребления памяти
+func (c ProcessChange) Delta() int64 {
+	return int64(c.AfterAverage) - int64(c.BeforeAverage)
+}
+
+// WindowComparison — сравнение сводок двух окон записи
+type WindowComparison struct {
+	Before, After RecordingSummary
+	BeforeWindow  TimeWindow
+	AfterWindow   TimeWindow
+	//Процессы по убыванию изменения среднего потребления памяти
+	Changes []ProcessChange
+}
+
+// compareSummaries сопоставляет процессы двух сводок по имени; процесс, отсутствующий
+// в одном из окон, считается потреблявшим в нем ноль байт
+func compareSummaries(before, after RecordingSummary) []ProcessChange {
+	changes := make(map[string]*ProcessChange)
+	change := func(name string) *ProcessChange {
+		c, ok := changes[name]
+		if !ok {
+			c = &ProcessChange{Name: name}
+			changes[name] = c
+		}
+		return c
+	}
+	for _, process := range before.Processes {
+		c := change(process.Name)
+		c.BeforeAverage, c.BeforePeak = process.Average, process.Peak
+	}
+	for _, process := range after.Processes {
+		c := change(process.Name)
+		c.AfterAverage, c.AfterPeak = process.Average, process.Peak
+	}
+
+	result := make([]ProcessChange, 0, len(changes))
+	for _, c := range changes {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Delta() != result[j].Delta() {
+			return result[i].Delta() > result[j].Delta()
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// formatSignedSize форматирует изменение размера со знаком
+func formatSignedSize(delta int64) string {
+	if delta < 0 {
+		return "-" + FormatMemorySize(uint64(-delta))
+	}
+	return "+" + FormatMemorySize(uint64(delta))
+}
+
+// FormatWindowComparison форматирует сравнение окон: общие показатели и top процессов
+// с наибольшим ростом и снижением среднего потребления памяти
+func FormatWindowComparison(c WindowComparison, top int) string {
+	var res strings.Builder
+	res.WriteString(fmt.Sprintf("Comparing %s (%d samples) with %s (%d samples)\n",
+		c.BeforeWindow.Text, c.Before.Samples, c.AfterWindow.Text, c.After.Samples))
+	res.WriteString(fmt.Sprintf("%-14s %10s  %10s  %10s\n", "", "BEFORE", "AFTER", "CHANGE"))
+	rows := []struct {
+		name          string
+		before, after uint64
+	}{
+		{"Average used", c.Before.AverageUsed, c.After.AverageUsed},
+		{"Peak used", c.Before.PeakUsed, c.After.PeakUsed},
+		{"Peak swap", c.Before.PeakSwap, c.After.PeakSwap},
+	}
+	for _, row := range rows {
+		res.WriteString(fmt.Sprintf("%-14s %10s  %10s  %10s\n", row.name,
+			FormatMemorySize(row.before), FormatMemorySize(row.after), formatSignedSize(int64(row.after)-int64(row.before))))
+	}
+
+	section := func(title string, changes []ProcessChange) {
+		res.WriteString("\n" + title + ":\n")
+		header := fmt.Sprintf("%-15s %10s  %10s  %10s  %12s", "NAME", "BEFORE", "AFTER", "CHANGE", "PEAK AFTER")
+		res.WriteString(header + "\n")
+		res.WriteString(strings.Repeat("-", len(header)) + "\n")
+		if len(changes) == 0 {
+			res.WriteString("(none)\n")
+		}
+		for _, change := range changes {
+			res.WriteString(fmt.Sprintf("%s %10s  %10s  %10s  %12s\n", fitLeft(getShortProcessName(change.Name), 15),
+				FormatMemorySize(change.BeforeAverage), FormatMemorySize(change.AfterAverage),
+				formatSignedSize(change.Delta()), FormatMemorySize(change.AfterPeak)))
+		}
+	}
+
+	var increases, decreases []ProcessChange
+	for _, change := range c.Changes {
+		if change.Delta() > 0 && (top <= 0 || len(increases) < top) {
+			increases = append(increases, change)
+		}
+	}
+	for i := len(c.Changes) - 1; i >= 0; i-- {
+		if c.Changes[i].Delta() < 0 && (top <= 0 || len(decreases) < top) {
+			decreases = append(decreases, c.Changes[i])
+		}
+	}
+	section("Biggest increases in average memory", increases)
+	section("Biggest decreases in average memory", decreases)
+	return res.String()
+}
+
+// runCompareCommand — подкоманда "compare": что выросло между двумя окнами записи
+func runCompareCommand(args []string) error {
+	flags := newCommandFlags("compare")
+	before := flags.String("before", "", "first time `window`, e.g. 09:00..10:00 or 2026-10-15T09:00..2026-10-15T10:00")
+	after := flags.String("after", "", "second time `window` in the same format")
+	top := flags.Int("top", 10, "number of processes to list in each direction")
+	flags.Parse(args)
+	if flags.NArg() == 0 || *before == "" || *after == "" {
+		return fmt.Errorf("Использование: memory-analyzer compare --before начало..конец --after начало..конец [--top N] file...")
+	}
+	comparison := WindowComparison{}
+	var err error
+	if comparison.BeforeWindow, err = ParseTimeWindow(*before); err != nil {
+		return err
+	}
+	if comparison.AfterWindow, err = ParseTimeWindow(*after); err != nil {
+		return err
+	}
+
+	beforeAcc, afterAcc := newRecordingAccumulator(), newRecordingAccumulator()
+	err = readRecordings(flags.Args(), func(sample Sample) error {
+		if comparison.BeforeWindow.Contains(sample.Time) {
+			beforeAcc.add(sample)
+		}
+		if comparison.AfterWindow.Contains(sample.Time) {
+			afterAcc.add(sample)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	comparison.Before, comparison.After = beforeAcc.result(), afterAcc.result()
+	if comparison.Before.Samples == 0 || comparison.After.Samples == 0 {
+		return fmt.Errorf("Нет замеров в окне %s или %s", *before, *after)
+	}
+	comparison.Changes = compareSummaries(comparison.Before, comparison.After)
+	fmt.Print(FormatWindowComparison(comparison, *top))
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Регистрируется здесь, а не в объявлении subcommands: дополнение само перебирает подкоманды
+	subcommands["completion"] = runCompletionCommand
+}
+
+// completeFiles — ответ --complete, по которому скрипт дополнения предлагает имена файлов
+const completeFiles = "__files__"
+
+// completionScripts — скрипты дополнения для оболочек; %[1]s — имя программы, %[2]s — имя
+// функции. Скрипты передают программе слова командной строки до курсора, а варианты
+// вычисляет подкоманда "completion --complete", поэтому новые флаги и подкоманды
+// дополняются без обновления скриптов
+var completionScripts = map[string]string{
+	"bash": `# bash completion for %[1]s
+_%[2]s() {
+    local IFS=$'\n'
+    local candidates
+    candidates=($(%[1]s completion --complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [ "${candidates[0]}" = "` + completeFiles + `" ]; then
+        COMPREPLY=($(compgen -f -- "${COMP_WORDS[COMP_CWORD]}"))
+    else
+        COMPREPLY=("${candidates[@]}")
+    fi
+}
+complete -o filenames -F _%[2]s %[1]s
+`,
+	"zsh": `#compdef %[1]s
+_%[2]s() {
+    local -a candidates
+    candidates=(${(f)"$(%[1]s completion --complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if [[ "${candidates[1]}" == "` + completeFiles + `" ]]; then
+        _files
+    else
+        compadd -- "${candidates[@]}"
+    fi
+}
+compdef _%[2]s %[1]s
+`,
+	"fish": `# fish completion for %[1]s
+function __%[2]s_complete
+    set -l tokens (commandline -opc)
+    set -l candidates (%[1]s completion --complete -- $tokens[2..-1] (commandline -ct) 2>/dev/null)
+    if test "$candidates[1]" = "` + completeFiles + `"
+        __fish_complete_path (commandline -ct)
+    else
+        printf '%%s\n' $candidates
+    end
+end
+complete -c %[1]s -f -a '(__%[2]s_complete)'
+`,
+}
+
+// runCompletionCommand — подкоманда "completion": выводит скрипт дополнения для bash, zsh
+// или fish, а с флагом --complete — варианты дополнения последнего слова командной строки
+func runCompletionCommand(args []string) error {
+	flags := newCommandFlags("completion")
+	complete := flags.Bool("complete", false, "print completions for the command line words after -- (used by the completion scripts)")
+	flags.Parse(args)
+	if *complete {
+		for _, candidate := range completeWords(flags.Args()) {
+			fmt.Println(candidate)
+		}
+		return nil
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Использование: memory-analyzer completion bash|zsh|fish")
+	}
+	script, ok := completionScripts[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("Неизвестная оболочка %q: поддерживаются bash, zsh и fish", flags.Arg(0))
+	}
+	program := filepath.Base(os.Args[0])
+	fmt.Printf(script, program, strings.NewReplacer("-", "_", ".", "_").Replace(program))
+	return nil
+}
+
+// completeWords возвращает варианты дополнения последнего слова words (слова после имени программы)
+func completeWords(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	// bash делит "--flag=value" на три слова и заменяет только последнее из них, поэтому
+	// варианты значения нужно выводить без "--flag=" (или с "=", если курсор сразу за ним)
+	assignmentPrefix := func(name string) string { return name + "=" }
+	if n := len(words); words[n-1] == "=" {
+		assignmentPrefix = func(string) string { return "=" }
+	} else if n >= 2 && words[n-2] == "=" {
+		assignmentPrefix = func(string) string { return "" }
+	}
+	words = joinAssignments(words)
+	current, previous := words[len(words)-1], words[:len(words)-1]
+
+	// Подкоманда — первое слово, если оно есть в списке; у baseline еще и действие save
+	command := []string{}
+	if len(previous) > 0 {
+		if _, ok := subcommands[previous[0]]; ok {
+			command = append(command, previous[0])
+			if previous[0] == "baseline" {
+				if len(previous) == 1 {
+					return filterPrefix([]string{"save"}, current)
+				}
+				command = append(command, previous[1])
+			}
+		}
+	}
+
+	// --flag=значение
+	if name, value, ok := strings.Cut(current, "="); ok && strings.HasPrefix(name, "-") {
+		if f, ok := findCompletionFlag(command, name); ok && f.Value != "" {
+			values := completeFlagValue(f, value)
+			for i := range values {
+				if values[i] != completeFiles {
+					values[i] = assignmentPrefix(name) + values[i]
+				}
+			}
+			return values
+		}
+		return nil
+	}
+	// Значение флага, указанное отдельным словом
+	if len(previous) > 0 && strings.HasPrefix(previous[len(previous)-1], "-") && !strings.Contains(previous[len(previous)-1], "=") {
+		if f, ok := findCompletionFlag(command, previous[len(previous)-1]); ok && f.Value != "" {
+			return completeFlagValue(f, current)
+		}
+	}
+	if strings.HasPrefix(current, "-") {
+		var names []string
+		for _, f := range completionFlags(command) {
+			names = append(names, "--"+f.Name)
+		}
+		return filterPrefix(names, current)
+	}
+	if len(command) == 0 && len(previous) == 0 {
+		names := make([]string, 0, len(subcommands))
+		for name := range subcommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return filterPrefix(names, current)
+	}
+	return []string{completeFiles}
+}
+
+// joinAssignments склеивает "--flag", "=", "value" обратно в "--flag=value": bash делит
+// слова по знаку "=" (COMP_WORDBREAKS)
+func joinAssignments(words []string) []string {
+	var joined []string
+	for i := 0; i < len(words); i++ {
+		if words[i] == "=" && len(joined) > 0 && strings.HasPrefix(joined[len(joined)-1], "-") {
+			joined[len(joined)-1] += "="
+			if i+1 < len(words) {
+				joined[len(joined)-1] += words[i+1]
+				i++
+			}
+			continue
+		}
+		joined = append(joined, words[i])
+	}
+	return joined
+}
+
+// completeFlagValue возвращает варианты значения флага по имени значения из его описания
+func completeFlagValue(f FlagDoc, prefix string) []string {
+	switch f.Value {
+	case "name":
+		return filterPrefix(runningProcesses(false), prefix)
+	case "pid":
+		return filterPrefix(runningProcesses(true), prefix)
+	case "file", "dir", "path", "socket":
+		return []string{completeFiles}
+	}
+	return nil
+}
+
+// runningProcesses возвращает имена или PID запущенных процессов без повторов
+func runningProcesses(pids bool) []string {
+	reader, err := newLocalReader()
+	if err != nil {
+		return nil
+	}
+	list, err := reader.GetProcessList()
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var values []string
+	for _, pid := range list {
+		value := strconv.Itoa(pid)
+		if !pids {
+			name, err := reader.ReadProcessName(pid)
+			if err != nil || name == "" {
+				continue
+			}
+			value = name
+		}
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	var matched []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			matched = append(matched, value)
+		}
+	}
+	return matched
+}
+
+func findCompletionFlag(command []string, arg string) (FlagDoc, bool) {
+	name := strings.TrimLeft(arg, "-")
+	for _, f := range completionFlags(command) {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FlagDoc{}, false
+}
+
+// completionFlags возвращает флаги программы или подкоманды command
+func completionFlags(command []string) []FlagDoc {
+	flags, err := commandFlags(strings.Join(command, " "))
+	if err != nil {
+		return nil
+	}
+	return flags
+}
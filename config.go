@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileConfig описывает содержимое конфигурационного файла в формате JSON
+type FileConfig struct {
+	//Бюджеты памяти процессов: имя процесса -> размер ("500MB", "8GB")
+	Budgets map[string]string `json:"budgets"`
+
+	//Внешние коллекторы, запускаемые как отдельные процессы
+	Plugins []PluginConfig `json:"plugins"`
+
+	//Дополнительные колонки таблицы процессов
+	Columns []ColumnConfig `json:"columns"`
+
+	//Правила оповещений
+	Alerts []AlertRule `json:"alerts"`
+
+	//Настройки записи замеров в режиме демона
+	Recording RecordingConfig `json:"recording"`
+
+	//Сортировка таблицы по умолчанию, например "name,-memory"; флаг --sort имеет приоритет
+	Sort string `json:"sort"`
+
+	//Источники бизнес-меток процессов
+	Labels LabelsConfig `json:"labels"`
+
+	//Именованные наборы настроек представления, выбираемые флагом --preset или клавишей P
+	Presets map[string]Preset `json:"presets"`
+
+	//Регулярные выражения для имен процессов, скрываемых из таблицы, например "^kworker/"
+	Ignore []string `json:"ignore"`
+	//Скрывать из таблицы сам анализатор и запустившую его оболочку
+	IgnoreSelf bool `json:"ignore_self"`
+	//Показывать под таблицей сводную строку скрытых процессов
+	ShowHidden bool `json:"show_hidden"`
+
+	//Отображение потоков ядра: mark (по умолчанию), hide или group
+	KernelThreads string `json:"kernel_threads"`
+
+	//Цветовая тема панели: dark (по умолчанию), light, monochrome или solarized
+	Theme string `json:"theme"`
+
+	//Периоды обновления панелей; флаги --interval, --process-interval и --detail-interval имеют приоритет
+	Refresh RefreshConfig `json:"refresh"`
+
+	//Регулярные выражения секретов в командных строках, скрываемых в дополнение к встроенным
+	//(--password, токены, пароли в адресах); в шаблоне с группами скрывается первая группа
+	Redact []string `json:"redact"`
+
+	//Защита HTTP-серверов: TLS, сертификаты клиентов, токены и разрешенные подсети
+	Server ServerSecurityConfig `json:"server"`
+
+	//Сервер и шаблоны писем для приемника smtp
+	SMTP SMTPConfig `json:"smtp"`
+
+	//Команды для приемников exec:<имя>, запускаемые при срабатывании и снятии оповещений
+	Exec []ExecNotifierConfig `json:"exec"`
+
+	//Размер истории замеров и уровни прореживания со средними значениями
+	History HistoryConfig `json:"history"`
+
+	//Учетная запись и метки для приемника remote_write
+	RemoteWrite RemoteWriteConfig `json:"remote_write"`
+
+	//Имя хоста и метки экспортируемых данных
+	Identity IdentityConfig `json:"identity"`
+}
+
+// defaultConfigPath возвращает путь к конфигурационному файлу по умолчанию
+// с учетом XDG_CONFIG_HOME
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "memory-analyzer", "config.json")
+}
+
+// defaultStatePath возвращает путь к файлу состояния программы (базовые линии, записи)
+// в каталоге XDG_STATE_HOME
+func defaultStatePath(name string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return name
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "memory-analyzer", name)
+}
+
+// LoadConfig читает конфигурационный файл
+//
+// Отсутствие файла не считается ошибкой: в этом случае возвращается пустая конфигурация
+func LoadConfig(path string) (FileConfig, error) {
+	var config FileConfig
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("Не удалось прочитать %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("Неверный формат %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// ParseBudgets переводит бюджеты процессов из конфигурации в байты
+func (c FileConfig) ParseBudgets() (map[string]uint64, error) {
+	budgets := make(map[string]uint64, len(c.Budgets))
+	for name, sizeStr := range c.Budgets {
+		size, err := parseByteSize(sizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("Неверный бюджет для %s: %q", name, sizeStr)
+		}
+		budgets[name] = size
+	}
+	return budgets, nil
+}
+
+// parseByteSize разбирает размер, заданный пользователем: "512", "500MB", "8G", "1.5 GiB"
+func parseByteSize(sizeStr string) (uint64, error) {
+	sizeStr = strings.ToUpper(strings.TrimSpace(sizeStr))
+	sizeStr = strings.TrimSuffix(sizeStr, "IB")
+	sizeStr = strings.TrimSuffix(sizeStr, "B")
+	sizeStr = strings.ReplaceAll(sizeStr, " ", "")
+	if sizeStr == "" {
+		return 0, fmt.Errorf("Пустой размер")
+	}
+	return parseMemSize(sizeStr)
+}
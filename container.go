@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ContainerReader реализуют источники данных, умеющие определять запуск в контейнере
+type ContainerReader interface {
+	//ReadContainer возвращает сведения о контейнере или nil, если анализатор работает на хосте
+	ReadContainer() (*ContainerInfo, error)
+}
+
+// ContainerInfo — контейнер, в котором запущен анализатор, и память его cgroup
+type ContainerInfo struct {
+	//docker, podman, kubernetes, containerd, lxc, значение переменной container init-процесса
+	//(например systemd-nspawn) или container, если среда не распознана
+	Runtime string `json:"runtime"`
+	Cgroup  string `json:"cgroup,omitempty"`
+	//Лимит памяти cgroup или 0, если он не задан
+	Limit uint64 `json:"limit_bytes,omitempty"`
+	//Потребление памяти cgroup и его часть без неактивного файлового кэша, который ядро
+	//вытеснит прежде, чем сработает лимит
+	Usage      uint64 `json:"usage_bytes"`
+	WorkingSet uint64 `json:"working_set_bytes"`
+	//Лимит и потребление swap (только cgroup v2); SwapLimited равно false, если лимита нет
+	SwapLimited bool   `json:"swap_limited,omitempty"`
+	SwapLimit   uint64 `json:"swap_limit_bytes,omitempty"`
+	SwapUsage   uint64 `json:"swap_usage_bytes,omitempty"`
+	//MemTotal хоста и признак того, что системная память в замере заменена памятью контейнера
+	HostTotal uint64 `json:"host_total_bytes"`
+	Applied   bool   `json:"applied"`
+}
+
+// reportHostMemory отключает замену системной памяти лимитами контейнера (флаг --host-memory)
+var reportHostMemory bool
+
+// containerCgroupMarkers — подстроки пути cgroup, по которым распознается среда контейнера;
+// kubepods проверяется раньше, так как под Kubernetes путь содержит и имя среды выполнения
+var containerCgroupMarkers = []struct{ marker, runtime string }{
+	{"kubepods", "kubernetes"},
+	{"libpod", "podman"},
+	{"docker", "docker"},
+	{"containerd", "containerd"},
+	{"lxc", "lxc"},
+}
+
+// ReadContainer распознает контейнер по файлам /.dockerenv и /run/.containerenv,
+// переменной container init-процесса, пути cgroup и пространству имен cgroup: внутри
+// собственного пространства корневая cgroup имеет лимит memory.max, которого у корня
+// на хосте нет. Снимки procfs (--procfs-snapshot) читаются как данные хоста
+func (l *LinuxMemoryReader) ReadContainer() (*ContainerInfo, error) {
+	if l.Root != "" {
+		return nil, nil
+	}
+	cgroup, _ := l.ReadProcessCgroup(os.Getpid())
+	runtime := detectContainerRuntime(l, cgroup)
+	if runtime == "" {
+		return nil, nil
+	}
+	info := &ContainerInfo{Runtime: runtime, Cgroup: cgroup}
+	info.Limit, _ = l.ReadCgroupMemoryLimit(cgroup)
+	root := l.path(cgroupRoot)
+	dir, v2 := ownCgroupDir(root, cgroup)
+	if dir == "" {
+		return info, nil
+	}
+	usageFile, inactiveKey := "memory.usage_in_bytes", "total_inactive_file"
+	if v2 {
+		usageFile, inactiveKey = "memory.current", "inactive_file"
+	}
+	info.Usage, _ = readCgroupValue(filepath.Join(dir, usageFile))
+	info.WorkingSet = info.Usage
+	if file, err := os.Open(filepath.Join(dir, "memory.stat")); err == nil {
+		stat, _ := parseKeyValues(file)
+		file.Close()
+		info.WorkingSet -= min(stat[inactiveKey], info.Usage)
+	}
+	if v2 {
+		if limit, err := os.ReadFile(filepath.Join(dir, "memory.swap.max")); err == nil && strings.TrimSpace(string(limit)) != "max" {
+			info.SwapLimit, err = strconv.ParseUint(strings.TrimSpace(string(limit)), 10, 64)
+			info.SwapLimited = err == nil
+			info.SwapUsage, _ = readCgroupValue(filepath.Join(dir, "memory.swap.current"))
+		}
+	}
+	return info, nil
+}
+
+// detectContainerRuntime возвращает среду контейнера или пустую строку, если признаков контейнера нет
+func detectContainerRuntime(l *LinuxMemoryReader, cgroup string) string {
+	if _, err := os.Stat(l.path(".dockerenv")); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat(l.path("run", ".containerenv")); err == nil {
+		return "podman"
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	// systemd-nspawn, LXC и podman задают init-процессу переменную container
+	if environ, err := os.ReadFile(l.procPath(1, "environ")); err == nil {
+		for _, variable := range bytes.Split(environ, []byte{0}) {
+			if value, ok := bytes.CutPrefix(variable, []byte("container=")); ok && len(value) > 0 {
+				return string(value)
+			}
+		}
+	}
+	for _, marker := range containerCgroupMarkers {
+		if strings.Contains(cgroup, marker.marker) {
+			return marker.runtime
+		}
+	}
+	if path.Clean("/"+cgroup) == "/" {
+		if _, err := os.Stat(filepath.Join(l.path(cgroupRoot), "memory.max")); err == nil {
+			return "container"
+		}
+	}
+	return ""
+}
+
+// ownCgroupDir возвращает каталог cgroup анализатора в иерархии root и признак cgroup v2.
+// Без собственного пространства имен cgroup путь из /proc/self/cgroup может отсутствовать
+// в смонтированной внутри контейнера иерархии, поэтому берется ближайший существующий родитель
+func ownCgroupDir(root, cgroup string) (string, bool) {
+	for dir := path.Clean("/" + cgroup); ; dir = path.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(root, dir, "memory.current")); err == nil {
+			return filepath.Join(root, dir), true
+		}
+		if _, err := os.Stat(filepath.Join(root, "memory", dir, "memory.usage_in_bytes")); err == nil {
+			return filepath.Join(root, "memory", dir), false
+		}
+		if dir == "/" {
+			return "", false
+		}
+	}
+}
+
+// readCgroupValue читает число из файла cgroup
+func readCgroupValue(file string) (uint64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// collectContainer определяет контейнер, если источник данных это поддерживает
+func collectContainer(reader MemoryReader) *ContainerInfo {
+	containerReader, ok := reader.(ContainerReader)
+	if !ok {
+		return nil
+	}
+	info, err := containerReader.ReadContainer()
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// applyContainerLimits заменяет память хоста памятью контейнера: внутри Docker /proc/meminfo
+// показывает MemTotal хоста, хотя процессы контейнера завершит OOM killer при достижении
+// лимита cgroup. Доступной считается память до лимита без рабочего набора cgroup, но не
+// больше доступной на хосте. Без лимита или с лимитом больше памяти хоста замер не меняется
+func applyContainerLimits(system *SystemMemoryInfo, container *ContainerInfo) {
+	container.HostTotal = system.TotalMemory
+	if reportHostMemory || container.Limit == 0 || container.Limit >= system.TotalMemory {
+		return
+	}
+	limit := container.Limit
+	system.TotalMemory = limit
+	system.AvailableMemory = min(system.AvailableMemory, limit-min(container.WorkingSet, limit))
+	system.FreeMemory = min(system.FreeMemory, limit-min(container.Usage, limit))
+	if container.SwapLimited {
+		system.SwapTotal = min(system.SwapTotal, container.SwapLimit)
+		system.SwapFree = system.SwapTotal - min(container.SwapUsage, system.SwapTotal)
+	}
+	container.Applied = true
+}
+
+// FormatContainer сообщает, что анализатор работает в контейнере и чья память показана
+func FormatContainer(container *ContainerInfo) string {
+	if container == nil {
+		return ""
+	}
+	switch {
+	case container.Applied:
+		return "Container: " + container.Runtime + ", totals are the cgroup limit (host has " + FormatMemorySize(container.HostTotal) + ")\n"
+	case container.Limit == 0:
+		return "Container: " + container.Runtime + ", no memory limit, totals are the host's\n"
+	}
+	return "Container: " + container.Runtime + ", limit " + FormatMemorySize(container.Limit) + ", totals are the host's\n"
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RecordingConfig — настройки записи замеров в режиме демона
+type RecordingConfig struct {
+	//Период замеров вне окон записи ("10s"); по умолчанию используется флаг --interval
+	Interval string `json:"interval"`
+	//Случайное отклонение моментов замеров ("2s" или "10%" периода); по умолчанию флаг --jitter
+	Jitter string `json:"jitter"`
+
+	//Окна записи; если они заданы, замеры записываются только внутри окон
+	Windows []RecordingWindow `json:"windows"`
+
+	//Ротация файла записи при достижении размера ("100MB") или возраста ("24h")
+	MaxSize string `json:"max_size"`
+	MaxAge  string `json:"max_age"`
+
+	//Хранение ротированных файлов: не больше KeepFiles файлов и не старше KeepDays дней;
+	//нулевые значения снимают соответствующее ограничение
+	KeepFiles int `json:"keep_files"`
+	KeepDays  int `json:"keep_days"`
+}
+
+// scheduleCheckInterval — как часто демон вне окон записи проверяет расписание,
+// чтобы переход на летнее время или смена часов не сдвинули начало окна
+const scheduleCheckInterval = time.Minute
+
+// rotationTimeFormat — формат метки времени в именах ротированных файлов
+const rotationTimeFormat = "20060102T150405.000000"
+
+// RotationPolicy задает ротацию и хранение файлов записи
+type RotationPolicy struct {
+	MaxSize   uint64
+	MaxAge    time.Duration
+	KeepFiles int
+	KeepAge   time.Duration
+}
+
+// rotationPolicy разбирает параметры ротации из конфигурации
+func (c RecordingConfig) rotationPolicy() (RotationPolicy, error) {
+	policy := RotationPolicy{KeepFiles: c.KeepFiles, KeepAge: time.Duration(c.KeepDays) * 24 * time.Hour}
+	if c.MaxSize != "" {
+		size, err := parseByteSize(c.MaxSize)
+		if err != nil {
+			return policy, fmt.Errorf("Неверный max_size: %q", c.MaxSize)
+		}
+		policy.MaxSize = size
+	}
+	if c.MaxAge != "" {
+		age, err := time.ParseDuration(c.MaxAge)
+		if err != nil || age <= 0 {
+			return policy, fmt.Errorf("Неверный max_age: %q", c.MaxAge)
+		}
+		policy.MaxAge = age
+	}
+	if c.KeepFiles < 0 || c.KeepDays < 0 {
+		return policy, fmt.Errorf("keep_files и keep_days не могут быть отрицательными")
+	}
+	return policy, nil
+}
+
+// SampleRecorder записывает замеры в файл в формате JSON Lines, по одному замеру в строке,
+// сжимая их, если расширение файла .gz или .zst
+//
+// Когда файл достигает размера или возраста из RotationPolicy, он переименовывается
+// в <имя>-<время>.<расширение>, а запись продолжается в новый файл
+type SampleRecorder struct {
+	path    string
+	policy  RotationPolicy
+	file    *os.File
+	counter *countingWriter
+	w       io.WriteCloser
+	opened  time.Time
+}
+
+// NewSampleRecorder открывает файл записи для дозаписи, создавая каталог при необходимости
+func NewSampleRecorder(path string, policy RotationPolicy) (*SampleRecorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &SampleRecorder{path: path, policy: policy}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *SampleRecorder) open() error {
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Не удалось открыть файл записи %s: %v", r.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	counter := &countingWriter{w: file, count: uint64(info.Size())}
+	w, err := compressWriter(counter, compressionForPath(r.path))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.counter, r.w, r.opened = file, counter, w, time.Now()
+	return nil
+}
+
+func (r *SampleRecorder) Write(sample Sample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if r.needsRotation(uint64(len(data))) {
+		if err := r.rotate(); err != nil {
+			return fmt.Errorf("Не удалось выполнить ротацию %s: %v", r.path, err)
+		}
+	}
+	if _, err := r.w.Write(data); err != nil {
+		return err
+	}
+	// Сбрасываем сжатые данные после каждого замера, чтобы при аварийном завершении
+	// в файле остались все записанные замеры
+	if flusher, ok := r.w.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// needsRotation проверяет ограничения ротации; при сжатии размер учитывается по уже
+// записанным в файл сжатым данным, поэтому файл может немного превысить MaxSize
+func (r *SampleRecorder) needsRotation(next uint64) bool {
+	size := r.counter.count
+	if size == 0 {
+		return false
+	}
+	if compressionForPath(r.path) != CompressionNone {
+		next = 0
+	}
+	if r.policy.MaxSize > 0 && size+next > r.policy.MaxSize {
+		return true
+	}
+	return r.policy.MaxAge > 0 && time.Since(r.opened) >= r.policy.MaxAge
+}
+
+// rotate переименовывает текущий файл, открывает новый и удаляет устаревшие файлы
+func (r *SampleRecorder) rotate() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	ext := recordingExt(r.path)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), time.Now().Format(rotationTimeFormat), ext)
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	return r.prune()
+}
+
+// prune удаляет ротированные файлы сверх KeepFiles и старше KeepAge
+func (r *SampleRecorder) prune() error {
+	rotated, err := rotatedFiles(r.path)
+	if err != nil {
+		return err
+	}
+	for i, path := range rotated {
+		expired := r.policy.KeepFiles > 0 && i < len(rotated)-r.policy.KeepFiles
+		if !expired && r.policy.KeepAge > 0 {
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > r.policy.KeepAge {
+				expired = true
+			}
+		}
+		if expired {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rotatedFiles возвращает ротированные копии файла записи от старых к новым
+func rotatedFiles(path string) ([]string, error) {
+	ext := recordingExt(path)
+	pattern := strings.TrimSuffix(path, ext) + "-*" + ext
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	// Метка времени в имени сортируется лексикографически
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Close завершает поток сжатия и закрывает файл
+func (r *SampleRecorder) Close() error {
+	err := r.w.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// registerPlugins регистрирует внешние коллекторы, источники меток процессов и шаблоны
+// скрытия секретов из конфигурации
+func registerPlugins(fileConfig FileConfig) error {
+	if err := configureLabels(fileConfig.Labels); err != nil {
+		return err
+	}
+	if err := configureRedaction(fileConfig.Redact); err != nil {
+		return err
+	}
+	for _, plugin := range fileConfig.Plugins {
+		c, err := newExecCollector(plugin)
+		if err != nil {
+			return err
+		}
+		RegisterCollector(c)
+	}
+	return nil
+}
+
+// daemonSettings — параметры демона, которые можно изменить без перезапуска,
+// перечитав конфигурационный файл
+type daemonSettings struct {
+	interval time.Duration
+	jitter   Jitter
+	schedule *RecordingSchedule
+	policy   RotationPolicy
+	alerts   *AlertEngine
+}
+
+// loadDaemonSettings читает конфигурационный файл и проверяет параметры демона;
+// период замеров и отклонение берутся из recording.interval и recording.jitter, а если они
+// не заданы — из флагов --interval и --jitter
+func loadDaemonSettings(configPath string, defaultInterval time.Duration, defaultJitter string) (*daemonSettings, FileConfig, error) {
+	fileConfig, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, fileConfig, err
+	}
+	settings := &daemonSettings{interval: defaultInterval}
+	if fileConfig.Recording.Interval != "" {
+		settings.interval, err = time.ParseDuration(fileConfig.Recording.Interval)
+		if err != nil {
+			return nil, fileConfig, fmt.Errorf("Неверный период записи: %q", fileConfig.Recording.Interval)
+		}
+	}
+	jitter := defaultJitter
+	if fileConfig.Recording.Jitter != "" {
+		jitter = fileConfig.Recording.Jitter
+	}
+	if settings.jitter, err = parseJitter(jitter); err != nil {
+		return nil, fileConfig, err
+	}
+	if settings.schedule, err = NewRecordingSchedule(fileConfig.Recording.Windows); err != nil {
+		return nil, fileConfig, err
+	}
+	if settings.schedule.Empty() && settings.interval <= 0 {
+		return nil, fileConfig, fmt.Errorf("Период замеров должен быть положительным: %v", settings.interval)
+	}
+	if settings.policy, err = fileConfig.Recording.rotationPolicy(); err != nil {
+		return nil, fileConfig, err
+	}
+	if settings.alerts, err = NewAlertEngine(fileConfig.Alerts); err != nil {
+		return nil, fileConfig, err
+	}
+	return settings, fileConfig, nil
+}
+
+// runDaemonCommand — подкоманда "daemon": без интерфейса записывает замеры в файл,
+// постоянно или только в окнах записи из конфигурации
+//
+// По SIGHUP или запросу POST /api/reload демон перечитывает конфигурацию (период
+// и окна записи, ротацию, правила оповещений), сохраняя накопленную историю
+func runDaemonCommand(args []string) error {
+	flags := newCommandFlags("daemon")
+	output := flags.String("output", defaultStatePath("recording.jsonl.gz"), "JSON Lines `file` to append samples to (.gz and .zst are compressed)")
+	interval := flags.Duration("interval", 10*time.Second, "sampling `interval` when recording.interval is not set in the config")
+	jitter := flags.String("jitter", "", "randomly shift each sample by up to this `amount` (e.g. 2s or 10%) so many daemons don't sample in lockstep")
+	configPath := flags.String("config", defaultConfigPath(), "path to the JSON configuration `file`")
+	var sinkSpecs stringList
+	flags.Var(&sinkSpecs, "sink", "also send samples to `type:target`, e.g. prometheus:127.0.0.1:9101 (repeatable)")
+	hostname := flags.String("hostname", "", "report this `name` instead of the detected hostname in samples, metrics and notifications")
+	var labels patternList
+	flags.Var(&labels, "label", "attach `key=value` to exported samples, metrics and notifications (repeatable)")
+	listen := flags.String("listen", "", "serve the HTTP API on this `address` (e.g. 127.0.0.1:9100)")
+	churnInterval := flags.Duration("churn-interval", 0, "poll the process list at this `interval` to count short-lived processes (0 disables)")
+	swapThrashRate := flags.String("swap-thrash-rate", defaultSwapThrashRate, "swap in+out `rate` per second that counts as thrashing")
+	swapThrashTicks := flags.Int("swap-thrash-ticks", defaultSwapThrashTicks, "consecutive `samples` above the swap thrash rate before thrashing is reported")
+	flags.BoolVar(&reportHostMemory, "host-memory", false, "inside a container, report the host's total memory instead of the cgroup limit")
+	flags.BoolVar(&fullProcessScan, "full-scan", false, "re-read every process's owner, namespace and cgroup on each refresh instead of only for new or changed processes")
+	flags.BoolVar(&wslQueryHost, "wsl-host", false, "under WSL2, also show Windows memory and the vmmem working set via powershell.exe (every 30s)")
+	statePath := flags.String("state-file", defaultStatePath("daemon-state.json.gz"), "resume history and alerts from this `file`, saving them every minute and on exit (empty disables)")
+	flags.Parse(args)
+
+	settings, fileConfig, err := loadDaemonSettings(*configPath, *interval, *jitter)
+	if err != nil {
+		return err
+	}
+	reader, err := newLocalReader()
+	if err != nil {
+		return err
+	}
+	if err := registerPlugins(fileConfig); err != nil {
+		return err
+	}
+	defer closeCollectors()
+	// При завершении файл записи закрывается с сохранением буферов сжатия, после чего выводится сводка
+	session := newRecordingAccumulator()
+	defer printSessionSummary(session)
+
+	recorder, err := NewSampleRecorder(*output, settings.policy)
+	if err != nil {
+		return err
+	}
+	defer recorder.Close()
+	if err := configureServerSecurity(fileConfig.Server); err != nil {
+		return err
+	}
+	if err := configureIdentity(fileConfig.Identity, *hostname, labels); err != nil {
+		return err
+	}
+	if err := configureSinks(fileConfig); err != nil {
+		return err
+	}
+	sinks, err := NewSinkSet(sinkSpecs)
+	if err != nil {
+		return err
+	}
+	defer sinks.Close()
+	churn, err := startChurnMonitor(*churnInterval)
+	if err != nil {
+		return err
+	}
+	thrash, err := NewSwapThrashDetector(*swapThrashRate, *swapThrashTicks)
+	if err != nil {
+		return err
+	}
+	if churn != nil {
+		defer churn.Stop()
+	}
+
+	var reloadRequests chan chan error
+	var alertRequests chan alertRequest
+	var server *APIServer
+	if *listen != "" {
+		server = NewAPIServer()
+		if err := server.Listen(*listen); err != nil {
+			return err
+		}
+		reloadRequests = server.reload
+		alertRequests = server.alerts
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	var next time.Time
+	reload := func() error {
+		updated, _, err := loadDaemonSettings(*configPath, *interval, *jitter)
+		if err != nil {
+			return err
+		}
+		updated.alerts.Restore(settings.alerts.Active(), settings.alerts.Silences())
+		settings = updated
+		recorder.policy = settings.policy
+		// Новый период вступает в силу сразу, а не после уже запланированного замера
+		// (с начальной задержкой, если задано отклонение)
+		next = time.Time{}
+		return nil
+	}
+
+	report := NewErrorReport(nil)
+	// У демона нет панели ошибок, поэтому стеки перехваченных паник выводятся в stderr
+	panicLogOutput = os.Stderr
+	// Ошибки приемников выводятся в stderr полностью, так как у демона нет панели ошибок
+	sinkReport := NewErrorReport(os.Stderr)
+	history, err := NewHistoryFromConfig(fileConfig.History)
+	if err != nil {
+		return err
+	}
+	lifetimes := NewLifetimeTracker()
+	lifetimes.ExitTime = churn.ExitTime
+	// История и сработавшие оповещения переживают перезапуск демона
+	resumed, err := LoadRuntimeState(*statePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading state, starting afresh: %v\n", err)
+	}
+	history.Restore(resumed.History)
+	history.RestoreTiers(resumed.Tiers)
+	settings.alerts.Restore(resumed.Alerts, resumed.Silences)
+	if server != nil {
+		server.SetHistory(history.Snapshot())
+	}
+	saver := &stateSaver{path: *statePath}
+	saveState := func() {
+		if err := saver.Save(RuntimeState{History: history.Points(), Tiers: history.Tiers(), Alerts: settings.alerts.Active(), Silences: settings.alerts.Silences()}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+	defer saveState()
+	defer func() {
+		if err := history.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}()
+	for {
+		now := time.Now()
+		period, recording := settings.interval, true
+		if !settings.schedule.Empty() {
+			period, recording = settings.schedule.Interval(now)
+		}
+
+		// После запуска, перечитывания конфигурации или начала окна записи первый замер
+		// откладывается на случайное время, чтобы демоны, запущенные одновременно, разошлись
+		if recording && next.IsZero() {
+			next = now.Add(settings.jitter.Splay(period))
+		}
+
+		var wait time.Duration
+		switch {
+		case recording && !now.Before(next):
+			report.BeginTick()
+			sample, err := collectSample(reader, report)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error collecting sample: %v\n", err)
+				if server != nil {
+					server.SetSampleError(err)
+				}
+			} else {
+				sample.Churn = churn.Snapshot(sample.Time)
+				thrash.Observe(sample.Time, sample.Swap)
+				history.Add(sample)
+				if err := history.SpillError(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+				sample.Exited = lifetimes.Observe(sample)
+				sample.Forecast = forecastExhaustion(history, sample.Time)
+				sample.Alerts = settings.alerts.Evaluate(sample, history)
+				if err := recorder.Write(sample); err != nil {
+					return fmt.Errorf("Не удалось записать замер: %v", err)
+				}
+				session.add(sample)
+				sinks.Write(sample, sinkReport)
+				if saver.Due(sample.Time) {
+					saveState()
+				}
+				if server != nil {
+					server.SetSample(sample)
+					server.SetExited(lifetimes.Recent())
+					server.SetHistory(history.Snapshot())
+				}
+			}
+			wait = settings.jitter.Delay(period)
+			next = now.Add(wait)
+		case recording:
+			wait = next.Sub(now)
+		default:
+			// Вне окон записи ждем начала ближайшего окна
+			next = time.Time{}
+			wait = settings.schedule.NextStart(now).Sub(now)
+			if wait > scheduleCheckInterval {
+				wait = scheduleCheckInterval
+			}
+		}
+
+		select {
+		case <-sigChan:
+			return nil
+		case <-hupChan:
+			if err := reload(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reloading config: %v\n", err)
+			}
+		case reply := <-reloadRequests:
+			reply <- reload()
+		case request := <-alertRequests:
+			var reply alertReply
+			if request.command.Action != "" {
+				reply.Rules, reply.err = settings.alerts.Apply(request.command, time.Now())
+			}
+			reply.Alerts, reply.Silences = settings.alerts.Active(), settings.alerts.Silences()
+			request.reply <- reply
+		case <-time.After(wait):
+		}
+	}
+}
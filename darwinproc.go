@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Состояния процесса в p_stat (<sys/proc.h>)
+const (
+	darwinStatusStopped = 4
+	darwinStatusZombie  = 5
+)
+
+// Состояния потока в pth_run_state (<mach/thread_info.h>)
+const (
+	threadStateRunning         = 1
+	threadStateUninterruptible = 4
+)
+
+// darwinProcess — сведения процесса из struct kinfo_proc
+type darwinProcess struct {
+	PPID   int
+	Status uint32
+	UID    uint32
+	//Имя процесса, обрезанное ядром до 16 символов
+	Comm string
+}
+
+// darwinTask — сведения задачи Mach процесса из struct proc_taskinfo
+type darwinTask struct {
+	Resident uint64
+	Threads  int
+	//Число выполняющихся потоков
+	Running int
+}
+
+// cString возвращает строку C из буфера фиксированного размера
+func cString(buf []byte) string {
+	if idx := bytes.IndexByte(buf, 0); idx != -1 {
+		buf = buf[:idx]
+	}
+	return string(buf)
+}
+
+// readProcessMemoryState читает RSS и состояние процесса через proc_pidinfo
+// вместо двух запусков ps. Зомби возвращаются с errNoResidentMemory, как в Linux.
+// Если ядро отказывает (процесс другого пользователя или песочница), они берутся из ps
+func (d *DarwinMemoryReader) readProcessMemoryState(pid int) (memoryState, error) {
+	process, err := procShortInfo(pid)
+	if errors.Is(err, syscall.EPERM) {
+		process, err := readPSFallback(pid)
+		return memoryState{Memory: process.RSS, State: process.State}, err
+	}
+	if err != nil {
+		return memoryState{}, processError(pid, err)
+	}
+	if process.Status == darwinStatusZombie {
+		return memoryState{State: StateZombie}, fmt.Errorf("%w: PID %d — зомби", errNoResidentMemory, pid)
+	}
+	task, err := procTaskInfo(pid)
+	if errors.Is(err, syscall.EPERM) {
+		process, err := readPSFallback(pid)
+		return memoryState{Memory: process.RSS, State: process.State}, err
+	}
+	if err != nil {
+		return memoryState{}, processError(pid, err)
+	}
+	return memoryState{Memory: task.Resident, State: darwinProcessState(pid, process, task)}, nil
+}
+
+// darwinProcessState определяет состояние процесса так же, как ps: остановленные процессы
+// по p_stat, остальные по состояниям потоков — D (U в ps), если хоть один поток
+// в непрерываемом ожидании, R, если хоть один выполняется, иначе S
+func darwinProcessState(pid int, process darwinProcess, task darwinTask) string {
+	if process.Status == darwinStatusStopped {
+		return "T"
+	}
+	states, _ := procThreadStates(pid, task.Threads)
+	running := task.Running > 0
+	for _, state := range states {
+		if state == threadStateUninterruptible {
+			return StateUninterruptible
+		}
+		running = running || state == threadStateRunning
+	}
+	if running {
+		return "R"
+	}
+	return "S"
+}
+
+// psFallbackMaxAge — сколько действует вывод ps в psFallback: этого хватает, чтобы
+// прочитать одну таблицу процессов одним запуском ps
+const psFallbackMaxAge = time.Second
+
+// psProcess — RSS, состояние и имя процесса из вывода ps
+type psProcess struct {
+	RSS   uint64
+	State string
+	Name  string
+}
+
+// psFallback — RSS, состояния и имена процессов, которые ядро без root не отдает (процессы других
+// пользователей). ps установлен с setuid root и видит их, поэтому они берутся из одного
+// запуска ps на все такие процессы, а не из отдельного запуска на каждый
+var psFallback = struct {
+	sync.Mutex
+	taken     time.Time
+	processes map[int]psProcess
+	err       error
+}{}
+
+// readPSFallback возвращает сведения процесса из общего вывода ps
+func readPSFallback(pid int) (psProcess, error) {
+	psFallback.Lock()
+	defer psFallback.Unlock()
+	if time.Since(psFallback.taken) >= psFallbackMaxAge {
+		psFallback.processes, psFallback.err = readPSProcesses()
+		psFallback.taken = time.Now()
+		// Без ps процессы других пользователей пропускаются, а не прерывают весь замер
+		if psFallback.err != nil {
+			markDegraded(fieldOtherUsersProcesses, "ps", psFallback.err)
+		} else {
+			restoreField(fieldOtherUsersProcesses)
+		}
+	}
+	if psFallback.err != nil {
+		return psProcess{}, psFallback.err
+	}
+	process, ok := psFallback.processes[pid]
+	if !ok {
+		return psProcess{}, fmt.Errorf("%w: pid %d", ErrProcessGone, pid)
+	}
+	return process, nil
+}
+
+// readPSProcesses разбирает вывод ps для всех процессов: строки вида
+// "  123  4567 Ss   /usr/sbin/syslogd" с RSS в килобайтах; путь может содержать пробелы
+func readPSProcesses() (map[int]psProcess, error) {
+	output, err := exec.Command(psPath, "-A", "-o", "pid=,rss=,state=,comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+	processes := make(map[int]psProcess)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		rss, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		// В macOS непрерываемое ожидание обозначается буквой U
+		state := fields[2][:1]
+		if state == "U" {
+			state = StateUninterruptible
+		}
+		processes[pid] = psProcess{RSS: rss * 1024, State: state, Name: strings.Join(fields[3:], " ")}
+	}
+	return processes, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyMetrics — метрики, которые можно вычислить по записи истории замеров (в ней
+// хранятся только системная память и память процессов), а значит, и их изменение
+// функциями delta и rate. Метрики процессов process.<имя>.memory_bytes тоже хранятся в истории
+var historyMetrics = map[string]bool{
+	"mem_used_pct":        true,
+	"mem_available_pct":   true,
+	"mem_used_bytes":      true,
+	"mem_available_bytes": true,
+	"swap_used_pct":       true,
+	"swap_used_bytes":     true,
+	"process_count":       true,
+}
+
+// processMetric возвращает суммарную память процессов с именем name по имени метрики
+// вида process.<имя>.memory_bytes; имя сравнивается так же, как в --pin
+func processMetric(metric string) (exprMetric, bool) {
+	rest, ok := strings.CutPrefix(metric, "process.")
+	if !ok {
+		return nil, false
+	}
+	name, ok := strings.CutSuffix(rest, ".memory_bytes")
+	if !ok || name == "" {
+		return nil, false
+	}
+	return func(s Sample) (float64, bool) {
+		var total uint64
+		found := false
+		for _, process := range s.Processes {
+			if matchesProcessName(process.Name, name) {
+				total += process.MemoryUsage
+				found = true
+			}
+		}
+		return float64(total), found
+	}, true
+}
+
+// parseChange разбирает вызов delta(метрика, окно) или rate(метрика, окно) после "("
+//
+// delta — изменение метрики за окно: текущее значение минус значение в последнем замере
+// истории, сделанном не позже чем окно назад; rate — то же изменение, деленное на время
+// между этими замерами, в единицах в секунду. Пока история короче окна, значение неизвестно
+func (p *exprParser) parseChange(function string) (exprOperand, error) {
+	name := p.next()
+	metric, err := lookupMetric(name)
+	if err != nil {
+		return exprOperand{}, err
+	}
+	if _, process := processMetric(name); !historyMetrics[name] && !process {
+		return exprOperand{}, fmt.Errorf("Метрика %s не хранится в истории замеров, %s для нее недоступна", name, function)
+	}
+	if !p.keyword(",") {
+		return exprOperand{}, fmt.Errorf("Ожидалась \",\" и окно после %s(%s", function, name)
+	}
+	token := p.next()
+	window, err := time.ParseDuration(token)
+	if err != nil || window <= 0 {
+		return exprOperand{}, fmt.Errorf("Неверное окно %q в %s(%s, ...)", token, function, name)
+	}
+	if !p.keyword(")") {
+		return exprOperand{}, fmt.Errorf("Ожидалась \")\" после %s(%s, %s", function, name, token)
+	}
+
+	operand := exprOperand{name: fmt.Sprintf("%s(%s, %s)", function, name, token)}
+	operand.value = func(sample Sample, history *History) (float64, bool) {
+		now, ok := metric(sample)
+		if !ok {
+			return 0, false
+		}
+		point, ok := history.At(sample.Time.Add(-window))
+		if !ok {
+			return 0, false
+		}
+		then, ok := metric(Sample{Time: point.Time, System: point.System, Processes: point.Processes})
+		if !ok {
+			return 0, false
+		}
+		change := now - then
+		if function == "rate" {
+			elapsed := sample.Time.Sub(point.Time).Seconds()
+			if elapsed <= 0 {
+				return 0, false
+			}
+			change /= elapsed
+		}
+		return change, true
+	}
+	suffix := ""
+	if function == "rate" {
+		suffix = "/s"
+	}
+	operand.format = func(v float64) string {
+		if strings.HasSuffix(name, "_bytes") {
+			return formatSignedSize(int64(v)) + suffix
+		}
+		return strconv.FormatFloat(v, 'f', 1, 64) + suffix
+	}
+	return operand, nil
+}
+
+// parseExprNumber разбирает число в сравнении: обычное число, размер ("512MB", "-1GB")
+// или скорость — размер или число на длительность ("50MB/min", "1GB/10m"), переводимая
+// в единицы в секунду для сравнения с rate
+func parseExprNumber(token string) (float64, error) {
+	if token == "" {
+		return 0, fmt.Errorf("нет числа")
+	}
+	amount, per, isRate := strings.Cut(token, "/")
+	sign := 1.0
+	if rest, ok := strings.CutPrefix(amount, "-"); ok {
+		amount, sign = rest, -1
+	}
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		size, sizeErr := parseByteSize(amount)
+		if sizeErr != nil {
+			return 0, sizeErr
+		}
+		value = float64(size)
+	}
+	if isRate {
+		// "min" и "sec" допускаются наряду с единицами time.ParseDuration; без числа — одна единица
+		per = strings.TrimSuffix(strings.Replace(per, "min", "m", 1), "ec")
+		if per != "" && strings.IndexAny(per[:1], "0123456789.") < 0 {
+			per = "1" + per
+		}
+		duration, err := time.ParseDuration(per)
+		if err != nil || duration <= 0 {
+			return 0, fmt.Errorf("неверная длительность %q", per)
+		}
+		value /= duration.Seconds()
+	}
+	return sign * value, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// desktopTimeout ограничивает время показа одного всплывающего уведомления
+const desktopTimeout = 10 * time.Second
+
+// desktopUrgencies — уровни важности всплывающих уведомлений (цель приемника desktop)
+// и их значения в спецификации org.freedesktop.Notifications
+var desktopUrgencies = map[string]byte{"low": 0, "normal": 1, "critical": 2}
+
+// desktopNotifier показывает всплывающее уведомление средствами рабочего стола
+type desktopNotifier func(ctx context.Context, title, message, urgency string) error
+
+func init() {
+	RegisterSinkType("desktop", newDesktopSink)
+}
+
+// desktopSink показывает всплывающее уведомление, когда оповещения срабатывают
+// или перестают выполняться, например когда локальный сервер разработки начинает
+// терять память
+type desktopSink struct {
+	urgency string
+	notify  desktopNotifier
+	tracker *alertTracker
+	queue   *notifyQueue
+}
+
+// newDesktopSink создает приемник по цели — уровню важности low, normal или critical
+func newDesktopSink(target string) (Sink, error) {
+	if _, ok := desktopUrgencies[target]; !ok {
+		return nil, fmt.Errorf("Неверный уровень важности %q: поддерживаются low, normal и critical", target)
+	}
+	notify, err := findDesktopNotifier()
+	if err != nil {
+		return nil, err
+	}
+	s := &desktopSink{urgency: target, notify: notify, tracker: newAlertTracker()}
+	s.queue = newNotifyQueue("уведомлений", s.show)
+	return s, nil
+}
+
+func (s *desktopSink) Name() string {
+	return "desktop:" + s.urgency
+}
+
+func (s *desktopSink) Write(sample Sample) error {
+	fired, resolved := s.tracker.Observe(sample.Alerts)
+	if len(fired) > 0 || len(resolved) > 0 {
+		return s.queue.Push(newNotification(sample, fired, resolved, 0))
+	}
+	return s.queue.Err()
+}
+
+// Close дожидается показа уведомлений из очереди
+func (s *desktopSink) Close() error {
+	s.queue.Close()
+	return nil
+}
+
+func (s *desktopSink) show(notification Notification) error {
+	title := "Memory alert: " + notification.Rules()
+	if len(notification.Fired) == 0 {
+		title = "Memory alert resolved: " + notification.Rules()
+	}
+	var lines []string
+	for _, alert := range notification.Fired {
+		lines = append(lines, alert.Message)
+	}
+	lines = append(lines, "Memory "+notification.Memory())
+	ctx, cancel := context.WithTimeout(context.Background(), desktopTimeout)
+	defer cancel()
+	if err := s.notify(ctx, title, strings.Join(lines, "\n"), s.urgency); err != nil {
+		return fmt.Errorf("Не удалось показать уведомление: %v", err)
+	}
+	return nil
+}
+
+// findDesktopNotifier выбирает способ показа уведомлений: на macOS — terminal-notifier
+// или osascript, на Linux — вызов org.freedesktop.Notifications по D-Bus через gdbus
+// или notify-send
+func findDesktopNotifier() (desktopNotifier, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("terminal-notifier"); err == nil {
+			return terminalNotifier, nil
+		}
+		return osascriptNotifier, nil
+	case "linux":
+		if _, err := exec.LookPath("gdbus"); err == nil {
+			return dbusNotifier, nil
+		}
+		if _, err := exec.LookPath("notify-send"); err == nil {
+			return notifySendNotifier, nil
+		}
+		return nil, fmt.Errorf("Для приемника desktop нужна утилита gdbus (glib) или notify-send (libnotify)")
+	}
+	return nil, fmt.Errorf("Приемник desktop не поддерживается на %s", runtime.GOOS)
+}
+
+func terminalNotifier(ctx context.Context, title, message, urgency string) error {
+	args := []string{"-title", "memory-analyzer", "-subtitle", title, "-message", message, "-group", "memory-analyzer"}
+	if urgency == "critical" {
+		args = append(args, "-sound", "Basso")
+	}
+	return runNotifier(ctx, "terminal-notifier", args...)
+}
+
+func osascriptNotifier(ctx context.Context, title, message, urgency string) error {
+	script := fmt.Sprintf("display notification %s with title \"memory-analyzer\" subtitle %s", appleScriptString(message), appleScriptString(title))
+	if urgency == "critical" {
+		script += " sound name \"Basso\""
+	}
+	return runNotifier(ctx, "osascript", "-e", script)
+}
+
+// dbusNotifier вызывает метод Notify сервиса уведомлений сеанса; аргументы gdbus
+// разбирает как значения GVariant
+func dbusNotifier(ctx context.Context, title, message, urgency string) error {
+	return runNotifier(ctx, "gdbus", "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify", "--",
+		gvariantString("memory-analyzer"), "0", gvariantString("dialog-warning"),
+		gvariantString(title), gvariantString(message), "@as []",
+		fmt.Sprintf("{'urgency': <byte %d>}", desktopUrgencies[urgency]), "-1")
+}
+
+func notifySendNotifier(ctx context.Context, title, message, urgency string) error {
+	return runNotifier(ctx, "notify-send", "--app-name", "memory-analyzer", "--icon", "dialog-warning", "--urgency", urgency, title, message)
+}
+
+// runNotifier запускает утилиту и возвращает ее сообщение об ошибке
+func runNotifier(ctx context.Context, name string, args ...string) error {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		if line := firstLine(strings.TrimSpace(string(output))); line != "" {
+			return fmt.Errorf("%s: %v: %s", name, err, line)
+		}
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// gvariantString записывает строку в текстовом формате GVariant
+func gvariantString(s string) string {
+	return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(s) + "\""
+}
+
+// appleScriptString записывает строку в синтаксисе AppleScript
+func appleScriptString(s string) string {
+	return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s) + "\""
+}
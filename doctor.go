@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Результаты проверок doctor
+const (
+	//Предположение выполняется, возможность работает полностью
+	doctorOK = "ok"
+	//Возможность работает с ограничениями или оценками вместо точных данных
+	doctorDegraded = "degraded"
+	//Возможность недоступна на этой машине
+	doctorOff = "off"
+	//Предположение, на котором основаны вычисления, нарушено: данные будут неверны
+	doctorFail = "FAIL"
+)
+
+// DoctorCheck — результат одной проверки
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// doctorBinary — внешняя программа и возможность, которой она нужна
+type doctorBinary struct {
+	name, feature string
+}
+
+// doctorBinaries — внешние программы по системам; отсутствие любой из них отключает
+// только указанную возможность
+var doctorBinaries = map[string][]doctorBinary{
+	"linux": {
+		{"zstd", "recordings compressed as .zst"},
+		{"ssh", "fleet subcommand"},
+		{"adb", "--adb for Android devices"},
+		{"gdbus", "desktop sink (or notify-send)"},
+		{"notify-send", "desktop sink fallback"},
+		{"yad", "tray --format yad"},
+	},
+	"darwin": {
+		{vmStatPath, "fallback for memory and swap activity counters when host_statistics64 is unavailable"},
+		{psPath, "memory of other users' processes without root, process details"},
+		{"footprint", "--app-bundle footprint"},
+		{"lsof", "open files of a process"},
+		{"ioreg", "GPU memory on Apple Silicon"},
+		{"zstd", "recordings compressed as .zst"},
+		{"ssh", "fleet subcommand"},
+		{"terminal-notifier", "desktop sink (or osascript)"},
+		{"osascript", "desktop sink fallback"},
+	},
+}
+
+// runDoctorCommand — подкоманда "doctor": проверяет предположения, на которых основаны
+// вычисления (размер страницы, наличие MemAvailable, smaps_rollup, версия cgroup, внешние
+// программы), и сообщает, какие возможности на этой машине работают полностью, с
+// ограничениями или недоступны
+func runDoctorCommand(args []string) error {
+	flags := newCommandFlags("doctor")
+	asJSON := flags.Bool("json", false, "print the checks and the capability matrix served at /api/capabilities as JSON")
+	flags.Parse(args)
+
+	checks, err := doctorChecks()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, check := range checks {
+		if check.Status == doctorFail {
+			failed++
+		}
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(newCapabilities(checks)); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("memory-analyzer %s on %s/%s\n", readBuildInfo().Version, runtime.GOOS, runtime.GOARCH)
+		for _, check := range checks {
+			fmt.Printf("%-9s %-18s %s\n", check.Status, check.Name, check.Detail)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Нарушенных предположений: %d", failed)
+	}
+	return nil
+}
+
+// doctorChecks выполняет проверки для текущей системы
+func doctorChecks() ([]DoctorCheck, error) {
+	var checks []DoctorCheck
+	switch runtime.GOOS {
+	case "linux":
+		checks = linuxDoctorChecks()
+	case "darwin":
+		checks = darwinDoctorChecks()
+	default:
+		return nil, fmt.Errorf("Unsupported operating system: %s", runtime.GOOS)
+	}
+	for _, binary := range doctorBinaries[runtime.GOOS] {
+		checks = append(checks, checkBinary(binary))
+	}
+	return checks, nil
+}
+
+func checkBinary(binary doctorBinary) DoctorCheck {
+	check := DoctorCheck{Name: binary.name}
+	if path, err := exec.LookPath(binary.name); err == nil {
+		check.Status, check.Detail = doctorOK, path
+	} else {
+		check.Status, check.Detail = doctorOff, "not found: "+binary.feature+" unavailable"
+	}
+	return check
+}
+
+func linuxDoctorChecks() []DoctorCheck {
+	var checks []DoctorCheck
+	add := func(name, status, detail string, args ...interface{}) {
+		checks = append(checks, DoctorCheck{Name: name, Status: status, Detail: fmt.Sprintf(detail, args...)})
+	}
+
+	add("kernel", doctorOK, "%s", readSysfsValue("/proc/sys/kernel/osrelease"))
+
+	memInfo, err := readMemInfoFile("/proc/meminfo")
+	if err != nil {
+		add("meminfo", doctorFail, "%v", err)
+		return checks
+	}
+
+	// Счетчики в страницах (/proc/vmstat, buddyinfo, balloon) переводятся в байты размером
+	// страницы Go, поэтому он должен совпадать с размером страницы ядра
+	pageSize := os.Getpagesize()
+	switch kernelPage := kernelPageSize(); {
+	case kernelPage != 0 && kernelPage != pageSize:
+		add("page size", doctorFail, "runtime reports %s but the kernel maps %s pages; page-based counters will be wrong", FormatMemorySize(uint64(pageSize)), FormatMemorySize(uint64(kernelPage)))
+	case pageSize != 4096:
+		add("page size", doctorOK, "%s pages (%s); page counters are scaled accordingly", FormatMemorySize(uint64(pageSize)), runtime.GOARCH)
+	default:
+		add("page size", doctorOK, "4.00 KB pages")
+	}
+
+	if hugePages := memInfo["HugePages_Total"]; hugePages > 0 {
+		size := memInfo["Hugepagesize"] * 1024
+		add("huge pages", doctorOK, "pool of %d x %s (%d free) is reserved memory outside every process RSS", hugePages, FormatMemorySize(size), memInfo["HugePages_Free"])
+	} else {
+		add("huge pages", doctorOK, "no hugetlbfs pool")
+	}
+	if thp := readSysfsValue("/sys/kernel/mm/transparent_hugepage/enabled"); thp != "" {
+		mode := thp
+		if start, end := strings.Index(thp, "["), strings.Index(thp, "]"); start >= 0 && end > start {
+			mode = thp[start+1 : end]
+		}
+		add("transparent huge", doctorOK, "%s; AnonHugePages %s, counted in process RSS", mode, FormatMemorySize(memInfo["AnonHugePages"]*1024))
+	}
+
+	if _, ok := memInfo["MemAvailable"]; ok {
+		add("MemAvailable", doctorOK, "reported by the kernel")
+	} else {
+		add("MemAvailable", doctorDegraded, "missing (kernel before 3.14); available memory is estimated as MemFree + Buffers + Cached")
+	}
+	if _, err := os.Stat("/proc/self/smaps_rollup"); err == nil {
+		add("smaps_rollup", doctorOK, "PSS is read from smaps_rollup")
+	} else {
+		add("smaps_rollup", doctorDegraded, "missing (kernel before 4.14); reconcile sums the whole smaps, which is slower")
+	}
+
+	switch version := cgroupVersion(); version {
+	case "":
+		add("cgroup", doctorOff, "no memory controller mounted at %s; LIMIT column and cgroup events unavailable", cgroupRoot)
+	default:
+		add("cgroup", doctorOK, "%s", version)
+	}
+	if _, err := os.ReadFile("/proc/pressure/memory"); err == nil {
+		add("pressure (PSI)", doctorOK, "/proc/pressure/memory")
+	} else {
+		add("pressure (PSI)", doctorOff, "unavailable (kernel before 4.20 or psi=0); pressure_* alert metrics never fire")
+	}
+	if stats, err := readVMStat(); err == nil {
+		if _, ok := stats["pswpin"]; ok {
+			add("swap counters", doctorOK, "pswpin/pswpout in /proc/vmstat")
+		} else {
+			add("swap counters", doctorOff, "pswpin missing; swap I/O and thrashing detection unavailable")
+		}
+	}
+
+	if os.Geteuid() == 0 {
+		add("privileges", doctorOK, "root: all processes and their smaps are readable")
+	} else {
+		add("privileges", doctorDegraded, "not root: smaps of other users' processes (reconcile, mapped files, inspect) are unreadable")
+	}
+	if source, err := openProcConnector(); err == nil {
+		source.Close()
+		add("proc connector", doctorOK, "exact process start/exit events for --churn-interval")
+	} else {
+		add("proc connector", doctorDegraded, "%v; churn is counted by polling", err)
+	}
+	add("gpu", doctorOff, "GPU memory is collected only on Apple Silicon")
+	add("ebpf", doctorOff, "not used: process memory is read from /proc")
+
+	reader := &LinuxMemoryReader{}
+	if container, _ := reader.ReadContainer(); container != nil {
+		detail := container.Runtime + ", no memory limit: totals are the host's"
+		if container.Limit > 0 && container.Limit < memInfo["MemTotal"]*1024 {
+			detail = container.Runtime + ", totals are the cgroup limit " + FormatMemorySize(container.Limit) + " (--host-memory shows the host)"
+		}
+		add("container", doctorOK, "%s", detail)
+	}
+	if version := wslVersion(); version != 0 {
+		add("wsl", doctorOK, "WSL%d; Total is the VM limit, see the wsl%d panel block", version, version)
+		if version == 2 {
+			checks = append(checks, checkBinary(doctorBinary{"powershell.exe", "--wsl-host Windows memory"}))
+		}
+	} else if hypervisor := detectHypervisor(); hypervisor != "" {
+		add("virtual machine", doctorOK, "%s; balloon size is shown in the vm block", hypervisor)
+	}
+	return checks
+}
+
+// kernelPageSize возвращает размер страницы ядра по KernelPageSize первого отображения
+// в /proc/self/smaps или 0, если его не удалось прочитать
+func kernelPageSize() int {
+	file, err := os.Open("/proc/self/smaps")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "KernelPageSize:"); ok {
+			kb, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "kB")))
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// cgroupVersion описывает иерархию cgroup с контроллером памяти или возвращает пустую строку
+func cgroupVersion() string {
+	controllers, err := os.ReadFile(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	if err == nil && strings.Contains(" "+string(controllers)+" ", " memory ") {
+		return "v2 (unified), memory controller enabled"
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "memory", "memory.limit_in_bytes")); err == nil {
+		if _, err := os.Stat(filepath.Join(cgroupRoot, "unified")); err == nil {
+			return "hybrid: memory controller on v1"
+		}
+		return "v1, memory controller enabled"
+	}
+	return ""
+}
+
+func darwinDoctorChecks() []DoctorCheck {
+	var checks []DoctorCheck
+	add := func(name, status, detail string, args ...interface{}) {
+		checks = append(checks, DoctorCheck{Name: name, Status: status, Detail: fmt.Sprintf(detail, args...)})
+	}
+	if version, err := sysctlString("kern.osproductversion"); err == nil {
+		add("macOS", doctorOK, "%s", version)
+	}
+
+	// Значения vm_stat переводятся в байты размером страницы из заголовка vm_stat или sysctl
+	// hw.pagesize; os.Getpagesize под Rosetta возвращает размер страницы Intel
+	kernelPage := darwinPageSize()
+	var vmStatPage uint64
+	if output, err := exec.Command(vmStatPath).Output(); err == nil {
+		_, vmStatPage = parseVmStat(string(output))
+	}
+	switch {
+	case vmStatPage != 0 && vmStatPage != kernelPage:
+		add("page size", doctorFail, "vm_stat reports %s pages but hw.pagesize is %s", FormatMemorySize(vmStatPage), FormatMemorySize(kernelPage))
+	case uint64(os.Getpagesize()) != kernelPage:
+		add("page size", doctorOK, "%s kernel pages; the runtime reports %s (Rosetta), vm_stat values use the kernel size", FormatMemorySize(kernelPage), FormatMemorySize(uint64(os.Getpagesize())))
+	default:
+		add("page size", doctorOK, "%s pages", FormatMemorySize(kernelPage))
+	}
+
+	if appleSilicon {
+		add("unified memory", doctorOK, "Apple Silicon: available memory is computed like Activity Monitor")
+		if _, err := readGPUMemory(); err == nil {
+			add("gpu", doctorOK, "GPU memory from ioreg AGXAccelerator")
+		} else {
+			add("gpu", doctorDegraded, "ioreg failed: %v", err)
+		}
+	} else {
+		add("gpu", doctorOff, "GPU memory is collected only on Apple Silicon")
+	}
+	add("ebpf", doctorOff, "not available on macOS")
+	if os.Geteuid() == 0 {
+		add("privileges", doctorOK, "root: memory of all processes is readable")
+	} else {
+		add("privileges", doctorDegraded, "not root: footprint and open files of other users' processes are unreadable")
+	}
+	if hypervisor := detectHypervisor(); hypervisor != "" {
+		add("virtual machine", doctorOK, "%s", hypervisor)
+	}
+	return checks
+}
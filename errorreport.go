@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// maxRecentErrors ограничивает число подробных сообщений, хранимых для панели ошибок
+const maxRecentErrors = 50
+
+type errorKey struct {
+	what   string
+	reason string
+}
+
+// ErrorReport собирает ошибки сбора данных и сворачивает повторяющиеся ошибки
+//
+// Вместо вывода каждой ошибки на экран панель показывает сводку за последнее обновление
+// ("12 processes unreadable (EPERM)"), а подробности доступны в панели ошибок и отладочном журнале.
+// Процессы читаются в несколько потоков, поэтому методы защищены мьютексом
+type ErrorReport struct {
+	mu      sync.Mutex
+	current map[errorKey]int
+	recent  []string
+	debug   *log.Logger
+}
+
+// NewErrorReport создает ErrorReport; если debugLog не nil, каждая ошибка записывается в него полностью
+func NewErrorReport(debugLog io.Writer) *ErrorReport {
+	r := &ErrorReport{current: make(map[errorKey]int)}
+	if debugLog != nil {
+		r.debug = log.New(debugLog, "", log.LstdFlags)
+	}
+	return r
+}
+
+// BeginTick очищает сводку перед новым циклом сбора данных
+func (r *ErrorReport) BeginTick() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = make(map[errorKey]int)
+}
+
+// Add учитывает ошибку; what описывает, что не удалось сделать ("processes unreadable")
+func (r *ErrorReport) Add(what string, err error) {
+	if r == nil || err == nil {
+		return
+	}
+	reason := errorReason(err)
+	if r.debug != nil {
+		r.debug.Printf("%s: %v", what, err)
+	}
+	// Процесс завершился между получением списка и чтением или вовсе не имеет
+	// пользовательской памяти (поток ядра) — это нормально и не требует внимания
+	if reason == "ENOENT" || reason == "ESRCH" || errors.Is(err, ErrProcessGone) || errors.Is(err, errNoResidentMemory) {
+		return
+	}
+	key := errorKey{what: what, reason: reason}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current[key] == 0 {
+		r.recent = append(r.recent, fmt.Sprintf("%s: %v", what, err))
+		if len(r.recent) > maxRecentErrors {
+			r.recent = r.recent[len(r.recent)-maxRecentErrors:]
+		}
+	}
+	r.current[key]++
+}
+
+// Summary возвращает сводку ошибок последнего цикла в одну строку или пустую строку
+func (r *ErrorReport) Summary() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.current) == 0 {
+		return ""
+	}
+	var parts []string
+	for key, count := range r.current {
+		parts = append(parts, fmt.Sprintf("%d %s (%s)", count, key.what, key.reason))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+// Recent возвращает последние подробные сообщения об ошибках, начиная с самых новых
+func (r *ErrorReport) Recent() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recent := make([]string, 0, len(r.recent))
+	for i := len(r.recent) - 1; i >= 0; i-- {
+		recent = append(recent, r.recent[i])
+	}
+	return recent
+}
+
+// errorReason возвращает короткую причину ошибки для группировки одинаковых ошибок
+func errorReason(err error) string {
+	var errno syscall.Errno
+	switch {
+	case errors.Is(err, ErrPermission), errors.Is(err, fs.ErrPermission):
+		return "EPERM"
+	case errors.Is(err, ErrParse):
+		return "bad format"
+	case errors.Is(err, errPanic):
+		return "panic"
+	case errors.Is(err, fs.ErrNotExist):
+		return "ENOENT"
+	case errors.As(err, &errno):
+		switch errno {
+		case syscall.ESRCH:
+			return "ESRCH"
+		case syscall.EACCES, syscall.EPERM:
+			return "EPERM"
+		}
+		return errno.Error()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "command failed"
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return "not found"
+	}
+	return "error"
+}
+
+// FormatErrorsPane форматирует панель с последними ошибками
+func FormatErrorsPane(report *ErrorReport) string {
+	var res strings.Builder
+	res.WriteString("Recent errors:\n")
+	panics := formatPanicRecords()
+	res.WriteString(panics)
+	recent := report.Recent()
+	if len(recent) == 0 && panics == "" {
+		res.WriteString("  none\n")
+	}
+	for i, message := range recent {
+		if i >= 10 {
+			res.WriteString(fmt.Sprintf("  ... and %d more\n", len(recent)-i))
+			break
+		}
+		res.WriteString("  ")
+		res.WriteString(message)
+		res.WriteString("\n")
+	}
+	return res.String()
+}
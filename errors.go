@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os/exec"
+	"syscall"
+)
+
+// Виды ошибок источников данных. Ошибки чтения оборачивают их через %w, поэтому
+// потребители и API различают причины через errors.Is, а не по тексту сообщения
+var (
+	//Нет прав на чтение данных процесса или системы (EPERM, EACCES)
+	ErrPermission = errors.New("Недостаточно прав")
+	//Процесс завершился между получением списка и чтением его данных
+	ErrProcessGone = errors.New("Процесс завершился")
+	//Возможность недоступна в этой операционной системе
+	ErrUnsupportedPlatform = errors.New("Не поддерживается в этой операционной системе")
+	//Данные ядра или вывод системной программы имеют неожиданный формат
+	ErrParse = errors.New("Неверный формат")
+)
+
+// errorCodes — машиночитаемые коды видов ошибок в ответах API
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrPermission, "permission"},
+	{ErrProcessGone, "process_gone"},
+	{ErrUnsupportedPlatform, "unsupported_platform"},
+	{ErrParse, "parse"},
+}
+
+// errorCode возвращает код вида ошибки или пустую строку, если вид не определен
+func errorCode(err error) string {
+	for _, kind := range errorCodes {
+		if errors.Is(err, kind.err) {
+			return kind.code
+		}
+	}
+	return ""
+}
+
+// processError относит ошибку чтения данных процесса pid к одному из видов: отсутствующий
+// каталог /proc/pid или ESRCH означают завершившийся процесс, EPERM и EACCES — нехватку прав.
+// Исходная ошибка остается в цепочке, и errors.Is(err, fs.ErrNotExist) продолжает работать
+func processError(pid int, err error) error {
+	switch {
+	case err == nil, errorCode(err) != "":
+		return err
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ESRCH):
+		return fmt.Errorf("%w: pid %d: %w", ErrProcessGone, pid, err)
+	}
+	return permissionError(err)
+}
+
+// permissionError помечает ошибки EPERM и EACCES видом ErrPermission
+func permissionError(err error) error {
+	if errors.Is(err, fs.ErrPermission) && !errors.Is(err, ErrPermission) {
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	}
+	return err
+}
+
+// commandProcessError относит ошибку ps к видам ошибок: ps завершается с кодом 1 без вывода,
+// если процесса pid уже нет
+func commandProcessError(pid int, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return fmt.Errorf("%w: pid %d", ErrProcessGone, pid)
+	}
+	return err
+}
+
+// writeError отвечает на запрос API ошибкой с текстом и кодом ее вида, если он известен
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := map[string]string{"error": err.Error()}
+	if code := errorCode(err); code != "" {
+		body["code"] = code
+	}
+	writeJSON(w, status, body)
+}
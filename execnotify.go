@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultExecTimeout ограничивает время работы команды уведомления, если в конфигурации не задано иное
+const defaultExecTimeout = 10 * time.Second
+
+// События приемника exec
+const (
+	ExecFiring   = "firing"
+	ExecResolved = "resolved"
+)
+
+// ExecNotifierConfig описывает команду, запускаемую приемником exec:<имя>
+type ExecNotifierConfig struct {
+	Name string `json:"name"`
+	//Команда и аргументы — шаблоны text/template; данные — NotificationEvent.
+	//Команда запускается без оболочки, поэтому подставленные значения не требуют экранирования
+	Command []string `json:"command"`
+	//Ограничение времени работы команды, например "30s" (по умолчанию 10s)
+	Timeout string `json:"timeout"`
+	//Число процессов в выдержке из таблицы, передаваемой на stdin (по умолчанию 10)
+	Top int `json:"top"`
+}
+
+// NotificationEvent — переход одного оповещения, для которого запускается команда.
+// Поля уведомления (.Host, .Memory, .ProcessTable и другие) доступны в шаблонах напрямую
+type NotificationEvent struct {
+	Notification
+	//ExecFiring или ExecResolved
+	Event string
+	Rule  string
+	//Текст и момент срабатывания; для снятого оповещения пусты
+	Message string
+	Since   time.Time
+}
+
+// execNotifier — проверенная команда уведомления
+type execNotifier struct {
+	config  ExecNotifierConfig
+	args    []*template.Template
+	timeout time.Duration
+}
+
+// activeExecNotifiers — команды из конфигурации по именам
+var activeExecNotifiers map[string]*execNotifier
+
+func init() {
+	RegisterSinkType("exec", newExecSink)
+}
+
+// configureExecNotifiers проверяет команды уведомлений из конфигурации и делает их
+// доступными приемникам exec
+func configureExecNotifiers(configs []ExecNotifierConfig) error {
+	activeExecNotifiers = make(map[string]*execNotifier, len(configs))
+	for _, config := range configs {
+		if config.Name == "" {
+			return fmt.Errorf("Не задано имя команды в разделе exec")
+		}
+		if _, ok := activeExecNotifiers[config.Name]; ok {
+			return fmt.Errorf("Команда exec %q задана дважды", config.Name)
+		}
+		if len(config.Command) == 0 {
+			return fmt.Errorf("Не задана команда exec %q", config.Name)
+		}
+		notifier := &execNotifier{config: config, timeout: defaultExecTimeout}
+		if config.Timeout != "" {
+			timeout, err := time.ParseDuration(config.Timeout)
+			if err != nil || timeout <= 0 {
+				return fmt.Errorf("Неверное ограничение времени exec %q: %q", config.Name, config.Timeout)
+			}
+			notifier.timeout = timeout
+		}
+		if notifier.config.Top == 0 {
+			notifier.config.Top = defaultNotifyTop
+		}
+		for i, arg := range config.Command {
+			tmpl, err := template.New(fmt.Sprintf("%s[%d]", config.Name, i)).Parse(arg)
+			if err != nil {
+				return fmt.Errorf("Неверный шаблон в команде exec %q: %v", config.Name, err)
+			}
+			notifier.args = append(notifier.args, tmpl)
+		}
+		activeExecNotifiers[config.Name] = notifier
+	}
+	return nil
+}
+
+// execSink запускает команду из конфигурации для каждого сработавшего и каждого снятого
+// оповещения. Команды выполняются по очереди в отдельной горутине
+type execSink struct {
+	notifier *execNotifier
+	tracker  *alertTracker
+	queue    *notifyQueue
+}
+
+// newExecSink создает приемник по цели — имени команды из раздела exec конфигурации
+func newExecSink(target string) (Sink, error) {
+	notifier, ok := activeExecNotifiers[target]
+	if !ok {
+		return nil, fmt.Errorf("Нет команды %q в разделе \"exec\" конфигурации", target)
+	}
+	s := &execSink{notifier: notifier, tracker: newAlertTracker()}
+	s.queue = newNotifyQueue("команд", s.run)
+	return s, nil
+}
+
+func (s *execSink) Name() string {
+	return "exec:" + s.notifier.config.Name
+}
+
+func (s *execSink) Write(sample Sample) error {
+	fired, resolved := s.tracker.Observe(sample.Alerts)
+	if len(fired) > 0 || len(resolved) > 0 {
+		return s.queue.Push(newNotification(sample, fired, resolved, s.notifier.config.Top))
+	}
+	return s.queue.Err()
+}
+
+// Close дожидается завершения команд из очереди
+func (s *execSink) Close() error {
+	s.queue.Close()
+	return nil
+}
+
+// run запускает команду для каждого перехода в уведомлении; возвращается первая ошибка,
+// но остальные команды все равно запускаются
+func (s *execSink) run(notification Notification) error {
+	var firstErr error
+	for _, event := range notificationEvents(notification) {
+		if err := s.notifier.Run(event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// notificationEvents разбивает уведомление на переходы отдельных оповещений
+func notificationEvents(notification Notification) []NotificationEvent {
+	var events []NotificationEvent
+	for _, alert := range notification.Fired {
+		events = append(events, NotificationEvent{Notification: notification, Event: ExecFiring, Rule: alert.Rule, Message: alert.Message, Since: alert.Since})
+	}
+	for _, rule := range notification.Resolved {
+		events = append(events, NotificationEvent{Notification: notification, Event: ExecResolved, Rule: rule})
+	}
+	return events
+}
+
+// Run подставляет данные перехода в аргументы и запускает команду. Переход описывается
+// также переменными окружения MEMORY_ANALYZER_*, а выдержка из таблицы процессов
+// передается на stdin
+func (n *execNotifier) Run(event NotificationEvent) error {
+	args := make([]string, len(n.args))
+	for i, tmpl := range n.args {
+		var arg bytes.Buffer
+		if err := tmpl.Execute(&arg, event); err != nil {
+			return fmt.Errorf("Ошибка шаблона команды exec %q: %v", n.config.Name, err)
+		}
+		args[i] = arg.String()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), execEnvironment(event)...)
+	cmd.Stdin = strings.NewReader(event.ProcessTable())
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Команда exec %q не завершилась за %s", n.config.Name, n.timeout)
+	}
+	if err != nil {
+		if line := firstLine(strings.TrimSpace(string(output))); line != "" {
+			return fmt.Errorf("Команда exec %q: %v: %s", n.config.Name, err, line)
+		}
+		return fmt.Errorf("Команда exec %q: %v", n.config.Name, err)
+	}
+	return nil
+}
+
+// execEnvironment описывает переход переменными окружения для команды
+func execEnvironment(event NotificationEvent) []string {
+	system := event.System
+	env := []string{
+		"MEMORY_ANALYZER_EVENT=" + event.Event,
+		"MEMORY_ANALYZER_RULE=" + event.Rule,
+		"MEMORY_ANALYZER_MESSAGE=" + event.Message,
+		"MEMORY_ANALYZER_HOST=" + event.Host,
+		"MEMORY_ANALYZER_TIME=" + event.Time.Format(time.RFC3339),
+		"MEMORY_ANALYZER_MEMORY=" + event.Memory(),
+		"MEMORY_ANALYZER_MEMORY_TOTAL_BYTES=" + strconv.FormatUint(system.TotalMemory, 10),
+		"MEMORY_ANALYZER_MEMORY_AVAILABLE_BYTES=" + strconv.FormatUint(system.AvailableMemory, 10),
+	}
+	if !event.Since.IsZero() {
+		env = append(env, "MEMORY_ANALYZER_SINCE="+event.Since.Format(time.RFC3339))
+	}
+	if len(event.Labels) > 0 {
+		env = append(env, "MEMORY_ANALYZER_LABELS="+formatIdentityLabels(event.Labels))
+	}
+	return env
+}
+
+// firstLine возвращает первую строку текста
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
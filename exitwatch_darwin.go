@@ -0,0 +1,67 @@
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// exitWatchTimeout — наибольшее время ожидания событий kqueue, после которого
+// проверяется, не закрыт ли наблюдатель
+const exitWatchTimeout = time.Second
+
+// ExitWatcher сообщает о завершении наблюдаемых процессов через kqueue (EVFILT_PROC, NOTE_EXIT)
+// без постоянного опроса ps
+type ExitWatcher struct {
+	kq   int
+	stop chan struct{}
+}
+
+func NewExitWatcher() (*ExitWatcher, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(kq)
+	return &ExitWatcher{kq: kq, stop: make(chan struct{})}, nil
+}
+
+// Watch подписывается на завершение процесса; для уже завершившегося процесса возвращает ESRCH
+func (w *ExitWatcher) Watch(pid int) error {
+	var event syscall.Kevent_t
+	syscall.SetKevent(&event, pid, syscall.EVFILT_PROC, syscall.EV_ADD|syscall.EV_ONESHOT)
+	event.Fflags = syscall.NOTE_EXIT
+	_, err := syscall.Kevent(w.kq, []syscall.Kevent_t{event}, nil, nil)
+	return err
+}
+
+// Run передает exited PID завершившихся процессов до вызова Close
+func (w *ExitWatcher) Run(exited func(pid int)) {
+	events := make([]syscall.Kevent_t, 64)
+	timeout := syscall.NsecToTimespec(int64(exitWatchTimeout))
+	for {
+		select {
+		case <-w.stop:
+			syscall.Close(w.kq)
+			return
+		default:
+		}
+		n, err := syscall.Kevent(w.kq, nil, events, &timeout)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		for _, event := range events[:n] {
+			if event.Filter == syscall.EVFILT_PROC && event.Fflags&syscall.NOTE_EXIT != 0 {
+				exited(int(event.Ident))
+			}
+		}
+	}
+}
+
+// Close останавливает Run; очередь kqueue закрывается после выхода из ожидания
+func (w *ExitWatcher) Close() error {
+	close(w.stop)
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+// ExitWatcher в Linux не используется: точные события завершения дает proc connector
+type ExitWatcher struct{}
+
+func NewExitWatcher() (*ExitWatcher, error) {
+	return nil, fmt.Errorf("%w: уведомления kqueue о завершении процессов доступны только в macOS", ErrUnsupportedPlatform)
+}
+
+func (w *ExitWatcher) Watch(pid int) error {
+	return fmt.Errorf("%w: уведомления kqueue о завершении процессов доступны только в macOS", ErrUnsupportedPlatform)
+}
+
+func (w *ExitWatcher) Run(exited func(pid int)) {}
+
+func (w *ExitWatcher) Close() error {
+	return nil
+}
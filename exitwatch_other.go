@@ -0,0 +1,23 @@
+//go:build !darwin && !linux
+
+package main
+
+import "fmt"
+
+// ExitWatcher на других системах не используется: завершения процессов обнаруживаются
+// опросом списка процессов
+type ExitWatcher struct{}
+
+func NewExitWatcher() (*ExitWatcher, error) {
+	return nil, fmt.Errorf("%w: уведомления kqueue о завершении процессов доступны только в macOS", ErrUnsupportedPlatform)
+}
+
+func (w *ExitWatcher) Watch(pid int) error {
+	return fmt.Errorf("%w: уведомления kqueue о завершении процессов доступны только в macOS", ErrUnsupportedPlatform)
+}
+
+func (w *ExitWatcher) Run(exited func(pid int)) {}
+
+func (w *ExitWatcher) Close() error {
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// explainMinPercent — потребители памяти меньше этой доли от всей памяти не упоминаются
+const explainMinPercent = 1.0
+
+// explainTopProcesses — сколько крупнейших процессов называется в описании
+const explainTopProcesses = 3
+
+// MemorySink — крупный потребитель памяти с объяснением и советом
+type MemorySink struct {
+	Title      string
+	Size       uint64
+	Detail     string
+	Suggestion string
+	//Память освобождается сама или не занимает RAM (page cache, swap); такие потребители
+	//перечисляются после тех, из которых складывается занятая память
+	Harmless bool
+}
+
+// explainMemory составляет список крупнейших потребителей памяти по убыванию размера:
+// сначала составляющие занятой памяти, затем page cache и swap
+func explainMemory(stats map[string]uint64, r Reconciliation, tmpfs, ballooning []TmpfsUsage) []MemorySink {
+	kb := func(key string) uint64 {
+		return stats[key] * 1024
+	}
+	var sinks []MemorySink
+
+	var names []string
+	for i, process := range r.Top {
+		if i == explainTopProcesses {
+			break
+		}
+		names = append(names, fmt.Sprintf("%s %s", process.Name, FormatMemorySize(process.MemoryUsage)))
+	}
+	sinks = append(sinks, MemorySink{
+		Title:      fmt.Sprintf("Processes (PSS of %d processes)", r.Processes),
+		Size:       r.PSS,
+		Detail:     "Largest: " + strings.Join(names, ", "),
+		Suggestion: "watch the largest processes for growth with the dashboard or \"report\"; restart or limit leaking services",
+	})
+
+	// Раздувшиеся tmpfs выделяются в отдельные пункты, а их файлы вычитаются из разделяемой памяти
+	shmem := kb("Shmem")
+	listed := make(map[string]bool)
+	for _, mount := range ballooning {
+		listed[mount.Mount] = true
+		var entries []string
+		for _, entry := range largestEntries(mount.Mount, tmpfsTopEntries) {
+			entries = append(entries, fmt.Sprintf("%s %s", entry.Mount, FormatMemorySize(entry.Used)))
+		}
+		detail := fmt.Sprintf("Files in this %s are kept in RAM (or swap) until deleted", mount.FSType)
+		if mount.Size > 0 {
+			detail += fmt.Sprintf("; %.0f%% of its %s size is used", percentOf(mount.Used, mount.Size), FormatMemorySize(mount.Size))
+		}
+		if len(entries) > 0 {
+			detail += ". Largest: " + strings.Join(entries, ", ")
+		}
+		sinks = append(sinks, MemorySink{
+			Title:      fmt.Sprintf("%s %s", mount.FSType, mount.Mount),
+			Size:       mount.Used,
+			Detail:     detail,
+			Suggestion: fmt.Sprintf("delete build artifacts and stale files in %s, or lower its size= mount option", mount.Mount),
+		})
+		if mount.FSType == "tmpfs" && shmem >= mount.Used {
+			shmem -= mount.Used
+		}
+	}
+
+	if shmem > 0 {
+		var mounts []string
+		for _, usage := range tmpfs {
+			if listed[usage.Mount] || usage.FSType != "tmpfs" {
+				continue
+			}
+			mounts = append(mounts, fmt.Sprintf("%s %s", usage.Mount, FormatMemorySize(usage.Used)))
+		}
+		detail := "Shared memory segments and tmpfs files; they cannot be reclaimed without swap"
+		if len(mounts) > 0 {
+			detail += ". tmpfs: " + strings.Join(mounts, ", ")
+		}
+		sinks = append(sinks, MemorySink{
+			Title:      "Shared memory and tmpfs",
+			Size:       shmem,
+			Detail:     detail,
+			Suggestion: "delete stale files in /dev/shm, /tmp and /run; check \"ipcs -m\" for orphaned segments",
+		})
+	}
+
+	// Cached включает Shmem, который уже учтен выше
+	if cache := kb("Cached") + kb("Buffers") - kb("Shmem"); kb("Cached")+kb("Buffers") > kb("Shmem") {
+		sinks = append(sinks, MemorySink{
+			Title:      "Page cache",
+			Size:       cache,
+			Detail:     "File data cached by the kernel; it is counted as available and is freed automatically when programs need memory",
+			Suggestion: "nothing to do: a large cache is normal and speeds up file access",
+			Harmless:   true,
+		})
+	}
+
+	if slab := kb("SReclaimable") + kb("SUnreclaim"); slab > 0 {
+		sinks = append(sinks, MemorySink{
+			Title:      "Kernel slab",
+			Size:       slab,
+			Detail:     fmt.Sprintf("%s reclaimable (dentry and inode caches), %s unreclaimable", FormatMemorySize(kb("SReclaimable")), FormatMemorySize(kb("SUnreclaim"))),
+			Suggestion: "if the unreclaimable part keeps growing, look for a kernel or driver leak with \"slabtop\"",
+		})
+	}
+
+	if r.HugePages > 0 {
+		sinks = append(sinks, MemorySink{
+			Title:      "Huge pages pool",
+			Size:       r.HugePages,
+			Detail:     fmt.Sprintf("%d of %d huge pages are free; the pool is reserved even when unused", stats["HugePages_Free"], stats["HugePages_Total"]),
+			Suggestion: "lower vm.nr_hugepages if most of the pool stays free",
+		})
+	}
+
+	if kernel := r.PageTables + r.KernelStack; kernel > 0 {
+		sinks = append(sinks, MemorySink{
+			Title:      "Page tables and kernel stacks",
+			Size:       kernel,
+			Detail:     fmt.Sprintf("Page tables %s, kernel stacks %s", FormatMemorySize(r.PageTables), FormatMemorySize(r.KernelStack)),
+			Suggestion: "large page tables come from many processes with big address spaces; huge pages reduce them",
+		})
+	}
+
+	// Файлы tmpfs обычно не отображены в память процессов и уже названы выше
+	residual := r.Residual()
+	for _, mount := range tmpfs {
+		residual -= int64(mount.Used)
+	}
+	if residual > 0 {
+		suggestion := "check driver and GPU memory, vmalloc usage (/proc/vmallocinfo) and unmapped tmpfs files"
+		if r.Unreadable > 0 {
+			suggestion = fmt.Sprintf("run as root: %d processes could not be read and their memory is part of this", r.Unreadable)
+		}
+		sinks = append(sinks, MemorySink{
+			Title:      "Unexplained",
+			Size:       uint64(residual),
+			Detail:     "Used memory not attributed to processes or the kernel structures above",
+			Suggestion: suggestion,
+		})
+	}
+
+	if swap := kb("SwapTotal") - kb("SwapFree"); kb("SwapTotal") > kb("SwapFree") {
+		sinks = append(sinks, MemorySink{
+			Title:      "Swap",
+			Size:       swap,
+			Detail:     "Memory of idle processes moved to disk; it is not part of used RAM",
+			Suggestion: "swap use is fine unless the system keeps swapping in and out (watch si/so in vmstat)",
+			Harmless:   true,
+		})
+	}
+
+	sort.SliceStable(sinks, func(i, j int) bool {
+		if sinks[i].Harmless != sinks[j].Harmless {
+			return !sinks[i].Harmless
+		}
+		return sinks[i].Size > sinks[j].Size
+	})
+	return sinks
+}
+
+// FormatExplanation форматирует список потребителей памяти с объяснениями и советами,
+// пропуская незначительные
+func FormatExplanation(system SystemMemoryInfo, sinks []MemorySink) string {
+	var res strings.Builder
+	used := system.TotalMemory - system.AvailableMemory
+	res.WriteString(fmt.Sprintf("Where did the memory go: %s of %s used (%.1f%%), %s available\n\n",
+		FormatMemorySize(used), FormatMemorySize(system.TotalMemory), percentOf(used, system.TotalMemory), FormatMemorySize(system.AvailableMemory)))
+	n := 0
+	for _, sink := range sinks {
+		percent := percentOf(sink.Size, system.TotalMemory)
+		if percent < explainMinPercent {
+			continue
+		}
+		n++
+		res.WriteString(fmt.Sprintf("%d. %s: %s (%.1f%% of RAM)\n", n, sink.Title, FormatMemorySize(sink.Size), percent))
+		res.WriteString(fmt.Sprintf("   %s\n", sink.Detail))
+		res.WriteString(fmt.Sprintf("   Suggestion: %s\n", sink.Suggestion))
+	}
+	if n == 0 {
+		res.WriteString("No significant memory consumers found\n")
+	}
+	return res.String()
+}
+
+// runExplainCommand — подкоманда "explain": разовая диагностика "куда делась память"
+// со списком крупнейших потребителей по убыванию и советами (Linux)
+func runExplainCommand(args []string) error {
+	flags := newCommandFlags("explain")
+	thresholdFlag := flags.String("tmpfs-threshold", defaultTmpfsThreshold, "report tmpfs and ramfs mounts using more than this `size` or percent of RAM separately")
+	flags.Parse(args)
+	threshold, err := parseSizeThreshold(*thresholdFlag)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("%w: диагностика памяти поддерживается только в Linux", ErrUnsupportedPlatform)
+	}
+	stats, err := readMemInfo()
+	if err != nil {
+		return err
+	}
+	r, err := reconcileMemory(stats, explainTopProcesses)
+	if err != nil {
+		return err
+	}
+	system, err := systemMemoryFromMemInfo(stats)
+	if err != nil {
+		return err
+	}
+	tmpfs, _ := readTmpfsUsage()
+	ballooning := ballooningTmpfs(tmpfs, threshold, system.TotalMemory)
+	fmt.Print(FormatExplanation(system, explainMemory(stats, r, tmpfs, ballooning)))
+	return nil
+}
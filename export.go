@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Поддерживаемые форматы экспорта таблицы процессов
+const (
+	ExportText = "text"
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+func isValidExportFormat(format string) bool {
+	switch format {
+	case ExportText, ExportCSV, ExportJSON:
+		return true
+	}
+	return false
+}
+
+// ExportProcesses записывает таблицу процессов в w в указанном формате
+//
+// Экспортируется ровно тот набор строк, который отображается на экране,
+// поэтому вызывающая сторона передает уже отсортированный и отфильтрованный слайс
+func ExportProcesses(w io.Writer, processes []ProcessInfo, format string) error {
+	switch format {
+	case ExportText:
+		_, err := io.WriteString(w, FormatTable(processes))
+		return err
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		header := []string{"pid", "name", "memory_bytes"}
+		var withBudget, withIO, withState, withNice, withLimit, withQoS, withBaseline bool
+		for _, process := range processes {
+			withBudget = withBudget || process.Budget > 0
+			withIO = withIO || process.IO != nil
+			withState = withState || process.State != ""
+			withNice = withNice || process.Nice != nil
+			withLimit = withLimit || process.CgroupLimit > 0
+			withQoS = withQoS || process.QoS != ""
+			withBaseline = withBaseline || process.Baseline != nil
+		}
+		if withBudget {
+			header = append(header, "budget_bytes")
+		}
+		if withIO {
+			header = append(header, "read_bytes", "write_bytes", "read_rate", "write_rate")
+		}
+		if withState {
+			header = append(header, "state")
+		}
+		if withNice {
+			header = append(header, "nice")
+		}
+		if withLimit {
+			header = append(header, "cgroup_limit_bytes")
+		}
+		if withQoS {
+			header = append(header, "qos")
+		}
+		if withBaseline {
+			header = append(header, "baseline_bytes")
+		}
+		if len(processes) > 0 {
+			for _, extra := range processes[0].Extra {
+				header = append(header, extra.Name)
+			}
+		}
+		labelKeys := processLabelKeys(processes)
+		for _, key := range labelKeys {
+			header = append(header, "label_"+key)
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, process := range processes {
+			record := []string{
+				strconv.Itoa(process.PID),
+				process.Name,
+				strconv.FormatUint(process.MemoryUsage, 10),
+			}
+			if withBudget {
+				// Процессы без бюджета оставляют колонку пустой, а не 0
+				budget := ""
+				if process.Budget > 0 {
+					budget = strconv.FormatUint(process.Budget, 10)
+				}
+				record = append(record, budget)
+			}
+			if withIO {
+				stats := process.IO
+				if stats == nil {
+					stats = &ProcessIO{}
+				}
+				record = append(record,
+					strconv.FormatUint(stats.ReadBytes, 10),
+					strconv.FormatUint(stats.WriteBytes, 10),
+					strconv.FormatFloat(stats.ReadRate, 'f', 0, 64),
+					strconv.FormatFloat(stats.WriteRate, 'f', 0, 64),
+				)
+			}
+			if withState {
+				record = append(record, process.State)
+			}
+			if withNice {
+				nice := ""
+				if process.Nice != nil {
+					nice = strconv.Itoa(*process.Nice)
+				}
+				record = append(record, nice)
+			}
+			if withLimit {
+				limit := ""
+				if process.CgroupLimit > 0 {
+					limit = strconv.FormatUint(process.CgroupLimit, 10)
+				}
+				record = append(record, limit)
+			}
+			if withQoS {
+				record = append(record, process.QoS)
+			}
+			if withBaseline {
+				// Как и в JSON, 0 означает, что процесса не было в базовой линии
+				baseline := ""
+				if process.Baseline != nil {
+					baseline = strconv.FormatUint(*process.Baseline, 10)
+				}
+				record = append(record, baseline)
+			}
+			for _, extra := range process.Extra {
+				record = append(record, extra.Value)
+			}
+			for _, key := range labelKeys {
+				record = append(record, process.Labels[key])
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case ExportJSON:
+		if processes == nil {
+			processes = []ProcessInfo{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(processes)
+	}
+	return fmt.Errorf("Неизвестный формат экспорта: %s", format)
+}
+
+// exportToPath записывает таблицу в файл path, либо в stdout, если path равен "-"
+func exportToPath(path string, processes []ProcessInfo, format string) error {
+	if path == "-" {
+		return ExportProcesses(os.Stdout, processes, format)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Не удалось создать файл экспорта: %v", err)
+	}
+	if err := ExportProcesses(file, processes, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// exportFileName формирует имя файла для экспорта по горячей клавише
+func exportFileName(format string, t time.Time) string {
+	ext := "txt"
+	if format != ExportText {
+		ext = format
+	}
+	return fmt.Sprintf("memory-analyzer-%s.%s", t.Format("20060102-150405"), ext)
+}
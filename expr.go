@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlertExpr срабатывает по выражению над метриками замера, например
+// "swap_used_pct > 30 AND pressure_avg10 > 5 for 2m"; выражение задается полем "expr" правила
+const AlertExpr = "expr"
+
+func init() {
+	alertTypes[AlertExpr] = newExprCondition
+}
+
+// exprMetric возвращает значение метрики в замере и признак того, что оно известно
+type exprMetric func(sample Sample) (float64, bool)
+
+// exprMetrics — метрики, доступные в выражениях оповещений. Кроме них доступны метрики
+// коллекторов в виде collector.<коллектор>.<метрика>
+var exprMetrics = map[string]exprMetric{
+	"mem_used_pct": func(s Sample) (float64, bool) {
+		return knownPercent(s.System.TotalMemory-s.System.AvailableMemory, s.System.TotalMemory)
+	},
+	"mem_available_pct": func(s Sample) (float64, bool) {
+		return knownPercent(s.System.AvailableMemory, s.System.TotalMemory)
+	},
+	"mem_available_bytes": func(s Sample) (float64, bool) { return float64(s.System.AvailableMemory), true },
+	"mem_used_bytes": func(s Sample) (float64, bool) {
+		return float64(s.System.TotalMemory - s.System.AvailableMemory), true
+	},
+	"swap_used_pct": func(s Sample) (float64, bool) {
+		return knownPercent(s.System.SwapTotal-s.System.SwapFree, s.System.SwapTotal)
+	},
+	"swap_used_bytes": func(s Sample) (float64, bool) { return float64(s.System.SwapTotal - s.System.SwapFree), true },
+	"swap_in_rate": func(s Sample) (float64, bool) {
+		if s.Swap == nil {
+			return 0, false
+		}
+		return s.Swap.InRate, true
+	},
+	"swap_out_rate": func(s Sample) (float64, bool) {
+		if s.Swap == nil {
+			return 0, false
+		}
+		return s.Swap.OutRate, true
+	},
+	"commit_pct": func(s Sample) (float64, bool) {
+		if s.VM == nil || s.VM.CommitLimit == 0 {
+			return 0, false
+		}
+		return s.VM.CommitPercent(), true
+	},
+	"oom_eta_seconds": func(s Sample) (float64, bool) {
+		if s.Forecast == nil {
+			return 0, false
+		}
+		return s.Forecast.ETASeconds, true
+	},
+	"process_count":        func(s Sample) (float64, bool) { return float64(len(s.Processes)), true },
+	"pressure_avg10":       pressureMetric("some", "avg10"),
+	"pressure_avg60":       pressureMetric("some", "avg60"),
+	"pressure_avg300":      pressureMetric("some", "avg300"),
+	"pressure_full_avg10":  pressureMetric("full", "avg10"),
+	"pressure_full_avg60":  pressureMetric("full", "avg60"),
+	"pressure_full_avg300": pressureMetric("full", "avg300"),
+}
+
+// knownPercent — percentOf для метрик: без общего объема значение неизвестно
+func knownPercent(part, total uint64) (float64, bool) {
+	return percentOf(part, total), total != 0
+}
+
+// pressureMetric читает среднюю долю времени (в процентах), в течение которой задачи ждали
+// память, из /proc/pressure/memory (Linux 4.20+ с включенным PSI): kind — some или full,
+// window — avg10, avg60 или avg300
+func pressureMetric(kind, window string) exprMetric {
+	return func(Sample) (float64, bool) {
+		file, err := os.Open("/proc/pressure/memory")
+		if err != nil {
+			return 0, false
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || fields[0] != kind {
+				continue
+			}
+			for _, field := range fields[1:] {
+				if name, value, ok := strings.Cut(field, "="); ok && name == window {
+					v, err := strconv.ParseFloat(value, 64)
+					return v, err == nil
+				}
+			}
+		}
+		return 0, false
+	}
+}
+
+// collectorMetric возвращает метрику коллектора по имени вида collector.<коллектор>.<метрика>
+func collectorMetric(name string) (exprMetric, bool) {
+	rest, ok := strings.CutPrefix(name, "collector.")
+	if !ok {
+		return nil, false
+	}
+	collector, metric, ok := strings.Cut(rest, ".")
+	if !ok || collector == "" || metric == "" {
+		return nil, false
+	}
+	return func(s Sample) (float64, bool) {
+		for _, result := range s.Collectors {
+			if result.Name != collector {
+				continue
+			}
+			for _, m := range result.Metrics {
+				if m.Name == metric {
+					return m.Value, true
+				}
+			}
+		}
+		return 0, false
+	}, true
+}
+
+// lookupMetric находит метрику по имени среди встроенных, метрик процессов и коллекторов
+func lookupMetric(name string) (exprMetric, error) {
+	if metric, ok := exprMetrics[name]; ok {
+		return metric, nil
+	}
+	if metric, ok := processMetric(name); ok {
+		return metric, nil
+	}
+	if metric, ok := collectorMetric(name); ok {
+		return metric, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("Выражение оборвано: ожидалась метрика")
+	}
+	return nil, fmt.Errorf("Неизвестная метрика %q, доступны: %s, process.<имя>.memory_bytes и collector.<имя>.<метрика>", name, strings.Join(exprMetricNames(), ", "))
+}
+
+// exprOperand — левая часть сравнения: значение метрики в замере или ее изменение
+// по истории замеров (delta, rate)
+type exprOperand struct {
+	name   string
+	value  func(sample Sample, history *History) (float64, bool)
+	format func(v float64) string
+}
+
+// metricOperand — значение метрики в текущем замере
+func metricOperand(name string, metric exprMetric) exprOperand {
+	return exprOperand{
+		name:   name,
+		value:  func(sample Sample, _ *History) (float64, bool) { return metric(sample) },
+		format: func(v float64) string { return formatExprValue(name, v) },
+	}
+}
+
+// exprNode — узел разобранного выражения. eval вызывается на каждом замере для всех узлов,
+// без сокращенного вычисления, чтобы условия с "for" непрерывно отслеживали свое состояние
+type exprNode interface {
+	eval(sample Sample, history *History) bool
+}
+
+// compareNode сравнивает операнд с числом; неизвестное значение дает ложь
+type compareNode struct {
+	operand exprOperand
+	op      string
+	value   float64
+}
+
+func (n *compareNode) eval(sample Sample, history *History) bool {
+	v, ok := n.operand.value(sample, history)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case ">":
+		return v > n.value
+	case ">=":
+		return v >= n.value
+	case "<":
+		return v < n.value
+	case "<=":
+		return v <= n.value
+	case "==":
+		return v == n.value
+	}
+	return v != n.value
+}
+
+type andNode struct{ left, right exprNode }
+
+func (n *andNode) eval(sample Sample, history *History) bool {
+	left, right := n.left.eval(sample, history), n.right.eval(sample, history)
+	return left && right
+}
+
+type orNode struct{ left, right exprNode }
+
+func (n *orNode) eval(sample Sample, history *History) bool {
+	left, right := n.left.eval(sample, history), n.right.eval(sample, history)
+	return left || right
+}
+
+type notNode struct{ inner exprNode }
+
+func (n *notNode) eval(sample Sample, history *History) bool {
+	return !n.inner.eval(sample, history)
+}
+
+// forNode истинен, когда вложенное условие выполняется непрерывно не меньше duration
+type forNode struct {
+	inner    exprNode
+	duration time.Duration
+	since    time.Time
+}
+
+func (n *forNode) eval(sample Sample, history *History) bool {
+	if !n.inner.eval(sample, history) {
+		n.since = time.Time{}
+		return false
+	}
+	if n.since.IsZero() {
+		n.since = sample.Time
+	}
+	return sample.Time.Sub(n.since) >= n.duration
+}
+
+// exprParser разбирает выражение методом рекурсивного спуска:
+//
+//	expr       = or ["for" duration]
+//	or         = and {("OR" | "||") and}
+//	and        = not {("AND" | "&&") not}
+//	not        = ("NOT" | "!") not | "(" expr ")" | comparison
+//	comparison = operand (">" | ">=" | "<" | "<=" | "==" | "!=") number
+//	operand    = metric | ("delta" | "rate") "(" metric "," duration ")"
+//
+// Ключевые слова не зависят от регистра; число может быть размером ("512MB")
+// или скоростью ("50MB/min")
+type exprParser struct {
+	tokens []string
+	pos    int
+	//Операнды в порядке первого упоминания — для описания срабатывания
+	operands []exprOperand
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	token := p.peek()
+	if token != "" {
+		p.pos++
+	}
+	return token
+}
+
+func (p *exprParser) keyword(words ...string) bool {
+	for _, word := range words {
+		if strings.EqualFold(p.peek(), word) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.keyword("for") {
+		token := p.next()
+		duration, err := time.ParseDuration(token)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("Неверная длительность после for: %q", token)
+		}
+		node = &forNode{inner: node, duration: duration}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and", "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.keyword("not", "!") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner}, nil
+	}
+	if p.keyword("(") {
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("Ожидалась \")\" вместо %q", p.peek())
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	operand, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch op {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return nil, fmt.Errorf("Ожидалось сравнение после %s вместо %q", operand.name, op)
+	}
+	token := p.next()
+	value, err := parseExprNumber(token)
+	if err != nil {
+		return nil, fmt.Errorf("Неверное число %q в сравнении с %s", token, operand.name)
+	}
+	known := false
+	for _, o := range p.operands {
+		known = known || o.name == operand.name
+	}
+	if !known {
+		p.operands = append(p.operands, operand)
+	}
+	return &compareNode{operand: operand, op: op, value: value}, nil
+}
+
+func (p *exprParser) parseOperand() (exprOperand, error) {
+	name := p.next()
+	function := strings.ToLower(name)
+	if (function == "delta" || function == "rate") && p.keyword("(") {
+		return p.parseChange(function)
+	}
+	metric, err := lookupMetric(name)
+	if err != nil {
+		return exprOperand{}, err
+	}
+	return metricOperand(name, metric), nil
+}
+
+func exprMetricNames() []string {
+	names := make([]string, 0, len(exprMetrics))
+	for name := range exprMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tokenizeExpr делит выражение на слова, скобки и операторы сравнения
+func tokenizeExpr(expr string) []string {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.ContainsRune("<>=!&|", rune(c)):
+			j := i + 1
+			if j < len(expr) && strings.ContainsRune("=&|", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(expr) && !strings.ContainsRune(" \t\n(),<>=!&|", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// formatExprValue форматирует значение метрики по суффиксу ее имени
+func formatExprValue(name string, v float64) string {
+	switch {
+	case strings.HasSuffix(name, "_bytes"):
+		return FormatMemorySize(uint64(v))
+	case strings.HasSuffix(name, "_rate"):
+		return formatRate(v)
+	case strings.HasSuffix(name, "_seconds"):
+		return formatETA(time.Duration(v * float64(time.Second)))
+	}
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+// exprCondition — условие правила типа expr
+type exprCondition struct {
+	root     exprNode
+	operands []exprOperand
+}
+
+func newExprCondition(expr string) (alertCondition, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("Пустое выражение: задайте поле \"expr\"")
+	}
+	parser := &exprParser{tokens: tokenizeExpr(expr)}
+	root, err := parser.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("Лишний текст в выражении: %q", strings.Join(parser.tokens[parser.pos:], " "))
+	}
+	return &exprCondition{root: root, operands: parser.operands}, nil
+}
+
+// check описывает срабатывание текущими значениями операндов выражения
+func (c *exprCondition) check(sample Sample, history *History) (string, bool) {
+	if !c.root.eval(sample, history) {
+		return "", false
+	}
+	parts := make([]string, 0, len(c.operands))
+	for _, operand := range c.operands {
+		if v, ok := operand.value(sample, history); ok {
+			parts = append(parts, operand.name+"="+operand.format(v))
+		}
+	}
+	return strings.Join(parts, ", "), true
+}
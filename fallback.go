@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Системные программы macOS запускаются по абсолютным путям, чтобы урезанный PATH
+// (launchd, cron, песочницы) не прятал их
+const (
+	psPath     = "/bin/ps"
+	vmStatPath = "/usr/bin/vm_stat"
+	sysctlPath = "/usr/sbin/sysctl"
+)
+
+// Значения замера, у которых есть запасной источник на случай, когда внешней программы
+// нет (минимальные контейнеры, песочницы, урезанный PATH) или она завершилась с ошибкой
+const (
+	//Свободная и доступная память: host_statistics64 или vm_stat, запасной источник — счетчики страниц sysctl
+	fieldAvailableMemory = "available memory"
+	//Обмен со swap: host_statistics64 или vm_stat, запасного источника нет
+	fieldSwapActivity = "swap activity"
+	//Объем RAM: sysctl hw.memsize, запасные источники — вывод sysctl и сумма счетчиков страниц
+	fieldTotalMemory = "total memory"
+	//Объем swap: sysctl vm.swapusage, запасной источник — вывод sysctl, без него swap считается пустым
+	fieldSwapUsage = "swap usage"
+	//Список процессов: sysctl kern.proc.all, запасной источник — ps
+	fieldProcessList = "process list"
+	//Память и состояние процессов других пользователей без root: ps, запасного источника нет
+	fieldOtherUsersProcesses = "other users' processes"
+)
+
+// DegradedField — значение замера, которое сейчас читается из запасного источника
+// или недоступно
+type DegradedField struct {
+	Field string `json:"field"`
+	//Источник и причина: "vm_stat ENOENT", "ps command failed"
+	Reason string `json:"reason"`
+}
+
+// degradedFields — причины по значениям замера. В отличие от паник (collectionPanics)
+// отметка снимается, как только основной источник снова работает
+var degradedFields = struct {
+	mu     sync.Mutex
+	fields map[string]string
+}{fields: make(map[string]string)}
+
+// markDegraded отмечает, что field читается из запасного источника или недоступен,
+// потому что source вернул ошибку err
+func markDegraded(field, source string, err error) {
+	degradedFields.mu.Lock()
+	defer degradedFields.mu.Unlock()
+	degradedFields.fields[field] = source + " " + errorReason(err)
+}
+
+// restoreField снимает отметку: field снова читается из основного источника
+func restoreField(field string) {
+	degradedFields.mu.Lock()
+	defer degradedFields.mu.Unlock()
+	delete(degradedFields.fields, field)
+}
+
+// degradedFieldList возвращает отмеченные значения в порядке названий
+func degradedFieldList() []DegradedField {
+	degradedFields.mu.Lock()
+	defer degradedFields.mu.Unlock()
+	fields := make([]DegradedField, 0, len(degradedFields.fields))
+	for field, reason := range degradedFields.fields {
+		fields = append(fields, DegradedField{Field: field, Reason: reason})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+// formatDegradedFields перечисляет отмеченные значения: "available memory (vm_stat ENOENT)"
+func formatDegradedFields(fields []DegradedField) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fmt.Sprintf("%s (%s)", field.Field, field.Reason)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatFallbacks сообщает о значениях из запасных источников или возвращает пустую строку
+func FormatFallbacks(fields []DegradedField) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	return paint(activeTheme.Warning, "Fallback: "+formatDegradedFields(fields)) + "\n"
+}
+
+// sysctlPageCounts оценивает свободную и доступную память в страницах по счетчикам sysctl,
+// если vm_stat недоступен. Неактивных страниц среди них нет, поэтому свободная память —
+// только свободные страницы, а доступная — еще и спекулятивные и кэш файлов
+func sysctlPageCounts() (uint64, uint64, error) {
+	var counts [3]uint64
+	for i, name := range []string{"vm.page_free_count", "vm.page_speculative_count", "vm.page_pageable_external_count"} {
+		count, err := sysctlUint(name)
+		if err != nil {
+			return 0, 0, err
+		}
+		counts[i] = count
+	}
+	return counts[0], counts[0] + counts[1] + counts[2], nil
+}
+
+// commandSysctlUint читает целочисленный параметр ядра из вывода "sysctl -n"
+func commandSysctlUint(name string) (uint64, error) {
+	output, err := exec.Command(sysctlPath, "-n", name).Output()
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %s: %q", ErrParse, name, strings.TrimSpace(string(output)))
+	}
+	return value, nil
+}
+
+// psProcessList возвращает PID всех процессов из вывода ps, если kern.proc.all недоступен
+func psProcessList() ([]int, error) {
+	output, err := exec.Command(psPath, "-e", "-o", "pid=").Output()
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		pid, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
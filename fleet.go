@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fleetRemoteScript выполняется на удаленном хосте: системная память и процесс
+// с наибольшим RSS (в килобайтах) через разделитель
+const fleetRemoteScript = "cat /proc/meminfo && echo --- && ps -eo rss=,comm= | sort -rn | head -n 1"
+
+// FleetHost — хост, опрашиваемый в режиме fleet
+type FleetHost struct {
+	//Имя хоста в таблице; по умолчанию совпадает с адресом
+	Name string `json:"name"`
+	//Адрес для ssh: "host", "user@host" или "ssh://user@host:port"
+	Address string `json:"address"`
+	//Группа хоста для сводки по группам, например дата-центр
+	Group string `json:"group"`
+	//Параметры подключения ssh; пустые значения берутся из конфигурации ssh
+	User         string   `json:"user"`
+	Port         int      `json:"port"`
+	IdentityFile string   `json:"identity_file"`
+	SSHArgs      []string `json:"ssh_args"`
+}
+
+// sshArgs возвращает аргументы ssh для подключения к хосту перед удаленной командой
+func (h FleetHost) sshArgs() []string {
+	var args []string
+	if h.User != "" {
+		args = append(args, "-l", h.User)
+	}
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	if h.IdentityFile != "" {
+		args = append(args, "-i", h.IdentityFile)
+	}
+	args = append(args, h.SSHArgs...)
+	return append(args, h.Address)
+}
+
+// HostSample — результат опроса одного хоста
+type HostSample struct {
+	Host   string
+	Group  string
+	System SystemMemoryInfo
+	//Процесс с наибольшим потреблением памяти
+	TopName   string
+	TopMemory uint64
+	Err       error
+}
+
+// loadHostList читает список хостов: по одному адресу в строке, строки с # пропускаются
+func loadHostList(path string) ([]FleetHost, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть список хостов %s: %v", path, err)
+	}
+	defer file.Close()
+	var hosts []FleetHost
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hosts = append(hosts, FleetHost{Name: line, Address: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка чтения %s: %v", path, err)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("Список хостов %s пуст", path)
+	}
+	return hosts, nil
+}
+
+// collectHostSample делает один замер на удаленном хосте через ssh
+//
+// ssh запускается в режиме BatchMode, чтобы хост, требующий ввода пароля,
+// завершался ошибкой, а не блокировал опрос остальных
+func collectHostSample(host FleetHost, timeout time.Duration) HostSample {
+	result := HostSample{Host: host.Name, Group: host.Group}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := []string{"-o", "BatchMode=yes", "-o", fmt.Sprintf("ConnectTimeout=%d", int(timeout.Seconds())+1)}
+	args = append(args, host.sshArgs()...)
+	args = append(args, fleetRemoteScript)
+	output, err := exec.CommandContext(ctx, "ssh", args...).Output()
+	if ctx.Err() != nil {
+		result.Err = fmt.Errorf("Нет ответа за %s", timeout)
+		return result
+	}
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+			lines := strings.Split(strings.TrimSpace(string(exitErr.Stderr)), "\n")
+			err = fmt.Errorf("%s", lines[len(lines)-1])
+		}
+		result.Err = fmt.Errorf("Ошибка ssh: %v", err)
+		return result
+	}
+
+	parts := bytes.SplitN(output, []byte("\n---\n"), 2)
+	memStats, err := parseMemInfo(bytes.NewReader(parts[0]))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if result.System, err = systemMemoryFromMemInfo(memStats); err != nil {
+		result.Err = err
+		return result
+	}
+	if len(parts) == 2 {
+		fields := strings.Fields(string(parts[1]))
+		if len(fields) >= 2 {
+			if rss, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+				result.TopMemory = rss * 1024
+				result.TopName = strings.Join(fields[1:], " ")
+			}
+		}
+	}
+	return result
+}
+
+// collectFleet опрашивает хосты параллельно, не больше parallel одновременно;
+// результаты возвращаются в порядке списка хостов
+func collectFleet(hosts []FleetHost, parallel int, timeout time.Duration) []HostSample {
+	results := make([]HostSample, len(hosts))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host FleetHost) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = collectHostSample(host, timeout)
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+// FormatFleetTable форматирует сравнительную таблицу хостов
+func FormatFleetTable(results []HostSample) string {
+	var res strings.Builder
+	header := "HOST                        TOTAL   USED %   SWAP %  TOP PROCESS"
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for _, result := range results {
+		host := result.Host
+		if len(host) > 22 {
+			host = host[:19] + "..."
+		}
+		if result.Err != nil {
+			res.WriteString(paint(activeTheme.Critical, fmt.Sprintf("%-22s  %v", host, result.Err)) + "\n")
+			continue
+		}
+		system := result.System
+		top := "-"
+		if result.TopName != "" {
+			top = fmt.Sprintf("%s (%s)", getShortProcessName(result.TopName), FormatMemorySize(result.TopMemory))
+		}
+		res.WriteString(fmt.Sprintf("%-22s  %10s  %6.1f%%  %6.1f%%  %s\n",
+			host, FormatMemorySize(system.TotalMemory), usedPercent(system), swapPercent(system), top))
+	}
+	return res.String()
+}
+
+func usedPercent(system SystemMemoryInfo) float64 {
+	if system.TotalMemory == 0 {
+		return 0
+	}
+	return float64(system.TotalMemory-system.AvailableMemory) / float64(system.TotalMemory) * 100
+}
+
+func swapPercent(system SystemMemoryInfo) float64 {
+	if system.SwapTotal == 0 {
+		return 0
+	}
+	return float64(system.SwapTotal-system.SwapFree) / float64(system.SwapTotal) * 100
+}
+
+// FormatFleetGroups форматирует сводку по группам хостов: средние проценты использования памяти
+// и swap по ответившим хостам и их суммарную память; без групп возвращает пустую строку
+func FormatFleetGroups(results []HostSample) string {
+	type groupStats struct {
+		name             string
+		hosts, reachable int
+		total            uint64
+		usedSum, swapSum float64
+	}
+	var groups []*groupStats
+	index := make(map[string]*groupStats)
+	for _, result := range results {
+		if result.Group == "" {
+			continue
+		}
+		group, ok := index[result.Group]
+		if !ok {
+			group = &groupStats{name: result.Group}
+			index[result.Group] = group
+			groups = append(groups, group)
+		}
+		group.hosts++
+		if result.Err != nil {
+			continue
+		}
+		group.reachable++
+		group.total += result.System.TotalMemory
+		group.usedSum += usedPercent(result.System)
+		group.swapSum += swapPercent(result.System)
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+
+	var res strings.Builder
+	res.WriteString("\nGroups:\n")
+	header := "GROUP                   HOSTS       TOTAL  AVG USED %  AVG SWAP %"
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for _, group := range groups {
+		name := group.name
+		if len(name) > 22 {
+			name = name[:19] + "..."
+		}
+		avgUsed, avgSwap := 0.0, 0.0
+		if group.reachable > 0 {
+			avgUsed = group.usedSum / float64(group.reachable)
+			avgSwap = group.swapSum / float64(group.reachable)
+		}
+		res.WriteString(fmt.Sprintf("%-22s  %3d/%-3d  %10s  %9.1f%%  %9.1f%%\n",
+			name, group.reachable, group.hosts, FormatMemorySize(group.total), avgUsed, avgSwap))
+	}
+	return res.String()
+}
+
+// runFleetCommand — подкоманда "fleet": один замер с каждого хоста списка через ssh
+func runFleetCommand(args []string) error {
+	flags := newCommandFlags("fleet")
+	hostsPath := flags.String("hosts", "", "`file` with one ssh destination per line")
+	inventoryPath := flags.String("inventory", "", "Ansible INI inventory or JSON host `file` with ssh options and groups")
+	parallel := flags.Int("parallel", 16, "maximum number of concurrent ssh connections")
+	timeout := flags.Duration("timeout", 10*time.Second, "per-host `timeout`")
+	flags.Parse(args)
+	if (*hostsPath == "") == (*inventoryPath == "") {
+		return fmt.Errorf("Использование: memory-analyzer fleet --hosts file | --inventory file [--parallel N] [--timeout d]")
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("Число одновременных подключений должно быть положительным: %d", *parallel)
+	}
+	var hosts []FleetHost
+	var err error
+	if *inventoryPath != "" {
+		hosts, err = loadInventory(*inventoryPath)
+	} else {
+		hosts, err = loadHostList(*hostsPath)
+	}
+	if err != nil {
+		return err
+	}
+	results := collectFleet(hosts, *parallel, *timeout)
+	fmt.Print(FormatFleetTable(results))
+	fmt.Print(FormatFleetGroups(results))
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Параметры прогноза исчерпания памяти
+const (
+	//Окно истории, по которому оценивается скорость убывания доступной памяти
+	forecastWindow = 10 * time.Minute
+	//Минимальное число замеров и длительность наблюдения для прогноза
+	forecastMinPoints = 5
+	forecastMinSpan   = 30 * time.Second
+	//Прогнозы дальше этого горизонта не показываются: на таких сроках тренд ничего не значит
+	forecastHorizon = 24 * time.Hour
+)
+
+// MemoryForecast — прогноз исчерпания доступной памяти при сохранении текущей тенденции
+type MemoryForecast struct {
+	//Скорость убывания доступной памяти в байтах в секунду
+	Rate float64 `json:"rate_bytes_per_second"`
+	//Оценка времени до исчерпания памяти в секундах
+	ETASeconds float64 `json:"eta_seconds"`
+}
+
+// ETA возвращает оценку времени до исчерпания памяти
+func (f MemoryForecast) ETA() time.Duration {
+	return time.Duration(f.ETASeconds * float64(time.Second))
+}
+
+// forecastExhaustion линейно экстраполирует убывание доступной памяти за последние
+// forecastWindow методом наименьших квадратов
+//
+// Возвращает nil, если данных недостаточно, память не убывает или исчерпание
+// ожидается позже forecastHorizon
+func forecastExhaustion(history *History, now time.Time) *MemoryForecast {
+	points := history.Since(now.Add(-forecastWindow))
+	if len(points) < forecastMinPoints || points[len(points)-1].Time.Sub(points[0].Time) < forecastMinSpan {
+		return nil
+	}
+	start := points[0].Time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, point := range points {
+		x := point.Time.Sub(start).Seconds()
+		y := float64(point.System.AvailableMemory)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope >= 0 {
+		return nil
+	}
+	available := float64(points[len(points)-1].System.AvailableMemory)
+	eta := available / -slope
+	if eta > forecastHorizon.Seconds() {
+		return nil
+	}
+	return &MemoryForecast{Rate: -slope, ETASeconds: eta}
+}
+
+// FormatForecast форматирует прогноз в одну строку
+func FormatForecast(forecast *MemoryForecast) string {
+	if forecast == nil {
+		return ""
+	}
+	return fmt.Sprintf("At current rate (-%s), memory exhausted in %s\n",
+		formatRate(forecast.Rate), formatETA(forecast.ETA()))
+}
+
+// formatETA округляет длительность до понятных человеку единиц: "~18 min", "~2.5 h"
+func formatETA(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1 min"
+	case d < time.Hour:
+		return fmt.Sprintf("~%d min", int(d.Minutes()+0.5))
+	}
+	return fmt.Sprintf("~%.1f h", d.Hours())
+}
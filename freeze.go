@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// frozenLabel — метка приостановленного процесса в конце строки таблицы
+const frozenLabel = "FROZEN"
+
+// FreezeTargets приостанавливает процессы сигналом SIGSTOP, а если все они уже приостановлены
+// из интерфейса — возобновляет их сигналом SIGCONT. Возвращает описание результата для строки
+// состояния. Процесс пропускается, если под его PID уже работает процесс с другим именем.
+// Сама программа и запустившая ее оболочка не приостанавливаются: иначе панель перестанет отвечать
+func (s *ViewState) FreezeTargets(reader MemoryReader, targets []ProcessInfo) string {
+	freeze := false
+	for _, process := range targets {
+		if _, ok := s.Frozen[process.PID]; !ok {
+			freeze = true
+			break
+		}
+	}
+	verb, action, signalName := "Resumed", "resume", "SIGCONT"
+	if freeze {
+		verb, action, signalName = "Froze", "freeze", "SIGSTOP"
+	}
+	done := 0
+	var failures []string
+	var auditErr error
+	record := func(process ProcessInfo, outcome, detail string) {
+		if err := s.Audit.auditSignal(action, signalName, process, outcome, detail); err != nil {
+			auditErr = err
+		}
+	}
+	for _, process := range targets {
+		if freeze && (process.PID == os.Getpid() || process.PID == os.Getppid()) {
+			failures = append(failures, fmt.Sprintf("%d: refusing to freeze the dashboard or its shell", process.PID))
+			record(process, AuditSkipped, "dashboard or its shell")
+			continue
+		}
+		if name, err := reader.ReadProcessName(process.PID); err != nil || name != process.Name {
+			delete(s.Frozen, process.PID)
+			failures = append(failures, fmt.Sprintf("%d: exited", process.PID))
+			record(process, AuditSkipped, "exited")
+			continue
+		}
+		if err := stopProcess(process.PID, freeze); err != nil {
+			failures = append(failures, fmt.Sprintf("%d: %v", process.PID, err))
+			record(process, AuditFailed, err.Error())
+			continue
+		}
+		record(process, AuditOK, "")
+		if freeze {
+			s.Frozen[process.PID] = process.Name
+		} else {
+			delete(s.Frozen, process.PID)
+		}
+		done++
+	}
+	status := fmt.Sprintf("%s %d of %d processes", verb, done, len(targets))
+	if len(failures) > 0 {
+		status += " (" + strings.Join(failures, ", ") + ")"
+	}
+	return withAuditError(status, auditErr)
+}
+
+// PruneFrozen забывает приостановленные процессы, которые завершились или чей PID занял
+// процесс с другим именем
+func (s *ViewState) PruneFrozen(processes []ProcessInfo) {
+	if len(s.Frozen) == 0 {
+		return
+	}
+	alive := make(map[int]string, len(processes))
+	for _, process := range processes {
+		alive[process.PID] = process.Name
+	}
+	for pid, name := range s.Frozen {
+		if alive[pid] != name {
+			delete(s.Frozen, pid)
+		}
+	}
+}
+
+// ResumeFrozen возобновляет все процессы, приостановленные из интерфейса, чтобы после выхода
+// из программы они не остались остановленными. Возвращает число возобновленных процессов
+func (s *ViewState) ResumeFrozen(reader MemoryReader) int {
+	resumed := 0
+	for pid, name := range s.Frozen {
+		if current, err := reader.ReadProcessName(pid); err == nil && current == name {
+			process := ProcessInfo{PID: pid, Name: name}
+			if err := stopProcess(pid, false); err != nil {
+				s.Audit.auditSignal("resume", "SIGCONT", process, AuditFailed, err.Error())
+			} else {
+				s.Audit.auditSignal("resume", "SIGCONT", process, AuditOK, "on exit")
+				resumed++
+			}
+		}
+		delete(s.Frozen, pid)
+	}
+	return resumed
+}
+
+// frozenRows дописывает метку FROZEN в конец строк приостановленных процессов таблицы FormatTable
+func frozenRows(table string, processes []ProcessInfo, frozen map[int]string) string {
+	if len(frozen) == 0 {
+		return table
+	}
+	lines := strings.Split(table, "\n")
+	for i, process := range processes {
+		idx := tableHeaderLines + i
+		if _, ok := frozen[process.PID]; ok && idx < len(lines) {
+			lines[idx] += "  " + paint(activeTheme.Warning, frozenLabel)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatFrozen возвращает строку со списком процессов, приостановленных из интерфейса
+func FormatFrozen(state *ViewState) string {
+	if len(state.Frozen) == 0 {
+		return ""
+	}
+	pids := make([]int, 0, len(state.Frozen))
+	for pid := range state.Frozen {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	names := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		names = append(names, fmt.Sprintf("%s (%d)", state.Frozen[pid], pid))
+	}
+	return fmt.Sprintf("Frozen (SIGSTOP): %s (z to resume, resumed on exit)\n", strings.Join(names, ", "))
+}
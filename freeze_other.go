@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "fmt"
+
+// stopProcess: SIGSTOP и SIGCONT есть только в Unix
+func stopProcess(pid int, stop bool) error {
+	return fmt.Errorf("%w: приостановка процессов доступна только в Unix", ErrUnsupportedPlatform)
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// stopProcess приостанавливает процесс сигналом SIGSTOP (stop) или возобновляет его сигналом SIGCONT
+func stopProcess(pid int, stop bool) error {
+	if stop {
+		return syscall.Kill(pid, syscall.SIGSTOP)
+	}
+	return syscall.Kill(pid, syscall.SIGCONT)
+}
@@ -0,0 +1,5 @@
+module github.com/gulmix/Memory-analizer
+
+go 1.22
+
+require golang.org/x/sys v0.28.0
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// grafanaTopProcesses — число рядов, в которые раскрывается цель process.*.memory_bytes
+const grafanaTopProcesses = 10
+
+// grafanaAllProcesses — цель с рядами процессов, занимавших больше всего памяти за период
+const grafanaAllProcesses = "process.*.memory_bytes"
+
+// grafanaQuery — запрос /query источников данных Grafana SimpleJSON и JSON (simpod)
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	MaxDataPoints int `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		//"timeserie" (по умолчанию) или "table"
+		Type string `json:"type"`
+	} `json:"targets"`
+}
+
+// grafanaSeries — временной ряд в ответе /query: точки [значение, время в миллисекундах]
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// grafanaTable — таблица в ответе /query для панелей с type "table"
+type grafanaTable struct {
+	Type    string              `json:"type"`
+	Columns []map[string]string `json:"columns"`
+	Rows    [][]interface{}     `json:"rows"`
+}
+
+// registerGrafana добавляет к API демона точки входа источников данных Grafana, чтобы
+// строить графики по истории замеров без внешней базы временных рядов: SimpleJSON и JSON
+// (simpod) подключаются к /grafana, Infinity читает GET /grafana/series
+func (s *APIServer) registerGrafana() {
+	s.mux.HandleFunc("/grafana", s.handleGrafanaHealth)
+	s.mux.HandleFunc("/grafana/", s.handleGrafanaHealth)
+	s.mux.HandleFunc("/grafana/search", s.handleGrafanaSearch)
+	s.mux.HandleFunc("/grafana/metrics", s.handleGrafanaSearch)
+	s.mux.HandleFunc("/grafana/query", s.handleGrafanaQuery)
+	s.mux.HandleFunc("/grafana/annotations", s.handleGrafanaAnnotations)
+	s.mux.HandleFunc("/grafana/series", s.handleGrafanaSeries)
+}
+
+// SetHistory сохраняет снимок истории замеров для запросов Grafana
+func (s *APIServer) SetHistory(snapshot HistorySnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = snapshot
+}
+
+// historySnapshot возвращает последний снимок истории
+func (s *APIServer) historySnapshot() HistorySnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.history
+}
+
+// handleGrafanaHealth отвечает на проверку подключения источника данных
+func (s *APIServer) handleGrafanaHealth(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/grafana" && r.URL.Path != "/grafana/" {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleGrafanaSearch перечисляет доступные цели, содержащие строку запроса: /search
+// (SimpleJSON) возвращает список строк, /metrics (JSON) — пары label/value
+func (s *APIServer) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Target string `json:"target"`
+		Metric string `json:"metric"`
+	}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&request)
+	}
+	filter := request.Target + request.Metric
+	var targets []string
+	for _, target := range grafanaTargets(s.historySnapshot()) {
+		if strings.Contains(target, filter) {
+			targets = append(targets, target)
+		}
+	}
+	if strings.HasSuffix(r.URL.Path, "/metrics") {
+		options := make([]map[string]string, len(targets))
+		for i, target := range targets {
+			options[i] = map[string]string{"label": target, "value": target}
+		}
+		writeJSON(w, http.StatusOK, options)
+		return
+	}
+	if targets == nil {
+		targets = []string{}
+	}
+	writeJSON(w, http.StatusOK, targets)
+}
+
+// grafanaTargets возвращает метрики истории, цель всех процессов и цели процессов
+// из последнего замера в порядке убывания памяти
+func grafanaTargets(history HistorySnapshot) []string {
+	targets := make([]string, 0, len(historyMetrics)+1)
+	for name := range historyMetrics {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+	targets = append(targets, grafanaAllProcesses)
+	latest, ok := history.Latest()
+	if !ok {
+		return targets
+	}
+	byName := make(map[string]uint64)
+	for _, process := range latest.Processes {
+		byName[process.Name] += process.MemoryUsage
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if byName[names[i]] != byName[names[j]] {
+			return byName[names[i]] > byName[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		targets = append(targets, "process."+name+".memory_bytes")
+	}
+	return targets
+}
+
+// handleGrafanaQuery возвращает ряды или таблицы для целей запроса за период
+func (s *APIServer) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	var query grafanaQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	points := s.historySnapshot().Range(query.Range.From, query.Range.To, query.MaxDataPoints)
+	response := []interface{}{}
+	for _, target := range query.Targets {
+		series, err := grafanaSeriesFor(target.Target, points, query.MaxDataPoints)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if target.Type == "table" {
+			response = append(response, grafanaTableFor(series))
+			continue
+		}
+		for _, ts := range series {
+			response = append(response, ts)
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleGrafanaAnnotations: история оповещений не хранится, поэтому аннотаций нет;
+// ответ нужен, чтобы настроенные в панели аннотации не выдавали ошибку
+func (s *APIServer) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, []interface{}{})
+}
+
+// handleGrafanaSeries: GET /grafana/series?target=цель&from=начало&to=конец возвращает
+// плоский список {time, target, value} для источника Infinity; from и to — миллисекунды
+// (${__from} и ${__to} в Grafana) или RFC 3339, без них — вся история
+func (s *APIServer) handleGrafanaSeries(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	var from, to time.Time
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		text := params.Get(bound.name)
+		if text == "" {
+			continue
+		}
+		t, err := parseGrafanaTime(text)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + bound.name + ": " + err.Error()})
+			return
+		}
+		*bound.value = t
+	}
+	maxPoints, _ := strconv.Atoi(params.Get("maxDataPoints"))
+	points := s.historySnapshot().Range(from, to, maxPoints)
+	series, err := grafanaSeriesFor(params.Get("target"), points, maxPoints)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	rows := []map[string]interface{}{}
+	for _, ts := range series {
+		for _, point := range ts.Datapoints {
+			rows = append(rows, map[string]interface{}{
+				"time":   time.UnixMilli(int64(point[1])).UTC().Format(time.RFC3339Nano),
+				"target": ts.Target,
+				"value":  point[0],
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+// parseGrafanaTime разбирает момент времени в миллисекундах Unix или в формате RFC 3339
+func parseGrafanaTime(text string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, text)
+}
+
+// historyRange возвращает точки истории в периоде; нулевая граница не ограничивает период
+func historyRange(points []HistoryPoint, from, to time.Time) []HistoryPoint {
+	start := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(from) })
+	end := len(points)
+	if !to.IsZero() {
+		end = sort.Search(len(points), func(i int) bool { return points[i].Time.After(to) })
+	}
+	if start >= end {
+		return nil
+	}
+	return points[start:end]
+}
+
+// grafanaSeriesFor строит ряды цели по точкам истории, прореживая их до maxPoints точек;
+// process.*.memory_bytes раскрывается в ряды процессов с наибольшим пиком за период
+func grafanaSeriesFor(target string, points []HistoryPoint, maxPoints int) ([]grafanaSeries, error) {
+	names := []string{target}
+	if target == grafanaAllProcesses {
+		names = topProcessNames(points, grafanaTopProcesses)
+		for i, name := range names {
+			names[i] = "process." + name + ".memory_bytes"
+		}
+	} else if _, process := processMetric(target); !historyMetrics[target] && !process {
+		if _, err := lookupMetric(target); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Метрика %s не хранится в истории замеров", target)
+	}
+
+	step := 1
+	if maxPoints > 0 && len(points) > maxPoints {
+		step = (len(points) + maxPoints - 1) / maxPoints
+	}
+	var series []grafanaSeries
+	for _, name := range names {
+		metric, _ := lookupMetric(name)
+		ts := grafanaSeries{Target: name, Datapoints: [][2]float64{}}
+		// Из каждой группы по step точек берется последняя, чтобы график заканчивался
+		// самым свежим замером
+		for i := len(points) - 1; i >= 0; i -= step {
+			point := points[i]
+			value, ok := metric(Sample{Time: point.Time, System: point.System, Processes: point.Processes})
+			if ok {
+				ts.Datapoints = append(ts.Datapoints, [2]float64{value, float64(point.Time.UnixMilli())})
+			}
+		}
+		for i, j := 0, len(ts.Datapoints)-1; i < j; i, j = i+1, j-1 {
+			ts.Datapoints[i], ts.Datapoints[j] = ts.Datapoints[j], ts.Datapoints[i]
+		}
+		series = append(series, ts)
+	}
+	return series, nil
+}
+
+// topProcessNames возвращает имена процессов с наибольшей суммарной памятью за период
+func topProcessNames(points []HistoryPoint, top int) []string {
+	peak := make(map[string]uint64)
+	for _, point := range points {
+		byName := make(map[string]uint64)
+		for _, process := range point.Processes {
+			byName[process.Name] += process.MemoryUsage
+		}
+		for name, memory := range byName {
+			peak[name] = max(peak[name], memory)
+		}
+	}
+	names := make([]string, 0, len(peak))
+	for name := range peak {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if peak[names[i]] != peak[names[j]] {
+			return peak[names[i]] > peak[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > top {
+		names = names[:top]
+	}
+	return names
+}
+
+// grafanaTableFor сводит ряды в таблицу с колонками Time, Target и Value
+func grafanaTableFor(series []grafanaSeries) grafanaTable {
+	table := grafanaTable{
+		Type: "table",
+		Columns: []map[string]string{
+			{"text": "Time", "type": "time"},
+			{"text": "Target", "type": "string"},
+			{"text": "Value", "type": "number"},
+		},
+		Rows: [][]interface{}{},
+	}
+	for _, ts := range series {
+		for _, point := range ts.Datapoints {
+			table.Rows = append(table.Rows, []interface{}{int64(point[1]), ts.Target, point[0]})
+		}
+	}
+	return table
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Режимы группировки таблицы процессов в порядке переключения клавишей g
+const (
+	GroupByNone  = ""
+	GroupByNetNS = "netns"
+)
+
+var groupModes = []string{GroupByNone, GroupByNetNS}
+
+// groupByLabelPrefix — префикс режимов группировки по метке процессов, например "label:team"
+const groupByLabelPrefix = "label:"
+
+// ProcessGroup — суммарное потребление памяти группой процессов
+type ProcessGroup struct {
+	Key         string `json:"key"`
+	Processes   int    `json:"processes"`
+	MemoryUsage uint64 `json:"memory_bytes"`
+	//Процесс группы с наибольшим потреблением памяти
+	Top ProcessInfo `json:"top"`
+}
+
+// NamespaceReader реализуют источники данных, умеющие определять сетевое пространство имен процесса
+type NamespaceReader interface {
+	//ReadProcessNetNS возвращает идентификатор сетевого пространства имен процесса, например "net:[4026531840]"
+	ReadProcessNetNS(pid int) (string, error)
+}
+
+func (l *LinuxMemoryReader) ReadProcessNetNS(pid int) (string, error) {
+	return os.Readlink(l.procPath(pid, "ns", "net"))
+}
+
+// hostNetNS возвращает сетевое пространство имен init-процесса, которое считается пространством хоста
+func hostNetNS(reader MemoryReader) string {
+	nsReader, ok := reader.(NamespaceReader)
+	if !ok {
+		return ""
+	}
+	ns, err := nsReader.ReadProcessNetNS(1)
+	if err != nil {
+		return ""
+	}
+	return ns
+}
+
+// groupKey возвращает ключ группы процесса для указанного режима группировки
+func groupKey(process ProcessInfo, mode string) string {
+	switch mode {
+	case GroupByNetNS:
+		if process.NetNS == "" {
+			return "unknown"
+		}
+		return process.NetNS
+	}
+	if key := strings.TrimPrefix(mode, groupByLabelPrefix); key != mode {
+		if value, ok := process.Labels[key]; ok && value != "" {
+			return value
+		}
+		return "unlabeled"
+	}
+	return ""
+}
+
+// GroupProcesses суммирует потребление памяти процессов по группам
+// и сортирует группы по убыванию потребления
+func GroupProcesses(processes []ProcessInfo, mode string) []ProcessGroup {
+	index := make(map[string]int)
+	var groups []ProcessGroup
+	for _, process := range processes {
+		key := groupKey(process, mode)
+		i, exists := index[key]
+		if !exists {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ProcessGroup{Key: key})
+		}
+		group := &groups[i]
+		group.Processes++
+		group.MemoryUsage += process.MemoryUsage
+		if process.MemoryUsage >= group.Top.MemoryUsage {
+			group.Top = process
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].MemoryUsage != groups[j].MemoryUsage {
+			return groups[i].MemoryUsage > groups[j].MemoryUsage
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+// FormatGroupTable форматирует таблицу групп процессов
+func FormatGroupTable(groups []ProcessGroup, mode string) string {
+	var res strings.Builder
+	res.WriteString(fmt.Sprintf("Grouped by %s:\n", mode))
+	header := "GROUP                   PROCS      MEMORY  TOP PROCESS"
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for _, group := range groups {
+		res.WriteString(fmt.Sprintf("%s %6d  %10s  %s\n",
+			fitLeft(ellipsize(group.Key, 22), 22), group.Processes, FormatMemorySize(group.MemoryUsage), getShortProcessName(group.Top.Name)))
+	}
+	return res.String()
+}
+
+// CycleGroup переключает режим группировки таблицы; после встроенных режимов
+// следуют режимы группировки по каждой метке процессов
+func (s *ViewState) CycleGroup() {
+	modes := append([]string(nil), groupModes...)
+	for _, key := range processLabeler.labelKeys() {
+		modes = append(modes, groupByLabelPrefix+key)
+	}
+	for i, mode := range modes {
+		if mode == s.GroupBy {
+			s.GroupBy = modes[(i+1)%len(modes)]
+			return
+		}
+	}
+	s.GroupBy = GroupByNone
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Размеры тепловой карты по умолчанию: число процессов и ширина шкалы времени в символах
+const (
+	defaultHeatmapRows  = 15
+	defaultHeatmapWidth = 60
+)
+
+// heatmapShades — символы уровней заполнения ячейки от 25% до 100% пика процесса
+var heatmapShades = []string{"░", "▒", "▓", "█"}
+
+// Heatmap — потребление памяти процессами во времени: строки — имена процессов,
+// колонки — равные промежутки времени, значение — наибольшая суммарная память
+// процессов с этим именем в промежутке
+type Heatmap struct {
+	Start, End time.Time
+	Names      []string
+	Cells      [][]uint64
+	Peaks      []uint64
+	Width      int
+}
+
+// BuildHeatmap строит тепловую карту rows процессов с наибольшим пиком по замерам истории;
+// если замеров меньше width, каждому замеру соответствует одна колонка
+func BuildHeatmap(points []HistoryPoint, rows, width int) Heatmap {
+	if len(points) < width {
+		width = len(points)
+	}
+	h := Heatmap{Width: width}
+	if len(points) == 0 || width <= 0 {
+		return h
+	}
+	h.Start, h.End = points[0].Time, points[len(points)-1].Time
+	span := h.End.Sub(h.Start)
+
+	cells := make(map[string][]uint64)
+	peaks := make(map[string]uint64)
+	for _, point := range points {
+		column := 0
+		if span > 0 {
+			column = int(float64(point.Time.Sub(h.Start)) / float64(span) * float64(width-1))
+		}
+		totals := make(map[string]uint64)
+		for _, process := range point.Processes {
+			totals[process.Name] += process.MemoryUsage
+		}
+		for name, total := range totals {
+			row, ok := cells[name]
+			if !ok {
+				row = make([]uint64, width)
+				cells[name] = row
+			}
+			if total > row[column] {
+				row[column] = total
+			}
+			if total > peaks[name] {
+				peaks[name] = total
+			}
+		}
+	}
+
+	for name := range cells {
+		h.Names = append(h.Names, name)
+	}
+	sort.Slice(h.Names, func(i, j int) bool {
+		if peaks[h.Names[i]] != peaks[h.Names[j]] {
+			return peaks[h.Names[i]] > peaks[h.Names[j]]
+		}
+		return h.Names[i] < h.Names[j]
+	})
+	if rows > 0 && len(h.Names) > rows {
+		h.Names = h.Names[:rows]
+	}
+	for _, name := range h.Names {
+		h.Cells = append(h.Cells, cells[name])
+		h.Peaks = append(h.Peaks, peaks[name])
+	}
+	return h
+}
+
+// FormatHeatmap форматирует тепловую карту; оттенок ячейки показывает потребление памяти
+// относительно пика процесса, чтобы были видны и утечки небольших процессов
+func FormatHeatmap(h Heatmap, color bool) string {
+	if len(h.Names) == 0 {
+		return ""
+	}
+	var res strings.Builder
+	res.WriteString("Memory Heatmap (relative to each process peak):\n")
+	for i, name := range h.Names {
+		res.WriteString(fitLeft(getShortProcessName(name), 15) + " |")
+		for _, value := range h.Cells[i] {
+			if value == 0 || h.Peaks[i] == 0 {
+				res.WriteString(" ")
+				continue
+			}
+			level := int(float64(value) / float64(h.Peaks[i]) * float64(len(heatmapShades)))
+			if level >= len(heatmapShades) {
+				level = len(heatmapShades) - 1
+			}
+			if color {
+				res.WriteString(paint(activeTheme.Heat[level], heatmapShades[level]))
+			} else {
+				res.WriteString(heatmapShades[level])
+			}
+		}
+		res.WriteString(fmt.Sprintf("| %s\n", FormatMemorySize(h.Peaks[i])))
+	}
+	start, end := h.Start.Format("01-02 15:04"), h.End.Format("01-02 15:04")
+	gap := h.Width + 2 - len(start) - len(end)
+	if gap < 1 {
+		gap = 1
+	}
+	res.WriteString(strings.Repeat(" ", 16) + start + strings.Repeat(" ", gap) + end + "\n")
+	return res.String()
+}
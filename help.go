@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+func init() {
+	subcommands["gen"] = runGenCommand
+}
+
+// CommandDoc — описание программы или подкоманды, по которому выводится справка --help
+// и создается страница man; флаги берутся из самих наборов флагов команд
+type CommandDoc struct {
+	//Имя подкоманды ("baseline save"); пустое — сама информационная панель
+	Name string
+	//Аргументы после флагов, например "file..."
+	Args string
+	//Одна строка для списка команд
+	Summary string
+	//Подробное описание; абзацы разделяются пустой строкой
+	Description string
+	Examples    []CommandExample
+}
+
+// CommandExample — пример запуска с пояснением
+type CommandExample struct {
+	Comment, Command string
+}
+
+// commandDocs — описания команд в порядке их вывода в справке и на странице man
+var commandDocs = []CommandDoc{
+	{
+		Summary: "interactive memory dashboard for Linux and macOS",
+		Description: "Shows system memory, swap and the processes using the most memory, refreshed in place. " +
+			"Processes can be searched, inspected, pinned, marked, exported, frozen and killed from the keyboard; " +
+			"the same data can be exported once (--export) or streamed to sinks (--sink).\n\n" +
+			"Reading the memory of all processes requires root; --drop-privileges keeps only a small reader helper privileged.",
+		Examples: []CommandExample{
+			{"Show the dashboard with I/O columns and the top 20 processes", "memory-analyzer --io --top 20"},
+			{"Save the process table as CSV and exit", "memory-analyzer --export top.csv --export-format csv"},
+			{"Run as root but keep only the reader privileged", "sudo memory-analyzer --drop-privileges"},
+		},
+	},
+	{
+		Name:        "daemon",
+		Summary:     "record samples to a file without the dashboard",
+		Description: "Appends a JSON line per sample to the recording file, optionally only inside recording windows, with rotation, alerts, sinks and an HTTP API. SIGHUP or POST /api/reload re-reads the configuration.",
+		Examples: []CommandExample{
+			{"Record every 10 seconds and serve the HTTP API on localhost", "memory-analyzer daemon --interval 10s --listen 127.0.0.1:9100"},
+		},
+	},
+	{
+		Name:        "report",
+		Args:        "file...",
+		Summary:     "summarize recordings made by the daemon",
+		Description: "Prints peak and average system memory usage and the processes with the highest peak; --heatmap draws process memory over time and --exited lists processes that exited during the recording.",
+		Examples: []CommandExample{
+			{"Top 10 processes of a recording and its rotated files", "memory-analyzer report --top 10 recording*.jsonl.gz"},
+		},
+	},
+	{
+		Name:        "replay",
+		Args:        "file...",
+		Summary:     "play a recording back on the dashboard",
+		Description: "Shows recorded samples on the dashboard faster or slower than real time.",
+		Examples: []CommandExample{
+			{"Replay ten times faster than real time", "memory-analyzer replay --speed 10 recording.jsonl.gz"},
+		},
+	},
+	{
+		Name:        "compare",
+		Args:        "file...",
+		Summary:     "show what grew between two time windows of a recording",
+		Description: "Compares average process memory in two windows of the recordings and lists the processes that grew and shrank the most.",
+		Examples: []CommandExample{
+			{"Compare the morning with the afternoon", "memory-analyzer compare --before 09:00..10:00 --after 15:00..16:00 recording.jsonl.gz"},
+		},
+	},
+	{
+		Name:        "chargeback",
+		Args:        "file...",
+		Summary:     "compute memory cost per process, user or label from recordings",
+		Description: "Multiplies memory use over time (GB-hours) by the given price and aggregates it by process name, user or a process label.",
+		Examples: []CommandExample{
+			{"Cost per team label as a Markdown table", "memory-analyzer chargeback --cost-per-gb-hour 0.005 --by label:team --format markdown recording.jsonl.gz"},
+		},
+	},
+	{
+		Name:        "baseline save",
+		Summary:     "record typical process memory usage as a baseline",
+		Description: "Averages several samples of a healthy system; the dashboard highlights processes deviating from it with --baseline.",
+		Examples: []CommandExample{
+			{"Average five samples two seconds apart", "memory-analyzer baseline save --samples 5 --interval 2s"},
+		},
+	},
+	{
+		Name:        "cached",
+		Args:        "path...",
+		Summary:     "show how much of files and directories is in the page cache",
+		Description: "Checks the pages of the files with mincore without reading them and lists the files with the most cached data.",
+		Examples: []CommandExample{
+			{"Cached files of a database directory", "memory-analyzer cached --top 20 /var/lib/postgresql"},
+		},
+	},
+	{
+		Name:        "reconcile",
+		Summary:     "reconcile used memory with process PSS and kernel memory (Linux)",
+		Description: "Compares MemTotal minus MemAvailable with the PSS of all processes, unreclaimable slab, page tables, kernel stacks and huge pages and prints what is left unaccounted.",
+		Examples: []CommandExample{
+			{"Full reconciliation needs root", "sudo memory-analyzer reconcile --top 10"},
+		},
+	},
+	{
+		Name:        "explain",
+		Summary:     "one-shot diagnosis of where the memory went",
+		Description: "Lists the largest memory consumers, including page cache, shared memory, tmpfs mounts, slab, huge pages and swap, with an explanation and advice for each.",
+		Examples: []CommandExample{
+			{"Report tmpfs mounts holding more than 2 GB", "sudo memory-analyzer explain --tmpfs-threshold 2GB"},
+		},
+	},
+	{
+		Name:        "fleet",
+		Summary:     "take one sample from many hosts over ssh",
+		Description: "Polls hosts from a list or an Ansible/JSON inventory in parallel over ssh in BatchMode and prints a comparison table; nothing has to be installed on the hosts.",
+		Examples: []CommandExample{
+			{"Poll the hosts of an inventory", "memory-analyzer fleet --inventory hosts.ini --parallel 16 --timeout 10s"},
+		},
+	},
+	{
+		Name:        "helper",
+		Summary:     "privileged reader serving process data to an unprivileged dashboard",
+		Description: "Reads memory and process data on behalf of --drop-privileges or --helper-socket clients. Only reading is allowed; access to the socket is limited by its mode and group.",
+		Examples: []CommandExample{
+			{"Serve members of the memstat group", "memory-analyzer helper --socket /run/memory-analyzer.sock --socket-group memstat"},
+		},
+	},
+	{
+		Name:        "procfs-check",
+		Args:        "snapshot-dir...",
+		Summary:     "check that procfs snapshots are read as expected",
+		Description: "Reads each snapshot taken with --procfs-snapshot and compares the result with its expected.json; the exit status is non-zero on any difference.",
+		Examples: []CommandExample{
+			{"Check the bundled snapshots", "memory-analyzer procfs-check testdata/procfs/*"},
+		},
+	},
+	{
+		Name:        "completion",
+		Args:        "bash|zsh|fish",
+		Summary:     "print a shell completion script",
+		Description: "Completes subcommands, flags, file names and names of running processes; candidates are computed by the program itself, so the script does not need to be regenerated after an upgrade.",
+		Examples: []CommandExample{
+			{"Install bash completion", "memory-analyzer completion bash > /etc/bash_completion.d/memory-analyzer"},
+		},
+	},
+	{
+		Name:        "gen",
+		Args:        "man",
+		Summary:     "generate the manual page",
+		Description: "Writes a man page in roff format built from the same descriptions and flags as --help.",
+		Examples: []CommandExample{
+			{"Install the manual page", "memory-analyzer gen man --output /usr/local/share/man/man1/memory-analyzer.1"},
+		},
+	},
+	{
+		Name:        "version",
+		Summary:     "print the version and build information",
+		Description: "Prints the release version, the commit the binary was built from and the Go version; release builds set the version with -ldflags \"-X main.version=...\".",
+		Examples: []CommandExample{
+			{"Print build information as JSON", "memory-analyzer version --json"},
+		},
+	},
+	{
+		Name:        "self-update",
+		Summary:     "update the binary to the latest GitHub release",
+		Description: "Downloads the release binary for this platform, verifies it against the release SHA256SUMS file and atomically replaces the running executable. Set GITHUB_TOKEN to avoid API rate limits.",
+		Examples: []CommandExample{
+			{"Check for a newer release", "memory-analyzer self-update --check"},
+			{"Update in place", "sudo memory-analyzer self-update"},
+		},
+	},
+	{
+		Name:        "tray",
+		Summary:     "show used memory in the macOS menu bar or a Linux tray",
+		Description: "Prints used memory as an xbar/SwiftBar plugin on macOS, or keeps a yad tray icon, a waybar module or a text status line updated on Linux. The menu opens the full dashboard in a terminal or a web dashboard given with --url.",
+		Examples: []CommandExample{
+			{"Body of an xbar/SwiftBar plugin script such as memory.10s.sh", "exec /usr/local/bin/memory-analyzer tray --format xbar"},
+			{"Linux tray icon with a Grafana link", "memory-analyzer tray --format yad --url http://grafana:3000/d/memory"},
+			{"waybar custom module", "memory-analyzer tray --format waybar --interval 5s"},
+		},
+	},
+	{
+		Name:        "doctor",
+		Summary:     "check what works on this machine",
+		Description: "Validates the assumptions the numbers rely on (page size, huge pages, MemAvailable, smaps_rollup, cgroup version, privileges, external programs) and prints which features are enabled, degraded or off. The exit status is non-zero when an assumption is broken and the numbers would be wrong.",
+		Examples: []CommandExample{
+			{"Check a new machine before deploying the daemon", "memory-analyzer doctor"},
+		},
+	},
+}
+
+// findCommandDoc возвращает описание команды по имени
+func findCommandDoc(name string) CommandDoc {
+	for _, doc := range commandDocs {
+		if doc.Name == name {
+			return doc
+		}
+	}
+	return CommandDoc{Name: name}
+}
+
+// FlagDoc — флаг команды для справки: имя значения берется из обратных кавычек в описании
+type FlagDoc struct {
+	Name    string `json:"name"`
+	Value   string `json:"value,omitempty"`
+	Usage   string `json:"usage"`
+	Default string `json:"default,omitempty"`
+}
+
+// flagsDumpEnv — переменная окружения, с которой -h выводит флаги команды в JSON вместо справки.
+// Флаги подкоманд объявляются внутри их функций, поэтому страница man и дополнение получают
+// их, запуская программу с -h
+const flagsDumpEnv = "MEMORY_ANALYZER_DUMP_FLAGS"
+
+// newCommandFlags создает набор флагов подкоманды, выводящий по -h подробную справку
+func newCommandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() { printCommandHelp(flags.Output(), name, flags) }
+	return flags
+}
+
+// describeFlags возвращает флаги набора в алфавитном порядке
+func describeFlags(flags *flag.FlagSet) []FlagDoc {
+	var docs []FlagDoc
+	flags.VisitAll(func(f *flag.Flag) {
+		value, usage := flag.UnquoteUsage(f)
+		doc := FlagDoc{Name: f.Name, Usage: usage}
+		if !isBoolFlag(f) {
+			doc.Value = value
+		}
+		switch f.DefValue {
+		case "", "0", "false", "0s", "[]":
+		default:
+			doc.Default = shortenHome(f.DefValue)
+		}
+		docs = append(docs, doc)
+	})
+	return docs
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// shortenHome заменяет домашний каталог пользователя на "~", чтобы значения по умолчанию
+// в справке и на странице man не зависели от того, кто ее создал
+func shortenHome(value string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == "/" {
+		return value
+	}
+	if value == home || strings.HasPrefix(value, home+string(os.PathSeparator)) {
+		return "~" + strings.TrimPrefix(value, home)
+	}
+	return value
+}
+
+// printCommandHelp выводит справку команды: использование, описание, подкоманды, флаги и примеры
+func printCommandHelp(w io.Writer, name string, flags *flag.FlagSet) {
+	if os.Getenv(flagsDumpEnv) != "" {
+		json.NewEncoder(os.Stdout).Encode(describeFlags(flags))
+		return
+	}
+	doc := findCommandDoc(name)
+	fmt.Fprintf(w, "Usage:\n  %s\n\n", commandSynopsis(doc))
+	if doc.Summary != "" {
+		fmt.Fprintf(w, "%s%s.\n\n", strings.ToUpper(doc.Summary[:1]), doc.Summary[1:])
+	}
+	if doc.Description != "" {
+		for _, paragraph := range strings.Split(doc.Description, "\n\n") {
+			fmt.Fprintf(w, "%s\n\n", wrapText(paragraph, 78, ""))
+		}
+	}
+	if name == "" {
+		fmt.Fprintln(w, "Commands:")
+		for _, sub := range commandDocs[1:] {
+			fmt.Fprintf(w, "  %-15s %s\n", sub.Name, sub.Summary)
+		}
+		fmt.Fprintf(w, "\nRun \"memory-analyzer <command> --help\" for the flags of a command.\n\n")
+	}
+	if flagDocs := describeFlags(flags); len(flagDocs) > 0 {
+		fmt.Fprintln(w, "Flags:")
+		for _, f := range flagDocs {
+			fmt.Fprintf(w, "  %s\n%s\n", flagSynopsis(f), wrapText(flagDescription(f), 72, "      "))
+		}
+		fmt.Fprintln(w)
+	}
+	if len(doc.Examples) > 0 {
+		fmt.Fprintln(w, "Examples:")
+		for _, example := range doc.Examples {
+			fmt.Fprintf(w, "  # %s\n  %s\n", example.Comment, example.Command)
+		}
+	}
+}
+
+// commandSynopsis возвращает строку использования: "memory-analyzer report [flags] file..."
+func commandSynopsis(doc CommandDoc) string {
+	parts := []string{"memory-analyzer"}
+	if doc.Name != "" {
+		parts = append(parts, doc.Name)
+	}
+	parts = append(parts, "[flags]")
+	if doc.Args != "" {
+		parts = append(parts, doc.Args)
+	}
+	return strings.Join(parts, " ")
+}
+
+func flagSynopsis(f FlagDoc) string {
+	if f.Value == "" {
+		return "--" + f.Name
+	}
+	return "--" + f.Name + " " + f.Value
+}
+
+func flagDescription(f FlagDoc) string {
+	if f.Default == "" {
+		return f.Usage
+	}
+	return fmt.Sprintf("%s (default %s)", f.Usage, f.Default)
+}
+
+// wrapText переносит текст по словам на строки не длиннее width с отступом indent
+func wrapText(text string, width int, indent string) string {
+	var lines []string
+	line := indent
+	for _, word := range strings.Fields(text) {
+		if len(line) > len(indent) && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = indent
+		}
+		if len(line) > len(indent) {
+			line += " "
+		}
+		line += word
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+// commandFlags возвращает флаги команды, запуская программу с -h и flagsDumpEnv
+func commandFlags(name string) ([]FlagDoc, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	args := append(strings.Fields(name), "-h")
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), flagsDumpEnv+"=1")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось получить флаги команды %q: %v", name, err)
+	}
+	var flags []FlagDoc
+	if err := json.Unmarshal(output, &flags); err != nil {
+		return nil, fmt.Errorf("Не удалось получить флаги команды %q: %v", name, err)
+	}
+	return flags, nil
+}
+
+// runGenCommand — подкоманда "gen": создает страницу man из описаний команд и их флагов
+func runGenCommand(args []string) error {
+	flags := newCommandFlags("gen")
+	output := flags.String("output", "", "write to `file` instead of stdout")
+	flags.Parse(args)
+	if flags.NArg() == 0 || flags.Arg(0) != "man" {
+		return fmt.Errorf("Использование: memory-analyzer gen man [--output file]")
+	}
+	// Флаги можно указать и после "man"
+	if flags.Parse(flags.Args()[1:]); flags.NArg() != 0 {
+		return fmt.Errorf("Использование: memory-analyzer gen man [--output file]")
+	}
+	page, err := renderManPage()
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.WriteString(page)
+		return err
+	}
+	return os.WriteFile(*output, []byte(page), 0644)
+}
+
+// renderManPage создает страницу man в формате roff
+func renderManPage() (string, error) {
+	var res strings.Builder
+	dashboard := commandDocs[0]
+	res.WriteString(".TH MEMORY-ANALYZER 1 \"\" \"memory-analyzer\" \"User Commands\"\n")
+	res.WriteString(".SH NAME\nmemory-analyzer \\- " + roffEscape(dashboard.Summary) + "\n")
+	res.WriteString(".SH SYNOPSIS\n.B memory-analyzer\n[\\fIflags\\fR]\n.br\n.B memory-analyzer\n\\fIcommand\\fR [\\fIflags\\fR] [\\fIargs\\fR]\n")
+	res.WriteString(".SH DESCRIPTION\n")
+	writeRoffParagraphs(&res, dashboard.Description)
+
+	flags, err := commandFlags("")
+	if err != nil {
+		return "", err
+	}
+	res.WriteString(".SH OPTIONS\n")
+	writeRoffFlags(&res, flags)
+
+	res.WriteString(".SH COMMANDS\n")
+	for _, doc := range commandDocs[1:] {
+		res.WriteString(".SS " + roffEscape(doc.Name) + "\n")
+		res.WriteString(".B " + roffEscape(commandSynopsis(doc)) + "\n.PP\n")
+		res.WriteString(roffEscape(strings.ToUpper(doc.Summary[:1])+doc.Summary[1:]) + ".\n")
+		writeRoffParagraphs(&res, doc.Description)
+		flags, err := commandFlags(doc.Name)
+		if err != nil {
+			return "", err
+		}
+		writeRoffFlags(&res, flags)
+	}
+
+	res.WriteString(".SH EXAMPLES\n")
+	for _, doc := range commandDocs {
+		for _, example := range doc.Examples {
+			res.WriteString(".PP\n" + roffEscape(example.Comment) + ":\n.PP\n.RS\n.nf\n" + roffEscape(example.Command) + "\n.fi\n.RE\n")
+		}
+	}
+
+	res.WriteString(".SH FILES\n")
+	files := map[string]string{
+		shortenHome(defaultConfigPath()):                         "configuration file (--config)",
+		shortenHome(defaultStatePath("recording.jsonl.gz")):      "recording written by the daemon",
+		shortenHome(defaultStatePath("baseline.json")):           "baseline saved by baseline save",
+		shortenHome(defaultStatePath("audit.jsonl")):             "audit log of kill, freeze and admin actions",
+		shortenHome(defaultStatePath("dashboard-state.json.gz")): "history, alerts and pins resumed by the dashboard",
+		shortenHome(defaultStatePath("daemon-state.json.gz")):    "history and alerts resumed by the daemon",
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		res.WriteString(".TP\n.I " + roffEscape(path) + "\n" + roffEscape(files[path]) + "\n")
+	}
+	return res.String(), nil
+}
+
+func writeRoffParagraphs(res *strings.Builder, text string) {
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if paragraph != "" {
+			res.WriteString(".PP\n" + roffEscape(paragraph) + "\n")
+		}
+	}
+}
+
+func writeRoffFlags(res *strings.Builder, flags []FlagDoc) {
+	for _, f := range flags {
+		res.WriteString(".TP\n.B \\-\\-" + roffEscape(f.Name))
+		if f.Value != "" {
+			res.WriteString(" \\fI" + roffEscape(f.Value) + "\\fR")
+		}
+		res.WriteString("\n" + roffEscape(flagDescription(f)) + "\n")
+	}
+}
+
+// roffEscape экранирует обратную косую черту и дефисы, а также точку и апостроф в начале строки,
+// которые roff принял бы за команду
+func roffEscape(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
@@ -0,0 +1,103 @@
+package main
+
+import "time"
+
+// defaultHistorySize — число хранимых замеров: час данных при обновлении раз в 3 секунды
+const defaultHistorySize = 1200
+
+// HistoryPoint — компактная запись одного замера в истории
+type HistoryPoint struct {
+	Time   time.Time        `json:"time"`
+	System SystemMemoryInfo `json:"system"`
+	//Процессы с заполненными только PID, Name и MemoryUsage
+	Processes []ProcessInfo `json:"processes"`
+}
+
+// History — кольцевой буфер последних замеров, на котором строятся прогнозы и правила оповещений,
+// и прореженные уровни со средними значениями, хранящие историю за недели в ограниченном объеме
+type History struct {
+	points []HistoryPoint
+	size   int
+	tiers  []*historyTier
+	//Предел и оценка объема памяти подробных замеров; 0 — без предела
+	maxBytes int
+	bytes    int
+	//Сегменты на диске для вытесненных замеров или nil, если они отбрасываются
+	spill *historySpill
+}
+
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &History{size: size}
+}
+
+// newHistoryPoint сохраняет из замера только данные, нужные истории
+func newHistoryPoint(sample Sample) HistoryPoint {
+	processes := make([]ProcessInfo, len(sample.Processes))
+	for i, process := range sample.Processes {
+		processes[i] = ProcessInfo{PID: process.PID, Name: process.Name, MemoryUsage: process.MemoryUsage}
+	}
+	return HistoryPoint{Time: sample.Time, System: sample.System, Processes: processes}
+}
+
+// Add добавляет замер в историю, вытесняя самые старые записи, и учитывает его
+// в прореженных уровнях
+func (h *History) Add(sample Sample) {
+	point := newHistoryPoint(sample)
+	h.points = append(h.points, point)
+	h.bytes += historyPointSize(point)
+	h.evict()
+	for _, tier := range h.tiers {
+		tier.add(point)
+	}
+}
+
+// Points возвращает все хранимые подробные замеры в хронологическом порядке
+func (h *History) Points() []HistoryPoint {
+	return h.points
+}
+
+// Restore заполняет историю замерами, сохраненными до перезапуска
+func (h *History) Restore(points []HistoryPoint) {
+	h.points = append(h.points[:0], points...)
+	h.bytes = 0
+	for _, point := range h.points {
+		h.bytes += historyPointSize(point)
+	}
+	h.evict()
+}
+
+// evict вытесняет самые старые замеры сверх числа size и предела памяти maxBytes
+// в сегменты на диске, если они включены
+func (h *History) evict() {
+	for len(h.points) > h.size || h.maxBytes > 0 && h.bytes > h.maxBytes && len(h.points) > 1 {
+		point := h.points[0]
+		h.points = h.points[1:]
+		size := historyPointSize(point)
+		h.bytes -= size
+		if h.spill != nil {
+			h.spill.add(point, size)
+		}
+	}
+}
+
+// At возвращает последний замер, сделанный не позже момента t; для моментов раньше
+// подробных замеров — среднее из прореженного уровня
+func (h *History) At(t time.Time) (HistoryPoint, bool) {
+	return h.Snapshot().At(t)
+}
+
+// Since возвращает замеры, сделанные не раньше момента t, в хронологическом порядке
+func (h *History) Since(t time.Time) []HistoryPoint {
+	if h == nil {
+		return nil
+	}
+	for i, point := range h.points {
+		if !point.Time.Before(t) {
+			return h.points[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const (
+	//Число вытесненных замеров в одном сегменте на диске
+	spillSegmentPoints = 300
+	//Срок хранения сегментов по умолчанию
+	defaultSpillRetention = 24 * time.Hour
+	//Объем вытесненных замеров, копящихся в памяти до записи сегмента, если предел памяти не задан
+	defaultSpillPendingBytes = 4 << 20
+)
+
+// historyPointSize оценивает объем памяти, занимаемый точкой истории
+func historyPointSize(point HistoryPoint) int {
+	size := int(unsafe.Sizeof(point)) + len(point.Processes)*int(unsafe.Sizeof(ProcessInfo{}))
+	for _, process := range point.Processes {
+		size += len(process.Name)
+	}
+	return size
+}
+
+// spillSegment — файл с вытесненными замерами за период от first до last
+type spillSegment struct {
+	path        string
+	first, last time.Time
+}
+
+// historySpill записывает замеры, вытесненные из памяти, в сегменты на диске и читает их
+// обратно, когда нужны старые данные
+type historySpill struct {
+	dir       string
+	retention time.Duration
+	//Вытесненные замеры, которые еще не записаны в сегмент
+	pending      []HistoryPoint
+	pendingBytes int
+	flushBytes   int
+	segments     []spillSegment
+	err          error
+
+	//Последний прочитанный сегмент: запросы delta и rate обращаются к одному и тому же
+	//сегменту на каждом замере
+	mu     sync.Mutex
+	cached string
+	points []HistoryPoint
+}
+
+// openHistorySpill открывает каталог сегментов, удаляя устаревшие; сегменты, записанные
+// до перезапуска, остаются доступны
+func openHistorySpill(dir string, retention time.Duration, flushBytes int) (*historySpill, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Не удалось создать каталог history.spill_dir: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &historySpill{dir: dir, retention: retention, flushBytes: flushBytes}
+	for _, entry := range entries {
+		if segment, ok := parseSpillSegment(filepath.Join(dir, entry.Name())); ok {
+			s.segments = append(s.segments, segment)
+		}
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].first.Before(s.segments[j].first) })
+	s.prune(time.Now())
+	return s, nil
+}
+
+// parseSpillSegment разбирает имя сегмента history-<первый замер>-<последний замер>.jsonl.gz
+// (моменты в миллисекундах Unix)
+func parseSpillSegment(path string) (spillSegment, bool) {
+	name, ok := strings.CutPrefix(filepath.Base(path), "history-")
+	if !ok {
+		return spillSegment{}, false
+	}
+	name, ok = strings.CutSuffix(name, ".jsonl.gz")
+	if !ok {
+		return spillSegment{}, false
+	}
+	firstText, lastText, ok := strings.Cut(name, "-")
+	if !ok {
+		return spillSegment{}, false
+	}
+	first, err1 := strconv.ParseInt(firstText, 10, 64)
+	last, err2 := strconv.ParseInt(lastText, 10, 64)
+	if err1 != nil || err2 != nil {
+		return spillSegment{}, false
+	}
+	return spillSegment{path: path, first: time.UnixMilli(first), last: time.UnixMilli(last)}, true
+}
+
+// add принимает вытесненный замер и записывает сегмент, когда их накопилось достаточно
+func (s *historySpill) add(point HistoryPoint, size int) {
+	s.pending = append(s.pending, point)
+	s.pendingBytes += size
+	if len(s.pending) >= spillSegmentPoints || s.pendingBytes >= s.flushBytes {
+		s.flush()
+	}
+}
+
+// flush записывает накопленные замеры в новый сегмент. Если записать не удалось, замеры
+// отбрасываются, чтобы не расходовать память, а ошибка возвращается History.SpillError
+func (s *historySpill) flush() {
+	if len(s.pending) == 0 {
+		return
+	}
+	first, last := s.pending[0].Time, s.pending[len(s.pending)-1].Time
+	path := filepath.Join(s.dir, fmt.Sprintf("history-%d-%d.jsonl.gz", first.UnixMilli(), last.UnixMilli()))
+	if err := writeSpillSegment(path, s.pending); err != nil {
+		s.err = fmt.Errorf("Не удалось записать сегмент истории %s: %v", path, err)
+	} else {
+		s.segments = append(s.segments, spillSegment{path: path, first: first, last: last})
+	}
+	s.pending, s.pendingBytes = nil, 0
+	s.prune(last)
+}
+
+// prune удаляет сегменты, последний замер которых старше срока хранения
+func (s *historySpill) prune(now time.Time) {
+	cutoff := now.Add(-s.retention)
+	i := 0
+	for ; i < len(s.segments) && s.segments[i].last.Before(cutoff); i++ {
+		os.Remove(s.segments[i].path)
+	}
+	s.segments = s.segments[i:]
+}
+
+// writeSpillSegment записывает замеры в сжатый файл JSON Lines через временный файл,
+// поэтому читатели видят только целые сегменты
+func writeSpillSegment(path string, points []HistoryPoint) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".segment-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	gz := gzip.NewWriter(tmp)
+	encoder := json.NewEncoder(gz)
+	for _, point := range points {
+		if err := encoder.Encode(point); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readSpillSegment читает замеры сегмента
+func readSpillSegment(path string) ([]HistoryPoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	var points []HistoryPoint
+	decoder := json.NewDecoder(gz)
+	for decoder.More() {
+		var point HistoryPoint
+		if err := decoder.Decode(&point); err != nil {
+			return points, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// load читает сегмент, запоминая его для следующих обращений
+func (s *historySpill) load(segment spillSegment) ([]HistoryPoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached == segment.path {
+		return s.points, nil
+	}
+	points, err := readSpillSegment(segment.path)
+	if err != nil {
+		return nil, err
+	}
+	s.cached, s.points = segment.path, points
+	return points, nil
+}
+
+// SpillError возвращает и сбрасывает ошибку записи сегментов истории на диск
+func (h *History) SpillError() error {
+	if h.spill == nil {
+		return nil
+	}
+	err := h.spill.err
+	h.spill.err = nil
+	return err
+}
+
+// Close записывает на диск вытесненные замеры, еще не попавшие в сегмент
+func (h *History) Close() error {
+	if h.spill == nil {
+		return nil
+	}
+	h.spill.flush()
+	return h.SpillError()
+}
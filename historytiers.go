@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultTierProcesses — число процессов с наибольшей средней памятью в каждой прореженной точке
+const defaultTierProcesses = 20
+
+// HistoryTier — уровень прореживания истории: средние значения за Resolution,
+// которые хранятся Retention
+type HistoryTier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// defaultHistoryTiers — подробные замеры дополняются минутными средними за сутки
+// и десятиминутными за две недели
+var defaultHistoryTiers = []HistoryTier{
+	{Resolution: time.Minute, Retention: 24 * time.Hour},
+	{Resolution: 10 * time.Minute, Retention: 14 * 24 * time.Hour},
+}
+
+// HistoryConfig — раздел history конфигурации
+type HistoryConfig struct {
+	//Число хранимых подробных замеров (по умолчанию 1200)
+	Points int `json:"points"`
+	//Уровни прореживания, например [{"resolution": "1m", "retention": "24h"}];
+	//пустой список отключает прореживание
+	Tiers []HistoryTierConfig `json:"tiers"`
+	//Число процессов в прореженных точках (по умолчанию 20)
+	TopProcesses int `json:"top_processes"`
+	//Предел памяти подробных замеров, например "64MB"; при превышении самые старые вытесняются
+	MaxMemory string `json:"max_memory"`
+	//Каталог сегментов с вытесненными замерами; без него вытесненные замеры отбрасываются
+	SpillDir string `json:"spill_dir"`
+	//Срок хранения сегментов, например "72h" (по умолчанию 24h)
+	SpillRetention string `json:"spill_retention"`
+}
+
+type HistoryTierConfig struct {
+	Resolution string `json:"resolution"`
+	Retention  string `json:"retention"`
+}
+
+// HistoryTierPoints — прореженные точки одного уровня в файле состояния
+type HistoryTierPoints struct {
+	Resolution string         `json:"resolution"`
+	Points     []HistoryPoint `json:"points"`
+}
+
+// NewHistoryFromConfig создает историю с размером и уровнями прореживания из конфигурации
+func NewHistoryFromConfig(config HistoryConfig) (*History, error) {
+	h := NewHistory(config.Points)
+	tiers := defaultHistoryTiers
+	if config.Tiers != nil {
+		tiers = nil
+		for _, tier := range config.Tiers {
+			resolution, err := time.ParseDuration(tier.Resolution)
+			if err != nil || resolution <= 0 {
+				return nil, fmt.Errorf("Неверное разрешение history.tiers %q", tier.Resolution)
+			}
+			retention, err := time.ParseDuration(tier.Retention)
+			if err != nil || retention < resolution {
+				return nil, fmt.Errorf("Неверный срок хранения history.tiers %q: он должен быть не меньше разрешения", tier.Retention)
+			}
+			tiers = append(tiers, HistoryTier{Resolution: resolution, Retention: retention})
+		}
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Resolution < tiers[j].Resolution })
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].Resolution == tiers[i-1].Resolution {
+			return nil, fmt.Errorf("Два уровня history.tiers с разрешением %s", tiers[i].Resolution)
+		}
+	}
+	top := config.TopProcesses
+	if top <= 0 {
+		top = defaultTierProcesses
+	}
+	for _, tier := range tiers {
+		h.tiers = append(h.tiers, &historyTier{HistoryTier: tier, top: top})
+	}
+	if config.MaxMemory != "" {
+		maxBytes, err := parseByteSize(config.MaxMemory)
+		if err != nil || maxBytes == 0 {
+			return nil, fmt.Errorf("Неверный предел памяти history.max_memory %q", config.MaxMemory)
+		}
+		h.maxBytes = int(maxBytes)
+	}
+	if config.SpillDir != "" {
+		retention := defaultSpillRetention
+		if config.SpillRetention != "" {
+			var err error
+			retention, err = time.ParseDuration(config.SpillRetention)
+			if err != nil || retention <= 0 {
+				return nil, fmt.Errorf("Неверный срок хранения history.spill_retention %q", config.SpillRetention)
+			}
+		}
+		// Вытесненные замеры копятся в памяти не дольше, чем занимает четверть предела
+		flushBytes := defaultSpillPendingBytes
+		if h.maxBytes > 0 {
+			flushBytes = h.maxBytes / 4
+		}
+		spill, err := openHistorySpill(config.SpillDir, retention, flushBytes)
+		if err != nil {
+			return nil, err
+		}
+		h.spill = spill
+	}
+	return h, nil
+}
+
+// historyTier накапливает замеры текущего интервала и хранит их средние
+type historyTier struct {
+	HistoryTier
+	top    int
+	points []HistoryPoint
+	bucket historyBucket
+}
+
+// historyBucket — суммы замеров, попавших в один интервал уровня
+type historyBucket struct {
+	start     time.Time
+	count     uint64
+	system    SystemMemoryInfo
+	processes map[int]*bucketProcess
+}
+
+type bucketProcess struct {
+	name   string
+	memory uint64
+}
+
+// add учитывает точку истории; когда точка попадает в следующий интервал, средние
+// законченного интервала добавляются к точкам уровня
+func (t *historyTier) add(point HistoryPoint) {
+	start := point.Time.Truncate(t.Resolution)
+	if t.bucket.count > 0 && !start.Equal(t.bucket.start) {
+		t.flush()
+	}
+	if t.bucket.count == 0 {
+		t.bucket = historyBucket{start: start, processes: make(map[int]*bucketProcess)}
+	}
+	b := &t.bucket
+	b.count++
+	b.system.TotalMemory += point.System.TotalMemory
+	b.system.FreeMemory += point.System.FreeMemory
+	b.system.AvailableMemory += point.System.AvailableMemory
+	b.system.SwapTotal += point.System.SwapTotal
+	b.system.SwapFree += point.System.SwapFree
+	for _, process := range point.Processes {
+		p, ok := b.processes[process.PID]
+		if !ok {
+			p = &bucketProcess{name: process.Name}
+			b.processes[process.PID] = p
+		}
+		p.memory += process.MemoryUsage
+	}
+}
+
+// flush добавляет средние накопленного интервала и удаляет точки старше срока хранения.
+// Память процесса усредняется по всем замерам интервала, поэтому процесс, проживший
+// часть интервала, учитывается пропорционально времени жизни
+func (t *historyTier) flush() {
+	b := t.bucket
+	point := HistoryPoint{Time: b.start, System: SystemMemoryInfo{
+		TotalMemory:     b.system.TotalMemory / b.count,
+		FreeMemory:      b.system.FreeMemory / b.count,
+		AvailableMemory: b.system.AvailableMemory / b.count,
+		SwapTotal:       b.system.SwapTotal / b.count,
+		SwapFree:        b.system.SwapFree / b.count,
+	}}
+	for pid, process := range b.processes {
+		point.Processes = append(point.Processes, ProcessInfo{PID: pid, Name: process.name, MemoryUsage: process.memory / b.count})
+	}
+	sort.Slice(point.Processes, func(i, j int) bool { return point.Processes[i].MemoryUsage > point.Processes[j].MemoryUsage })
+	if len(point.Processes) > t.top {
+		point.Processes = point.Processes[:t.top]
+	}
+	t.bucket = historyBucket{}
+	t.points = append(t.points, point)
+	t.trim(point.Time)
+}
+
+// trim удаляет точки старше срока хранения относительно момента now
+func (t *historyTier) trim(now time.Time) {
+	cutoff := now.Add(-t.Retention)
+	i := sort.Search(len(t.points), func(i int) bool { return t.points[i].Time.After(cutoff) })
+	t.points = t.points[i:]
+}
+
+// Tiers возвращает прореженные точки всех уровней для файла состояния
+func (h *History) Tiers() []HistoryTierPoints {
+	var tiers []HistoryTierPoints
+	for _, tier := range h.tiers {
+		if len(tier.points) > 0 {
+			tiers = append(tiers, HistoryTierPoints{Resolution: tier.Resolution.String(), Points: tier.points})
+		}
+	}
+	return tiers
+}
+
+// RestoreTiers заполняет уровни точками, сохраненными до перезапуска; точки уровней,
+// которых больше нет в конфигурации, отбрасываются
+func (h *History) RestoreTiers(saved []HistoryTierPoints) {
+	for _, tier := range h.tiers {
+		for _, points := range saved {
+			if resolution, err := time.ParseDuration(points.Resolution); err == nil && resolution == tier.Resolution && len(points.Points) > 0 {
+				tier.points = append(tier.points[:0], points.Points...)
+				tier.trim(time.Now())
+			}
+		}
+	}
+}
+
+// HistorySnapshot — снимок истории: подробные замеры в памяти, вытесненные на диск
+// и прореженные уровни. Точки не изменяются после добавления, а сегменты на диске —
+// после записи, поэтому снимок можно читать из другой горутины, пока история пополняется
+type HistorySnapshot struct {
+	points   []HistoryPoint
+	pending  []HistoryPoint
+	segments []spillSegment
+	spill    *historySpill
+	//Прореженные уровни от подробного к грубому
+	tiers []historyTierSnapshot
+}
+
+type historyTierSnapshot struct {
+	resolution time.Duration
+	points     []HistoryPoint
+}
+
+// Snapshot возвращает снимок всех уровней истории без копирования точек
+func (h *History) Snapshot() HistorySnapshot {
+	if h == nil {
+		return HistorySnapshot{}
+	}
+	snapshot := HistorySnapshot{points: h.points}
+	if h.spill != nil {
+		snapshot.pending, snapshot.segments, snapshot.spill = h.spill.pending, h.spill.segments, h.spill
+	}
+	for _, tier := range h.tiers {
+		snapshot.tiers = append(snapshot.tiers, historyTierSnapshot{resolution: tier.Resolution, points: tier.points})
+	}
+	return snapshot
+}
+
+// Latest возвращает последний замер
+func (s HistorySnapshot) Latest() (HistoryPoint, bool) {
+	if len(s.points) == 0 {
+		return HistoryPoint{}, false
+	}
+	return s.points[len(s.points)-1], true
+}
+
+// Range возвращает точки за период в хронологическом порядке: для каждого момента берутся
+// самые подробные из сохранившихся точек, а более грубые уровни продолжают их в прошлое.
+// Сегменты с диска читаются, только если подробные точки нужны: когда даже первый
+// прореженный уровень дает за период не меньше maxPoints точек, они не читаются.
+// Нулевая граница не ограничивает период
+func (s HistorySnapshot) Range(from, to time.Time, maxPoints int) []HistoryPoint {
+	levels := [][]HistoryPoint{s.points, s.pending}
+	if s.spill != nil && !s.coarseEnough(from, to, maxPoints) {
+		var spilled []HistoryPoint
+		for _, segment := range s.segments {
+			if segment.last.Before(from) || !to.IsZero() && segment.first.After(to) {
+				continue
+			}
+			// Удаленный за это время сегмент просто пропускается
+			if points, err := readSpillSegment(segment.path); err == nil {
+				spilled = append(spilled, points...)
+			}
+		}
+		levels = append(levels, spilled)
+	}
+	for _, tier := range s.tiers {
+		levels = append(levels, tier.points)
+	}
+	return historyRange(mergeHistoryLevels(levels), from, to)
+}
+
+// coarseEnough сообщает, что первый прореженный уровень дает за период не меньше maxPoints
+// точек; запрос всей истории всегда обходится прореженными уровнями, если они есть
+func (s HistorySnapshot) coarseEnough(from, to time.Time, maxPoints int) bool {
+	switch {
+	case len(s.tiers) == 0:
+		return false
+	case from.IsZero():
+		return true
+	case maxPoints <= 0:
+		return false
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	return int(to.Sub(from)/s.tiers[0].resolution) >= maxPoints
+}
+
+// mergeHistoryLevels сводит уровни от подробного к грубому в одну хронологическую
+// последовательность: точки каждого следующего уровня берутся только до начала предыдущих
+func mergeHistoryLevels(levels [][]HistoryPoint) []HistoryPoint {
+	var merged []HistoryPoint
+	for _, points := range levels {
+		if len(merged) > 0 {
+			cutoff := merged[0].Time
+			i := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(cutoff) })
+			points = points[:i]
+		}
+		if len(points) > 0 {
+			merged = append(append([]HistoryPoint(nil), points...), merged...)
+		}
+	}
+	return merged
+}
+
+// At возвращает последнюю точку не позже момента t: сначала среди подробных замеров
+// в памяти, затем в сегментах на диске и, наконец, в прореженных уровнях
+func (s HistorySnapshot) At(t time.Time) (HistoryPoint, bool) {
+	for _, points := range [][]HistoryPoint{s.points, s.pending} {
+		if point, ok := lastPointAt(points, t); ok {
+			return point, true
+		}
+	}
+	// Последний сегмент, начатый не позже t
+	i := sort.Search(len(s.segments), func(i int) bool { return s.segments[i].first.After(t) })
+	if i > 0 {
+		if points, err := s.spill.load(s.segments[i-1]); err == nil {
+			if point, ok := lastPointAt(points, t); ok {
+				return point, true
+			}
+		}
+	}
+	for _, tier := range s.tiers {
+		if point, ok := lastPointAt(tier.points, t); ok {
+			return point, true
+		}
+	}
+	return HistoryPoint{}, false
+}
+
+// lastPointAt возвращает последнюю из упорядоченных по времени точек не позже момента t
+func lastPointAt(points []HistoryPoint, t time.Time) (HistoryPoint, bool) {
+	i := sort.Search(len(points), func(i int) bool { return points[i].Time.After(t) })
+	if i == 0 {
+		return HistoryPoint{}, false
+	}
+	return points[i-1], true
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Счетчики страниц читаются вызовом host_statistics64 из libSystem, как их читает сам
+// vm_stat, вместо запуска vm_stat на каждом обновлении. Как и golang.org/x/sys/unix,
+// функции libSystem вызываются через трамплины на ассемблере (hoststat_darwin_*.s)
+// и syscall.syscall6, который переключает горутину в системный вызов
+
+const (
+	//Вид сведений host_statistics64: struct vm_statistics64 (HOST_VM_INFO64)
+	hostVMInfo64 = 4
+	//Размер struct vm_statistics64 в 4-байтных словах (HOST_VM_INFO64_COUNT)
+	hostVMInfo64Count = 38
+)
+
+// vmStatistics64 — struct vm_statistics64 (<mach/vm_statistics.h>), значения в страницах
+type vmStatistics64 struct {
+	FreeCount                          uint32
+	ActiveCount                        uint32
+	InactiveCount                      uint32
+	WireCount                          uint32
+	ZeroFillCount                      uint64
+	Reactivations                      uint64
+	Pageins                            uint64
+	Pageouts                           uint64
+	Faults                             uint64
+	CowFaults                          uint64
+	Lookups                            uint64
+	Hits                               uint64
+	Purges                             uint64
+	PurgeableCount                     uint32
+	SpeculativeCount                   uint32
+	Decompressions                     uint64
+	Compressions                       uint64
+	Swapins                            uint64
+	Swapouts                           uint64
+	CompressorPageCount                uint32
+	ThrottledCount                     uint32
+	ExternalPageCount                  uint32
+	InternalPageCount                  uint32
+	TotalUncompressedPagesInCompressor uint64
+}
+
+//go:cgo_import_dynamic libc_mach_host_self mach_host_self "/usr/lib/libSystem.B.dylib"
+//go:cgo_import_dynamic libc_host_statistics64 host_statistics64 "/usr/lib/libSystem.B.dylib"
+
+var machHostSelfTrampolineAddr uintptr
+var hostStatistics64TrampolineAddr uintptr
+
+//go:linkname syscall_syscall6 syscall.syscall6
+func syscall_syscall6(fn, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2 uintptr, err syscall.Errno)
+
+// hostPort — порт хоста Mach. mach_host_self добавляет ссылку на порт при каждом
+// вызове, поэтому порт запрашивается один раз
+var hostPort = struct {
+	once sync.Once
+	port uintptr
+}{}
+
+// machHostSelf возвращает порт хоста Mach
+func machHostSelf() uintptr {
+	hostPort.once.Do(func() {
+		hostPort.port, _, _ = syscall_syscall6(machHostSelfTrampolineAddr, 0, 0, 0, 0, 0, 0)
+	})
+	return hostPort.port
+}
+
+// hostVMStatistics возвращает счетчики страниц host_statistics64 под теми же ключами,
+// что parseVmStat: "free", "wired down", "File-backed pages", "Swapins" и другие.
+// Свободные страницы, как и в vm_stat, считаются без спекулятивных
+func hostVMStatistics() (map[string]uint64, error) {
+	var stats vmStatistics64
+	count := uint32(hostVMInfo64Count)
+	kr, _, _ := syscall_syscall6(hostStatistics64TrampolineAddr, machHostSelf(), hostVMInfo64,
+		uintptr(unsafe.Pointer(&stats)), uintptr(unsafe.Pointer(&count)), 0, 0)
+	if kr != 0 {
+		return nil, fmt.Errorf("host_statistics64: kern_return_t %d", int32(kr))
+	}
+	if count < hostVMInfo64Count {
+		return nil, fmt.Errorf("%w host_statistics64: %d слов из %d", ErrParse, count, hostVMInfo64Count)
+	}
+	return map[string]uint64{
+		"free":                   uint64(stats.FreeCount - min(stats.SpeculativeCount, stats.FreeCount)),
+		"active":                 uint64(stats.ActiveCount),
+		"inactive":               uint64(stats.InactiveCount),
+		"speculative":            uint64(stats.SpeculativeCount),
+		"throttled":              uint64(stats.ThrottledCount),
+		"wired down":             uint64(stats.WireCount),
+		"purgeable":              uint64(stats.PurgeableCount),
+		"File-backed pages":      uint64(stats.ExternalPageCount),
+		"Anonymous pages":        uint64(stats.InternalPageCount),
+		"stored in compressor":   stats.TotalUncompressedPagesInCompressor,
+		"occupied by compressor": uint64(stats.CompressorPageCount),
+		"Pageins":                stats.Pageins,
+		"Pageouts":               stats.Pageouts,
+		"Swapins":                stats.Swapins,
+		"Swapouts":               stats.Swapouts,
+	}, nil
+}
@@ -0,0 +1,13 @@
+// Трамплины к функциям libSystem для hoststat_darwin.go
+
+#include "textflag.h"
+
+TEXT libc_mach_host_self_trampoline<>(SB),NOSPLIT,$0-0
+	JMP	libc_mach_host_self(SB)
+GLOBL	·machHostSelfTrampolineAddr(SB), RODATA, $8
+DATA	·machHostSelfTrampolineAddr(SB)/8, $libc_mach_host_self_trampoline<>(SB)
+
+TEXT libc_host_statistics64_trampoline<>(SB),NOSPLIT,$0-0
+	JMP	libc_host_statistics64(SB)
+GLOBL	·hostStatistics64TrampolineAddr(SB), RODATA, $8
+DATA	·hostStatistics64TrampolineAddr(SB)/8, $libc_host_statistics64_trampoline<>(SB)
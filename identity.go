@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// IdentityConfig — раздел identity конфигурации: как машина называет себя в
+// экспортируемых данных
+type IdentityConfig struct {
+	//Имя хоста вместо определенного системой, например имя узла вместо идентификатора контейнера
+	Hostname string `json:"hostname"`
+	//Метки всех экспортируемых замеров, метрик и уведомлений, например {"cluster": "prod"}
+	Labels map[string]string `json:"labels"`
+}
+
+// exportIdentity — имя хоста и метки из конфигурации и флагов --hostname и --label
+var exportIdentity IdentityConfig
+
+// configureIdentity задает имя хоста и метки экспортируемых данных; флаги дополняют
+// раздел identity конфигурации и заменяют совпадающие с ним значения
+func configureIdentity(config IdentityConfig, hostname string, labels []string) error {
+	identity := IdentityConfig{Hostname: config.Hostname, Labels: make(map[string]string)}
+	if hostname != "" {
+		identity.Hostname = hostname
+	}
+	for name, value := range config.Labels {
+		identity.Labels[name] = value
+	}
+	for _, label := range labels {
+		name, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return fmt.Errorf("Неверная метка %q: ожидается имя=значение", label)
+		}
+		identity.Labels[strings.TrimSpace(name)] = value
+	}
+	for name := range identity.Labels {
+		if name == "__name__" || name == "host" || !validPromLabelName(name) {
+			return fmt.Errorf("Неверное имя метки %q", name)
+		}
+	}
+	if len(identity.Labels) == 0 {
+		identity.Labels = nil
+	}
+	exportIdentity = identity
+	return nil
+}
+
+// exportHostname возвращает имя хоста для экспортируемых данных
+func exportHostname() string {
+	if exportIdentity.Hostname != "" {
+		return exportIdentity.Hostname
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// exportLabels возвращает метки, добавляемые к каждой метрике Prometheus: метки
+// из конфигурации и host, если имя хоста задано явно. Определенное системой имя
+// не добавляется, так как Prometheus сам ставит метку instance опрашиваемого адреса
+func exportLabels() map[string]string {
+	if exportIdentity.Hostname == "" {
+		return exportIdentity.Labels
+	}
+	labels := map[string]string{"host": exportIdentity.Hostname}
+	for name, value := range exportIdentity.Labels {
+		labels[name] = value
+	}
+	return labels
+}
+
+// stampIdentity записывает в замер имя хоста и метки, чтобы записи разных машин
+// в общем хранилище различались
+func stampIdentity(sample *Sample) {
+	sample.Host = exportHostname()
+	sample.Labels = exportIdentity.Labels
+}
+
+// formatIdentityLabels перечисляет метки через запятую в порядке имен: "cluster=prod,zone=a"
+func formatIdentityLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// IgnoreList — процессы, скрываемые из таблицы как не относящиеся к делу:
+// потоки ядра, kworker, командная оболочка и сам анализатор
+type IgnoreList struct {
+	//Регулярные выражения для имени процесса
+	Patterns []*regexp.Regexp
+	//PID, скрываемые независимо от имени (анализатор и запустившая его оболочка)
+	PIDs map[int]bool
+	//Показывать под таблицей сводную строку скрытых процессов
+	ShowHidden bool
+}
+
+// NewIgnoreList компилирует регулярные выражения списка; withSelf добавляет
+// в список сам анализатор и его родительский процесс (обычно командную оболочку)
+func NewIgnoreList(patterns []string, withSelf, showHidden bool) (*IgnoreList, error) {
+	if len(patterns) == 0 && !withSelf {
+		return nil, nil
+	}
+	list := &IgnoreList{PIDs: make(map[int]bool), ShowHidden: showHidden}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Неверное регулярное выражение %q в списке игнорирования: %v", pattern, err)
+		}
+		list.Patterns = append(list.Patterns, re)
+	}
+	if withSelf {
+		list.PIDs[os.Getpid()] = true
+		list.PIDs[os.Getppid()] = true
+	}
+	return list, nil
+}
+
+// Ignores сообщает, скрывается ли процесс из таблицы
+func (l *IgnoreList) Ignores(process ProcessInfo) bool {
+	if l == nil {
+		return false
+	}
+	if l.PIDs[process.PID] {
+		return true
+	}
+	for _, re := range l.Patterns {
+		if re.MatchString(process.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenSummary считает число и суммарную память скрытых списком процессов
+func (l *IgnoreList) hiddenSummary(processes []ProcessInfo) (int, uint64) {
+	count, memory := 0, uint64(0)
+	for _, process := range processes {
+		if l.Ignores(process) {
+			count++
+			memory += process.MemoryUsage
+		}
+	}
+	return count, memory
+}
+
+// FormatHidden возвращает сводную строку скрытых процессов, если она включена
+func FormatHidden(processes []ProcessInfo, config DisplayConfig) string {
+	if config.Ignore == nil || !config.Ignore.ShowHidden {
+		return ""
+	}
+	count, memory := config.Ignore.hiddenSummary(processes)
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Hidden (%d procs): %s\n", count, FormatMemorySize(memory))
+}
+
+// patternList реализует flag.Value для повторяемых флагов с регулярными выражениями;
+// в отличие от stringList значение не делится по запятым, которые встречаются в выражениях
+type patternList []string
+
+func (l *patternList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *patternList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
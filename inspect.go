@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProcessDetails — подробная информация о процессе для окна просмотра
+type ProcessDetails struct {
+	PID        int    `json:"pid"`
+	Executable string `json:"executable,omitempty"`
+	Cwd        string `json:"cwd,omitempty"`
+	Cmdline    string `json:"cmdline,omitempty"`
+	EnvVars    int    `json:"env_vars"`
+	EnvSize    uint64 `json:"env_bytes"`
+}
+
+// DetailReader реализуют источники данных, умеющие читать подробности о процессе
+//
+// Интерфейс необязательный: для источников без этой возможности окно просмотра
+// показывает только данные из таблицы
+type DetailReader interface {
+	//ReadProcessDetails возвращает путь к исполняемому файлу, рабочий каталог,
+	//командную строку и размер окружения процесса
+	//
+	//Поля, которые не удалось прочитать (например, из-за прав доступа), остаются пустыми
+	ReadProcessDetails(pid int) (ProcessDetails, error)
+}
+
+func (l *LinuxMemoryReader) ReadProcessDetails(pid int) (ProcessDetails, error) {
+	details := ProcessDetails{PID: pid}
+	procDir := l.procPath(pid)
+	if _, err := os.Stat(procDir); err != nil {
+		return details, processError(pid, err)
+	}
+	details.Executable, _ = os.Readlink(filepath.Join(procDir, "exe"))
+	details.Cwd, _ = os.Readlink(filepath.Join(procDir, "cwd"))
+	if cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline")); err == nil {
+		details.Cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+	if environ, err := os.ReadFile(filepath.Join(procDir, "environ")); err == nil {
+		details.EnvSize = uint64(len(environ))
+		details.EnvVars = bytes.Count(environ, []byte{0})
+	}
+	return details, nil
+}
+
+func (d *DarwinMemoryReader) ReadProcessDetails(pid int) (ProcessDetails, error) {
+	details := ProcessDetails{PID: pid}
+	pidStr := strconv.Itoa(pid)
+	output, err := exec.Command(psPath, "-ww", "-p", pidStr, "-o", "command=").Output()
+	if err != nil {
+		// Без ps командная строка и окружение недоступны, но путь к исполняемому файлу
+		// ядро отдает через proc_pidinfo
+		if path, pathErr := procExecutablePath(pid); pathErr == nil && path != "" {
+			details.Executable = path
+			return details, nil
+		}
+		return details, commandProcessError(pid, err)
+	}
+	details.Cmdline = strings.TrimSpace(string(output))
+	if output, err := exec.Command(psPath, "-p", pidStr, "-o", "comm=").Output(); err == nil {
+		details.Executable = strings.TrimSpace(string(output))
+	}
+	if output, err := exec.Command("lsof", "-a", "-p", pidStr, "-d", "cwd", "-Fn").Output(); err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(line, "n") {
+				details.Cwd = strings.TrimPrefix(line, "n")
+			}
+		}
+	}
+	// ps -E дописывает окружение после командной строки
+	if output, err := exec.Command(psPath, "-E", "-ww", "-p", pidStr, "-o", "command=").Output(); err == nil {
+		env := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(output)), details.Cmdline))
+		if env != "" {
+			details.EnvSize = uint64(len(env))
+			details.EnvVars = len(strings.Fields(env))
+		}
+	}
+	return details, nil
+}
+
+// FormatProcessDetails форматирует окно просмотра выбранного процесса
+func FormatProcessDetails(process ProcessInfo, details *ProcessDetails) string {
+	var res strings.Builder
+	res.WriteString(fmt.Sprintf("Process %d (%s):\n", process.PID, process.Name))
+	res.WriteString(fmt.Sprintf("  Memory:     %s\n", FormatMemorySize(process.MemoryUsage)))
+	if details == nil {
+		res.WriteString("  No details available\n")
+		return res.String()
+	}
+	res.WriteString(fmt.Sprintf("  Executable: %s\n", valueOrUnknown(details.Executable)))
+	res.WriteString(fmt.Sprintf("  Cwd:        %s\n", valueOrUnknown(details.Cwd)))
+	res.WriteString(fmt.Sprintf("  Command:    %s\n", valueOrUnknown(shortenCommand(details.Cmdline))))
+	if details.EnvSize > 0 {
+		res.WriteString(fmt.Sprintf("  Env:        %d variables, %s\n", details.EnvVars, FormatMemorySize(details.EnvSize)))
+	} else {
+		res.WriteString("  Env:        unknown\n")
+	}
+	return res.String()
+}
+
+// maxCommandLength ограничивает длину командной строки в окне просмотра
+const maxCommandLength = 200
+
+// shortenCommand сворачивает командную строку в одну строку и обрезает ее до maxCommandLength
+func shortenCommand(cmdline string) string {
+	cmdline = strings.Join(strings.Fields(cmdline), " ")
+	if len(cmdline) > maxCommandLength {
+		cmdline = cmdline[:maxCommandLength-3] + "..."
+	}
+	return cmdline
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown (permission denied?)"
+	}
+	return value
+}
+
+// formatInspectPanel форматирует окно просмотра процесса, открытого в state
+func formatInspectPanel(processes []ProcessInfo, state *ViewState) string {
+	for _, process := range processes {
+		if process.PID == state.InspectPID {
+			return FormatProcessDetails(process, state.Details)
+		}
+	}
+	return fmt.Sprintf("Process %d has exited\n", state.InspectPID)
+}
+
+// inspectProcess читает подробности о процессе, если источник данных это поддерживает
+func inspectProcess(reader MemoryReader, pid int) *ProcessDetails {
+	detailReader, ok := reader.(DetailReader)
+	if !ok {
+		return nil
+	}
+	details, err := detailReader.ReadProcessDetails(pid)
+	if err != nil {
+		return nil
+	}
+	details.Cmdline = activeRedactor.Redact(details.Cmdline)
+	return &details
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loadInventory читает хосты режима fleet из инвентаря: JSON-файла (по расширению .json)
+// или инвентаря Ansible в формате INI
+func loadInventory(path string) ([]FleetHost, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть инвентарь %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var hosts []FleetHost
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var inventory struct {
+			Hosts []FleetHost `json:"hosts"`
+		}
+		if err := json.NewDecoder(file).Decode(&inventory); err != nil {
+			return nil, fmt.Errorf("Неверный формат %s: %v", path, err)
+		}
+		hosts = inventory.Hosts
+	} else if hosts, err = parseAnsibleInventory(file); err != nil {
+		return nil, fmt.Errorf("Неверный формат %s: %v", path, err)
+	}
+
+	for i := range hosts {
+		if hosts[i].Address == "" {
+			hosts[i].Address = hosts[i].Name
+		}
+		if hosts[i].Address == "" {
+			return nil, fmt.Errorf("Хост без адреса в инвентаре %s", path)
+		}
+		if hosts[i].Name == "" {
+			hosts[i].Name = hosts[i].Address
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("Инвентарь %s не содержит хостов", path)
+	}
+	return hosts, nil
+}
+
+// parseAnsibleInventory разбирает инвентарь Ansible в формате INI
+//
+// Поддерживаются секции групп со строками "имя переменная=значение ...", секции [группа:vars]
+// и переменные ansible_host, ansible_user, ansible_port, ansible_ssh_private_key_file,
+// ansible_ssh_common_args и ansible_ssh_extra_args. Хост относится к первой группе, в которой
+// он объявлен; секции [группа:children] пропускаются
+func parseAnsibleInventory(file *os.File) ([]FleetHost, error) {
+	type hostEntry struct {
+		name, group string
+		vars        map[string]string
+	}
+	var entries []hostEntry
+	seen := make(map[string]bool)
+	groupVars := make(map[string]map[string]string)
+
+	section, sectionKind := "", ""
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section, sectionKind = strings.Trim(line, "[]"), ""
+			if i := strings.Index(section, ":"); i >= 0 {
+				section, sectionKind = section[:i], section[i+1:]
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		switch sectionKind {
+		case "children":
+			continue
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("Строка %d: ожидается переменная=значение", lineNo)
+			}
+			if groupVars[section] == nil {
+				groupVars[section] = make(map[string]string)
+			}
+			groupVars[section][strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+			continue
+		}
+		entry := hostEntry{name: fields[0], group: section, vars: make(map[string]string)}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("Строка %d: ожидается переменная=значение: %q", lineNo, field)
+			}
+			entry.vars[key] = strings.Trim(value, `"'`)
+		}
+		if seen[entry.name] {
+			continue
+		}
+		seen[entry.name] = true
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	hosts := make([]FleetHost, 0, len(entries))
+	for _, entry := range entries {
+		vars := make(map[string]string)
+		for key, value := range groupVars["all"] {
+			vars[key] = value
+		}
+		for key, value := range groupVars[entry.group] {
+			vars[key] = value
+		}
+		for key, value := range entry.vars {
+			vars[key] = value
+		}
+		host := FleetHost{Name: entry.name, Address: vars["ansible_host"], User: vars["ansible_user"],
+			IdentityFile: vars["ansible_ssh_private_key_file"]}
+		if entry.group != "all" && entry.group != "ungrouped" {
+			host.Group = entry.group
+		}
+		if port := vars["ansible_port"]; port != "" {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("Неверный ansible_port хоста %s: %q", entry.name, port)
+			}
+			host.Port = p
+		}
+		host.SSHArgs = append(strings.Fields(vars["ansible_ssh_common_args"]), strings.Fields(vars["ansible_ssh_extra_args"])...)
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
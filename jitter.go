@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Jitter — случайное отклонение моментов замеров демона от периода. Когда сотни демонов
+// запускаются или перечитывают конфигурацию одновременно и отправляют замеры одному
+// агрегатору, без отклонения их замеры и сетевые всплески совпадают по времени
+type Jitter struct {
+	//Отклонение в единицах времени ("2s") или в долях периода ("10%"); нулевое — без отклонения
+	fixed    time.Duration
+	fraction float64
+}
+
+// parseJitter разбирает отклонение вида "2s" или "10%"; пустая строка — без отклонения
+func parseJitter(spec string) (Jitter, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Jitter{}, nil
+	}
+	if percent, ok := strings.CutSuffix(spec, "%"); ok {
+		value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || value < 0 || value > 50 {
+			return Jitter{}, fmt.Errorf("Отклонение в процентах должно быть от 0 до 50: %q", spec)
+		}
+		return Jitter{fraction: value / 100}, nil
+	}
+	d, err := time.ParseDuration(spec)
+	if err != nil || d < 0 {
+		return Jitter{}, fmt.Errorf("Неверное отклонение замеров: %q (например \"2s\" или \"10%%\")", spec)
+	}
+	return Jitter{fixed: d}, nil
+}
+
+// spread возвращает наибольшее отклонение для периода; оно не превышает половины периода,
+// чтобы соседние замеры не сливались
+func (j Jitter) spread(period time.Duration) time.Duration {
+	spread := j.fixed
+	if j.fraction > 0 {
+		spread = time.Duration(float64(period) * j.fraction)
+	}
+	if spread > period/2 {
+		spread = period / 2
+	}
+	return spread
+}
+
+// Delay возвращает паузу до следующего замера: период со случайным отклонением в пределах
+// ±spread. Отклонения независимы, поэтому в среднем замеры идут с заданным периодом, а фазы
+// разных демонов расходятся
+func (j Jitter) Delay(period time.Duration) time.Duration {
+	spread := j.spread(period)
+	if spread <= 0 {
+		return period
+	}
+	return period - spread + time.Duration(rand.Int63n(int64(2*spread)+1))
+}
+
+// Splay возвращает случайную задержку первого замера после запуска или перечитывания
+// конфигурации, от нуля до spread
+func (j Jitter) Splay(period time.Duration) time.Duration {
+	spread := j.spread(period)
+	if spread <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(spread) + 1))
+}
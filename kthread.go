@@ -0,0 +1,68 @@
+package main
+
+import "fmt"
+
+// Режимы отображения потоков ядра в таблице процессов
+const (
+	//Потоки ядра показываются в квадратных скобках, как в ps
+	KernelThreadsMark = "mark"
+	//Потоки ядра не показываются
+	KernelThreadsHide = "hide"
+	//Потоки ядра сводятся в одну строку под таблицей
+	KernelThreadsGroup = "group"
+)
+
+// pfKthread — флаг PF_KTHREAD в поле flags файла /proc/[pid]/stat
+const pfKthread = 0x00200000
+
+// kthreaddPID — PID kthreadd, родителя всех потоков ядра в Linux
+const kthreaddPID = 2
+
+// KernelThreadReader реализуют источники данных, умеющие отличать потоки ядра от процессов
+type KernelThreadReader interface {
+	//IsKernelThread сообщает, является ли процесс потоком ядра
+	IsKernelThread(pid int) (bool, error)
+}
+
+// IsKernelThread определяет поток ядра по флагу PF_KTHREAD или по родителю kthreadd
+func (l *LinuxMemoryReader) IsKernelThread(pid int) (bool, error) {
+	if pid == kthreaddPID {
+		return true, nil
+	}
+	stat, err := l.readProcessStat(pid)
+	if err != nil {
+		return false, err
+	}
+	return stat.kernelThread(pid), nil
+}
+
+// isValidKernelThreadsMode проверяет режим отображения потоков ядра
+func isValidKernelThreadsMode(mode string) bool {
+	return mode == KernelThreadsMark || mode == KernelThreadsHide || mode == KernelThreadsGroup
+}
+
+// showsKernelThread сообщает, показывается ли поток ядра отдельной строкой таблицы
+func showsKernelThread(process ProcessInfo, config DisplayConfig) bool {
+	return !process.Kernel || config.KernelThreads == "" || config.KernelThreads == KernelThreadsMark
+}
+
+// FormatKernelThreads возвращает строку со сводкой потоков ядра, сведенных в режиме group
+func FormatKernelThreads(processes []ProcessInfo, config DisplayConfig) string {
+	if config.KernelThreads != KernelThreadsGroup {
+		return ""
+	}
+	count, blocked := 0, 0
+	for _, process := range processes {
+		if !process.Kernel {
+			continue
+		}
+		count++
+		if process.State == StateUninterruptible {
+			blocked++
+		}
+	}
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Kernel threads (%d): %d uninterruptible (D)\n", count, blocked)
+}
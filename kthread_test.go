@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// kthreadProcess — процесс источника данных kthreadReader
+type kthreadProcess struct {
+	name string
+	rss  uint64
+	//В /proc/[pid]/status нет строки VmRSS, как у потоков ядра и зомби
+	noRSS  bool
+	state  string
+	kernel bool
+}
+
+// kthreadReader — источник данных с заданными процессами, который читает память так же,
+// как LinuxMemoryReader: процесс без строки VmRSS возвращает errNoResidentMemory
+type kthreadReader struct {
+	processes map[int]kthreadProcess
+}
+
+func (r *kthreadReader) ReadSystemMemory() (SystemMemoryInfo, error) {
+	return SystemMemoryInfo{}, nil
+}
+
+func (r *kthreadReader) GetProcessList() ([]int, error) {
+	var pids []int
+	for pid := range r.processes {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+func (r *kthreadReader) ReadProcessMemory(pid int) (uint64, error) {
+	if r.processes[pid].noRSS {
+		return 0, fmt.Errorf("%w: VmRSS не найден для PID %d", errNoResidentMemory, pid)
+	}
+	return r.processes[pid].rss, nil
+}
+
+func (r *kthreadReader) ReadProcessName(pid int) (string, error) {
+	return r.processes[pid].name, nil
+}
+
+func (r *kthreadReader) ReadProcessState(pid int) (string, error) {
+	return r.processes[pid].state, nil
+}
+
+func (r *kthreadReader) IsKernelThread(pid int) (bool, error) {
+	return r.processes[pid].kernel, nil
+}
+
+func TestKernelThreadsShown(t *testing.T) {
+	reader := &kthreadReader{processes: map[int]kthreadProcess{
+		1:   {name: "systemd", rss: 11240 << 10, state: "S"},
+		2:   {name: "kthreadd", noRSS: true, state: "S", kernel: true},
+		41:  {name: "kworker/0:1", noRSS: true, state: "I", kernel: true},
+		57:  {name: "jbd2/sda1-8", noRSS: true, state: "D", kernel: true},
+		900: {name: "defunct", noRSS: true, state: "Z"},
+		901: {name: "exiting", noRSS: true, state: "S"},
+	}}
+	processes, err := collectProcesses(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPID := make(map[int]ProcessInfo)
+	for _, process := range processes {
+		byPID[process.PID] = process
+	}
+
+	tests := []struct {
+		name   string
+		pid    int
+		shown  bool
+		kernel bool
+	}{
+		{name: "process", pid: 1, shown: true},
+		{name: "kthreadd", pid: 2, shown: true, kernel: true},
+		{name: "no RSS line", pid: 41, shown: true, kernel: true},
+		{name: "no RSS line in D", pid: 57, shown: true, kernel: true},
+		{name: "zombie", pid: 900, shown: true},
+		{name: "user process without RSS", pid: 901},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process, shown := byPID[tt.pid]
+			if shown != tt.shown {
+				t.Fatalf("процесс %d в списке: %v, ожидалось %v", tt.pid, shown, tt.shown)
+			}
+			if shown && process.Kernel != tt.kernel {
+				t.Errorf("Kernel = %v, ожидалось %v", process.Kernel, tt.kernel)
+			}
+		})
+	}
+
+	t.Run("mark", func(t *testing.T) {
+		config := DisplayConfig{KernelThreads: KernelThreadsMark}
+		table := FormatTable(visibleProcesses(processes, config, &ViewState{}))
+		if !strings.Contains(table, "[kworker/0:1]") {
+			t.Errorf("в таблице нет строки [kworker/0:1]:\n%s", table)
+		}
+		if summary := FormatKernelThreads(processes, config); summary != "" {
+			t.Errorf("сводка в режиме mark: %q", summary)
+		}
+	})
+
+	t.Run("group", func(t *testing.T) {
+		config := DisplayConfig{KernelThreads: KernelThreadsGroup}
+		table := FormatTable(visibleProcesses(processes, config, &ViewState{}))
+		if strings.Contains(table, "kworker") {
+			t.Errorf("поток ядра показан отдельной строкой в режиме group:\n%s", table)
+		}
+		want := "Kernel threads (3): 1 uninterruptible (D)\n"
+		if summary := FormatKernelThreads(processes, config); summary != want {
+			t.Errorf("FormatKernelThreads = %q, ожидалось %q", summary, want)
+		}
+	})
+}
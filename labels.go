@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// labelLookupTimeout ограничивает время HTTP-запроса меток одного процесса
+const labelLookupTimeout = 2 * time.Second
+
+// LabelsConfig описывает источники бизнес-меток процессов (команда, сервис, центр затрат)
+type LabelsConfig struct {
+	//Метки, назначаемые процессам по имени или PID
+	Rules []LabelRule `json:"rules"`
+	//Адрес HTTP-запроса меток с подстановкой {{pid}} и {{name}}; ответ — JSON-объект меток
+	//
+	//Ответы кэшируются по итоговому адресу, поэтому адрес без {{pid}} запрашивается
+	//один раз для всех процессов с одним именем
+	URL string `json:"url"`
+}
+
+// LabelRule назначает метки процессам с указанным именем или PID
+type LabelRule struct {
+	Process string            `json:"process"`
+	PID     int               `json:"pid"`
+	Labels  map[string]string `json:"labels"`
+}
+
+func (r LabelRule) matches(process ProcessInfo) bool {
+	if r.PID != 0 {
+		return r.PID == process.PID
+	}
+	return matchesProcessName(process.Name, r.Process)
+}
+
+// Labeler назначает процессам метки по правилам и HTTP-запросам
+type Labeler struct {
+	config LabelsConfig
+	client *http.Client
+	cache  map[string]map[string]string
+	//Ключи меток, встречавшиеся у процессов, для колонок и режимов группировки
+	keys map[string]bool
+}
+
+// processLabeler — источник меток из конфигурации или nil, если метки не настроены
+var processLabeler *Labeler
+
+// configureLabels проверяет настройки меток и включает их назначение при сборе замеров
+func configureLabels(config LabelsConfig) error {
+	if len(config.Rules) == 0 && config.URL == "" {
+		processLabeler = nil
+		return nil
+	}
+	for _, rule := range config.Rules {
+		if rule.Process == "" && rule.PID == 0 {
+			return fmt.Errorf("Правило меток без process и pid")
+		}
+	}
+	if config.URL != "" {
+		if _, err := url.Parse(expandLabelURL(config.URL, ProcessInfo{})); err != nil {
+			return fmt.Errorf("Неверный адрес меток %q: %v", config.URL, err)
+		}
+	}
+	labeler := &Labeler{
+		config: config,
+		client: &http.Client{Timeout: labelLookupTimeout},
+		cache:  make(map[string]map[string]string),
+		keys:   make(map[string]bool),
+	}
+	for _, rule := range config.Rules {
+		for key := range rule.Labels {
+			labeler.keys[key] = true
+		}
+	}
+	processLabeler = labeler
+	return nil
+}
+
+func expandLabelURL(template string, process ProcessInfo) string {
+	template = strings.ReplaceAll(template, "{{pid}}", strconv.Itoa(process.PID))
+	return strings.ReplaceAll(template, "{{name}}", url.QueryEscape(process.Name))
+}
+
+// lookup запрашивает метки процесса по HTTP; ошибки кэшируются как отсутствие меток,
+// чтобы недоступный сервис не замедлял каждое обновление
+func (l *Labeler) lookup(process ProcessInfo) map[string]string {
+	address := expandLabelURL(l.config.URL, process)
+	if labels, ok := l.cache[address]; ok {
+		return labels
+	}
+	var labels map[string]string
+	if resp, err := l.client.Get(address); err == nil {
+		if resp.StatusCode == http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(&labels)
+		}
+		resp.Body.Close()
+	}
+	l.cache[address] = labels
+	return labels
+}
+
+// apply назначает метки процессам: сначала из правил, затем недостающие из HTTP-запроса
+func (l *Labeler) apply(processes []ProcessInfo) {
+	if l == nil {
+		return
+	}
+	used := make(map[string]bool)
+	for i := range processes {
+		labels := make(map[string]string)
+		for _, rule := range l.config.Rules {
+			if !rule.matches(processes[i]) {
+				continue
+			}
+			for key, value := range rule.Labels {
+				if _, ok := labels[key]; !ok {
+					labels[key] = value
+				}
+			}
+		}
+		if l.config.URL != "" {
+			used[expandLabelURL(l.config.URL, processes[i])] = true
+			for key, value := range l.lookup(processes[i]) {
+				if _, ok := labels[key]; !ok {
+					labels[key] = value
+				}
+				l.keys[key] = true
+			}
+		}
+		if len(labels) > 0 {
+			processes[i].Labels = labels
+		}
+	}
+	// Ответы для завершившихся процессов больше не понадобятся
+	for address := range l.cache {
+		if !used[address] {
+			delete(l.cache, address)
+		}
+	}
+}
+
+// labelKeys возвращает отсортированные ключи меток, встречавшиеся у процессов
+func (l *Labeler) labelKeys() []string {
+	if l == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(l.keys))
+	for key := range l.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// processLabelKeys возвращает отсортированные ключи меток процессов из списка
+func processLabelKeys(processes []ProcessInfo) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, process := range processes {
+		for key := range process.Labels {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labelValue возвращает значение метки процесса или "-"
+func labelValue(process ProcessInfo, key string) string {
+	if value, ok := process.Labels[key]; ok && value != "" {
+		return value
+	}
+	return "-"
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Сведения о процессах и параметры ядра macOS читаются через sysctl и proc_pidinfo из
+// libproc вместо запуска ps и sysctl на каждом обновлении. Список процессов и их краткие
+// сведения отдает kern.proc в виде struct kinfo_proc из golang.org/x/sys/unix; оберток
+// libproc там нет, поэтому proc_pidinfo вызывается из libSystem через трамплин
+// (libproc_darwin_*.s), как host_statistics64, а ответы читаются в структуры,
+// повторяющие <sys/proc_info.h>. Сам системный вызов proc_info — закрытый интерфейс
+// ядра, и Apple сохраняет совместимость только для libproc
+
+const (
+	//Виды сведений proc_pidinfo
+	procPIDTaskInfo    = 4
+	procPIDThreadInfo  = 5
+	procPIDListThreads = 6
+	procPIDPathInfo    = 11
+
+	//Наибольший путь к исполняемому файлу (PROC_PIDPATHINFO_MAXSIZE)
+	procPathInfoMaxSize = 4 * 1024
+)
+
+// procTaskInfoData — struct proc_taskinfo
+type procTaskInfoData struct {
+	VirtualSize     uint64
+	ResidentSize    uint64
+	TotalUser       uint64
+	TotalSystem     uint64
+	ThreadsUser     uint64
+	ThreadsSystem   uint64
+	Policy          int32
+	Faults          int32
+	Pageins         int32
+	CowFaults       int32
+	MessagesSent    int32
+	MessagesRecv    int32
+	SyscallsMach    int32
+	SyscallsUnix    int32
+	ContextSwitches int32
+	ThreadNum       int32
+	NumRunning      int32
+	Priority        int32
+}
+
+// procThreadInfoData — struct proc_threadinfo
+type procThreadInfoData struct {
+	UserTime    uint64
+	SystemTime  uint64
+	CPUUsage    int32
+	Policy      int32
+	RunState    int32
+	Flags       int32
+	SleepTime   int32
+	CurPri      int32
+	Priority    int32
+	MaxPriority int32
+	Name        [64]byte
+}
+
+// xswUsage — struct xsw_usage из vm.swapusage
+type xswUsage struct {
+	Total     uint64
+	Avail     uint64
+	Used      uint64
+	PageSize  uint32
+	Encrypted int32
+}
+
+//go:cgo_import_dynamic libc_proc_pidinfo proc_pidinfo "/usr/lib/libSystem.B.dylib"
+
+var procPIDInfoTrampolineAddr uintptr
+
+// procPIDInfoRaw вызывает proc_pidinfo и возвращает число записанных в buf байт.
+// proc_pidinfo сообщает об ошибке нулем, а не -1, поэтому трамплин заменяет 0 на -1,
+// чтобы syscall6 вернул errno. Пустой ответ без ошибки (путь kernel_task) тоже дает 0:
+// тогда errno не установлен, и возвращается 0 байт
+func procPIDInfoRaw(pid, flavor int, arg uint64, buf unsafe.Pointer, size int) (int, error) {
+	n, _, errno := syscall_syscall6(procPIDInfoTrampolineAddr, uintptr(pid), uintptr(flavor), uintptr(arg),
+		uintptr(buf), uintptr(size), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	if int(n) < 0 {
+		return 0, nil
+	}
+	return int(n), nil
+}
+
+// procPIDInfo читает сведения вида flavor о процессе pid в структуру info размером size;
+// ответ короче структуры считается ошибкой разбора
+func procPIDInfo(pid, flavor int, arg uint64, info unsafe.Pointer, size int) error {
+	n, err := procPIDInfoRaw(pid, flavor, arg, info, size)
+	if err != nil {
+		return err
+	}
+	if n < size {
+		return fmt.Errorf("%w proc_pidinfo %d PID %d: %d байт из %d", ErrParse, flavor, pid, n, size)
+	}
+	return nil
+}
+
+// procListPIDs возвращает PID всех процессов из kern.proc.all
+func procListPIDs() ([]int, error) {
+	processes, err := unix.SysctlKinfoProcSlice("kern.proc.all")
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(processes))
+	for _, process := range processes {
+		pids = append(pids, int(process.Proc.P_pid))
+	}
+	return pids, nil
+}
+
+// procShortInfo читает struct kinfo_proc из kern.proc.pid; ядро отдает ее для процессов
+// любых пользователей. Для завершившегося процесса ответ пуст, и x/sys возвращает EIO
+func procShortInfo(pid int) (darwinProcess, error) {
+	process, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if errors.Is(err, unix.EIO) {
+		return darwinProcess{}, unix.ESRCH
+	}
+	if err != nil {
+		return darwinProcess{}, err
+	}
+	return darwinProcess{
+		PPID:   int(process.Eproc.Ppid),
+		Status: uint32(process.Proc.P_stat),
+		Comm:   cString(process.Proc.P_comm[:]),
+		UID:    process.Eproc.Ucred.Uid,
+	}, nil
+}
+
+// procTaskInfo читает struct proc_taskinfo; для процессов других пользователей без root
+// ядро возвращает EPERM
+func procTaskInfo(pid int) (darwinTask, error) {
+	var info procTaskInfoData
+	if err := procPIDInfo(pid, procPIDTaskInfo, 0, unsafe.Pointer(&info), int(unsafe.Sizeof(info))); err != nil {
+		return darwinTask{}, err
+	}
+	return darwinTask{
+		Resident: info.ResidentSize,
+		Threads:  int(info.ThreadNum),
+		Running:  int(info.NumRunning),
+	}, nil
+}
+
+// procThreadStates возвращает состояния (pth_run_state) не более threads потоков процесса.
+// Потоки, завершившиеся между вызовами, пропускаются
+func procThreadStates(pid, threads int) ([]int32, error) {
+	ids := make([]uint64, threads+16)
+	n, err := procPIDInfoRaw(pid, procPIDListThreads, 0, unsafe.Pointer(&ids[0]), len(ids)*8)
+	if err != nil {
+		return nil, err
+	}
+	var states []int32
+	for _, id := range ids[:n/8] {
+		var info procThreadInfoData
+		if err := procPIDInfo(pid, procPIDThreadInfo, id, unsafe.Pointer(&info), int(unsafe.Sizeof(info))); err != nil {
+			continue
+		}
+		states = append(states, info.RunState)
+	}
+	return states, nil
+}
+
+// procExecutablePath возвращает путь к исполняемому файлу процесса (proc_pidpath)
+func procExecutablePath(pid int) (string, error) {
+	buf := make([]byte, procPathInfoMaxSize)
+	n, err := procPIDInfoRaw(pid, procPIDPathInfo, 0, unsafe.Pointer(&buf[0]), len(buf))
+	if err != nil {
+		return "", err
+	}
+	return cString(buf[:n]), nil
+}
+
+// procNice читает nice процесса через getpriority: в macOS он возвращает nice
+// без смещения, в отличие от системного вызова Linux
+func procNice(pid int) (int, error) {
+	return unix.Getpriority(unix.PRIO_PROCESS, pid)
+}
+
+// sysctlString читает строковый параметр ядра
+func sysctlString(name string) (string, error) {
+	return unix.Sysctl(name)
+}
+
+// sysctlUint читает целочисленный параметр ядра. Счетчики страниц vm.page_*_count
+// занимают 4 байта, а hw.memsize и hw.pagesize — 8, поэтому значение читается как есть
+// (unix.SysctlRaw), а не через unix.SysctlUint64, которая принимает только 8 байт
+func sysctlUint(name string) (uint64, error) {
+	value, err := unix.SysctlRaw(name)
+	if err != nil {
+		return 0, err
+	}
+	switch len(value) {
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(value)), nil
+	case 8:
+		return binary.LittleEndian.Uint64(value), nil
+	}
+	return 0, fmt.Errorf("%w %s: %d байт", ErrParse, name, len(value))
+}
+
+// sysctlSwapUsage возвращает общий и свободный объем swap из struct xsw_usage (vm.swapusage).
+// Ответ другого размера считается ошибкой разбора, и объем берется из вывода sysctl
+func sysctlSwapUsage() (uint64, uint64, error) {
+	value, err := unix.SysctlRaw("vm.swapusage")
+	if err != nil {
+		return 0, 0, err
+	}
+	var usage xswUsage
+	if len(value) != int(unsafe.Sizeof(usage)) {
+		return 0, 0, fmt.Errorf("%w vm.swapusage: %d байт", ErrParse, len(value))
+	}
+	usage = *(*xswUsage)(unsafe.Pointer(&value[0]))
+	return usage.Total, min(usage.Avail, usage.Total), nil
+}
@@ -0,0 +1,15 @@
+// Трамплин к proc_pidinfo из libSystem для libproc_darwin.go
+
+#include "textflag.h"
+
+// proc_pidinfo возвращает int, а при ошибке — 0 с установленным errno. Результат
+// расширяется со знаком до 64 бит, а 0 заменяется на -1, по которому syscall6 читает errno
+TEXT libc_proc_pidinfo_trampoline<>(SB),NOSPLIT,$0-0
+	BL	libc_proc_pidinfo(SB)
+	MOVW	R0, R0
+	CBNZ	R0, done
+	MOVD	$-1, R0
+done:
+	RET
+GLOBL	·procPIDInfoTrampolineAddr(SB), RODATA, $8
+DATA	·procPIDInfoTrampolineAddr(SB)/8, $libc_proc_pidinfo_trampoline<>(SB)
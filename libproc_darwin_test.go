@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Структуры ядра читаются по раскладке из заголовков macOS, поэтому результат системных
+// вызовов сверяется с выводом ps, sysctl и vm_stat, которые читают те же данные
+
+// psFields возвращает поля вывода ps для процесса pid
+func psFields(t *testing.T, pid int, format string) []string {
+	t.Helper()
+	output, err := exec.Command(psPath, "-p", strconv.Itoa(pid), "-o", format).Output()
+	if err != nil {
+		t.Fatalf("ps: %v", err)
+	}
+	return strings.Fields(string(output))
+}
+
+// near сообщает, отличаются ли значения не больше чем на slack
+func near(a, b, slack uint64) bool {
+	return max(a, b)-min(a, b) <= slack
+}
+
+func TestProcListPIDs(t *testing.T) {
+	pids, err := procListPIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(pids, os.Getpid()) || !slices.Contains(pids, 1) {
+		t.Fatalf("в списке из %d процессов нет текущего процесса или launchd", len(pids))
+	}
+}
+
+func TestProcShortInfoMatchesPS(t *testing.T) {
+	pid := os.Getpid()
+	process, err := procShortInfo(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := psFields(t, pid, "ppid=,uid=,stat=,ucomm=")
+	if len(fields) < 4 {
+		t.Fatalf("неожиданный вывод ps: %q", fields)
+	}
+	if strconv.Itoa(process.PPID) != fields[0] || strconv.Itoa(int(process.UID)) != fields[1] {
+		t.Errorf("PPID, UID = %d, %d; ps: %s, %s", process.PPID, process.UID, fields[0], fields[1])
+	}
+	if process.Status == darwinStatusZombie || process.Status == darwinStatusStopped {
+		t.Errorf("текущий процесс в состоянии %d; ps: %s", process.Status, fields[2])
+	}
+	if comm := strings.Join(fields[3:], " "); !strings.HasPrefix(comm, process.Comm) || process.Comm == "" {
+		t.Errorf("имя %q; ps: %q", process.Comm, comm)
+	}
+	if _, err := procShortInfo(99999999); err == nil {
+		t.Error("нет ошибки для несуществующего PID")
+	}
+}
+
+func TestProcTaskInfoMatchesPS(t *testing.T) {
+	pid := os.Getpid()
+	task, err := procTaskInfo(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := psFields(t, pid, "rss=")
+	if len(fields) != 1 {
+		t.Fatalf("неожиданный вывод ps: %q", fields)
+	}
+	rss, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RSS тестового процесса меняется между вызовами, поэтому допускается расхождение
+	if !near(task.Resident, rss*1024, 16<<20) {
+		t.Errorf("resident = %d; ps: %d КБ", task.Resident, rss)
+	}
+	if task.Threads < 1 {
+		t.Errorf("потоков: %d", task.Threads)
+	}
+	if states, err := procThreadStates(pid, task.Threads); err != nil || len(states) == 0 {
+		t.Errorf("состояния потоков: %v, %v", states, err)
+	}
+}
+
+func TestSysctlSwapUsageMatchesSysctl(t *testing.T) {
+	total, free, err := sysctlSwapUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdTotal, cmdFree, err := commandSwapUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sysctl печатает мегабайты с двумя знаками, а свободный объем меняется между вызовами
+	if !near(total, cmdTotal, 1<<20) || !near(free, cmdFree, 64<<20) {
+		t.Errorf("total, free = %d, %d; sysctl: %d, %d", total, free, cmdTotal, cmdFree)
+	}
+}
+
+func TestSysctlUint(t *testing.T) {
+	for _, name := range []string{"hw.memsize", "hw.pagesize", "vm.page_free_count"} {
+		value, err := sysctlUint(name)
+		if err != nil || value == 0 {
+			t.Errorf("%s = %d, %v", name, value, err)
+			continue
+		}
+		output, err := exec.Command(sysctlPath, "-n", name).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := strconv.ParseUint(strings.TrimSpace(string(output)), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Число свободных страниц меняется между вызовами
+		if !near(value, expected, 64<<10) {
+			t.Errorf("%s = %d; sysctl: %d", name, value, expected)
+		}
+	}
+}
+
+func TestHostVMStatisticsMatchesVmStat(t *testing.T) {
+	stats, err := hostVMStatistics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(vmStatPath).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, pageSize := parseVmStat(string(output))
+	if pageSize != darwinPageSize() {
+		t.Errorf("размер страницы vm_stat %d, hw.pagesize %d", pageSize, darwinPageSize())
+	}
+	for key, value := range stats {
+		want, ok := expected[key]
+		if !ok {
+			t.Errorf("vm_stat не выводит %q", key)
+			continue
+		}
+		// Счетчики событий только растут, а число страниц меняется между вызовами
+		if !near(value, want, max(want/4, 4096)) {
+			t.Errorf("%s = %d; vm_stat: %d", key, value, want)
+		}
+	}
+}
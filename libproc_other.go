@@ -0,0 +1,49 @@
+//go:build !darwin
+
+package main
+
+import "fmt"
+
+// errNoLibproc — proc_pidinfo, sysctl по именам и host_statistics64 есть только в macOS;
+// на других системах DarwinMemoryReader не используется
+var errNoLibproc = fmt.Errorf("%w: proc_pidinfo, sysctl и host_statistics64 доступны только в macOS", ErrUnsupportedPlatform)
+
+func procListPIDs() ([]int, error) {
+	return nil, errNoLibproc
+}
+
+func procShortInfo(pid int) (darwinProcess, error) {
+	return darwinProcess{}, errNoLibproc
+}
+
+func procTaskInfo(pid int) (darwinTask, error) {
+	return darwinTask{}, errNoLibproc
+}
+
+func procThreadStates(pid, threads int) ([]int32, error) {
+	return nil, errNoLibproc
+}
+
+func procExecutablePath(pid int) (string, error) {
+	return "", errNoLibproc
+}
+
+func procNice(pid int) (int, error) {
+	return 0, errNoLibproc
+}
+
+func sysctlString(name string) (string, error) {
+	return "", errNoLibproc
+}
+
+func sysctlUint(name string) (uint64, error) {
+	return 0, errNoLibproc
+}
+
+func sysctlSwapUsage() (uint64, uint64, error) {
+	return 0, 0, errNoLibproc
+}
+
+func hostVMStatistics() (map[string]uint64, error) {
+	return nil, errNoLibproc
+}
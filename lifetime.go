@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// minLifetimeSamples — сколько замеров процесс должен прожить, чтобы при завершении
+// для него сохранялась сводка; более короткие процессы не успевают показать динамику
+const minLifetimeSamples = 2
+
+// exitedHistorySize — число сводок завершившихся процессов, доступных через API и на панели
+const exitedHistorySize = 100
+
+// exitedDisplayRows — число последних завершившихся процессов на информационной панели
+const exitedDisplayRows = 3
+
+// ProcessLifetime — сводка потребления памяти процессом за время его наблюдения
+type ProcessLifetime struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name"`
+	//Время первого и последнего замера, в которых процесс присутствовал; End — точное
+	//время завершения, если его зафиксировал монитор churn
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Peak  uint64    `json:"peak_bytes"`
+	//Среднее потребление памяти по замерам
+	Average uint64 `json:"average_bytes"`
+	//Изменение потребления памяти от первого замера до последнего
+	Growth  int64 `json:"growth_bytes"`
+	Samples int   `json:"samples"`
+}
+
+type lifetimeAccumulator struct {
+	lifetime ProcessLifetime
+	first    uint64
+	last     uint64
+	sum      float64
+}
+
+func (a *lifetimeAccumulator) result() ProcessLifetime {
+	lifetime := a.lifetime
+	lifetime.Average = uint64(a.sum / float64(lifetime.Samples))
+	lifetime.Growth = int64(a.last) - int64(a.first)
+	return lifetime
+}
+
+// LifetimeTracker следит за процессами между замерами и составляет сводки завершившихся
+type LifetimeTracker struct {
+	live   map[int]*lifetimeAccumulator
+	exited []ProcessLifetime
+	//ExitTime уточняет время завершения процесса, если оно известно источнику событий
+	ExitTime func(pid int) (time.Time, bool)
+}
+
+func NewLifetimeTracker() *LifetimeTracker {
+	return &LifetimeTracker{live: make(map[int]*lifetimeAccumulator)}
+}
+
+// Observe учитывает замер и возвращает сводки процессов, завершившихся с предыдущего замера.
+// Процесс с тем же PID, но другим именем считается новым: PID был использован повторно
+func (t *LifetimeTracker) Observe(sample Sample) []ProcessLifetime {
+	var exited []ProcessLifetime
+	finish := func(acc *lifetimeAccumulator) {
+		if acc.lifetime.Samples < minLifetimeSamples {
+			return
+		}
+		lifetime := acc.result()
+		if t.ExitTime != nil {
+			if end, ok := t.ExitTime(lifetime.PID); ok && end.After(lifetime.End) {
+				lifetime.End = end
+			}
+		}
+		exited = append(exited, lifetime)
+	}
+
+	seen := make(map[int]bool, len(sample.Processes))
+	for _, process := range sample.Processes {
+		seen[process.PID] = true
+		acc, ok := t.live[process.PID]
+		if ok && acc.lifetime.Name != process.Name {
+			finish(acc)
+			ok = false
+		}
+		if !ok {
+			acc = &lifetimeAccumulator{
+				lifetime: ProcessLifetime{PID: process.PID, Name: process.Name, Start: sample.Time},
+				first:    process.MemoryUsage,
+			}
+			t.live[process.PID] = acc
+		}
+		acc.lifetime.End = sample.Time
+		acc.lifetime.Samples++
+		if process.MemoryUsage > acc.lifetime.Peak {
+			acc.lifetime.Peak = process.MemoryUsage
+		}
+		acc.last = process.MemoryUsage
+		acc.sum += float64(process.MemoryUsage)
+	}
+	for pid, acc := range t.live {
+		if !seen[pid] {
+			finish(acc)
+			delete(t.live, pid)
+		}
+	}
+
+	t.exited = lastLifetimes(append(t.exited, exited...), exitedHistorySize)
+	return exited
+}
+
+// Recent возвращает сводки последних завершившихся процессов в порядке завершения
+func (t *LifetimeTracker) Recent() []ProcessLifetime {
+	return append([]ProcessLifetime(nil), t.exited...)
+}
+
+// FormatLifetimes форматирует сводки завершившихся процессов; title открывает таблицу
+func FormatLifetimes(title string, lifetimes []ProcessLifetime) string {
+	if len(lifetimes) == 0 {
+		return ""
+	}
+	var res strings.Builder
+	res.WriteString(title + ":\n")
+	header := fmt.Sprintf("%-7s %-15s %-19s %10s %10s %10s %10s", "PID", "NAME", "EXITED", "LIFETIME", "PEAK", "AVERAGE", "GROWTH")
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for _, lifetime := range lifetimes {
+		res.WriteString(fmt.Sprintf("%-7d %s %-19s %10s %10s %10s %10s\n",
+			lifetime.PID, fitLeft(getShortProcessName(lifetime.Name), 15), lifetime.End.Format("2006-01-02 15:04:05"),
+			lifetime.End.Sub(lifetime.Start).Round(time.Second), FormatMemorySize(lifetime.Peak),
+			FormatMemorySize(lifetime.Average), formatSignedSize(lifetime.Growth)))
+	}
+	return res.String()
+}
+
+// lastLifetimes возвращает не более n последних сводок
+func lastLifetimes(lifetimes []ProcessLifetime, n int) []ProcessLifetime {
+	if len(lifetimes) > n {
+		return lifetimes[len(lifetimes)-n:]
+	}
+	return lifetimes
+}
+
+// formatRecentExits форматирует сводки последних завершившихся процессов для панели
+func formatRecentExits(state *ViewState) string {
+	if state == nil {
+		return ""
+	}
+	return FormatLifetimes("Recently Exited", lastLifetimes(state.Exited, exitedDisplayRows))
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -34,11 +39,38 @@ type MemoryReader interface {
 	//Принимает PID процесса
 	//В случае ошибки возвращает 0 и описание ошибки
 	ReadProcessMemory(pid int) (uint64, error)
+
+	//ReadProcessName возвращает имя исполняемого файла процесса
+	//
+	//Принимает PID процесса
+	//В случае ошибки возвращает пустую строку и описание ошибки
+	ReadProcessName(pid int) (string, error)
 }
 
+// errNoResidentMemory возвращается для процессов без резидентной памяти (потоки ядра, зомби)
+var errNoResidentMemory = errors.New("Процесс не использует пользовательскую память")
+
 type DarwinMemoryReader struct{}
 
-type LinuxMemoryReader struct{}
+type LinuxMemoryReader struct {
+	//Корень файловой системы, из которого читаются /proc и /sys; пустая строка — "/".
+	//Позволяет читать снимки, сохраненные флагом --procfs-snapshot
+	Root string
+}
+
+// path возвращает путь к файлу с учетом корня Root, например path("proc", "meminfo")
+func (l *LinuxMemoryReader) path(elem ...string) string {
+	root := l.Root
+	if root == "" {
+		root = "/"
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// procPath возвращает путь к файлу в каталоге /proc/[pid]
+func (l *LinuxMemoryReader) procPath(pid int, elem ...string) string {
+	return l.path(append([]string{"proc", strconv.Itoa(pid)}, elem...)...)
+}
 
 type SystemMemoryInfo struct {
 	TotalMemory     uint64
@@ -49,9 +81,54 @@ type SystemMemoryInfo struct {
 }
 
 type ProcessInfo struct {
-	PID         int
-	Name        string
-	MemoryUsage uint64
+	PID         int    `json:"pid"`
+	Name        string `json:"name"`
+	MemoryUsage uint64 `json:"memory_bytes"`
+	Pinned      bool   `json:"pinned,omitempty"`
+	Budget      uint64 `json:"budget_bytes,omitempty"`
+
+	//Значения дополнительных колонок из конфигурации, в порядке их объявления
+	Extra []ExtraValue `json:"extra,omitempty"`
+
+	//Статистика ввода-вывода, если источник данных ее поддерживает
+	IO *ProcessIO `json:"io,omitempty"`
+
+	//Сетевое пространство имен процесса ("host" для пространства имен init-процесса)
+	NetNS string `json:"netns,omitempty"`
+
+	//Пользователь, от которого запущен процесс
+	User string `json:"user,omitempty"`
+
+	//Бизнес-метки процесса (команда, сервис, центр затрат) из конфигурации
+	Labels map[string]string `json:"labels,omitempty"`
+
+	//Однобуквенное состояние процесса (R, S, D, Z, T, I)
+	State string `json:"state,omitempty"`
+
+	//Значение nice процесса, nil, если источник данных его не поддерживает
+	Nice *int `json:"nice,omitempty"`
+
+	//Процесс — поток ядра (Linux)
+	Kernel bool `json:"kernel,omitempty"`
+
+	//Cgroup процесса и действующий лимит памяти этой cgroup (0 — лимит не задан)
+	Cgroup      string `json:"cgroup,omitempty"`
+	CgroupLimit uint64 `json:"cgroup_limit_bytes,omitempty"`
+	//Класс QoS пода Kubernetes по пути cgroup (Guaranteed, Burstable, BestEffort)
+	QoS string `json:"qos,omitempty"`
+
+	//Типичное потребление памяти по базовой линии (0 — процесса не было в базовой линии),
+	//nil, если сравнение с базовой линией не включено
+	Baseline *uint64 `json:"baseline_bytes,omitempty"`
+}
+
+// BudgetPercent возвращает потребление памяти процессом в процентах от его бюджета
+// или 0, если бюджет для процесса не задан
+func (p ProcessInfo) BudgetPercent() float64 {
+	if p.Budget == 0 {
+		return 0
+	}
+	return float64(p.MemoryUsage) / float64(p.Budget) * 100
 }
 
 // DisplayConfig будет использоваться при отображении информационной панели, которую мы создадим позже.
@@ -62,135 +139,323 @@ type DisplayConfig struct {
 	//Измеряется с помощью time.Duration
 	UpdateInterval time.Duration
 
+	//Период обновления при работе от батареи. Более редкие обновления уменьшают число пробуждений процессора
+	//
+	//Нулевое значение отключает переключение периода обновления в зависимости от источника питания
+	BatteryInterval time.Duration
+
 	//Ограничивает число процессов в списке.Помогает избежать перегруженности экрана
 	//Позволяет сфокусироваться на самых важных процессах
 	//Обычно показываются процессы с наибольшим потреблением памяти
 	TopProcesses int
+
+	//Формат, в котором сохраняется текущая таблица процессов при экспорте
+	//
+	//Одно из значений ExportText, ExportCSV или ExportJSON
+	ExportFormat string
+
+	//Имена процессов, которые всегда показываются в начале таблицы независимо от их места в рейтинге
+	PinnedNames []string
+
+	//Если задано, в таблице показываются только процессы с этими именами
+	Filter []string
+
+	//Ожидаемый бюджет памяти в байтах для процессов с указанными именами
+	//
+	//Если бюджеты заданы, в таблице появляется колонка с процентом использования бюджета
+	Budgets map[string]uint64
+
+	//Минимальное изменение значений в байтах, при котором панель перерисовывается
+	//
+	//Меньшие колебания памяти не вызывают перерисовку, что снижает мерцание терминала
+	ChangeThreshold uint64
+
+	//Дополнительные колонки таблицы, значения которых вычисляются внешними командами
+	Columns []ColumnConfig
+
+	//Показывать колонки ввода-вывода процессов при запуске
+	ShowIO bool
+
+	//Базовая линия для сравнения и пороги значимого отклонения от нее
+	Baseline           *Baseline
+	BaselineThresholds BaselineThresholds
+
+	//Сортировка таблицы при запуске; клавиша s переключает ее на другие варианты
+	Sort SortSpec
+
+	//Процесс остается на прежней строке таблицы, пока его место в сортировке
+	//изменилось не больше чем на это число позиций
+	//
+	//Нулевое значение отключает удержание строк
+	RowHysteresis int
+
+	//Разрешить из интерфейса действия администратора над памятью ядра (adminActions)
+	AllowAdminActions bool
+
+	//Срок заглушения сработавших оповещений клавишей S
+	SilenceDuration time.Duration
+
+	//Выводить панель простым текстом для программ экранного доступа (FormatPlainDashboard)
+	Plain bool
+
+	//Процессы, скрываемые из таблицы, или nil
+	Ignore *IgnoreList
+
+	//Отображение потоков ядра: KernelThreadsMark, KernelThreadsHide или KernelThreadsGroup
+	KernelThreads string
 }
 
-func (d *DarwinMemoryReader) GetProcessList() ([]int, error) {
-	cmd := exec.Command("ps", "-e", "-o", "pid=")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
+// refreshInterval возвращает период обновления с учетом источника питания
+func (c DisplayConfig) refreshInterval(onBattery bool) time.Duration {
+	if onBattery && c.BatteryInterval > 0 {
+		return c.BatteryInterval
 	}
+	return c.UpdateInterval
+}
 
-	var pids []int
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		pid, err := strconv.Atoi(line)
-		if err != nil {
-			continue
-		}
-		pids = append(pids, pid)
+// GetProcessList читает список процессов из sysctl kern.proc.all, а если песочница его
+// запрещает — из вывода ps
+func (d *DarwinMemoryReader) GetProcessList() ([]int, error) {
+	pids, err := procListPIDs()
+	if err == nil {
+		restoreField(fieldProcessList)
+		return pids, nil
+	}
+	markDegraded(fieldProcessList, "sysctl", err)
+	pids, psErr := psProcessList()
+	if psErr != nil {
+		return nil, fmt.Errorf("sysctl: %w; ps: %w", err, psErr)
 	}
 	return pids, nil
 }
 
 func (d *DarwinMemoryReader) ReadProcessMemory(pid int) (uint64, error) {
-	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "rss=")
-	output, err := cmd.Output()
+	state, err := d.readProcessMemoryState(pid)
+	return state.Memory, err
+}
+
+// ReadProcessName возвращает путь к исполняемому файлу процесса, как ps -o comm,
+// или имя процесса, если пути нет (kernel_task). Если песочница запрещает proc_pidinfo,
+// имя берется из общего вывода ps
+func (d *DarwinMemoryReader) ReadProcessName(pid int) (string, error) {
+	if path, err := procExecutablePath(pid); err == nil && path != "" {
+		return path, nil
+	}
+	process, err := procShortInfo(pid)
+	if errors.Is(err, syscall.EPERM) {
+		fallback, err := readPSFallback(pid)
+		return fallback.Name, err
+	}
 	if err != nil {
-		return 0, err
+		return "", processError(pid, err)
 	}
+	return process.Comm, nil
+}
 
-	rssStr := strings.TrimSpace(string(output))
-	if rssStr == "" {
-		return 0, fmt.Errorf("Процесс с pid %d не найден", pid)
+func (d *DarwinMemoryReader) ReadSystemMemory() (SystemMemoryInfo, error) {
+	var freePages, availablePages uint64
+	VmStats, pageSize, vmErr := readVmStat()
+	if vmErr == nil {
+		restoreField(fieldAvailableMemory)
+		freePages = VmStats["free"] + VmStats["inactive"]
+		availablePages = VmStats["free"] + VmStats["inactive"] + VmStats["speculative"]
+		if fileCache, exists := VmStats["file-backed pages"]; exists {
+			availablePages += fileCache
+		} else if cache, exists := VmStats["cache"]; exists {
+			availablePages += cache
+		}
+	} else {
+		// Без host_statistics64 и vm_stat (песочница) память оценивается по счетчикам sysctl,
+		// а единая память Apple Silicon не вычисляется
+		markDegraded(fieldAvailableMemory, "vm_stat", vmErr)
+		var err error
+		if freePages, availablePages, err = sysctlPageCounts(); err != nil {
+			return SystemMemoryInfo{}, fmt.Errorf("%w; sysctl: %w", vmErr, err)
+		}
+		pageSize = darwinPageSize()
 	}
-
-	rssKb, err := strconv.ParseUint(rssStr, 10, 64)
+	totalMemory, err := readTotalMemory(VmStats, pageSize)
 	if err != nil {
+		return SystemMemoryInfo{}, err
+	}
+	total, free, err := sysctlSwapUsage()
+	if err == nil {
+		restoreField(fieldSwapUsage)
+	} else {
+		// struct xsw_usage читается напрямую; если ядро ее не отдало или ее размер
+		// изменился, объем swap берется из текстового вывода sysctl, а без него
+		// swap считается пустым, и остальные значения замера сохраняются
+		var cmdErr error
+		if total, free, cmdErr = commandSwapUsage(); cmdErr != nil {
+			markDegraded(fieldSwapUsage, "sysctl", fmt.Errorf("vm.swapusage: %w; sysctl: %w", err, cmdErr))
+			total, free = 0, 0
+		} else {
+			restoreField(fieldSwapUsage)
+		}
+	}
+	info := SystemMemoryInfo{
+		TotalMemory:     totalMemory,
+		FreeMemory:      freePages * pageSize,
+		AvailableMemory: availablePages * pageSize,
+		SwapTotal:       total,
+		SwapFree:        free,
+	}
+	if appleSilicon {
+		if unified, ok := unifiedMemoryFromVmStat(VmStats, pageSize); ok {
+			info.AvailableMemory = unified.Available(totalMemory)
+		}
+	}
+	return info, nil
+}
+
+// readTotalMemory возвращает объем RAM из hw.memsize, а если sysctl его не отдает —
+// из вывода "sysctl -n hw.memsize". Без обоих объем оценивается по сумме счетчиков
+// страниц stats и отмечается в degraded_fields; ошибка возвращается, только если
+// и счетчиков нет
+func readTotalMemory(stats map[string]uint64, pageSize uint64) (uint64, error) {
+	totalMemory, err := sysctlUint("hw.memsize")
+	if err == nil && totalMemory == 0 {
+		err = fmt.Errorf("%w: не удалось получить информации об общем объеме RAM", ErrParse)
+	}
+	if err == nil {
+		restoreField(fieldTotalMemory)
+		return totalMemory, nil
+	}
+	cmdTotal, cmdErr := commandSysctlUint("hw.memsize")
+	if cmdErr == nil && cmdTotal > 0 {
+		restoreField(fieldTotalMemory)
+		return cmdTotal, nil
+	}
+	if cmdErr != nil {
+		err = fmt.Errorf("hw.memsize: %w; sysctl: %w", err, cmdErr)
+	}
+	markDegraded(fieldTotalMemory, "sysctl", err)
+	var pages uint64
+	for _, key := range []string{"free", "active", "inactive", "speculative", "throttled", "wired down", "occupied by compressor"} {
+		pages += stats[key]
+	}
+	if pages == 0 {
 		return 0, err
 	}
+	return pages * pageSize, nil
+}
 
-	return rssKb * 1024, nil
+// vmStatPageSizePattern находит размер страницы в заголовке vm_stat:
+// "Mach Virtual Memory Statistics: (page size of 16384 bytes)"
+var vmStatPageSizePattern = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// darwinPageSize возвращает размер страницы памяти ядра macOS: 16 КБ на Apple Silicon,
+// 4 КБ на Intel. Значение sysctl предпочтительнее os.Getpagesize, которое под Rosetta
+// возвращает размер страницы эмулируемой платформы
+func darwinPageSize() uint64 {
+	if size, err := sysctlUint("hw.pagesize"); err == nil && size > 0 {
+		return size
+	}
+	return uint64(os.Getpagesize())
 }
 
-func (d *DarwinMemoryReader) ReadSystemMemory() (SystemMemoryInfo, error) {
-	cmd := exec.Command("sysctl", "-n", "hw.memsize")
-	output, err := cmd.Output()
+// commandSwapUsage читает объем swap из вывода "sysctl -n vm.swapusage"
+func commandSwapUsage() (uint64, uint64, error) {
+	output, err := exec.Command(sysctlPath, "-n", "vm.swapusage").Output()
 	if err != nil {
-		return SystemMemoryInfo{}, err
+		return 0, 0, err
+	}
+	return parseSwapUsage(string(output))
+}
+
+// swapUsagePattern находит пары "имя = значение" в выводе vm.swapusage. Значение может
+// содержать десятичную запятую и пробел перед единицей измерения
+var swapUsagePattern = regexp.MustCompile(`(?i)\b(total|used|free)\s*=\s*(\d+(?:[.,]\d+)?)\s*([KMGT])?(?:i?B)?`)
+
+// parseSwapUsage разбирает вывод "sysctl -n vm.swapusage" разных версий macOS:
+//
+//	total = 2048.00M  used = 1024.00M  free = 1024.00M  (encrypted)
+//	total = 0.00M  used = 0.00M  free = 0.00M  (encrypted)
+//	total = 64.00M  used = 0.00M  free = 64.00M
+//	total = 2048,00M  used = 1024,00M  free = 1024,00M  (encrypted)
+//
+// Значения ищутся по именам, поэтому порядок полей и пробелы вокруг "=" не важны
+func parseSwapUsage(output string) (uint64, uint64, error) {
+	values := make(map[string]string)
+	for _, match := range swapUsagePattern.FindAllStringSubmatch(output, -1) {
+		values[strings.ToLower(match[1])] = match[2] + strings.ToUpper(match[3])
 	}
-	totalMemoryStr := strings.TrimSpace(string(output))
-	if totalMemoryStr == "" {
-		return SystemMemoryInfo{}, fmt.Errorf("Не удалось получить информации об общем объеме RAM")
+	totalStr, hasTotal := values["total"]
+	freeStr, hasFree := values["free"]
+	if !hasTotal || !hasFree {
+		return 0, 0, fmt.Errorf("%w SwapInfo: %q", ErrParse, strings.TrimSpace(output))
 	}
-	totalMemory, err := strconv.ParseUint(totalMemoryStr, 10, 64)
+	total, err := parseMemSize(totalStr)
 	if err != nil {
-		return SystemMemoryInfo{}, err
+		return 0, 0, fmt.Errorf("Невозможно распарсить TotalSwap: %w", err)
 	}
-	VmStats := make(map[string]uint64)
-	cmd = exec.Command("vm_stat")
-	output, err = cmd.Output()
+	free, err := parseMemSize(freeStr)
 	if err != nil {
-		return SystemMemoryInfo{}, err
+		return 0, 0, fmt.Errorf("Невозможно распарсить FreeSwap: %w", err)
+	}
+	if free > total {
+		free = total
+	}
+	return total, free, nil
+}
+
+// readVmStat возвращает счетчики страниц с ключами parseVmStat и размер страницы. Счетчики
+// читаются вызовом host_statistics64, а если он недоступен — из вывода vm_stat
+func readVmStat() (map[string]uint64, uint64, error) {
+	stats, hostErr := hostVMStatistics()
+	if hostErr == nil {
+		return stats, darwinPageSize(), nil
+	}
+	output, err := exec.Command(vmStatPath).Output()
+	if err != nil {
+		return nil, 0, fmt.Errorf("host_statistics64: %w; vm_stat: %w", hostErr, err)
+	}
+	stats, pageSize := parseVmStat(string(output))
+	if pageSize == 0 {
+		pageSize = darwinPageSize()
 	}
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	return stats, pageSize, nil
+}
+
+// parseVmStat разбирает вывод vm_stat: строки вида "Pages free:   12345." со значениями
+// в страницах и размер страницы из заголовка (0, если заголовка нет).
+// Строки, которые не удалось разобрать, пропускаются
+func parseVmStat(output string) (map[string]uint64, uint64) {
+	stats := make(map[string]uint64)
+	var pageSize uint64
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
 		if strings.HasPrefix(line, "Mach Virtual Memory Statistics") {
+			if match := vmStatPageSizePattern.FindStringSubmatch(line); match != nil {
+				pageSize, _ = strconv.ParseUint(match[1], 10, 64)
+			}
 			continue
 		}
-		parts := strings.Split(line, ":")
-		if len(parts) != 2 {
+		idx := strings.LastIndex(line, ":")
+		if idx == -1 {
 			continue
 		}
-		key := strings.TrimSpace(parts[0])
-		valueStr := strings.Trim(strings.TrimSpace(parts[1]), ".")
-
+		key := strings.TrimSpace(line[:idx])
+		valueStr := strings.Trim(strings.TrimSpace(line[idx+1:]), ".")
 		value, err := strconv.ParseUint(valueStr, 10, 64)
 		if err != nil {
 			continue
 		}
-		key = strings.TrimPrefix(key, "Pages ")
-		VmStats[key] = value
-	}
-	freePages := VmStats["free"] + VmStats["inactive"]
-	availablePages := VmStats["free"] + VmStats["inactive"] + VmStats["speculative"]
-	if fileCache, exists := VmStats["file-backed pages"]; exists {
-		availablePages += fileCache
-	} else if cache, exists := VmStats["cache"]; exists {
-		availablePages += cache
-	}
-	cmd = exec.Command("sysctl", "-n", "vm.swapusage")
-	output, err = cmd.Output()
-	if err != nil {
-		return SystemMemoryInfo{}, err
+		stats[strings.TrimPrefix(key, "Pages ")] = value
 	}
-	output = []byte(strings.TrimSpace(string(output)))
-	parts := strings.Fields(string(output))
-	if len(parts) < 9 {
-		return SystemMemoryInfo{}, fmt.Errorf("Неверный формат SwapInfo")
-	}
-	total, err := parseMemSize(parts[2])
-	if err != nil {
-		return SystemMemoryInfo{}, fmt.Errorf("Невозможно распарсить TotalSwap")
-	}
-	free, err := parseMemSize(parts[8])
-	if err != nil {
-		return SystemMemoryInfo{}, fmt.Errorf("Невозможно распарсить FreeSwap")
-	}
-	info := SystemMemoryInfo{
-		TotalMemory:     totalMemory,
-		FreeMemory:      freePages * uint64(4096),
-		AvailableMemory: availablePages * uint64(4096),
-		SwapTotal:       total,
-		SwapFree:        free,
-	}
-	return info, nil
+	return stats, pageSize
 }
 
+// parseMemSize разбирает размер с суффиксом K, M, G или T ("1024.00M").
+// Отрицательные, бесконечные и не помещающиеся в uint64 значения считаются ошибкой,
+// а десятичная запятая некоторых локалей ("1024,00M") — точкой
 func parseMemSize(sizeStr string) (uint64, error) {
+	original := sizeStr
+	sizeStr = strings.TrimSpace(sizeStr)
 	var mult uint64 = 1
 	if strings.HasSuffix(sizeStr, "K") {
 		mult = 1024
@@ -205,17 +470,22 @@ func parseMemSize(sizeStr string) (uint64, error) {
 		mult = 1024 * 1024 * 1024 * 1024
 		sizeStr = strings.TrimSuffix(sizeStr, "T")
 	}
+	sizeStr = strings.Replace(sizeStr, ",", ".", 1)
 	val, err := strconv.ParseFloat(sizeStr, 64)
-	if err != nil {
-		return 0, err
+	if err != nil || math.IsNaN(val) || val < 0 {
+		return 0, fmt.Errorf("%w размера: %q", ErrParse, original)
+	}
+	size := val * float64(mult)
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("%w: слишком большой размер %q", ErrParse, original)
 	}
-	return uint64(val * float64(mult)), nil
+	return uint64(size), nil
 }
 
 func (l *LinuxMemoryReader) GetProcessList() ([]int, error) {
 	var pids []int
 
-	entries, err := os.ReadDir("/proc")
+	entries, err := os.ReadDir(l.path("proc"))
 	if err != nil {
 		return nil, err
 	}
@@ -242,11 +512,19 @@ func (l *LinuxMemoryReader) GetProcessList() ([]int, error) {
 	return pids, nil
 }
 
+// ReadProcessMemory читает RSS процесса; в живой системе — из /proc/[pid]/stat,
+// см. readProcessMemoryState
 func (l *LinuxMemoryReader) ReadProcessMemory(pid int) (uint64, error) {
-	pathName := filepath.Join("proc", strconv.Itoa(pid), "status")
+	state, err := l.readProcessMemoryState(pid)
+	return state.Memory, err
+}
+
+// readStatusRSS ищет VmRSS в /proc/[pid]/status
+func (l *LinuxMemoryReader) readStatusRSS(pid int) (uint64, error) {
+	pathName := l.procPath(pid, "status")
 	file, err := os.Open(pathName)
 	if err != nil {
-		return 0, err
+		return 0, processError(pid, err)
 	}
 	defer file.Close()
 	scanner := bufio.NewScanner(file)
@@ -261,31 +539,49 @@ func (l *LinuxMemoryReader) ReadProcessMemory(pid int) (uint64, error) {
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return 0, fmt.Errorf("Ошибка при читении файла: %v", err)
+		return 0, processError(pid, fmt.Errorf("Ошибка при чтении файла: %w", err))
 	}
-	return 0, fmt.Errorf("VmRSS не найден для PID %d", pid)
+	return 0, fmt.Errorf("%w: VmRSS не найден для PID %d", errNoResidentMemory, pid)
+}
+
+func (l *LinuxMemoryReader) ReadProcessName(pid int) (string, error) {
+	data, err := os.ReadFile(l.procPath(pid, "comm"))
+	if err != nil {
+		return "", processError(pid, err)
+	}
+	// Ядро обрезает comm до 15 байт и может разорвать последний символ UTF-8
+	name := strings.TrimSpace(strings.ToValidUTF8(string(data), ""))
+	if name == "" {
+		return "", fmt.Errorf("Пустое имя процесса для PID %d", pid)
+	}
+	return name, nil
 }
 
 func (l *LinuxMemoryReader) ReadSystemMemory() (SystemMemoryInfo, error) {
-	file, err := os.Open("/proc/meminfo")
+	file, err := os.Open(l.path("proc", "meminfo"))
 	if err != nil {
-		return SystemMemoryInfo{}, fmt.Errorf("Не удалось открыть /proc/meminfo: %v", err)
+		return SystemMemoryInfo{}, fmt.Errorf("Не удалось открыть /proc/meminfo: %w", permissionError(err))
 	}
 	defer file.Close()
 	memStats, err := parseMemInfo(file)
 	if err != nil {
 		return SystemMemoryInfo{}, err
 	}
+	return systemMemoryFromMemInfo(memStats)
+}
+
+// systemMemoryFromMemInfo переводит значения /proc/meminfo (в килобайтах) в SystemMemoryInfo
+func systemMemoryFromMemInfo(memStats map[string]uint64) (SystemMemoryInfo, error) {
 	var info SystemMemoryInfo
 	if total, exists := memStats["MemTotal"]; exists {
 		info.TotalMemory = total * 1024
 	} else {
-		return SystemMemoryInfo{}, fmt.Errorf("MemTotal не найден")
+		return SystemMemoryInfo{}, fmt.Errorf("%w /proc/meminfo: MemTotal не найден", ErrParse)
 	}
 	if free, exists := memStats["MemFree"]; exists {
 		info.FreeMemory = free * 1024
 	} else {
-		return SystemMemoryInfo{}, fmt.Errorf("MemFree не найден")
+		return SystemMemoryInfo{}, fmt.Errorf("%w /proc/meminfo: MemFree не найден", ErrParse)
 	}
 	if available, exists := memStats["MemAvailable"]; exists {
 		info.AvailableMemory = available * 1024
@@ -301,17 +597,17 @@ func (l *LinuxMemoryReader) ReadSystemMemory() (SystemMemoryInfo, error) {
 	if swapTotal, exists := memStats["SwapTotal"]; exists {
 		info.SwapTotal = swapTotal * 1024
 	} else {
-		return info, fmt.Errorf("SwapTotal не найден")
+		return info, fmt.Errorf("%w /proc/meminfo: SwapTotal не найден", ErrParse)
 	}
 	if swapFree, exists := memStats["SwapFree"]; exists {
 		info.SwapFree = swapFree * 1024
 	} else {
-		return info, fmt.Errorf("SwapFree не найден")
+		return info, fmt.Errorf("%w /proc/meminfo: SwapFree не найден", ErrParse)
 	}
 	return info, nil
 }
 
-func parseMemInfo(file *os.File) (map[string]uint64, error) {
+func parseMemInfo(file io.Reader) (map[string]uint64, error) {
 	stats := make(map[string]uint64)
 	scanner := bufio.NewScanner(file)
 
@@ -337,10 +633,10 @@ func parseMemInfo(file *os.File) (map[string]uint64, error) {
 		stats[key] = val
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("Ошибка чтения: %v", err)
+		return nil, fmt.Errorf("Ошибка чтения: %w", err)
 	}
 	if len(stats) == 0 {
-		return nil, fmt.Errorf("Не удалось извлечь данные")
+		return nil, fmt.Errorf("%w: не удалось извлечь данные", ErrParse)
 	}
 	return stats, nil
 }
@@ -348,14 +644,17 @@ func parseMemInfo(file *os.File) (map[string]uint64, error) {
 func extractValue(line string) (uint64, error) {
 	parts := strings.SplitN(line, ":", 2)
 	if len(parts) != 2 {
-		return 0, fmt.Errorf("Неверный формат строки")
+		return 0, fmt.Errorf("%w строки %q", ErrParse, line)
+	}
+	// "VmRSS:	  1234 kB"; у обрезанной строки значения может не быть
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%w: пустое значение в строке %q", ErrParse, line)
 	}
-	valueStr := strings.TrimSpace(parts[1])
-	valueStr = strings.TrimSuffix(valueStr, "kB")
-	valueStr = strings.TrimSpace(valueStr)
+	valueStr := strings.TrimSuffix(fields[0], "kB")
 	val, err := strconv.ParseUint(valueStr, 10, 64)
 	if err != nil {
-		return 0, fmt.Errorf("Не удалось конвертировать значение VmRSS: %s", valueStr)
+		return 0, fmt.Errorf("%w: не удалось конвертировать значение %s: %s", ErrParse, strings.TrimSpace(parts[0]), valueStr)
 	}
 	return val, nil
 }
@@ -406,17 +705,80 @@ func getShortProcessName(fullName string) string {
 	if strings.HasSuffix(baseName, "-helper") {
 		baseName = strings.TrimSuffix(baseName, "-helper")
 	}
-	if len(baseName) > 15 {
-		baseName = baseName[:12] + "..."
-	}
-	return baseName
+	return ellipsize(baseName, 15)
 }
 
 func FormatTable(processes []ProcessInfo) string {
+	withBudget := false
+	for _, process := range processes {
+		if process.Budget > 0 {
+			withBudget = true
+			break
+		}
+	}
+	withIO, withState, withLimit, withBaseline := false, false, false, false
+	withNice, withQoS := false, false
+	for _, process := range processes {
+		if process.Nice != nil {
+			withNice = true
+		}
+		if process.QoS != "" {
+			withQoS = true
+		}
+		if process.Baseline != nil {
+			withBaseline = true
+		}
+		if process.IO != nil {
+			withIO = true
+		}
+		if process.State != "" {
+			withState = true
+		}
+		if process.CgroupLimit > 0 {
+			withLimit = true
+		}
+	}
+	var extraNames []string
+	if len(processes) > 0 {
+		for _, extra := range processes[0].Extra {
+			extraNames = append(extraNames, extra.Name)
+		}
+	}
+	labelKeys := processLabelKeys(processes)
 	var res strings.Builder
 	res.WriteString("Process List:\n")
-	res.WriteString("PID      NAME            MEMORY\n")
-	res.WriteString("--------------------------------\n")
+	header := "PID      NAME            MEMORY"
+	if withState {
+		header += "  S"
+	}
+	if withNice {
+		header += "   NI"
+	}
+	if withBudget {
+		header += "      BUDGET"
+	}
+	if withLimit {
+		header += "       LIMIT"
+	}
+	if withQoS {
+		header += "  " + fitRight("QOS", extraColumnWidth)
+	}
+	if withBaseline {
+		header += "    BASELINE"
+	}
+	if withIO {
+		for _, name := range []string{"READ", "WRITE", "READ/s", "WRITE/s"} {
+			header += "  " + fitRight(name, extraColumnWidth)
+		}
+	}
+	for _, name := range extraNames {
+		header += "  " + fitRight(name, extraColumnWidth)
+	}
+	for _, key := range labelKeys {
+		header += "  " + fitRight(strings.ToUpper(key), extraColumnWidth)
+	}
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
 	for _, process := range processes {
 		pidStr := fmt.Sprintf("%d", process.PID)
 		if len(pidStr) > 8 {
@@ -425,11 +787,10 @@ func FormatTable(processes []ProcessInfo) string {
 			pidStr = pidStr + strings.Repeat(" ", 8-len(pidStr))
 		}
 		name := getShortProcessName(process.Name)
-		if len(name) > 15 {
-			name = name[:15]
-		} else {
-			name = name + strings.Repeat(" ", 15-len(name))
+		if process.Kernel {
+			name = "[" + ellipsize(process.Name, 13) + "]"
 		}
+		name = fitLeft(name, 15)
 		memoryStr := FormatMemorySize(process.MemoryUsage)
 		if len(memoryStr) > 10 {
 			memoryStr = memoryStr[:10]
@@ -437,15 +798,92 @@ func FormatTable(processes []ProcessInfo) string {
 			memoryStr = strings.Repeat(" ", 10-len(memoryStr)) + memoryStr
 		}
 		res.WriteString(pidStr)
-		res.WriteString(" ")
+		if process.Pinned {
+			res.WriteString("*")
+		} else {
+			res.WriteString(" ")
+		}
 		res.WriteString(name)
 		res.WriteString(" ")
 		res.WriteString(memoryStr)
+		if withState {
+			state := process.State
+			if state == "" {
+				state = "-"
+			}
+			res.WriteString("  ")
+			res.WriteString(state)
+		}
+		if withNice {
+			res.WriteString("  ")
+			res.WriteString(fitRight(formatNice(process), 3))
+		}
+		if withBudget {
+			budgetStr := "-"
+			if process.Budget > 0 {
+				budgetStr = fmt.Sprintf("%.1f%%", process.BudgetPercent())
+			}
+			if len(budgetStr) < 10 {
+				budgetStr = strings.Repeat(" ", 10-len(budgetStr)) + budgetStr
+			}
+			res.WriteString("  ")
+			res.WriteString(budgetStr)
+		}
+		if withLimit {
+			limitStr := "-"
+			if process.CgroupLimit > 0 {
+				limitStr = fmt.Sprintf("%.1f%%", process.LimitPercent())
+			}
+			res.WriteString("  ")
+			res.WriteString(fitRight(limitStr, extraColumnWidth))
+		}
+		if withQoS {
+			qos := process.QoS
+			if qos == "" {
+				qos = "-"
+			}
+			res.WriteString("  ")
+			res.WriteString(fitRight(qos, extraColumnWidth))
+		}
+		if withBaseline {
+			res.WriteString("  ")
+			res.WriteString(fitRight(formatBaselineChange(process), extraColumnWidth))
+		}
+		if withIO {
+			values := []string{"-", "-", "-", "-"}
+			if process.IO != nil {
+				values = []string{
+					FormatMemorySize(process.IO.ReadBytes),
+					FormatMemorySize(process.IO.WriteBytes),
+					formatRate(process.IO.ReadRate),
+					formatRate(process.IO.WriteRate),
+				}
+			}
+			for _, value := range values {
+				res.WriteString("  ")
+				res.WriteString(fitRight(value, extraColumnWidth))
+			}
+		}
+		for _, extra := range process.Extra {
+			value := extra.Value
+			if value == "" {
+				value = "-"
+			}
+			res.WriteString("  ")
+			res.WriteString(fitRight(value, extraColumnWidth))
+		}
+		for _, key := range labelKeys {
+			res.WriteString("  ")
+			res.WriteString(fitRight(labelValue(process, key), extraColumnWidth))
+		}
 		res.WriteString("\n")
 	}
 	return res.String()
 }
 
+// extraColumnWidth — ширина дополнительных колонок таблицы
+const extraColumnWidth = 10
+
 func FormatSystemStats(stats SystemMemoryInfo) string {
 	var res strings.Builder
 	res.WriteString("System Memory:\n")
@@ -467,60 +905,625 @@ func FormatSystemStats(stats SystemMemoryInfo) string {
 	return res.String()
 }
 
-func DisplayDashboard(stats SystemMemoryInfo, processes []ProcessInfo, config DisplayConfig) {
+func DisplayDashboard(sample Sample, config DisplayConfig, state *ViewState) {
+	if config.Plain {
+		fmt.Print(FormatPlainDashboard(sample, config, state))
+		return
+	}
 	var res strings.Builder
+	if isTerminal(os.Stdout) {
+		res.WriteString(clearScreen)
+	}
 	res.WriteString("=== Memory Analyzer ===\n\n")
+	res.WriteString(FormatSwapThrashBanner(sample.Swap, sample.System))
 
-	res.WriteString(FormatSystemStats(stats))
+	res.WriteString(FormatSystemStats(sample.System))
+	res.WriteString(FormatContainer(sample.Container))
+	res.WriteString(FormatSwapActivity(sample.Swap))
+	res.WriteString(FormatOvercommit(sample.VM))
+	res.WriteString(FormatForecast(sample.Forecast))
+	res.WriteString(FormatChurn(sample.Churn))
 	res.WriteString("\n")
 
-	res.WriteString("Top Memory Processes:\n")
+	if state.ShowTunables {
+		res.WriteString(FormatVMTunables(sample.VM, sample.System))
+		res.WriteString("\n")
+	}
+
+	if alertsStr := FormatAlerts(sample.Alerts); alertsStr != "" {
+		res.WriteString(alertsStr)
+		res.WriteString("\n")
+	}
+
+	if collectorsStr := FormatCollectors(sample.Collectors); collectorsStr != "" {
+		res.WriteString(collectorsStr)
+		res.WriteString("\n")
+	}
+
+	if eventsStr := FormatCgroupEvents(sample.CgroupEvents); eventsStr != "" {
+		res.WriteString(eventsStr)
+		res.WriteString("\n")
+	}
+
+	state.TableHeaderRow = 0
+	tablePos, table, tableRows := 0, "", 0
+	if state.ShowFiles {
+		res.WriteString(FormatFileCensus(state.Files, config.TopProcesses))
+	} else if state.GroupBy != GroupByNone {
+		res.WriteString(FormatGroupTable(GroupProcesses(sample.Processes, state.GroupBy), state.GroupBy))
+	} else {
+		res.WriteString("Top Memory Processes:\n")
 
-	res.WriteString(FormatTable(processes))
+		view := visibleProcesses(sample.Processes, config, state)
+		state.ClampSelection(len(view))
+		res.WriteString(FormatStateCounts(sample.Processes))
+		table = highlightMatches(markRows(FormatTable(view), view, state.Marked), view, state.Search)
+		table = frozenRows(table, view, state.Frozen)
+		table = colorizeBaselineRows(table, view, config.BaselineThresholds)
+		table = colorizeStateRows(colorizeLimitRows(colorizeBudgetRows(table, view), view), view)
+		if state.Interactive {
+			table = highlightRow(table, state.Selected)
+		}
+		// Заголовок — вторая строка таблицы после "Process List:"
+		state.TableHeaderRow = strings.Count(res.String(), "\n") + 2
+		state.TableHeader = strings.SplitN(table, "\n", 3)[1]
+		tablePos, tableRows = res.Len(), len(view)
+		res.WriteString(table)
+		res.WriteString(FormatTotals(view, sample.Processes, sample.System))
+		res.WriteString(FormatHidden(sample.Processes, config))
+		res.WriteString(FormatKernelThreads(sample.Processes, config))
+	}
 	res.WriteString("\n")
 
-	currentTime := time.Now().Format("2006-01-02 15:04:05")
+	if exitedStr := formatRecentExits(state); exitedStr != "" {
+		res.WriteString(exitedStr)
+		res.WriteString("\n")
+	}
+
+	if state.InspectPID != 0 {
+		res.WriteString(formatInspectPanel(sample.Processes, state))
+		res.WriteString("\n")
+	}
+
+	currentTime := sample.Time.Format("2006-01-02 15:04:05")
+	if ages := formatPanelAges(sample.Time, state.ProcessesUpdated, state.DetailsUpdated); ages != "" {
+		currentTime += " (" + ages + ")"
+	}
 	res.WriteString(fmt.Sprintf("Updated: %s\n", currentTime))
+	if state.OnBattery {
+		res.WriteString(fmt.Sprintf("On battery power: refreshing every %s\n", config.BatteryInterval))
+	}
 
-	res.WriteString("Press Ctrl+C to exit\n")
+	if summary := state.Errors.Summary(); summary != "" {
+		res.WriteString(fmt.Sprintf("Errors: %s (x for details)\n", summary))
+	}
+	res.WriteString(FormatDegraded(sample.Degraded))
+	res.WriteString(FormatFallbacks(sample.DegradedFields))
+	if state.ShowErrors {
+		res.WriteString(FormatErrorsPane(state.Errors))
+	}
 
-	fmt.Print(res.String())
+	res.WriteString(fmt.Sprintf("Sort: %s\n", state.sortSpec(config)))
+	if state.Preset != "" {
+		res.WriteString(fmt.Sprintf("Preset: %s\n", state.Preset))
+	}
+	res.WriteString(FormatSearch(state))
+	res.WriteString(FormatMarks(state))
+	res.WriteString(FormatFrozen(state))
+	if state.Interactive {
+		if state.ReadOnly {
+			res.WriteString("j/k select, / search, Enter inspect, Space mark, a mark all, p pin, w watch, s sort, g group, P preset, f files, v vm settings, i I/O, e export, x errors, Ctrl+C exit (read-only)\n")
+		} else {
+			res.WriteString("j/k select, / search, Enter inspect, Space mark, a mark all, p pin, w watch, z freeze, K kill, s sort, g group, P preset, f files, v vm settings, i I/O, e export, x errors, Ctrl+C exit\n")
+		}
+		if config.AllowAdminActions {
+			res.WriteString("D drop caches, C compact memory\n")
+		}
+		if len(sample.Alerts) > 0 {
+			res.WriteString(fmt.Sprintf("A acknowledge alerts, S silence alerts for %s\n", config.SilenceDuration))
+		}
+	}
+	if state.Status != "" {
+		res.WriteString(state.Status)
+		res.WriteString("\n")
+	}
+
+	// Если строки процессов не помещаются на экран, прокручиваются только они,
+	// а системная статистика, заголовок таблицы и подсказки остаются на месте.
+	// Одна строка остается под курсором, другая — под строкой с положением в списке
+	output := res.String()
+	state.PaneRows = 0
+	if table != "" && state.ScreenRows > 0 {
+		height := state.ScreenRows - (strings.Count(output, "\n") - tableRows) - 2
+		if height >= minPaneRows {
+			state.PaneRows = height
+			output = output[:tablePos] + scrollPane(table, tableRows, height, state) + output[tablePos+len(table):]
+		}
+	}
+	if state.PaneRows == 0 {
+		state.ScrollOffset = 0
+	}
+	fmt.Print(output)
 }
 
-func getProcName(pid int) string {
-	switch pid {
-	case 1234, 1001:
-		return "chrome"
-	case 5678, 2002:
-		return "vscode"
-	case 9101, 3003:
-		return "terminal"
-	default:
-		return fmt.Sprintf("process-%d", pid)
+// batchReader реализуют источники данных, которые возвращают все процессы за один вызов
+type batchReader interface {
+	CollectProcesses() ([]ProcessInfo, error)
+}
+
+// collectProcesses собирает информацию о памяти всех процессов, доступных для чтения
+//
+// Ошибки чтения отдельных процессов не прерывают сбор и учитываются в report
+func collectProcesses(reader MemoryReader, report *ErrorReport) ([]ProcessInfo, error) {
+	if batch, ok := reader.(batchReader); ok {
+		return batch.CollectProcesses()
 	}
+	pids, err := reader.GetProcessList()
+	if err != nil {
+		return nil, err
+	}
+	processes, _ := readProcesses(reader, pids, report)
+	return processes, nil
 }
 
-func main() {
-	var reader MemoryReader
+// readProcessInfo читает память и сведения одного процесса; владелец, пространство
+// имен и cgroup берутся из cache, если процесс уже встречался под тем же именем и его
+// RSS мало изменился (deep сообщает, что они прочитаны заново). Паника при разборе данных процесса
+// возвращается как ошибка, и остальные процессы читаются как обычно
+func readProcessInfo(reader MemoryReader, pid int, hostNS string, limits *cgroupLimits, cache *processCache, report *ErrorReport) (_ ProcessInfo, deep bool, err error) {
+	defer recoverPanic("processes", &err)
+	var fast memoryState
+	if fastReader, ok := reader.(memoryStateReader); ok {
+		fast, err = fastReader.readProcessMemoryState(pid)
+	} else {
+		fast.Memory, err = reader.ReadProcessMemory(pid)
+		if stateReader, ok := reader.(StateReader); ok {
+			fast.State, _ = stateReader.ReadProcessState(pid)
+		}
+		if kernelReader, ok := reader.(KernelThreadReader); ok && errors.Is(err, errNoResidentMemory) {
+			fast.Kernel, _ = kernelReader.IsKernelThread(pid)
+		}
+	}
+	mem, state, kernel := fast.Memory, fast.State, fast.Kernel
+	// Потоки ядра, зомби и процессы в состоянии D показываются даже без пользовательской
+	// памяти: потоки ядра отмечаются или сводятся в строку по режиму --kernel-threads,
+	// а скопление зомби и процессов в D часто сопровождает инциденты с памятью и вводом-выводом
+	if err != nil && !(errors.Is(err, errNoResidentMemory) && (kernel || isAlarmingState(state))) {
+		return ProcessInfo{}, false, err
+	}
+	name, err := reader.ReadProcessName(pid)
+	if err != nil {
+		report.Add("process names unreadable", err)
+		name = fmt.Sprintf("process-%d", pid)
+	}
+	process := ProcessInfo{
+		PID:         pid,
+		Name:        name,
+		MemoryUsage: mem,
+		State:       state,
+		Nice:        fast.Nice,
+	}
+	identity, cached := cache.lookup(pid, name, mem, time.Now())
+	if !cached {
+		identity = readProcessIdentity(reader, pid, name, mem, kernel, hostNS)
+		cache.store(pid, identity)
+	}
+	identity.apply(&process)
+	// Приоритет меняется через renice, а статистика ввода-вывода — постоянно,
+	// поэтому они читаются на каждом обновлении, если nice не прочитан вместе с памятью;
+	// у чужих процессов без прав root статистика ввода-вывода недоступна, это не ошибка
+	if priorityReader, ok := reader.(PriorityReader); ok && process.Nice == nil {
+		if nice, err := priorityReader.ReadProcessNice(pid); err == nil {
+			process.Nice = &nice
+		}
+	}
+	if ioReader, ok := reader.(IOReader); ok {
+		if stats, err := ioReader.ReadProcessIO(pid); err == nil {
+			process.IO = &stats
+		}
+	}
+	if limits != nil && process.Cgroup != "" {
+		process.CgroupLimit = limits.limit(process.Cgroup)
+	}
+	return process, !cached, nil
+}
+
+// newLocalReader возвращает MemoryReader для текущей операционной системы
+func newLocalReader() (MemoryReader, error) {
 	switch runtime.GOOS {
 	case "darwin":
-		reader = &DarwinMemoryReader{}
+		return &DarwinMemoryReader{}, nil
 	case "linux":
-		reader = &LinuxMemoryReader{}
+		return &LinuxMemoryReader{}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
+
+// subcommands — подкоманды, которые выполняются вместо запуска информационной панели
+var subcommands = map[string]func(args []string) error{
+	"helper":       runHelperCommand,
+	"baseline":     runBaselineCommand,
+	"daemon":       runDaemonCommand,
+	"report":       runReportCommand,
+	"replay":       runReplayCommand,
+	"cached":       runCachedCommand,
+	"fleet":        runFleetCommand,
+	"chargeback":   runChargebackCommand,
+	"compare":      runCompareCommand,
+	"reconcile":    runReconcileCommand,
+	"explain":      runExplainCommand,
+	"procfs-check": runProcfsCheckCommand,
+	"version":      runVersionCommand,
+	"self-update":  runSelfUpdateCommand,
+	"tray":         runTrayCommand,
+	"doctor":       runDoctorCommand,
+}
+
+func main() {
+	// С setuid root работал бы весь процесс — с плагинами, экспортом в файлы и путями
+	// из аргументов пользователя, включая путь сокета помощника. Права root получает
+	// только помощник, запущенный администратором (см. "helper --socket")
+	if os.Geteuid() != os.Getuid() {
+		fmt.Fprintln(os.Stderr, "Error: memory-analyzer must not be installed setuid; run \"memory-analyzer helper --socket\" as a root service instead")
+		os.Exit(1)
+	}
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	exportPath := flag.String("export", "", "write the process table to `file` (\"-\" for stdout) and exit")
+	exportFormat := flag.String("export-format", ExportText, "export `format`: text, csv or json")
+	var pinned stringList
+	flag.Var(&pinned, "pin", "always show processes with this `name` at the top (repeatable)")
+	systemInterval := flag.Duration("interval", 0, "refresh `interval` of the system panel (default 3s)")
+	processInterval := flag.Duration("process-interval", 0, "refresh the process table every `interval` (default: with the system panel)")
+	detailInterval := flag.Duration("detail-interval", 0, "refresh the inspect window, file census and collectors every `interval` (default: with the system panel)")
+	batteryInterval := flag.Duration("battery-interval", 10*time.Second, "refresh `interval` while running on battery power (0 disables)")
+	changeThreshold := flag.String("change-threshold", "0", "redraw only when values change by more than `size` (e.g. 1MB)")
+	var adb adbFlag
+	flag.Var(&adb, "adb", "read memory of an Android device over adb (use --adb=`serial` to pick a device)")
+	appBundle := flag.String("app-bundle", "", "watch footprint and memory events of the macOS app with this bundle `id`")
+	dropPrivs := flag.Bool("drop-privileges", false, "when run via sudo, read processes in a privileged helper and run everything else as the invoking user")
+	helperSocket := flag.String("helper-socket", "", "read processes through a privileged helper listening on this unix `socket`")
+	debugLogPath := flag.String("debug-log", "", "append every collection error with full details to `file`")
+	top := flag.Int("top", 10, "number of processes in the table (0 shows all; rows that do not fit the terminal scroll)")
+	showIO := flag.Bool("io", false, "show per-process I/O columns (Linux)")
+	baselinePath := flag.String("baseline", "", "highlight processes deviating from the baseline in `file` (see \"baseline save\")")
+	baselinePercent := flag.Float64("baseline-percent", 50, "minimum deviation from the baseline in `percent`")
+	baselineDelta := flag.String("baseline-delta", "50MB", "minimum deviation from the baseline as a `size`")
+	var sinkSpecs stringList
+	flag.Var(&sinkSpecs, "sink", "also send samples to `type:target`, e.g. jsonl:samples.jsonl.gz or prometheus:127.0.0.1:9101 (repeatable)")
+	hostname := flag.String("hostname", "", "report this `name` instead of the detected hostname in samples, metrics and notifications")
+	var labels patternList
+	flag.Var(&labels, "label", "attach `key=value` to exported samples, metrics and notifications (repeatable)")
+	sortFlag := flag.String("sort", "", "sort the table by comma-separated `columns` (pid, name, memory, io, state; \"-\" for descending), e.g. name,-memory")
+	hysteresis := flag.Int("hysteresis", 0, "keep table rows in place unless a process moves by more than `N` positions (0 disables)")
+	allowAdmin := flag.Bool("allow-admin-actions", false, "allow dropping caches (D) and compacting memory (C) from the dashboard, after confirmation (requires root)")
+	readOnly := flag.Bool("read-only", false, "disable actions that change the system (kill, freeze, admin actions) and hide their keys")
+	auditPath := flag.String("audit-log", defaultStatePath("audit.jsonl"), "append kill, freeze and admin actions to this JSON Lines `file` (empty disables the file)")
+	silenceDuration := flag.Duration("silence-duration", defaultSilenceDuration, "silence firing alerts for this `duration` when S is pressed")
+	statePath := flag.String("state-file", defaultStatePath("dashboard-state.json.gz"), "resume history, alerts, pinned processes and the baseline from this `file`, saving them every minute and on exit (empty disables)")
+	presetName := flag.String("preset", "", "start with the named view `preset` from the configuration file")
+	configPath := flag.String("config", defaultConfigPath(), "path to the JSON configuration `file`")
+	plain := flag.Bool("plain", false, "print the dashboard as plain \"label: value\" lines without tables, colors or cursor control (for screen readers)")
+	var ignore patternList
+	flag.Var(&ignore, "ignore", "hide processes whose name matches this regular `expression` from the table (repeatable)")
+	ignoreSelf := flag.Bool("ignore-self", false, "hide the analyzer itself and the shell that started it from the table")
+	showHidden := flag.Bool("show-hidden", false, "show a summary row with the number and memory of hidden processes")
+	kernelThreads := flag.String("kernel-threads", "", "show kernel threads in brackets (mark), hide them (hide) or summarize them in one row (group)")
+	procfsRoot := flag.String("procfs", "", "read processes from a procfs snapshot in `dir` instead of /proc (Linux reader)")
+	procfsSnapshot := flag.String("procfs-snapshot", "", "save the /proc and cgroup files the Linux reader uses to `dir` as a test fixture and exit")
+	swapThrashRate := flag.String("swap-thrash-rate", defaultSwapThrashRate, "swap in+out `rate` per second that counts as thrashing")
+	swapThrashTicks := flag.Int("swap-thrash-ticks", defaultSwapThrashTicks, "consecutive `samples` above the swap thrash rate before thrashing is reported")
+	flag.BoolVar(&reportHostMemory, "host-memory", false, "inside a container, report the host's total memory instead of the cgroup limit")
+	flag.BoolVar(&fullProcessScan, "full-scan", false, "re-read every process's owner, namespace and cgroup on each refresh instead of only for new or changed processes")
+	flag.BoolVar(&wslQueryHost, "wsl-host", false, "under WSL2, also show Windows memory and the vmmem working set via powershell.exe (every 30s)")
+	churnInterval := flag.Duration("churn-interval", 0, "poll the process list at this `interval` (e.g. 200ms) to count short-lived processes (0 disables)")
+	flag.Usage = func() { printCommandHelp(flag.CommandLine.Output(), "", flag.CommandLine) }
+	flag.Parse()
+
+	if *readOnly && *allowAdmin {
+		fmt.Println("Error: --read-only and --allow-admin-actions cannot be used together")
+		return
+	}
+
+	fileConfig, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	if err := applyTheme(fileConfig.Theme); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	threshold, err := parseByteSize(*changeThreshold)
+	if err != nil {
+		fmt.Printf("Invalid change threshold: %s\n", *changeThreshold)
+		return
+	}
+	budgets, err := fileConfig.ParseBudgets()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	// Поврежденный файл состояния не мешает запуску: панель начинает без накопленной истории
+	resumed, err := LoadRuntimeState(*statePath)
+	if err != nil {
+		fmt.Printf("Error loading state, starting afresh: %v\n", err)
+	}
+	if *baselinePath == "" && resumed.Baseline != "" {
+		if _, err := os.Stat(resumed.Baseline); err == nil {
+			*baselinePath = resumed.Baseline
+		}
+	}
+
+	var baseline *Baseline
+	if *baselinePath != "" {
+		if baseline, err = LoadBaseline(*baselinePath); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	baselineBytes, err := parseByteSize(*baselineDelta)
+	if err != nil {
+		fmt.Printf("Invalid baseline delta: %s\n", *baselineDelta)
+		return
+	}
+
+	alerts, err := NewAlertEngine(fileConfig.Alerts)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	alerts.Restore(resumed.Alerts, resumed.Silences)
+
+	ignoreList, err := NewIgnoreList(append(fileConfig.Ignore, ignore...), *ignoreSelf || fileConfig.IgnoreSelf, *showHidden || fileConfig.ShowHidden)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	kernelMode := KernelThreadsMark
+	if fileConfig.KernelThreads != "" {
+		kernelMode = fileConfig.KernelThreads
+	}
+	if *kernelThreads != "" {
+		kernelMode = *kernelThreads
+	}
+	if !isValidKernelThreadsMode(kernelMode) {
+		fmt.Printf("Unknown kernel threads mode: %s\n", kernelMode)
+		return
+	}
+
+	sortSpec := defaultSort
+	if fileConfig.Sort != "" {
+		sortSpec = fileConfig.Sort
+	}
+	if *sortFlag != "" {
+		sortSpec = *sortFlag
+	}
+	sortOrder, err := ParseSortSpec(sortSpec)
+	if err != nil {
+		fmt.Printf("Invalid sort: %v\n", err)
+		return
+	}
+
+	if !isValidExportFormat(*exportFormat) {
+		fmt.Printf("Unknown export format: %s\n", *exportFormat)
+		return
+	}
+
+	// Разовый снимок procfs для интеграционных проверок без запуска информационной панели
+	if *procfsSnapshot != "" {
+		if err := captureProcfs(*procfsSnapshot); err != nil {
+			fmt.Printf("Error capturing procfs snapshot: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("procfs snapshot saved to %s\n", *procfsSnapshot)
+		return
+	}
+
+	var reader MemoryReader
+	switch {
+	case adb.enabled:
+		adbReader := NewAdbMemoryReader(adb.serial)
+		// Встроенные коллекторы описывают локальную машину, а не устройство
+		collectors = nil
+		RegisterCollector(&adbCategoryCollector{reader: adbReader})
+		reader = adbReader
+	case *helperSocket != "":
+		client, err := dialHelper(*helperSocket)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer client.Close()
+		reader = client
+	case *procfsRoot != "":
+		reader = &LinuxMemoryReader{Root: *procfsRoot}
+	case *dropPrivs:
+		client, err := privilegedReader()
+		if err != nil {
+			fmt.Printf("Error dropping privileges: %v\n", err)
+			return
+		}
+		defer client.Close()
+		reader = client
 	default:
-		fmt.Printf("Unsupported operating system: %s\n", runtime.GOOS)
+		reader, err = newLocalReader()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if *appBundle != "" {
+		if runtime.GOOS != "darwin" {
+			fmt.Println("--app-bundle is only supported on macOS")
+			return
+		}
+		RegisterCollector(newAppFootprintCollector(*appBundle))
+	}
+
+	thrash, err := NewSwapThrashDetector(*swapThrashRate, *swapThrashTicks)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if adb.enabled && *churnInterval > 0 {
+		fmt.Println("--churn-interval is not supported for Android devices")
+		return
+	}
+	churn, err := startChurnMonitor(*churnInterval)
+	if err != nil {
+		fmt.Printf("Error starting churn monitor: %v\n", err)
+		return
+	}
+	if churn != nil {
+		defer churn.Stop()
+	}
+
+	if err := registerPlugins(fileConfig); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	defer closeCollectors()
+
+	if err := configureServerSecurity(fileConfig.Server); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	if err := configureIdentity(fileConfig.Identity, *hostname, labels); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	if err := configureSinks(fileConfig); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	sinks, err := NewSinkSet(sinkSpecs)
+	if err != nil {
+		fmt.Printf("Error creating sink: %v\n", err)
+		return
+	}
+	defer sinks.Close()
+
+	intervals, err := refreshIntervals(fileConfig.Refresh, *systemInterval, *processInterval, *detailInterval)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
 		return
 	}
 
 	// Создание конфигурации
 	config := DisplayConfig{
-		UpdateInterval: 3 * time.Second,
-		TopProcesses:   10,
+		UpdateInterval:    intervals.System,
+		BatteryInterval:   *batteryInterval,
+		TopProcesses:      *top,
+		ExportFormat:      *exportFormat,
+		PinnedNames:       pinned,
+		Budgets:           budgets,
+		ChangeThreshold:   threshold,
+		Columns:           fileConfig.Columns,
+		ShowIO:            *showIO,
+		Sort:              sortOrder,
+		RowHysteresis:     *hysteresis,
+		AllowAdminActions: *allowAdmin,
+		SilenceDuration:   *silenceDuration,
+		Plain:             *plain,
+		Ignore:            ignoreList,
+		KernelThreads:     kernelMode,
+		Baseline:          baseline,
+		BaselineThresholds: BaselineThresholds{
+			Percent: *baselinePercent,
+			Bytes:   baselineBytes,
+		},
+	}
+	presets, err := buildPresetConfigs(config, fileConfig.Presets)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	if config, err = presets.lookup(*presetName); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Разовый экспорт без запуска информационной панели
+	if *exportPath != "" {
+		processes, err := collectProcesses(reader, nil)
+		if err != nil {
+			fmt.Printf("Error getting process list: %v\n", err)
+			os.Exit(1)
+		}
+		processLabeler.apply(processes)
+		fillExtraColumns(processes, visibleProcesses(processes, config, nil), config.Columns)
+		if err := exportToPath(*exportPath, visibleProcesses(processes, config, nil), config.ExportFormat); err != nil {
+			fmt.Printf("Error exporting process table: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Настройка обработки сигналов
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	resizeChan := make(chan os.Signal, 1)
+	notifyResize(resizeChan)
+
+	// Сводка по сеансу выводится при выходе после восстановления режима терминала
+	session := newRecordingAccumulator()
+	defer printSessionSummary(session)
+
+	// Чтение горячих клавиш, если программа запущена в терминале
+	var debugLog io.Writer
+	if *debugLogPath != "" {
+		logFile, err := os.OpenFile(*debugLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Printf("Error opening debug log: %v\n", err)
+			return
+		}
+		defer logFile.Close()
+		debugLog = logFile
+		panicLogOutput = logFile
+	}
+
+	state := NewViewState()
+	state.ReadOnly = *readOnly
+	// В режиме только для просмотра действий нет, поэтому журнал аудита не открывается
+	if !*readOnly {
+		if state.Audit, err = OpenAuditLog(*auditPath); err != nil {
+			fmt.Printf("Error opening audit log: %v\n", err)
+			return
+		}
+		defer state.Audit.Close()
+	}
+	state.ShowIO = config.ShowIO
+	state.Preset = *presetName
+	state.WatchNames = resumed.WatchNames
+	state.restorePins(reader, resumed.Pinned)
+	state.Errors = NewErrorReport(debugLog)
+	keys := make(chan string)
+	if isTerminal(os.Stdin) {
+		if restore, err := enableKeyboardInput(); err == nil {
+			defer restore()
+			if !config.Plain {
+				defer enableMouseReporting()()
+			}
+			go readKeys(keys)
+			state.Interactive = true
+		}
+	}
+	if isTerminal(os.Stdout) && !config.Plain {
+		state.ScreenRows = terminalRows()
+	}
 
 	// Создание ticker
 	ticker := time.NewTicker(config.UpdateInterval)
@@ -528,42 +1531,324 @@ func main() {
 
 	fmt.Printf("Starting Memory Analyzer on %s\n", runtime.GOOS)
 
+	var sample Sample
+	refresher := newPanelRefresher(intervals)
+	history, err := NewHistoryFromConfig(fileConfig.History)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+	history.Restore(resumed.History)
+	history.RestoreTiers(resumed.Tiers)
+	lifetimes := NewLifetimeTracker()
+	lifetimes.ExitTime = churn.ExitTime
+	var lastRendered *dashboardSnapshot
+	var lastError string
+
+	// Состояние сохраняется с абсолютным путем базовой линии, чтобы ее нашел запуск из другого каталога
+	saver := &stateSaver{path: *statePath}
+	baselineFile := *baselinePath
+	if baselineFile != "" {
+		baselineFile, _ = filepath.Abs(baselineFile)
+	}
+	runtimeState := func() RuntimeState {
+		saved := RuntimeState{History: history.Points(), Tiers: history.Tiers(), Alerts: alerts.Active(), Silences: alerts.Silences(), WatchNames: state.WatchNames, Baseline: baselineFile}
+		// До первого замера имена закрепленных процессов неизвестны, и сохраняются прежние
+		saved.Pinned = resumed.Pinned
+		if !sample.Time.IsZero() {
+			saved.Pinned = state.pinnedProcesses(sample.Processes)
+		}
+		return saved
+	}
+
 	// Основной цикл
 	for {
 		select {
 		case <-sigChan:
 			fmt.Println("\nReceived interrupt signal. Exiting...")
+			if resumed := state.ResumeFrozen(reader); resumed > 0 {
+				fmt.Printf("Resumed %d frozen processes\n", resumed)
+			}
+			if err := saver.Save(runtimeState()); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			if err := history.Close(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
 			return
-		case <-ticker.C:
-			// Получение системной информации
-			sysInfo, err := reader.ReadSystemMemory()
-			if err != nil {
-				fmt.Printf("Error reading system memory: %v\n", err)
+		case <-resizeChan:
+			if state.ScreenRows == 0 {
+				continue
+			}
+			state.ScreenRows = terminalRows()
+			if !sample.Time.IsZero() {
+				DisplayDashboard(sample, config, state)
+				lastRendered = newDashboardSnapshot(sample, config, state)
+			}
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			if state.PendingAction != nil {
+				if key == "y" {
+					if result, err := runAdminAction(*state.PendingAction, reader, state.Audit); err != nil {
+						state.Status = fmt.Sprintf("Error: %v", err)
+					} else {
+						state.Status = result
+					}
+				} else {
+					state.Status = "Cancelled"
+				}
+				state.PendingAction = nil
+				if !sample.Time.IsZero() {
+					DisplayDashboard(sample, config, state)
+					lastRendered = newDashboardSnapshot(sample, config, state)
+				}
+				continue
+			}
+			if state.PendingKill != nil {
+				if key == "y" {
+					state.Status = killProcesses(reader, state.PendingKill, state.Audit)
+					state.ClearMarks()
+				} else {
+					state.Status = "Cancelled"
+				}
+				state.PendingKill = nil
+				if !sample.Time.IsZero() {
+					DisplayDashboard(sample, config, state)
+					lastRendered = newDashboardSnapshot(sample, config, state)
+				}
+				continue
+			}
+			// Во время ввода строки поиска клавиши дополняют ее, а не управляют панелью
+			if state.Searching && !strings.HasPrefix(key, mouseKeyPrefix) {
+				state.HandleSearchKey(key)
+				if !sample.Time.IsZero() {
+					DisplayDashboard(sample, config, state)
+					lastRendered = newDashboardSnapshot(sample, config, state)
+				}
+				continue
+			}
+			if action, ok := findAdminAction(key); ok && config.AllowAdminActions {
+				state.PendingAction = &action
+				state.Status = fmt.Sprintf("%s? Press y to confirm, any other key to cancel", action.Prompt)
+				if !sample.Time.IsZero() {
+					DisplayDashboard(sample, config, state)
+					lastRendered = newDashboardSnapshot(sample, config, state)
+				}
+				continue
+			}
+			view := visibleProcesses(sample.Processes, config, state)
+			if event, ok := parseMouseKey(key); ok {
+				if !state.HandleMouse(event, view, config) {
+					continue
+				}
+				if state.InspectPID != 0 && state.Details == nil {
+					state.Details = inspectProcess(reader, state.InspectPID)
+				}
+				if !sample.Time.IsZero() {
+					DisplayDashboard(sample, config, state)
+					lastRendered = newDashboardSnapshot(sample, config, state)
+				}
 				continue
 			}
+			switch key {
+			case "j", "down":
+				state.MoveSelection(1, len(view))
+			case "k", "up":
+				state.MoveSelection(-1, len(view))
+			case "pgdn":
+				state.PageSelection(1, len(view))
+			case "pgup":
+				state.PageSelection(-1, len(view))
+			case "home":
+				state.Selected = 0
+			case "end":
+				state.MoveSelection(len(view), len(view))
+			case " ":
+				if state.Selected < len(view) {
+					state.ToggleMark(view[state.Selected])
+					state.MoveSelection(1, len(view))
+				}
+			case "a":
+				state.MarkAll(view)
+			case "p":
+				if len(state.Marked) == 0 {
+					if state.Selected < len(view) {
+						state.TogglePin(view[state.Selected])
+					}
+				} else {
+					state.PinTargets(state.actionTargets(view))
+					state.ClearMarks()
+				}
+			case "w":
+				targets := state.actionTargets(view)
+				state.Status = fmt.Sprintf("Added %d names to the watch list", state.WatchTargets(targets))
+				state.ClearMarks()
+			case "K":
+				targets := state.actionTargets(view)
+				switch {
+				case state.ReadOnly:
+					state.Status = "Killing processes is disabled in read-only mode"
+				case adb.enabled:
+					state.Status = "Killing processes is not supported for Android devices"
+				case len(targets) > 0:
+					state.PendingKill = targets
+					state.Status = killPrompt(targets)
+				}
+			case "z":
+				targets := state.actionTargets(view)
+				switch {
+				case state.ReadOnly:
+					state.Status = "Freezing processes is disabled in read-only mode"
+				case adb.enabled:
+					state.Status = "Freezing processes is not supported for Android devices"
+				case len(targets) > 0:
+					state.Status = state.FreezeTargets(reader, targets)
+					state.ClearMarks()
+				}
+			case "enter":
+				if state.Selected < len(view) {
+					state.ToggleInspect(view[state.Selected])
+					if state.InspectPID != 0 {
+						state.Details = inspectProcess(reader, state.InspectPID)
+					}
+				}
+			case "esc":
+				switch {
+				case state.InspectPID != 0:
+					state.CloseInspect()
+				case state.Search != "":
+					state.ClearSearch()
+				default:
+					state.ClearMarks()
+				}
+			case "/":
+				state.StartSearch()
+			case "x":
+				state.ShowErrors = !state.ShowErrors
+			case "i":
+				state.ShowIO = !state.ShowIO
+			case "v":
+				state.ShowTunables = !state.ShowTunables
+			case "s":
+				state.CycleSort(config)
+			case "g":
+				state.CycleGroup()
+			case "P":
+				state.CyclePreset(presets)
+				config, _ = presets.lookup(state.Preset)
+			case "f":
+				state.ToggleFiles()
+				if state.ShowFiles {
+					state.Files = mappedFileCensus(reader, sample.Processes)
+				}
+			case "A", "S":
+				command := AlertCommand{Action: AlertAck}
+				if key == "S" {
+					command = toggleSilenceCommand(sample.Alerts, config.SilenceDuration)
+				}
+				rules, err := alerts.Apply(command, time.Now())
+				state.Status = alertCommandStatus(command, rules, err)
+				sample.Alerts = alerts.Active()
+			case "e":
+				path := exportFileName(config.ExportFormat, time.Now())
+				exported := view
+				if len(state.Marked) > 0 {
+					exported = state.actionTargets(view)
+					state.ClearMarks()
+				}
+				if err := exportToPath(path, exported, config.ExportFormat); err != nil {
+					state.Status = fmt.Sprintf("Error exporting process table: %v", err)
+				} else {
+					state.Status = fmt.Sprintf("Exported to %s", path)
+				}
+			default:
+				continue
+			}
+			if !sample.Time.IsZero() {
+				DisplayDashboard(sample, config, state)
+				lastRendered = newDashboardSnapshot(sample, config, state)
+			}
+		case <-ticker.C:
+			// Переключение периода обновления при смене источника питания
+			if config.BatteryInterval > 0 {
+				if battery := onBatteryPower(); battery != state.OnBattery {
+					state.OnBattery = battery
+					ticker.Reset(config.refreshInterval(battery))
+					lastRendered = nil
+				}
+			}
 
-			// Получение списка процессов
-			pids, err := reader.GetProcessList()
+			// Сбор системной информации, процессов и данных коллекторов
+			state.Errors.BeginTick()
+			next, err := refresher.collect(reader, sample, state.Errors)
 			if err != nil {
-				fmt.Printf("Error getting process list: %v\n", err)
+				state.Errors.Add("sample collection failures", err)
+				if sample.Time.IsZero() {
+					if message := err.Error(); message != lastError {
+						fmt.Printf("Error collecting sample: %v\n", err)
+						lastError = message
+					}
+				} else {
+					// Показываем предыдущие данные со сводкой ошибок вместо прокрутки экрана
+					DisplayDashboard(sample, config, state)
+					lastRendered = nil
+				}
 				continue
 			}
-
-			// Сбор информации о процессах
-			var processes []ProcessInfo
-			for _, pid := range pids {
-				if mem, err := reader.ReadProcessMemory(pid); err == nil {
-					name := getProcName(pid)
-					processes = append(processes, ProcessInfo{
-						PID:         pid,
-						Name:        name,
-						MemoryUsage: mem,
-					})
+			next.Churn = churn.Snapshot(next.Time)
+			if refresher.Processes {
+				applyIORates(next.Processes, sample.Processes, refresher.ProcessElapsed)
+				applyCgroupEventDeltas(next.CgroupEvents, sample.CgroupEvents)
+			}
+			sample = next
+			if refresher.staggered() {
+				state.ProcessesUpdated, state.DetailsUpdated = refresher.ProcessesAt, refresher.DetailsAt
+			}
+			thrash.Observe(sample.Time, sample.Swap)
+			state.PruneMarks(sample.Processes)
+			state.PruneFrozen(sample.Processes)
+			state.UpdateRanks(sample.Processes, config)
+			session.add(sample)
+			history.Add(sample)
+			if err := history.SpillError(); err != nil {
+				state.Errors.Add("history spill failures", err)
+			}
+			sample.Exited = lifetimes.Observe(sample)
+			state.Exited = lifetimes.Recent()
+			sample.Forecast = forecastExhaustion(history, sample.Time)
+			sample.Alerts = alerts.Evaluate(sample, history)
+			sample.Audit = state.Audit.Drain()
+			sinks.Write(sample, state.Errors)
+			if saver.Due(sample.Time) {
+				if err := saver.Save(runtimeState()); err != nil {
+					state.Errors.Add("state saving failures", err)
 				}
 			}
+			if refresher.Details {
+				if state.InspectPID != 0 {
+					state.Details = inspectProcess(reader, state.InspectPID)
+				}
+				if state.ShowFiles {
+					state.Files = mappedFileCensus(reader, sample.Processes)
+				}
+			}
+			// Значения дополнительных колонок хранятся в строках процессов и обновляются вместе с ними
+			if refresher.Processes {
+				fillExtraColumns(sample.Processes, visibleProcesses(sample.Processes, config, state), config.Columns)
+			}
+
+			// Панель перерисовывается только при значимых изменениях
+			snapshot := newDashboardSnapshot(sample, config, state)
+			if !lastRendered.changedBeyond(snapshot, config.ChangeThreshold) {
+				continue
+			}
 
 			// Отображение информационной панели
-			DisplayDashboard(sysInfo, processes, config)
+			DisplayDashboard(sample, config, state)
+			lastRendered = snapshot
 		}
 	}
 }
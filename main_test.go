@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Фаззинг разборщиков вывода системы: на любых входных данных они не должны паниковать,
+// а ошибка разбора всегда оборачивает ErrParse. Затравочные данные — настоящий вывод
+// и его искажения: отрицательные значения, десятичная запятая, обрезанные строки,
+// NaN и переполнение. Запуск: go test -fuzz FuzzParseMemSize
+
+const meminfoSample = `MemTotal:       32601228 kB
+MemFree:         8210044 kB
+MemAvailable:   24120336 kB
+SwapTotal:       8388604 kB
+SwapFree:        8120012 kB
+`
+
+const vmStatSample = `Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                               12473.
+Pages active:                            412911.
+Pages inactive:                          402147.
+Pages speculative:                         9862.
+Pages wired down:                        157327.
+Pages occupied by compressor:             92018.
+File-backed pages:                       288211.
+Anonymous pages:                         536709.
+Swapins:                                      0.
+Swapouts:                                     0.
+`
+
+func FuzzParseMemInfo(f *testing.F) {
+	for _, seed := range []string{
+		meminfoSample,
+		"MemTotal:       -32601228 kB\n",
+		"MemTotal:       32601228,5 kB\n",
+		"MemTotal:       3260",
+		"MemTotal:",
+		"MemTotal:       NaN kB\n",
+		"MemTotal:       18446744073709551616 kB\n",
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		stats, err := parseMemInfo(strings.NewReader(input))
+		if err != nil {
+			// Строка длиннее буфера bufio.Scanner — ошибка чтения, а не разбора
+			if !errors.Is(err, ErrParse) && !errors.Is(err, bufio.ErrTooLong) {
+				t.Fatalf("ошибка без ErrParse: %v", err)
+			}
+			return
+		}
+		if len(stats) == 0 {
+			t.Fatal("пустой результат без ошибки")
+		}
+		for key := range stats {
+			if key != strings.TrimSpace(key) || !strings.Contains(input, key) {
+				t.Fatalf("ключ %q не из входных данных", key)
+			}
+		}
+	})
+}
+
+func FuzzParseMemSize(f *testing.F) {
+	for _, seed := range []string{
+		"1024.00M", "1024,00M", "0.00M", "2G", "512K", "7",
+		"-1024.00M", "-0", "1024.", ",5M", "M", "",
+		"NaN", "NaNM", "Inf", "-Inf", "1e400",
+		"18446744073709551615", "18446744073709551616", "16777216T",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		size, err := parseMemSize(input)
+		if err != nil {
+			if !errors.Is(err, ErrParse) {
+				t.Fatalf("parseMemSize(%q): ошибка без ErrParse: %v", input, err)
+			}
+			return
+		}
+		if strings.HasPrefix(strings.TrimSpace(input), "-") && size != 0 {
+			t.Fatalf("parseMemSize(%q) = %d для отрицательного размера", input, size)
+		}
+	})
+}
+
+func FuzzExtractValue(f *testing.F) {
+	for _, seed := range []string{
+		"VmRSS:\t  1234 kB", "VmRSS:\t1234kB", "VmRSS:",
+		"VmRSS:\t  -1234 kB", "VmRSS:\t  12,34 kB", "VmRSS:\t  NaN kB",
+		"VmRSS:\t  18446744073709551616 kB", "VmRSS", "",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		if _, err := extractValue(input); err != nil && !errors.Is(err, ErrParse) {
+			t.Fatalf("extractValue(%q): ошибка без ErrParse: %v", input, err)
+		}
+	})
+}
+
+func FuzzParseVmStat(f *testing.F) {
+	for _, seed := range []string{
+		vmStatSample,
+		vmStatSample[:len(vmStatSample)/2],
+		"Mach Virtual Memory Statistics: (page size of -4096 bytes)\nPages free: 1.\n",
+		"Mach Virtual Memory Statistics: (page size of 99999999999999999999 bytes)\n",
+		"Pages free:                              -12473.\n",
+		"Pages free:                              12473,5.\n",
+		"Pages free:                                 NaN.\n",
+		"Pages free:                18446744073709551616.\n",
+		"Pages free:", ":", "",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		stats, pageSize := parseVmStat(input)
+		if pageSize != 0 && !strings.Contains(input, "page size of") {
+			t.Fatalf("размер страницы %d без заголовка", pageSize)
+		}
+		for key := range stats {
+			if !strings.Contains(input, key) {
+				t.Fatalf("ключ %q не из входных данных", key)
+			}
+		}
+	})
+}
+
+func FuzzParseSwapUsage(f *testing.F) {
+	for _, seed := range []string{
+		"total = 2048.00M  used = 1024.00M  free = 1024.00M  (encrypted)",
+		"total = 2048,00M  used = 1024,00M  free = 1024,00M  (encrypted)",
+		"total = -2048.00M  used = 0.00M  free = -1.00M",
+		"total = 2048.00M  used = 1024.00M  free = 40",
+		"total = 2048.00M  used = 1024.00M",
+		"total = NaN  used = NaN  free = NaN",
+		"total = 99999999999999999999T  used = 0.00M  free = 1.00M",
+		"free = 4096.00M  total = 1024.00M",
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		total, free, err := parseSwapUsage(input)
+		if err != nil {
+			if !errors.Is(err, ErrParse) {
+				t.Fatalf("parseSwapUsage(%q): ошибка без ErrParse: %v", input, err)
+			}
+			return
+		}
+		if free > total {
+			t.Fatalf("parseSwapUsage(%q): свободно %d больше общего объема %d", input, free, total)
+		}
+	})
+}
+
+// TestParseSwapUsage проверяет разбор настоящего вывода "sysctl -n vm.swapusage"
+func TestParseSwapUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		total  uint64
+		free   uint64
+		err    bool
+	}{
+		{name: "macOS 10.9 Intel", output: "total = 64.00M  used = 0.00M  free = 64.00M\n", total: 67108864, free: 67108864},
+		{name: "macOS 10.15 Intel", output: "total = 1024.00M  used = 220.75M  free = 803.25M  (encrypted)\n", total: 1073741824, free: 842268672},
+		{name: "macOS 14 Apple Silicon", output: "total = 3072.00M  used = 2139.31M  free = 932.69M  (encrypted)\n", total: 3221225472, free: 977996349},
+		{name: "swap off", output: "total = 0.00M  used = 0.00M  free = 0.00M  (encrypted)\n", total: 0, free: 0},
+		{name: "with sysctl name", output: "vm.swapusage: total = 2048.00M  used = 1024.00M  free = 1024.00M  (encrypted)\n", total: 2147483648, free: 1073741824},
+		{name: "gigabytes", output: "total = 4.00G  used = 2.50G  free = 1.50G  (encrypted)", total: 4294967296, free: 1610612736},
+		{name: "comma decimal locale", output: "total = 2048,00M  used = 1024,00M  free = 1024,00M  (encrypted)\n", total: 2147483648, free: 1073741824},
+		{name: "comma decimal without encrypted", output: "total = 1024,00M  used = 220,75M  free = 803,25M\n", total: 1073741824, free: 842268672},
+		{name: "no used", output: "total = 1024.00M  free = 803.25M  (encrypted)", total: 1073741824, free: 842268672},
+		{name: "free above total", output: "total = 1024.00M  used = 0.00M  free = 2048.00M", total: 1073741824, free: 1073741824},
+		{name: "no free", output: "total = 1024.00M  used = 220.75M  (encrypted)", err: true},
+		{name: "no total", output: "used = 220.75M  free = 803.25M  (encrypted)", err: true},
+		{name: "only encrypted", output: "(encrypted)\n", err: true},
+		{name: "empty", output: "", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, free, err := parseSwapUsage(tt.output)
+			if tt.err {
+				if !errors.Is(err, ErrParse) {
+					t.Fatalf("ошибка = %v, ожидалась ErrParse", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.total || free != tt.free {
+				t.Errorf("total, free = %d, %d; ожидалось %d, %d", total, free, tt.total, tt.free)
+			}
+		})
+	}
+}
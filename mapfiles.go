@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mapping — отображение файла в адресное пространство процесса
+type Mapping struct {
+	Path string
+	//Размер отображения, резидентная часть и пропорциональная доля резидентной части (PSS)
+	Size, Rss, Pss uint64
+}
+
+// MappingReader реализуют источники данных, умеющие читать отображения файлов процесса
+type MappingReader interface {
+	//ReadProcessMappings возвращает отображения файлов процесса; анонимная память
+	//([heap], [stack] и т.п.) не включается
+	ReadProcessMappings(pid int) ([]Mapping, error)
+}
+
+func (l *LinuxMemoryReader) ReadProcessMappings(pid int) ([]Mapping, error) {
+	file, err := os.Open(l.procPath(pid, "smaps"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mappings []Mapping
+	var current *Mapping
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// Строка заголовка отображения: адреса, права, смещение, устройство, inode и путь
+		if !strings.HasSuffix(fields[0], ":") {
+			current = nil
+			if len(fields) >= 6 && strings.HasPrefix(fields[5], "/") {
+				mappings = append(mappings, Mapping{Path: strings.Join(fields[5:], " ")})
+				current = &mappings[len(mappings)-1]
+			}
+			continue
+		}
+		if current == nil || len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "Size:":
+			current.Size = value * 1024
+		case "Rss:":
+			current.Rss = value * 1024
+		case "Pss:":
+			current.Pss = value * 1024
+		}
+	}
+	return mappings, scanner.Err()
+}
+
+// MappedFile — файл, отображенный в память процессов, с суммами по всем процессам
+type MappedFile struct {
+	Path string `json:"path"`
+	//Число процессов, отобразивших файл
+	Processes int    `json:"processes"`
+	Size      uint64 `json:"mapped_bytes"`
+	Rss       uint64 `json:"rss_bytes"`
+	//Сумма PSS — доля файла в физической памяти без двойного учета общих страниц
+	Pss uint64 `json:"pss_bytes"`
+}
+
+// FileCensus — перечень отображенных файлов по всем процессам
+type FileCensus struct {
+	Files []MappedFile
+	//Число процессов, отображения которых не удалось прочитать (обычно из-за прав доступа)
+	Unreadable int
+}
+
+// mappedFileCensus собирает отображения файлов всех процессов и суммирует их по путям;
+// файлы сортируются по убыванию PSS. Возвращает nil, если источник данных не умеет читать отображения
+func mappedFileCensus(reader MemoryReader, processes []ProcessInfo) *FileCensus {
+	mappingReader, ok := reader.(MappingReader)
+	if !ok {
+		return nil
+	}
+	census := &FileCensus{}
+	index := make(map[string]int)
+	for _, process := range processes {
+		mappings, err := mappingReader.ReadProcessMappings(process.PID)
+		if err != nil {
+			census.Unreadable++
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, mapping := range mappings {
+			i, exists := index[mapping.Path]
+			if !exists {
+				i = len(census.Files)
+				index[mapping.Path] = i
+				census.Files = append(census.Files, MappedFile{Path: mapping.Path})
+			}
+			file := &census.Files[i]
+			if !seen[mapping.Path] {
+				seen[mapping.Path] = true
+				file.Processes++
+			}
+			file.Size += mapping.Size
+			file.Rss += mapping.Rss
+			file.Pss += mapping.Pss
+		}
+	}
+	sort.SliceStable(census.Files, func(i, j int) bool {
+		if census.Files[i].Pss != census.Files[j].Pss {
+			return census.Files[i].Pss > census.Files[j].Pss
+		}
+		return census.Files[i].Path < census.Files[j].Path
+	})
+	return census
+}
+
+// FormatFileCensus форматирует таблицу top файлов с наибольшей долей в физической памяти
+func FormatFileCensus(census *FileCensus, top int) string {
+	if census == nil {
+		return "Mapped files are not supported on this system\n"
+	}
+	var res strings.Builder
+	res.WriteString("Mapped Files:\n")
+	header := "PROCS      MAPPED         RSS         PSS  FILE"
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for i, file := range census.Files {
+		if top > 0 && i >= top {
+			break
+		}
+		path := ellipsizeLeft(file.Path, 60)
+		res.WriteString(fmt.Sprintf("%5d  %10s  %10s  %10s  %s\n",
+			file.Processes, FormatMemorySize(file.Size), FormatMemorySize(file.Rss), FormatMemorySize(file.Pss), path))
+	}
+	if census.Unreadable > 0 {
+		res.WriteString(fmt.Sprintf("Mappings of %d processes could not be read (run as root to include them)\n", census.Unreadable))
+	}
+	return res.String()
+}
+
+// ToggleFiles показывает перечень отображенных файлов вместо таблицы процессов или скрывает его
+func (s *ViewState) ToggleFiles() {
+	s.ShowFiles = !s.ShowFiles
+	s.Files = nil
+}
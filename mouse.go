@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Управляющие последовательности включения и выключения отчетов о нажатиях мыши
+// в расширенном формате SGR (1006), который не ограничивает координаты 223 колонками
+const (
+	mouseReportingOn  = "\033[?1000h\033[?1006h"
+	mouseReportingOff = "\033[?1000l\033[?1006l"
+)
+
+// Кнопки мыши в отчетах SGR
+const (
+	mouseLeft      = 0
+	mouseWheelUp   = 64
+	mouseWheelDown = 65
+)
+
+// mouseKeyPrefix начинает имя "клавиши", которым readKeys передает нажатие мыши: "mouse:кнопка:x:y"
+const mouseKeyPrefix = "mouse:"
+
+// mouseWheelStep — на сколько строк смещается выделение за один щелчок колеса
+const mouseWheelStep = 3
+
+// MouseEvent — нажатие кнопки мыши или прокрутка колеса; координаты считаются от 1
+type MouseEvent struct {
+	Button int
+	X, Y   int
+}
+
+// enableMouseReporting включает отчеты о нажатиях мыши и возвращает функцию их выключения
+func enableMouseReporting() func() {
+	fmt.Fprint(os.Stdout, mouseReportingOn)
+	return func() {
+		fmt.Fprint(os.Stdout, mouseReportingOff)
+	}
+}
+
+// decodeMouse разбирает отчет SGR "ESC [ < кнопка ; x ; y M" в начале input и возвращает
+// имя клавиши и длину последовательности; отпускание кнопки (завершающее "m") пропускается
+func decodeMouse(input []byte) (string, int, bool) {
+	if len(input) < 3 || input[0] != 27 || input[1] != '[' || input[2] != '<' {
+		return "", 0, false
+	}
+	end := 3
+	for end < len(input) && input[end] != 'M' && input[end] != 'm' {
+		end++
+	}
+	if end == len(input) {
+		return "", 0, false
+	}
+	fields := strings.Split(string(input[3:end]), ";")
+	if len(fields) != 3 || input[end] == 'm' {
+		return "", end + 1, true
+	}
+	return mouseKeyPrefix + strings.Join(fields, ":"), end + 1, true
+}
+
+// parseMouseKey восстанавливает событие мыши из имени клавиши, полученного от readKeys
+func parseMouseKey(key string) (MouseEvent, bool) {
+	if !strings.HasPrefix(key, mouseKeyPrefix) {
+		return MouseEvent{}, false
+	}
+	fields := strings.Split(strings.TrimPrefix(key, mouseKeyPrefix), ":")
+	if len(fields) != 3 {
+		return MouseEvent{}, false
+	}
+	var values [3]int
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return MouseEvent{}, false
+		}
+		values[i] = value
+	}
+	return MouseEvent{Button: values[0], X: values[1], Y: values[2]}, true
+}
+
+// headerSortColumns сопоставляет заголовки колонок таблицы процессов колонкам сортировки
+var headerSortColumns = map[string]string{
+	"PID":     SortByPID,
+	"NAME":    SortByName,
+	"MEMORY":  SortByMemory,
+	"S":       SortByState,
+	"READ":    SortByIO,
+	"WRITE":   SortByIO,
+	"READ/s":  SortByIO,
+	"WRITE/s": SortByIO,
+}
+
+// headerColumnAt возвращает колонку сортировки под позицией x (от 1) строки заголовка таблицы
+func headerColumnAt(header string, x int) (string, bool) {
+	runes := []rune(header)
+	i := x - 1
+	if i < 0 || i >= len(runes) || runes[i] == ' ' {
+		return "", false
+	}
+	start, end := i, i
+	for start > 0 && runes[start-1] != ' ' {
+		start--
+	}
+	for end < len(runes) && runes[end] != ' ' {
+		end++
+	}
+	column, ok := headerSortColumns[string(runes[start:end])]
+	return column, ok
+}
+
+// SortByColumn сортирует таблицу по колонке; повторный выбор той же колонки меняет
+// направление. Память и ввод-вывод сначала сортируются по убыванию, остальные — по возрастанию
+func (s *ViewState) SortByColumn(column string, config DisplayConfig) {
+	descending := column == SortByMemory || column == SortByIO
+	if current := s.sortSpec(config); len(current) > 0 && current[0].Column == column {
+		descending = !current[0].Descending
+	}
+	key := column
+	if descending {
+		key = "-" + column
+	}
+	if column != SortByMemory {
+		key += "," + defaultSort
+	}
+	s.SortKey = key
+	s.Ranks = nil
+}
+
+// HandleMouse применяет событие мыши к таблице view: щелчок по заголовку колонки сортирует
+// по ней, щелчок по строке выделяет процесс и открывает окно просмотра, колесо перемещает
+// выделение. Возвращает false, если событие ничего не изменило
+func (s *ViewState) HandleMouse(event MouseEvent, view []ProcessInfo, config DisplayConfig) bool {
+	switch event.Button {
+	case mouseWheelUp:
+		s.MoveSelection(-mouseWheelStep, len(view))
+		return true
+	case mouseWheelDown:
+		s.MoveSelection(mouseWheelStep, len(view))
+		return true
+	case mouseLeft:
+	default:
+		return false
+	}
+	if s.TableHeaderRow == 0 {
+		return false
+	}
+	if event.Y == s.TableHeaderRow {
+		column, ok := headerColumnAt(s.TableHeader, event.X)
+		if ok {
+			s.SortByColumn(column, config)
+		}
+		return ok
+	}
+	row := event.Y - s.TableHeaderRow - (tableHeaderLines - 1)
+	if row < 0 || (s.PaneRows > 0 && row >= s.PaneRows) {
+		return false
+	}
+	row += s.ScrollOffset
+	if row >= len(view) {
+		return false
+	}
+	s.Selected = row
+	if s.InspectPID != view[row].PID {
+		s.ToggleInspect(view[row])
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultNotifyTop — число процессов в выдержке из таблицы в уведомлении
+const defaultNotifyTop = 10
+
+// notifyQueueSize — число уведомлений, ожидающих отправки, после которого новые пропускаются
+const notifyQueueSize = 16
+
+// alertTracker отслеживает переходы оповещений между замерами для приемников-уведомлений:
+// о каждом срабатывании сообщается один раз, а не на каждом замере. Подтвержденные
+// и заглушенные оповещения не рассылаются; если заглушение истекло, а условие все еще
+// выполняется, оповещение рассылается как новое
+type alertTracker struct {
+	notified map[string]bool
+}
+
+func newAlertTracker() *alertTracker {
+	return &alertTracker{notified: make(map[string]bool)}
+}
+
+// Observe возвращает оповещения, сработавшие с предыдущего замера, и правила оповещений,
+// о срабатывании которых сообщалось и которые с тех пор перестали выполняться
+func (t *alertTracker) Observe(alerts []Alert) (fired []Alert, resolved []string) {
+	current := make(map[string]bool, len(alerts))
+	for _, alert := range alerts {
+		current[alert.Rule] = true
+		if t.notified[alert.Rule] || alert.Muted() {
+			continue
+		}
+		t.notified[alert.Rule] = true
+		fired = append(fired, alert)
+	}
+	for rule := range t.notified {
+		if !current[rule] {
+			delete(t.notified, rule)
+			resolved = append(resolved, rule)
+		}
+	}
+	sort.Strings(resolved)
+	return fired, resolved
+}
+
+// Notification — данные уведомления об оповещениях, доступные в шаблонах
+type Notification struct {
+	Host string
+	//Метки из раздела identity конфигурации и флагов --label
+	Labels   map[string]string
+	Time     time.Time
+	Fired    []Alert
+	Resolved []string
+	System   SystemMemoryInfo
+	//Процессы с наибольшим потреблением памяти
+	Processes []ProcessInfo
+}
+
+// newNotification собирает уведомление по замеру с top процессами с наибольшим потреблением памяти
+func newNotification(sample Sample, fired []Alert, resolved []string, top int) Notification {
+	processes := append([]ProcessInfo(nil), sample.Processes...)
+	sort.SliceStable(processes, func(i, j int) bool { return processes[i].MemoryUsage > processes[j].MemoryUsage })
+	if top > 0 && len(processes) > top {
+		processes = processes[:top]
+	}
+	return Notification{Host: exportHostname(), Labels: exportIdentity.Labels, Time: sample.Time, Fired: fired, Resolved: resolved, System: sample.System, Processes: processes}
+}
+
+// Rules перечисляет через запятую правила сработавших и снятых оповещений
+func (n Notification) Rules() string {
+	rules := make([]string, 0, len(n.Fired)+len(n.Resolved))
+	for _, alert := range n.Fired {
+		rules = append(rules, alert.Rule)
+	}
+	return strings.Join(append(rules, n.Resolved...), ", ")
+}
+
+// Memory описывает системную память одной строкой
+func (n Notification) Memory() string {
+	used := n.System.TotalMemory - n.System.AvailableMemory
+	return fmt.Sprintf("used %s of %s (%.1f%%), available %s", FormatMemorySize(used), FormatMemorySize(n.System.TotalMemory),
+		percentOf(used, n.System.TotalMemory), FormatMemorySize(n.System.AvailableMemory))
+}
+
+// ProcessTable — выдержка из таблицы процессов простым текстом без цветов
+func (n Notification) ProcessTable() string {
+	var res strings.Builder
+	res.WriteString(fmt.Sprintf("%-8s %-24s %12s\n", "PID", "NAME", "MEMORY"))
+	for _, process := range n.Processes {
+		res.WriteString(fmt.Sprintf("%-8d %-24s %12s\n", process.PID, ellipsize(process.Name, 24), FormatMemorySize(process.MemoryUsage)))
+	}
+	return res.String()
+}
+
+// notifyQueue отправляет уведомления в отдельной горутине, чтобы медленный получатель
+// не задерживал обновление панели; ошибка отправки возвращается следующим вызовом Push или Err
+type notifyQueue struct {
+	//Что ставится в очередь, для сообщения о переполнении: "писем", "команд"
+	what  string
+	send  func(Notification) error
+	queue chan Notification
+	done  chan struct{}
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func newNotifyQueue(what string, send func(Notification) error) *notifyQueue {
+	q := &notifyQueue{what: what, send: send, queue: make(chan Notification, notifyQueueSize), done: make(chan struct{})}
+	go q.run()
+	return q
+}
+
+// Push ставит уведомление в очередь и возвращает ошибку предыдущей отправки
+func (q *notifyQueue) Push(notification Notification) error {
+	select {
+	case q.queue <- notification:
+	default:
+		return fmt.Errorf("Очередь %s переполнена, уведомление о %s пропущено", q.what, notification.Rules())
+	}
+	return q.Err()
+}
+
+// Err возвращает и сбрасывает ошибку последней отправки
+func (q *notifyQueue) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err := q.lastErr
+	q.lastErr = nil
+	return err
+}
+
+// Close дожидается отправки уведомлений из очереди
+func (q *notifyQueue) Close() {
+	close(q.queue)
+	<-q.done
+}
+
+func (q *notifyQueue) run() {
+	defer close(q.done)
+	for notification := range q.queue {
+		if err := q.send(notification); err != nil {
+			q.mu.Lock()
+			q.lastErr = err
+			q.mu.Unlock()
+		}
+	}
+}
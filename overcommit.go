@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AlertOvercommit срабатывает, когда в строгом режиме overcommit выделено больше
+// порога в процентах от CommitLimit
+const AlertOvercommit = "overcommit"
+
+// gaugeWidth — ширина шкалы в символах
+const gaugeWidth = 20
+
+func init() {
+	alertTypes[AlertOvercommit] = newOvercommitCondition
+}
+
+// CommitPercent возвращает долю CommitLimit, уже выделенную процессам (Committed_AS)
+func (t VMTunables) CommitPercent() float64 {
+	if t.CommitLimit == 0 {
+		return 0
+	}
+	return float64(t.Committed) / float64(t.CommitLimit) * 100
+}
+
+// formatGauge рисует шкалу заполнения вида [#####-----]
+func formatGauge(percent float64, width int) string {
+	filled := int(percent / 100 * float64(width))
+	if filled > width {
+		filled = width
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+// FormatOvercommit форматирует шкалу Committed_AS относительно CommitLimit
+//
+// В строгом режиме (vm.overcommit_memory=2) выделение памяти завершается ошибкой при достижении
+// CommitLimit задолго до исчерпания свободной памяти, поэтому шкала окрашивается при приближении к пределу;
+// в остальных режимах предел не применяется и шкала носит справочный характер
+func FormatOvercommit(tunables *VMTunables) string {
+	if tunables == nil || tunables.CommitLimit == 0 {
+		return ""
+	}
+	percent := tunables.CommitPercent()
+	line := fmt.Sprintf("Committed: %s %s of %s CommitLimit (%.1f%%", formatGauge(percent, gaugeWidth),
+		FormatMemorySize(tunables.Committed), FormatMemorySize(tunables.CommitLimit), percent)
+	if tunables.OvercommitMemory != OvercommitNever {
+		return line + ", not enforced: " + overcommitModeName(tunables.OvercommitMemory) + ")\n"
+	}
+	line += ", enforced)"
+	switch {
+	case percent >= limitCriticalPercent:
+		line = paint(activeTheme.Critical, line)
+	case percent >= limitWarningPercent:
+		line = paint(activeTheme.Warning, line)
+	}
+	return line + "\n"
+}
+
+// overcommitCondition срабатывает в строгом режиме overcommit при выделении больше threshold процентов CommitLimit
+type overcommitCondition struct {
+	threshold float64
+}
+
+func newOvercommitCondition(threshold string) (alertCondition, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(threshold, "%"), 64)
+	if err != nil || percent <= 0 {
+		return nil, fmt.Errorf("Порог должен быть процентом от CommitLimit, например \"90\": %q", threshold)
+	}
+	return &overcommitCondition{threshold: percent}, nil
+}
+
+func (c *overcommitCondition) check(sample Sample, history *History) (string, bool) {
+	vm := sample.VM
+	if vm == nil || vm.OvercommitMemory != OvercommitNever || vm.CommitPercent() < c.threshold {
+		return "", false
+	}
+	return fmt.Sprintf("committed %s of %s CommitLimit (%.1f%%), allocations will start failing",
+		FormatMemorySize(vm.Committed), FormatMemorySize(vm.CommitLimit), vm.CommitPercent()), true
+}
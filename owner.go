@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// OwnerReader реализуют источники данных, умеющие определять владельца процесса
+type OwnerReader interface {
+	//ReadProcessUser возвращает имя пользователя, от которого запущен процесс
+	ReadProcessUser(pid int) (string, error)
+}
+
+// userNames кэширует имена пользователей по UID, чтобы не читать базу пользователей для каждого процесса
+var userNames = struct {
+	sync.Mutex
+	names map[uint32]string
+}{names: make(map[uint32]string)}
+
+// lookupUserName возвращает имя пользователя по UID или сам UID, если пользователь не найден
+func lookupUserName(uid uint32) string {
+	userNames.Lock()
+	defer userNames.Unlock()
+	if name, ok := userNames.names[uid]; ok {
+		return name
+	}
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	userNames.names[uid] = name
+	return name
+}
+
+func (l *LinuxMemoryReader) ReadProcessUser(pid int) (string, error) {
+	// В снимке procfs владельцы файлов не сохраняются, а база пользователей принадлежит
+	// другой машине, поэтому UID берется из status и не переводится в имя
+	if l.Root != "" {
+		return readStatusUID(l.procPath(pid, "status"))
+	}
+	info, err := os.Stat(l.procPath(pid))
+	if err != nil {
+		return "", processError(pid, err)
+	}
+	uid, ok := fileOwner(info)
+	if !ok {
+		return "", fmt.Errorf("%w: владелец процесса %d недоступен", ErrUnsupportedPlatform, pid)
+	}
+	return lookupUserName(uid), nil
+}
+
+func (d *DarwinMemoryReader) ReadProcessUser(pid int) (string, error) {
+	process, err := procShortInfo(pid)
+	if err != nil {
+		return "", processError(pid, err)
+	}
+	return lookupUserName(process.UID), nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileOwner: UID владельца файла есть только в Unix
+func fileOwner(info os.FileInfo) (uint32, bool) {
+	return 0, false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// maxProcessWorkers ограничивает число одновременно читаемых процессов: чтение /proc
+// упирается в системные вызовы (в macOS — proc_pidinfo)
+const maxProcessWorkers = 8
+
+// StageTimings — длительность этапов сбора одного замера в секундах. Системная статистика,
+// таблица процессов и коллекторы собираются одновременно, поэтому Total меньше суммы этапов;
+// этапы, не выполнявшиеся в этом замере (панели с собственным периодом), равны 0
+type StageTimings struct {
+	//Память, контейнер, параметры ядра и swap
+	System float64 `json:"system_seconds"`
+	//Получение списка процессов и чтение сведений о каждом из них
+	ProcessList    float64 `json:"process_list_seconds,omitempty"`
+	ProcessDetails float64 `json:"process_details_seconds,omitempty"`
+	//Встроенные коллекторы и внешние плагины
+	Collectors float64 `json:"collectors_seconds,omitempty"`
+	Total      float64 `json:"total_seconds"`
+	//Число процессов, сведения которых прочитаны полностью; у остальных владелец,
+	//пространство имен и cgroup взяты из кэша предыдущих обновлений
+	DeepReads int `json:"deep_reads,omitempty"`
+}
+
+// collectStages заполняет замер: системная статистика собирается всегда, таблица процессов
+// и коллекторы — если processes и details, одновременно с ней. Ошибка системной статистики
+// важнее ошибки таблицы процессов
+func collectStages(reader MemoryReader, sample *Sample, report *ErrorReport, processes, details bool) error {
+	start := time.Now()
+	timings := &StageTimings{}
+	var wg sync.WaitGroup
+	var processErr error
+	if processes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			processErr = collectProcessTable(reader, sample, report, timings)
+		}()
+	}
+	if details {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stage := time.Now()
+			sample.Collectors = runCollectors()
+			timings.Collectors = time.Since(stage).Seconds()
+		}()
+	}
+	systemErr := collectSystem(reader, sample)
+	timings.System = time.Since(start).Seconds()
+	wg.Wait()
+	timings.Total = time.Since(start).Seconds()
+	sample.Timings = timings
+	sample.Degraded = degradedComponents()
+	sample.DegradedFields = degradedFieldList()
+	if systemErr != nil {
+		return systemErr
+	}
+	return processErr
+}
+
+// readProcesses читает сведения о процессах pids в несколько потоков и возвращает их
+// вместе с числом процессов, сведения которых прочитаны полностью, а не взяты из кэша.
+// Порядок процессов сохраняется, а нечитаемые процессы пропускаются и учитываются в report
+func readProcesses(reader MemoryReader, pids []int, report *ErrorReport) ([]ProcessInfo, int) {
+	cache := processCacheFor(reader)
+	cache.retain(pids)
+	hostNS := hostNetNS(reader)
+	var limits *cgroupLimits
+	if cgReader, ok := reader.(CgroupReader); ok {
+		limits = &cgroupLimits{reader: cgReader, limits: make(map[string]uint64)}
+	}
+	read := make([]ProcessInfo, len(pids))
+	ok := make([]bool, len(pids))
+	deep := make([]bool, len(pids))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < min(maxProcessWorkers, runtime.NumCPU(), len(pids)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				process, deepRead, err := readProcessInfo(reader, pids[i], hostNS, limits, cache, report)
+				if err != nil {
+					report.Add("processes unreadable", err)
+					continue
+				}
+				read[i], ok[i], deep[i] = process, true, deepRead
+			}
+		}()
+	}
+	for i := range pids {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	processes := read[:0]
+	deepReads := 0
+	for i, process := range read {
+		if ok[i] {
+			processes = append(processes, process)
+		}
+		if deep[i] {
+			deepReads++
+		}
+	}
+	return processes, deepReads
+}
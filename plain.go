@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ansiSequence — управляющие последовательности терминала (цвета, перемещение курсора)
+var ansiSequence = regexp.MustCompile("\033\\[[0-9;?]*[A-Za-z]")
+
+// stripANSI удаляет из текста цвета и управляющие последовательности терминала
+func stripANSI(s string) string {
+	return ansiSequence.ReplaceAllString(s, "")
+}
+
+// plainLines добавляет строки уже отформатированного блока панели без цветов
+// и отступов, пропуская заголовок блока
+func plainLines(res *strings.Builder, prefix, block string) {
+	for i, line := range strings.Split(strings.TrimRight(stripANSI(block), "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (i == 0 && strings.HasSuffix(line, ":")) {
+			continue
+		}
+		res.WriteString(prefix + line + "\n")
+	}
+}
+
+// FormatPlainDashboard форматирует панель для программ экранного доступа и брайлевских
+// дисплеев: строки "метка: значение" в постоянном порядке, без таблиц, рамок, цветов
+// и управления курсором. Каждое обновление отделяется пустой строкой
+func FormatPlainDashboard(sample Sample, config DisplayConfig, state *ViewState) string {
+	var res strings.Builder
+	stats := sample.System
+	used := stats.TotalMemory - stats.AvailableMemory
+	swapUsed := stats.SwapTotal - stats.SwapFree
+	res.WriteString(fmt.Sprintf("Updated: %s\n", sample.Time.Format("2006-01-02 15:04:05")))
+	res.WriteString(fmt.Sprintf("Total memory: %s\n", FormatMemorySize(stats.TotalMemory)))
+	res.WriteString(fmt.Sprintf("Used memory: %s, %.1f percent\n", FormatMemorySize(used), percentOf(used, stats.TotalMemory)))
+	res.WriteString(fmt.Sprintf("Available memory: %s\n", FormatMemorySize(stats.AvailableMemory)))
+	res.WriteString(fmt.Sprintf("Swap used: %s, %.1f percent\n", FormatMemorySize(swapUsed), percentOf(swapUsed, stats.SwapTotal)))
+	if sample.VM != nil && sample.VM.CommitLimit > 0 {
+		res.WriteString(fmt.Sprintf("Committed: %s of %s, %.1f percent\n",
+			FormatMemorySize(sample.VM.Committed), FormatMemorySize(sample.VM.CommitLimit), sample.VM.CommitPercent()))
+	}
+	if sample.Forecast != nil {
+		res.WriteString(fmt.Sprintf("Forecast: memory exhausted in %s\n", formatETA(sample.Forecast.ETA())))
+	}
+	if swap := sample.Swap; swap != nil && swap.Thrashing {
+		res.WriteString(fmt.Sprintf("Warning: swap thrashing since %s, in %s, out %s\n",
+			swap.Since.Format("15:04:05"), formatRate(swap.InRate), formatRate(swap.OutRate)))
+	}
+	plainLines(&res, "", FormatContainer(sample.Container))
+	plainLines(&res, "", FormatSwapActivity(sample.Swap))
+	plainLines(&res, "", FormatChurn(sample.Churn))
+	for _, alert := range sample.Alerts {
+		res.WriteString(fmt.Sprintf("Alert: %s, %s, since %s%s\n", alert.Rule, alert.Message, alert.Since.Format("15:04:05"), alertMuteSuffix(alert)))
+	}
+	plainLines(&res, "Collector: ", FormatCollectors(sample.Collectors))
+	plainLines(&res, "", FormatStateCounts(sample.Processes))
+
+	if state.GroupBy != GroupByNone && !state.ShowFiles {
+		groups := GroupProcesses(sample.Processes, state.GroupBy)
+		for i, group := range groups {
+			res.WriteString(fmt.Sprintf("Group %d of %d: %s, %d processes, memory %s\n",
+				i+1, len(groups), group.Key, group.Processes, FormatMemorySize(group.MemoryUsage)))
+		}
+	} else {
+		view := visibleProcesses(sample.Processes, config, state)
+		state.ClampSelection(len(view))
+		for i, process := range view {
+			res.WriteString(fmt.Sprintf("Process %d of %d: %s\n", i+1, len(view), plainProcess(process)))
+		}
+		plainLines(&res, "", FormatTotals(view, sample.Processes, sample.System))
+		plainLines(&res, "", FormatHidden(sample.Processes, config))
+		plainLines(&res, "", FormatKernelThreads(sample.Processes, config))
+		plainLines(&res, "", FormatFrozen(state))
+		if state.Interactive && state.Selected < len(view) {
+			res.WriteString(fmt.Sprintf("Selected: %s\n", plainProcess(view[state.Selected])))
+		}
+	}
+
+	for _, lifetime := range lastLifetimes(state.Exited, exitedDisplayRows) {
+		res.WriteString(fmt.Sprintf("Exited: %s, PID %d, at %s, lived %s, peak %s, growth %s\n",
+			lifetime.Name, lifetime.PID, lifetime.End.Format("15:04:05"), lifetime.End.Sub(lifetime.Start).Round(time.Second),
+			FormatMemorySize(lifetime.Peak), formatSignedSize(lifetime.Growth)))
+	}
+
+	if summary := state.Errors.Summary(); summary != "" {
+		res.WriteString(fmt.Sprintf("Errors: %s\n", summary))
+	}
+	if len(sample.Degraded) > 0 {
+		res.WriteString(fmt.Sprintf("Degraded: recovered from a crash in %s\n", strings.Join(sample.Degraded, ", ")))
+	}
+	if len(sample.DegradedFields) > 0 {
+		res.WriteString(fmt.Sprintf("Fallback: %s\n", formatDegradedFields(sample.DegradedFields)))
+	}
+	res.WriteString(fmt.Sprintf("Sort: %s\n", state.sortSpec(config)))
+	if state.Preset != "" {
+		res.WriteString(fmt.Sprintf("Preset: %s\n", state.Preset))
+	}
+	plainLines(&res, "", FormatSearch(state))
+	plainLines(&res, "", FormatMarks(state))
+	if state.Status != "" {
+		res.WriteString(fmt.Sprintf("Status: %s\n", state.Status))
+	}
+	res.WriteString("\n")
+	return res.String()
+}
+
+// plainProcess описывает процесс одной строкой: имя, PID, память и отклонения от нормы
+func plainProcess(process ProcessInfo) string {
+	parts := []string{process.Name, fmt.Sprintf("PID %d", process.PID), "memory " + FormatMemorySize(process.MemoryUsage)}
+	if process.Budget > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f percent of budget", process.BudgetPercent()))
+	}
+	if isAlarmingState(process.State) {
+		parts = append(parts, "state "+process.State)
+	}
+	if process.Nice != nil && *process.Nice != 0 {
+		parts = append(parts, fmt.Sprintf("nice %d", *process.Nice))
+	}
+	if process.QoS != "" {
+		parts = append(parts, "QoS "+process.QoS)
+	}
+	if process.Pinned {
+		parts = append(parts, "pinned")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func percentOf(value, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(value) / float64(total) * 100
+}
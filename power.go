@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+// onBatteryPower определяет, работает ли компьютер от батареи
+//
+// Если определить источник питания не удалось (например, на сервере без батареи),
+// считается, что компьютер подключен к сети
+func onBatteryPower() bool {
+	switch runtime.GOOS {
+	case "darwin":
+		return darwinOnBattery()
+	case "linux":
+		return linuxOnBattery()
+	}
+	return false
+}
+
+func darwinOnBattery() bool {
+	output, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(output), "'Battery Power'")
+}
+
+func linuxOnBattery() bool {
+	entries, err := os.ReadDir(powerSupplyDir)
+	if err != nil {
+		return false
+	}
+	discharging := false
+	for _, entry := range entries {
+		dir := filepath.Join(powerSupplyDir, entry.Name())
+		switch readSysfsValue(filepath.Join(dir, "type")) {
+		case "Mains":
+			if readSysfsValue(filepath.Join(dir, "online")) == "1" {
+				return false
+			}
+		case "Battery":
+			if readSysfsValue(filepath.Join(dir, "status")) == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
+
+func readSysfsValue(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preset — именованный набор настроек представления из конфигурации ("databases", "web"),
+// позволяющий команде пользоваться одинаковыми представлениями
+type Preset struct {
+	//Показывать только процессы с этими именами (закрепленные процессы показываются всегда)
+	Processes []string `json:"processes"`
+	//Имена процессов, закрепляемых в начале таблицы в дополнение к флагу --pin
+	Pin []string `json:"pin"`
+	//Дополнительные колонки в дополнение к колонкам из конфигурации
+	Columns []ColumnConfig `json:"columns"`
+	//Бюджеты памяти, заменяющие бюджеты из конфигурации для тех же имен
+	Budgets map[string]string `json:"budgets"`
+	//Сортировка таблицы в формате ParseSortSpec
+	Sort string `json:"sort"`
+	//Число процессов в таблице
+	Top int `json:"top"`
+}
+
+// apply возвращает конфигурацию представления с настройками набора поверх base
+func (p Preset) apply(base DisplayConfig) (DisplayConfig, error) {
+	config := base
+	config.Filter = p.Processes
+	config.PinnedNames = append(append([]string(nil), base.PinnedNames...), p.Pin...)
+	config.Columns = append(append([]ColumnConfig(nil), base.Columns...), p.Columns...)
+
+	budgets, err := FileConfig{Budgets: p.Budgets}.ParseBudgets()
+	if err != nil {
+		return config, err
+	}
+	config.Budgets = make(map[string]uint64, len(base.Budgets)+len(budgets))
+	for name, budget := range base.Budgets {
+		config.Budgets[name] = budget
+	}
+	for name, budget := range budgets {
+		config.Budgets[name] = budget
+	}
+
+	if p.Sort != "" {
+		if config.Sort, err = ParseSortSpec(p.Sort); err != nil {
+			return config, err
+		}
+	}
+	if p.Top > 0 {
+		config.TopProcesses = p.Top
+	}
+	return config, nil
+}
+
+// PresetConfigs — конфигурации представления для каждого набора настроек;
+// пустое имя соответствует конфигурации без набора
+type PresetConfigs map[string]DisplayConfig
+
+// buildPresetConfigs проверяет наборы настроек и заранее строит для них конфигурации представления
+func buildPresetConfigs(base DisplayConfig, presets map[string]Preset) (PresetConfigs, error) {
+	configs := PresetConfigs{"": base}
+	for name, preset := range presets {
+		if name == "" {
+			return nil, fmt.Errorf("Набор настроек с пустым именем")
+		}
+		config, err := preset.apply(base)
+		if err != nil {
+			return nil, fmt.Errorf("Неверный набор настроек %q: %v", name, err)
+		}
+		configs[name] = config
+	}
+	return configs, nil
+}
+
+// names возвращает имена наборов в порядке переключения: без набора, затем по алфавиту
+func (c PresetConfigs) names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup возвращает конфигурацию набора или ошибку со списком доступных наборов
+func (c PresetConfigs) lookup(name string) (DisplayConfig, error) {
+	config, ok := c[name]
+	if !ok {
+		return config, fmt.Errorf("Неизвестный набор настроек %q, доступны: %s", name, strings.Join(c.names()[1:], ", "))
+	}
+	return config, nil
+}
+
+// CyclePreset переключает набор настроек на следующий и сбрасывает сортировку,
+// выбранную клавишей s, чтобы действовала сортировка набора
+func (s *ViewState) CyclePreset(presets PresetConfigs) {
+	names := presets.names()
+	s.SortKey = ""
+	s.Ranks = nil
+	for i, name := range names {
+		if name == s.Preset {
+			s.Preset = names[(i+1)%len(names)]
+			return
+		}
+	}
+	s.Preset = ""
+}
+
+// matchesFilter сообщает, проходит ли процесс фильтр config.Filter
+func matchesFilter(process ProcessInfo, config DisplayConfig) bool {
+	if len(config.Filter) == 0 {
+		return true
+	}
+	for _, name := range config.Filter {
+		if matchesProcessName(process.Name, name) {
+			return true
+		}
+	}
+	return false
+}
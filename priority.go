@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Классы QoS подов Kubernetes
+const (
+	QoSGuaranteed = "Guaranteed"
+	QoSBurstable  = "Burstable"
+	QoSBestEffort = "BestEffort"
+)
+
+// PriorityReader реализуют источники данных, умеющие читать приоритет (nice) процесса
+type PriorityReader interface {
+	//ReadProcessNice возвращает значение nice процесса от -20 до 19
+	ReadProcessNice(pid int) (int, error)
+}
+
+// ReadProcessNice читает nice из /proc/[pid]/stat
+func (l *LinuxMemoryReader) ReadProcessNice(pid int) (int, error) {
+	stat, err := l.readProcessStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Nice, nil
+}
+
+// ReadProcessNice читает nice через getpriority
+func (d *DarwinMemoryReader) ReadProcessNice(pid int) (int, error) {
+	nice, err := procNice(pid)
+	if err != nil {
+		return 0, processError(pid, err)
+	}
+	return nice, nil
+}
+
+// kubernetesQoS определяет класс QoS пода Kubernetes по пути cgroup процесса или возвращает
+// пустую строку, если процесс не в поде. kubelet размещает поды BestEffort и Burstable во
+// вложенных группах, а поды Guaranteed — прямо в корневой группе kubepods:
+//
+//	/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod<uid>.slice/... (драйвер systemd)
+//	/kubepods/burstable/pod<uid>/<container> (драйвер cgroupfs)
+//	/kubepods.slice/kubepods-pod<uid>.slice/... (Guaranteed)
+func kubernetesQoS(cgroup string) string {
+	parts := strings.Split(strings.Trim(cgroup, "/"), "/")
+	for i, part := range parts {
+		if part != "kubepods" && part != "kubepods.slice" {
+			continue
+		}
+		if i+1 == len(parts) {
+			return ""
+		}
+		switch next := parts[i+1]; {
+		case next == "besteffort" || next == "kubepods-besteffort.slice":
+			return QoSBestEffort
+		case next == "burstable" || next == "kubepods-burstable.slice":
+			return QoSBurstable
+		case strings.HasPrefix(next, "pod") || strings.HasPrefix(next, "kubepods-pod"):
+			return QoSGuaranteed
+		}
+		return ""
+	}
+	return ""
+}
+
+// formatNice форматирует nice процесса или "-", если он неизвестен
+func formatNice(process ProcessInfo) string {
+	if process.Nice == nil {
+		return "-"
+	}
+	return strconv.Itoa(*process.Nice)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// Разделение привилегий: при запуске через sudo чтение /proc выполняет отдельный
+// процесс-помощник с правами root, а интерфейс и все остальное работает от имени
+// пользователя, вызвавшего sudo. Помощник и клиент обмениваются строками JSON
+
+type helperRequest struct {
+	Method string `json:"method"`
+	PID    int    `json:"pid,omitempty"`
+}
+
+type helperResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	//Код вида ошибки, по которому клиент восстанавливает ее для errors.Is
+	Code string `json:"code,omitempty"`
+}
+
+// helperNoResidentMemory — код ошибки процесса без пользовательской памяти (поток ядра, зомби);
+// в API он не нужен, поэтому в errorCodes его нет
+const helperNoResidentMemory = "no_resident_memory"
+
+// helperErrorCode возвращает код вида ошибки для ответа помощника
+func helperErrorCode(err error) string {
+	if errors.Is(err, errNoResidentMemory) {
+		return helperNoResidentMemory
+	}
+	return errorCode(err)
+}
+
+// helperError — ошибка, полученная от помощника: текст прежний, а вид восстановлен по коду,
+// поэтому после сброса прав errors.Is(err, ErrProcessGone) и фильтры ErrorReport работают
+// так же, как при чтении без помощника
+type helperError struct {
+	msg  string
+	kind error
+}
+
+// newHelperError восстанавливает ошибку помощника по тексту и коду
+func newHelperError(msg, code string) error {
+	err := &helperError{msg: msg}
+	if code == helperNoResidentMemory {
+		err.kind = errNoResidentMemory
+	}
+	for _, kind := range errorCodes {
+		if kind.code == code {
+			err.kind = kind.err
+		}
+	}
+	return err
+}
+
+func (e *helperError) Error() string {
+	return e.msg
+}
+
+func (e *helperError) Unwrap() error {
+	return e.kind
+}
+
+// Is сопоставляет нехватку прав и с fs.ErrPermission, как исходную ошибку EPERM или EACCES
+func (e *helperError) Is(target error) bool {
+	return e.kind == ErrPermission && target == fs.ErrPermission
+}
+
+// serveHelper обрабатывает запросы клиента, пока тот не закроет соединение.
+// Если mu не nil, запросы выполняются под ним: так несколько клиентов сокета читают
+// данные последовательно через один и тот же reader со всеми его возможностями
+//
+// Помощник выполняет только операции чтения из MemoryReader и DetailReader
+func serveHelper(reader MemoryReader, mu *sync.Mutex, r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var req helperRequest
+		if err := dec.Decode(&req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("Неверный запрос к помощнику: %v", err)
+		}
+		if mu != nil {
+			mu.Lock()
+		}
+		result, err := handleHelperRequest(reader, req)
+		if mu != nil {
+			mu.Unlock()
+		}
+		var resp helperResponse
+		if err != nil {
+			resp.Error, resp.Code = err.Error(), helperErrorCode(err)
+		} else if resp.Result, err = json.Marshal(result); err != nil {
+			resp.Error = err.Error()
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func handleHelperRequest(reader MemoryReader, req helperRequest) (interface{}, error) {
+	switch req.Method {
+	case "ReadSystemMemory":
+		return reader.ReadSystemMemory()
+	case "GetProcessList":
+		return reader.GetProcessList()
+	case "ReadProcessMemory":
+		return reader.ReadProcessMemory(req.PID)
+	case "ReadProcessName":
+		return reader.ReadProcessName(req.PID)
+	case "CollectProcesses":
+		return collectProcesses(reader, nil)
+	case "ReadProcessDetails":
+		detailReader, ok := reader.(DetailReader)
+		if !ok {
+			return nil, fmt.Errorf("Подробности о процессах не поддерживаются")
+		}
+		// Клиенты сокета — непривилегированные пользователи, поэтому секреты в командных
+		// строках чужих процессов скрываются до отправки, а не на стороне клиента
+		details, err := detailReader.ReadProcessDetails(req.PID)
+		details.Cmdline = activeRedactor.Redact(details.Cmdline)
+		return details, err
+	case "ReadProcessMappings":
+		mappingReader, ok := reader.(MappingReader)
+		if !ok {
+			return nil, fmt.Errorf("%w: отображения файлов", ErrUnsupportedPlatform)
+		}
+		return mappingReader.ReadProcessMappings(req.PID)
+	case "ReadVMTunables":
+		tunablesReader, ok := reader.(TunablesReader)
+		if !ok {
+			return nil, fmt.Errorf("%w: параметры памяти ядра", ErrUnsupportedPlatform)
+		}
+		return tunablesReader.ReadVMTunables()
+	}
+	return nil, fmt.Errorf("Неизвестный метод: %s", req.Method)
+}
+
+// HelperClient реализует MemoryReader, перенаправляя вызовы привилегированному помощнику
+type HelperClient struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	dec    *json.Decoder
+	closer io.Closer
+}
+
+func NewHelperClient(conn io.ReadWriteCloser) *HelperClient {
+	return &HelperClient{
+		enc:    json.NewEncoder(conn),
+		dec:    json.NewDecoder(conn),
+		closer: conn,
+	}
+}
+
+func (c *HelperClient) call(method string, pid int, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.enc.Encode(helperRequest{Method: method, PID: pid}); err != nil {
+		return fmt.Errorf("Помощник недоступен: %v", err)
+	}
+	var resp helperResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return fmt.Errorf("Помощник недоступен: %v", err)
+	}
+	if resp.Error != "" {
+		return newHelperError(resp.Error, resp.Code)
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (c *HelperClient) ReadSystemMemory() (SystemMemoryInfo, error) {
+	var info SystemMemoryInfo
+	err := c.call("ReadSystemMemory", 0, &info)
+	return info, err
+}
+
+func (c *HelperClient) GetProcessList() ([]int, error) {
+	var pids []int
+	err := c.call("GetProcessList", 0, &pids)
+	return pids, err
+}
+
+func (c *HelperClient) ReadProcessMemory(pid int) (uint64, error) {
+	var mem uint64
+	err := c.call("ReadProcessMemory", pid, &mem)
+	return mem, err
+}
+
+func (c *HelperClient) ReadProcessName(pid int) (string, error) {
+	var name string
+	err := c.call("ReadProcessName", pid, &name)
+	return name, err
+}
+
+// CollectProcesses получает от помощника данные обо всех процессах за один запрос
+func (c *HelperClient) CollectProcesses() ([]ProcessInfo, error) {
+	var processes []ProcessInfo
+	err := c.call("CollectProcesses", 0, &processes)
+	return processes, err
+}
+
+func (c *HelperClient) ReadProcessDetails(pid int) (ProcessDetails, error) {
+	var details ProcessDetails
+	err := c.call("ReadProcessDetails", pid, &details)
+	return details, err
+}
+
+// ReadProcessMappings получает от помощника отображения файлов процесса для переписи файлов
+func (c *HelperClient) ReadProcessMappings(pid int) ([]Mapping, error) {
+	var mappings []Mapping
+	err := c.call("ReadProcessMappings", pid, &mappings)
+	return mappings, err
+}
+
+func (c *HelperClient) ReadVMTunables() (VMTunables, error) {
+	var tunables VMTunables
+	err := c.call("ReadVMTunables", 0, &tunables)
+	return tunables, err
+}
+
+// Close закрывает соединение с помощником, после чего он завершается
+func (c *HelperClient) Close() error {
+	return c.closer.Close()
+}
+
+// pipeConn объединяет stdin и stdout дочернего процесса в одно соединение
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (p *pipeConn) Close() error {
+	err := p.WriteCloser.Close()
+	p.cmd.Wait()
+	return err
+}
+
+// startPrivilegedHelper запускает копию программы в режиме помощника,
+// сохраняющую текущие (root) права
+func startPrivilegedHelper() (*HelperClient, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(executable, "helper")
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Не удалось запустить помощника: %v", err)
+	}
+	return NewHelperClient(&pipeConn{Reader: stdout, WriteCloser: stdin, cmd: cmd}), nil
+}
+
+// sudoCredentials возвращает UID и GID пользователя, запустившего программу через sudo
+func sudoCredentials() (int, int, error) {
+	uidStr, gidStr := os.Getenv("SUDO_UID"), os.Getenv("SUDO_GID")
+	if uidStr == "" || gidStr == "" {
+		return 0, 0, fmt.Errorf("Программа запущена не через sudo: SUDO_UID/SUDO_GID не заданы")
+	}
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Неверный SUDO_UID: %s", uidStr)
+	}
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Неверный SUDO_GID: %s", gidStr)
+	}
+	return uid, gid, nil
+}
+
+// privilegedReader запускает помощника с правами root и сбрасывает права текущего процесса
+func privilegedReader() (*HelperClient, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("--drop-privileges требует запуска через sudo")
+	}
+	uid, gid, err := sudoCredentials()
+	if err != nil {
+		return nil, err
+	}
+	client, err := startPrivilegedHelper()
+	if err != nil {
+		return nil, err
+	}
+	if err := dropPrivileges(uid, gid); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// dialHelper подключается к помощнику, слушающему unix-сокет
+func dialHelper(socketPath string) (*HelperClient, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось подключиться к помощнику %s: %v", socketPath, err)
+	}
+	return NewHelperClient(conn), nil
+}
+
+// runHelperCommand — подкоманда "helper": обслуживает запросы на чтение через stdin/stdout,
+// либо, если указан --socket, через unix-сокет для непривилегированных клиентов
+func runHelperCommand(args []string) error {
+	flags := newCommandFlags("helper")
+	socketPath := flags.String("socket", "", "listen on a unix socket at `path` instead of stdin/stdout")
+	socketMode := flags.String("socket-mode", "0660", "permission `mode` of the socket file")
+	socketGroup := flags.String("socket-group", "", "`group` allowed to connect to the socket")
+	flags.Parse(args)
+
+	reader, err := newLocalReader()
+	if err != nil {
+		return err
+	}
+	if *socketPath == "" {
+		return serveHelper(reader, nil, os.Stdin, os.Stdout)
+	}
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("Неверный режим доступа: %s", *socketMode)
+	}
+	return serveHelperSocket(reader, *socketPath, os.FileMode(mode), *socketGroup)
+}
+
+// serveHelperSocket принимает подключения клиентов на unix-сокете
+//
+// Доступ к сокету ограничивается правами файла сокета и его группой,
+// поэтому администратор решает, какие пользователи получают полную картину системы
+func serveHelperSocket(reader MemoryReader, socketPath string, mode os.FileMode, group string) error {
+	os.Remove(socketPath)
+	listener, err := listenPrivateSocket(socketPath)
+	if err != nil {
+		return fmt.Errorf("Не удалось открыть сокет %s: %v", socketPath, err)
+	}
+	defer listener.Close()
+	// Сокет создан доступным только владельцу; группа назначается раньше прав,
+	// чтобы ни в какой момент к нему не могли подключиться посторонние
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("Группа %s не найдена: %v", group, err)
+		}
+		gid, _ := strconv.Atoi(g.Gid)
+		if err := os.Chown(socketPath, -1, gid); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(socketPath, mode); err != nil {
+		return err
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		listener.Close()
+	}()
+
+	// Каждый клиент обслуживается отдельной горутиной, но чтение данных о процессах
+	// выполняется последовательно, как и при работе без помощника. Все клиенты читают
+	// через один reader, поэтому кэш сведений процессов (processCaches) у них общий
+	// и не растет с каждым подключением
+	var mu sync.Mutex
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return nil
+		}
+		go func() {
+			defer conn.Close()
+			serveHelper(reader, &mu, conn, conn)
+		}()
+	}
+}
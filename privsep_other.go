@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// dropPrivileges: сброс прав через setuid есть только в Unix
+func dropPrivileges(uid, gid int) error {
+	return fmt.Errorf("%w: сброс прав доступен только в Unix", ErrUnsupportedPlatform)
+}
+
+// listenPrivateSocket: umask есть только в Unix
+func listenPrivateSocket(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// dropPrivileges необратимо переключает процесс на указанного пользователя
+func dropPrivileges(uid, gid int) error {
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups: %v", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %v", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %v", err)
+	}
+	if syscall.Geteuid() == 0 && uid != 0 {
+		return fmt.Errorf("Права root не были сброшены")
+	}
+	return nil
+}
+
+// listenPrivateSocket создает unix-сокет с правами 0600. Права файла сокета задает
+// umask в момент bind, поэтому она меняется на время создания: между net.Listen
+// и os.Chmod к сокету с правами по умолчанию успел бы подключиться любой пользователь.
+// umask действует на весь процесс, но помощник в это время не создает других файлов
+func listenPrivateSocket(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// Константы proc connector ядра Linux (linux/netlink.h, linux/connector.h, linux/cn_proc.h);
+// они объявлены здесь, так как пакет syscall определяет их не для всех систем
+const (
+	afNetlink            = 16
+	netlinkConnector     = 11
+	solNetlink           = 270
+	netlinkAddMembership = 1
+	nlmsgDone            = 3
+
+	cnIdxProc         = 1
+	cnValProc         = 1
+	procCnMcastListen = 1
+
+	procEventFork = 0x00000001
+	procEventExec = 0x00000002
+	procEventExit = 0x80000000
+
+	nlmsgHeaderSize = 16
+	cnMsgHeaderSize = 20
+	//Смещение данных события от начала cn_msg: what, cpu и timestamp_ns
+	procEventDataOffset = cnMsgHeaderSize + 16
+)
+
+// ProcEventSource — источник точных событий запуска и завершения процессов
+// через netlink proc connector Linux; требует CAP_NET_ADMIN
+type ProcEventSource struct {
+	file *os.File
+}
+
+// openProcConnector подписывается на события процессов; на других системах и без прав
+// возвращает ошибку, и монитор churn переходит на опрос списка процессов
+func openProcConnector() (*ProcEventSource, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("%w: proc connector доступен только в Linux", ErrUnsupportedPlatform)
+	}
+	fd, err := syscall.Socket(afNetlink, syscall.SOCK_DGRAM, netlinkConnector)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть netlink-сокет: %v", err)
+	}
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetsockoptInt(fd, solNetlink, netlinkAddMembership, cnIdxProc); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("Не удалось подписаться на события процессов: %v", err)
+	}
+	// Неблокирующий сокет обслуживается планировщиком Go, и Close прерывает ожидание Read
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	source := &ProcEventSource{file: os.NewFile(uintptr(fd), "proc-connector")}
+
+	// Сообщение без адреса уходит ядру; сокет получает адрес автоматически
+	msg := make([]byte, nlmsgHeaderSize+cnMsgHeaderSize+4)
+	binary.NativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:], nlmsgDone)
+	binary.NativeEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+	cn := msg[nlmsgHeaderSize:]
+	binary.NativeEndian.PutUint32(cn[0:], cnIdxProc)
+	binary.NativeEndian.PutUint32(cn[4:], cnValProc)
+	binary.NativeEndian.PutUint16(cn[16:], 4)
+	binary.NativeEndian.PutUint32(cn[cnMsgHeaderSize:], procCnMcastListen)
+	if _, err := source.file.Write(msg); err != nil {
+		source.Close()
+		return nil, fmt.Errorf("Не удалось включить proc connector: %v", err)
+	}
+	return source, nil
+}
+
+// Run читает события до закрытия источника и передает монитору запуски (fork),
+// смену программы (exec) и завершения (exit) процессов; события потоков пропускаются
+//
+// При переполнении буфера сокета часть событий теряется, и показатель
+// за этот промежуток занижен
+func (s *ProcEventSource) Run(m *ChurnMonitor) {
+	buf := make([]byte, os.Getpagesize())
+	for {
+		n, err := s.file.Read(buf)
+		if errors.Is(err, syscall.ENOBUFS) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		for data := buf[:n]; len(data) >= nlmsgHeaderSize; {
+			length := int(binary.NativeEndian.Uint32(data[0:]))
+			if length < nlmsgHeaderSize || length > len(data) {
+				break
+			}
+			s.handle(m, data[nlmsgHeaderSize:length])
+			if next := (length + 3) &^ 3; next < len(data) {
+				data = data[next:]
+			} else {
+				break
+			}
+		}
+	}
+}
+
+func (s *ProcEventSource) handle(m *ChurnMonitor, event []byte) {
+	if len(event) < procEventDataOffset+16 {
+		return
+	}
+	what := binary.NativeEndian.Uint32(event[cnMsgHeaderSize:])
+	data := event[procEventDataOffset:]
+	switch what {
+	case procEventFork:
+		pid, tgid := binary.NativeEndian.Uint32(data[8:]), binary.NativeEndian.Uint32(data[12:])
+		if pid == tgid {
+			m.processStarted(int(pid))
+		}
+	case procEventExec:
+		pid, tgid := binary.NativeEndian.Uint32(data[0:]), binary.NativeEndian.Uint32(data[4:])
+		if pid == tgid {
+			m.processExecuted(int(pid))
+		}
+	case procEventExit:
+		pid, tgid := binary.NativeEndian.Uint32(data[0:]), binary.NativeEndian.Uint32(data[4:])
+		if pid == tgid {
+			m.processExited(int(pid))
+		}
+	}
+}
+
+// Close отписывается от событий и прерывает Run
+func (s *ProcEventSource) Close() error {
+	return s.file.Close()
+}
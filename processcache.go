@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	//Сведения процесса перечитываются, если сменилось его имя (exec) или RSS изменился
+	//больше чем на восьмую часть и больше чем на deepReadMinDelta; небольшие колебания
+	//памяти не должны вызывать полное чтение
+	deepReadMinDelta = 1024 * 1024
+	//Сведения процесса перечитываются не реже этого периода, чтобы переименование,
+	//смена пользователя или перенос в другую cgroup без изменения памяти не терялись надолго
+	deepReadMaxAge = time.Minute
+)
+
+// fullProcessScan отключает кэш сведений процессов: все сведения читаются на каждом
+// обновлении (флаг --full-scan)
+var fullProcessScan bool
+
+// processIdentity — сведения процесса, которые почти не меняются за время его жизни.
+// Это отдельные файлы /proc и системные вызовы для каждого процесса, поэтому
+// они кэшируются и читаются только при изменениях.
+// Имя читается на каждом обновлении и служит признаком exec
+type processIdentity struct {
+	Name   string
+	User   string
+	Kernel bool
+	NetNS  string
+	Cgroup string
+	QoS    string
+
+	//RSS и время чтения сведений; по ним определяется, пора ли прочитать их снова
+	rss  uint64
+	read time.Time
+}
+
+// apply переносит сведения в строку таблицы процессов
+func (i processIdentity) apply(process *ProcessInfo) {
+	process.User, process.Kernel = i.User, i.Kernel
+	process.NetNS, process.Cgroup, process.QoS = i.NetNS, i.Cgroup, i.QoS
+}
+
+// stale сообщает, что сведения нужно прочитать снова: процесс сменил имя, его RSS
+// сильно изменился или сведения слишком давно не обновлялись
+func (i processIdentity) stale(name string, rss uint64, now time.Time) bool {
+	delta := max(rss, i.rss) - min(rss, i.rss)
+	return name != i.Name || now.Sub(i.read) >= deepReadMaxAge || delta > max(i.rss/8, deepReadMinDelta)
+}
+
+// processCache — сведения процессов из предыдущих обновлений по PID
+type processCache struct {
+	mu      sync.Mutex
+	entries map[int]processIdentity
+}
+
+// processCaches — кэши по источникам данных: снимки procfs и удаленные машины
+// не должны смешиваться с локальными процессами
+var processCaches = struct {
+	sync.Mutex
+	caches map[MemoryReader]*processCache
+}{caches: make(map[MemoryReader]*processCache)}
+
+// processCacheFor возвращает кэш сведений процессов источника данных или nil, если
+// кэш отключен флагом --full-scan
+func processCacheFor(reader MemoryReader) *processCache {
+	if fullProcessScan {
+		return nil
+	}
+	processCaches.Lock()
+	defer processCaches.Unlock()
+	cache := processCaches.caches[reader]
+	if cache == nil {
+		cache = &processCache{entries: make(map[int]processIdentity)}
+		processCaches.caches[reader] = cache
+	}
+	return cache
+}
+
+// lookup возвращает сведения процесса pid, если они есть и еще действительны для его
+// текущих имени и RSS
+func (c *processCache) lookup(pid int, name string, rss uint64, now time.Time) (processIdentity, bool) {
+	if c == nil {
+		return processIdentity{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	identity, ok := c.entries[pid]
+	if !ok || identity.stale(name, rss, now) {
+		return processIdentity{}, false
+	}
+	return identity, true
+}
+
+// store сохраняет прочитанные сведения процесса
+func (c *processCache) store(pid int, identity processIdentity) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[pid] = identity
+}
+
+// retain удаляет сведения процессов, которых нет в списке pids: завершившийся процесс
+// не оставляет устаревших сведений новому процессу с тем же PID
+func (c *processCache) retain(pids []int) {
+	if c == nil {
+		return
+	}
+	live := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		live[pid] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for pid := range c.entries {
+		if !live[pid] {
+			delete(c.entries, pid)
+		}
+	}
+}
+
+// readProcessIdentity читает сведения процесса с именем name; kernel — уже определенный
+// при чтении памяти признак потока ядра
+func readProcessIdentity(reader MemoryReader, pid int, name string, rss uint64, kernel bool, hostNS string) processIdentity {
+	identity := processIdentity{Name: name, rss: rss, read: time.Now(), Kernel: kernel}
+	if ownerReader, ok := reader.(OwnerReader); ok {
+		identity.User, _ = ownerReader.ReadProcessUser(pid)
+	}
+	// Пространства имен чужих процессов без прав root недоступны, это не ошибка
+	if nsReader, ok := reader.(NamespaceReader); ok {
+		if ns, err := nsReader.ReadProcessNetNS(pid); err == nil {
+			if ns == hostNS {
+				ns = "host"
+			}
+			identity.NetNS = ns
+		}
+	}
+	if cgReader, ok := reader.(CgroupReader); ok {
+		if cgroup, err := cgReader.ReadProcessCgroup(pid); err == nil {
+			identity.Cgroup = cgroup
+			identity.QoS = kubernetesQoS(cgroup)
+		}
+	}
+	return identity
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// procfsFixtureFile — файл снимка procfs с ожидаемым результатом чтения снимка
+const procfsFixtureFile = "expected.json"
+
+// procfsSystemFiles — системные файлы /proc, которые читает LinuxMemoryReader;
+// version сохраняется, чтобы было видно, с какого ядра снят снимок
+var procfsSystemFiles = []string{
+	"meminfo",
+	"version",
+	"sys/vm/swappiness",
+	"sys/vm/overcommit_memory",
+	"sys/vm/overcommit_ratio",
+	"sys/vm/min_free_kbytes",
+}
+
+// procfsProcessFiles — файлы /proc/[pid], которые читает LinuxMemoryReader при сборе процессов
+var procfsProcessFiles = []string{"status", "comm", "stat", "io", "cgroup"}
+
+// procfsCgroupFiles — файлы cgroup v2 и v1 с лимитами и событиями памяти
+var procfsCgroupFiles = []string{"memory.max", "memory.events", "memory.limit_in_bytes", "memory.oom_control", "memory.failcnt"}
+
+// ProcfsFixture — результат чтения снимка procfs, с которым сравнивается повторное чтение
+type ProcfsFixture struct {
+	System    SystemMemoryInfo `json:"system"`
+	VM        *VMTunables      `json:"vm,omitempty"`
+	Processes []ProcessInfo    `json:"processes"`
+}
+
+// readProcfsFixture читает снимок procfs с корнем root так же, как информационная панель читает /proc
+func readProcfsFixture(root string) (ProcfsFixture, error) {
+	reader := &LinuxMemoryReader{Root: root}
+	system, err := reader.ReadSystemMemory()
+	if err != nil {
+		return ProcfsFixture{}, err
+	}
+	processes, err := collectProcesses(reader, nil)
+	if err != nil {
+		return ProcfsFixture{}, err
+	}
+	return ProcfsFixture{System: system, VM: collectVMTunables(reader), Processes: processes}, nil
+}
+
+// marshalProcfsFixture возвращает содержимое файла expected.json
+func marshalProcfsFixture(fixture ProcfsFixture) ([]byte, error) {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// copySnapshotFile копирует файл procfs в снимок; файлы, которые не удалось прочитать
+// (процесс завершился, нет прав), пропускаются
+func copySnapshotFile(src, dst string) bool {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false
+	}
+	return os.WriteFile(dst, data, 0644) == nil
+}
+
+// captureProcfs сохраняет в каталог dir файлы /proc и /sys/fs/cgroup, которые читает
+// LinuxMemoryReader, и ожидаемый результат их чтения. Снимок используется как фикстура
+// для проверки чтения procfs разных ядер (procfs-check) и для запуска панели с флагом --procfs
+func captureProcfs(dir string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("%w: снимок procfs поддерживается только в Linux", ErrUnsupportedPlatform)
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("Каталог %s не пуст", dir)
+	}
+	live := &LinuxMemoryReader{}
+	snapshot := &LinuxMemoryReader{Root: dir}
+	for _, name := range procfsSystemFiles {
+		copySnapshotFile(live.path("proc", name), snapshot.path("proc", name))
+	}
+	if _, err := os.Stat(snapshot.path("proc", "meminfo")); err != nil {
+		return fmt.Errorf("Не удалось сохранить /proc/meminfo: %v", err)
+	}
+
+	pids, err := live.GetProcessList()
+	if err != nil {
+		return err
+	}
+	cgroups := make(map[string]bool)
+	for _, pid := range pids {
+		if !copySnapshotFile(live.procPath(pid, "status"), snapshot.procPath(pid, "status")) {
+			continue
+		}
+		for _, name := range procfsProcessFiles[1:] {
+			copySnapshotFile(live.procPath(pid, name), snapshot.procPath(pid, name))
+		}
+		if ns, err := live.ReadProcessNetNS(pid); err == nil {
+			os.MkdirAll(snapshot.procPath(pid, "ns"), 0755)
+			os.Symlink(ns, snapshot.procPath(pid, "ns", "net"))
+		}
+		if cgroup, err := snapshot.ReadProcessCgroup(pid); err == nil {
+			cgroups[cgroup] = true
+		}
+	}
+
+	// Лимиты cgroup читаются с учетом родительских групп, поэтому сохраняется вся цепочка
+	copied := make(map[string]bool)
+	for cgroup := range cgroups {
+		for group := path.Clean("/" + cgroup); !copied[group]; group = path.Dir(group) {
+			copied[group] = true
+			for _, name := range procfsCgroupFiles {
+				copySnapshotFile(filepath.Join(live.path(cgroupRoot), group, name), filepath.Join(snapshot.path(cgroupRoot), group, name))
+				copySnapshotFile(filepath.Join(live.path(cgroupRoot), "memory", group, name), filepath.Join(snapshot.path(cgroupRoot), "memory", group, name))
+			}
+		}
+	}
+
+	fixture, err := readProcfsFixture(dir)
+	if err != nil {
+		return fmt.Errorf("Не удалось прочитать сохраненный снимок: %v", err)
+	}
+	data, err := marshalProcfsFixture(fixture)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, procfsFixtureFile), data, 0644)
+}
+
+// readStatusUID возвращает действующий UID процесса из строки "Uid:" файла status
+func readStatusUID(statusPath string) (string, error) {
+	file, err := os.Open(statusPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Uid: реальный, действующий, сохраненный и файловый UID
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[0] == "Uid:" {
+			if _, err := strconv.ParseUint(fields[2], 10, 32); err != nil {
+				return "", fmt.Errorf("%w UID в %s: %q", ErrParse, statusPath, fields[2])
+			}
+			return fields[2], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%w %s: Uid не найден", ErrParse, statusPath)
+}
+
+// firstDifference возвращает номер и содержимое первой различающейся строки двух текстов
+func firstDifference(expected, actual []byte) (int, string, string) {
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var want, got string
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if want != got {
+			return i + 1, want, got
+		}
+	}
+	return 0, "", ""
+}
+
+// checkProcfsFixture читает снимок procfs из каталога dir и сравнивает результат
+// с сохраненным expected.json; при расхождении ошибка содержит первую различающуюся строку
+func checkProcfsFixture(dir string) (ProcfsFixture, error) {
+	fixture, err := readProcfsFixture(dir)
+	if err != nil {
+		return ProcfsFixture{}, err
+	}
+	actual, err := marshalProcfsFixture(fixture)
+	if err != nil {
+		return ProcfsFixture{}, err
+	}
+	expected, err := os.ReadFile(filepath.Join(dir, procfsFixtureFile))
+	if err != nil {
+		return ProcfsFixture{}, err
+	}
+	if !bytes.Equal(expected, actual) {
+		line, want, got := firstDifference(expected, actual)
+		return ProcfsFixture{}, fmt.Errorf("%s line %d\n  expected: %s\n  actual:   %s", procfsFixtureFile, line, strings.TrimSpace(want), strings.TrimSpace(got))
+	}
+	return fixture, nil
+}
+
+// runProcfsCheckCommand — подкоманда "procfs-check": интеграционная проверка чтения procfs.
+// Каждый каталог — снимок procfs (например, из testdata/procfs), который читается заново
+// и сравнивается с сохраненным expected.json; с --update ожидаемый результат перезаписывается
+func runProcfsCheckCommand(args []string) error {
+	flags := newCommandFlags("procfs-check")
+	update := flags.Bool("update", false, "rewrite expected.json of every snapshot with the current result")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("Использование: memory-analyzer procfs-check [--update] snapshot-dir...")
+	}
+	failed := 0
+	for _, dir := range flags.Args() {
+		if *update {
+			fixture, err := readProcfsFixture(dir)
+			if err != nil {
+				return err
+			}
+			actual, err := marshalProcfsFixture(fixture)
+			if err != nil {
+				return err
+			}
+			expectedPath := filepath.Join(dir, procfsFixtureFile)
+			if err := os.WriteFile(expectedPath, actual, 0644); err != nil {
+				return err
+			}
+			fmt.Printf("updated %s\n", expectedPath)
+			continue
+		}
+		fixture, err := checkProcfsFixture(dir)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", dir, err)
+			failed++
+			continue
+		}
+		fmt.Printf("ok   %s (%d processes)\n", dir, len(fixture.Processes))
+	}
+	if failed > 0 {
+		return fmt.Errorf("Снимков с ошибками: %d из %d", failed, flags.NArg())
+	}
+	return nil
+}
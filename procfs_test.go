@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestProcfsFixtures читает каждый снимок из testdata/procfs так же, как procfs-check,
+// и сравнивает результат с его expected.json
+func TestProcfsFixtures(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "procfs", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("в testdata/procfs нет снимков")
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			fixture, err := checkProcfsFixture(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fixture.Processes) == 0 {
+				t.Error("в снимке не прочитано ни одного процесса")
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ProcessIO — статистика ввода-вывода процесса
+type ProcessIO struct {
+	//Байты, прочитанные с устройств хранения (без учета попаданий в page cache)
+	ReadBytes uint64 `json:"read_bytes"`
+	//Байты, записанные на устройства хранения
+	WriteBytes uint64 `json:"write_bytes"`
+	//Скорость чтения и записи в байтах в секунду с момента предыдущего обновления
+	ReadRate  float64 `json:"read_rate"`
+	WriteRate float64 `json:"write_rate"`
+}
+
+// IOReader реализуют источники данных, умеющие читать статистику ввода-вывода процессов
+type IOReader interface {
+	//ReadProcessIO возвращает накопленную статистику ввода-вывода процесса
+	//
+	//Скорости в возвращаемой структуре не заполняются
+	ReadProcessIO(pid int) (ProcessIO, error)
+}
+
+func (l *LinuxMemoryReader) ReadProcessIO(pid int) (ProcessIO, error) {
+	file, err := os.Open(l.procPath(pid, "io"))
+	if err != nil {
+		return ProcessIO{}, processError(pid, err)
+	}
+	defer file.Close()
+	stats, err := parseMemInfo(file)
+	if err != nil {
+		return ProcessIO{}, err
+	}
+	readBytes, ok := stats["read_bytes"]
+	if !ok {
+		return ProcessIO{}, fmt.Errorf("%w /proc/%d/io: read_bytes не найден", ErrParse, pid)
+	}
+	return ProcessIO{ReadBytes: readBytes, WriteBytes: stats["write_bytes"]}, nil
+}
+
+// applyIORates вычисляет скорости ввода-вывода процессов по разнице с предыдущим замером
+func applyIORates(processes []ProcessInfo, previous []ProcessInfo, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	prevIO := make(map[int]*ProcessIO, len(previous))
+	for _, process := range previous {
+		if process.IO != nil {
+			prevIO[process.PID] = process.IO
+		}
+	}
+	seconds := elapsed.Seconds()
+	for _, process := range processes {
+		prev, ok := prevIO[process.PID]
+		if process.IO == nil || !ok {
+			continue
+		}
+		if process.IO.ReadBytes >= prev.ReadBytes {
+			process.IO.ReadRate = float64(process.IO.ReadBytes-prev.ReadBytes) / seconds
+		}
+		if process.IO.WriteBytes >= prev.WriteBytes {
+			process.IO.WriteRate = float64(process.IO.WriteBytes-prev.WriteBytes) / seconds
+		}
+	}
+}
+
+// ioRate возвращает суммарную скорость ввода-вывода процесса для сортировки
+func (p ProcessInfo) ioRate() float64 {
+	if p.IO == nil {
+		return 0
+	}
+	return p.IO.ReadRate + p.IO.WriteRate
+}
+
+func formatRate(rate float64) string {
+	return FormatMemorySize(uint64(rate)) + "/s"
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procPageSize — размер страницы, в которой /proc/[pid]/stat указывает RSS. В Linux
+// os.Getpagesize берет его из вектора AT_PAGESZ ядра, поэтому на arm64 с 16 и 64 КБ
+// страницами он тоже верен
+var procPageSize = uint64(os.Getpagesize())
+
+// ProcessStat — поля однострочного /proc/[pid]/stat, нужные таблице процессов
+type ProcessStat struct {
+	State string
+	PPID  int
+	//Флаги процесса (PF_*); PF_KTHREAD отмечает потоки ядра
+	Flags uint64
+	//Значение nice от -20 до 19
+	Nice int
+	//Резидентная память в страницах; 0 у потоков ядра и зомби
+	RSS uint64
+}
+
+// parseProcessStat разбирает /proc/[pid]/stat. Имя процесса в скобках может содержать
+// пробелы и скобки, поэтому поля отсчитываются от последней ")":
+// state ppid pgrp session tty_nr tpgid flags ... (nice — 17-е, rss — 22-е поле после имени)
+func parseProcessStat(pid int, data []byte) (ProcessStat, error) {
+	stat := string(data)
+	idx := strings.LastIndex(stat, ")")
+	if idx == -1 {
+		return ProcessStat{}, fmt.Errorf("%w /proc/%d/stat", ErrParse, pid)
+	}
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 22 {
+		return ProcessStat{}, fmt.Errorf("%w /proc/%d/stat", ErrParse, pid)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ProcessStat{}, fmt.Errorf("%w /proc/%d/stat", ErrParse, pid)
+	}
+	flags, err := strconv.ParseUint(fields[6], 10, 64)
+	if err != nil {
+		return ProcessStat{}, fmt.Errorf("%w /proc/%d/stat", ErrParse, pid)
+	}
+	nice, err := strconv.Atoi(fields[16])
+	if err != nil {
+		return ProcessStat{}, fmt.Errorf("%w /proc/%d/stat", ErrParse, pid)
+	}
+	rss, err := strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return ProcessStat{}, fmt.Errorf("%w /proc/%d/stat", ErrParse, pid)
+	}
+	return ProcessStat{State: fields[0][:1], PPID: ppid, Flags: flags, Nice: nice, RSS: uint64(max(rss, 0))}, nil
+}
+
+// kernelThread определяет поток ядра по флагу PF_KTHREAD или по родителю kthreadd
+func (s ProcessStat) kernelThread(pid int) bool {
+	return pid == kthreaddPID || s.PPID == kthreaddPID || s.Flags&pfKthread != 0
+}
+
+// readProcessStat читает и разбирает /proc/[pid]/stat
+func (l *LinuxMemoryReader) readProcessStat(pid int) (ProcessStat, error) {
+	data, err := os.ReadFile(l.procPath(pid, "stat"))
+	if err != nil {
+		return ProcessStat{}, processError(pid, err)
+	}
+	return parseProcessStat(pid, data)
+}
+
+// memoryState — память и сведения процесса, прочитанные одним обращением
+type memoryState struct {
+	Memory uint64
+	State  string
+	//Значение nice; nil, если источник читает его отдельно (PriorityReader)
+	Nice *int
+	//Поток ядра
+	Kernel bool
+}
+
+// memoryStateReader реализуют источники данных, которые читают память и состояние
+// процесса одним обращением вместо ReadProcessMemory, ReadProcessState и других
+type memoryStateReader interface {
+	readProcessMemoryState(pid int) (memoryState, error)
+}
+
+// readProcessMemoryState читает RSS, состояние, nice и признак потока ядра из одной строки
+// /proc/[pid]/stat вместо поиска VmRSS среди полусотни строк status и повторного чтения stat.
+// У процессов без пользовательской памяти (потоки ядра, зомби) возвращается
+// errNoResidentMemory вместе с остальными сведениями. В снимках procfs размер страницы
+// снявшей их машины неизвестен, поэтому RSS в килобайтах берется из status, как в ReadProcessMemory
+func (l *LinuxMemoryReader) readProcessMemoryState(pid int) (memoryState, error) {
+	stat, err := l.readProcessStat(pid)
+	if err != nil {
+		return memoryState{}, err
+	}
+	state := memoryState{State: stat.State, Nice: &stat.Nice, Kernel: stat.kernelThread(pid)}
+	if l.Root != "" {
+		state.Memory, err = l.readStatusRSS(pid)
+		return state, err
+	}
+	if stat.RSS == 0 {
+		return state, fmt.Errorf("%w: RSS равен 0 для PID %d", errNoResidentMemory, pid)
+	}
+	state.Memory = stat.RSS * procPageSize
+	return state, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Сравнение чтения памяти и состояния процесса: одна строка stat против поиска VmRSS
+// в status и отдельного чтения stat для состояния, как было до readProcessMemoryState.
+// Снимок из testdata содержит укороченные status, поэтому для сравнения с настоящим
+// status из полусотни строк есть вариант live с /proc/self.
+// Запуск: go test -run '^$' -bench ReadProcessStat -benchmem
+
+// statBenchTargets возвращает источники данных для сравнения: снимок procfs и /proc
+func statBenchTargets() map[string]struct {
+	reader *LinuxMemoryReader
+	pid    int
+} {
+	targets := map[string]struct {
+		reader *LinuxMemoryReader
+		pid    int
+	}{
+		"fixture": {&LinuxMemoryReader{Root: filepath.Join("testdata", "procfs", "linux-6.8")}, 2410},
+	}
+	if _, err := os.Stat("/proc/self/stat"); err == nil {
+		targets["live"] = struct {
+			reader *LinuxMemoryReader
+			pid    int
+		}{&LinuxMemoryReader{}, os.Getpid()}
+	}
+	return targets
+}
+
+func BenchmarkReadProcessStat(b *testing.B) {
+	for name, target := range statBenchTargets() {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := target.reader.readProcessStat(target.pid); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadProcessStatus(b *testing.B) {
+	for name, target := range statBenchTargets() {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := target.reader.readStatusRSS(target.pid); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := target.reader.readProcessStat(target.pid); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestParseProcessStat проверяет отсчет полей от последней ")" на строках /proc/[pid]/stat,
+// в том числе с именами, содержащими пробелы и скобки
+func TestParseProcessStat(t *testing.T) {
+	tests := []struct {
+		name   string
+		pid    int
+		stat   string
+		want   ProcessStat
+		kernel bool
+		err    bool
+	}{
+		{
+			name: "process",
+			pid:  2410,
+			stat: "2410 (firefox) S 1180 2410 2410 0 -1 4194560 912034 1203 12 0 40213 9120 3 1 20 0 142 0 5123 4123451392 120398 18446744073709551615 94004253945856 94004253965737 140720760252528 0 0 0 0 4096 17663 0 0 0 17 3 0 0 0 0 0 94004253981744 94004253983360 94004854185984 140720760255817 140720760255837 140720760255837 140720760258539 0\n",
+			want: ProcessStat{State: "S", PPID: 1180, Flags: 4194560, Nice: 0, RSS: 120398},
+		},
+		{
+			name: "comm with spaces",
+			pid:  3120,
+			stat: "3120 (Web Content) R 2410 2410 2410 0 -1 4194560 20311 0 0 0 811 95 0 0 25 5 31 0 9123 2890752000 48213 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 1 0 0 0 0 0\n",
+			want: ProcessStat{State: "R", PPID: 2410, Flags: 4194560, Nice: 5, RSS: 48213},
+		},
+		{
+			// Имя "a) R 99 (b" до последней ")" похоже на начало полей
+			name: "comm with parentheses",
+			pid:  5000,
+			stat: "5000 (a) R 99 (b) D 1 5000 5000 0 -1 4194304 0 0 0 0 0 0 0 0 39 19 1 0 100 4096 7 18446744073709551615\n",
+			want: ProcessStat{State: "D", PPID: 1, Flags: 4194304, Nice: 19, RSS: 7},
+		},
+		{
+			name: "negative nice",
+			pid:  880,
+			stat: "880 (pipewire) S 1 880 880 0 -1 4194560 0 0 0 0 0 0 0 0 9 -11 2 0 400 0 3210 18446744073709551615\n",
+			want: ProcessStat{State: "S", PPID: 1, Flags: 4194560, Nice: -11, RSS: 3210},
+		},
+		{
+			name:   "kthreadd",
+			pid:    2,
+			stat:   "2 (kthreadd) S 0 0 0 0 -1 2129984 0 0 0 0 0 0 0 0 20 0 1 0 5 0 0 18446744073709551615 0 0 0 0 0 0 0 2147483647 0 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n",
+			want:   ProcessStat{State: "S", PPID: 0, Flags: 2129984, Nice: 0, RSS: 0},
+			kernel: true,
+		},
+		{
+			name:   "kernel thread",
+			pid:    41,
+			stat:   "41 (kworker/0:1-events) I 2 0 0 0 -1 69238880 0 0 0 0 0 12 0 0 20 0 1 0 100 0 0 18446744073709551615\n",
+			want:   ProcessStat{State: "I", PPID: 2, Flags: 69238880, Nice: 0, RSS: 0},
+			kernel: true,
+		},
+		{
+			name: "negative rss",
+			pid:  77,
+			stat: "77 (exiting) Z 1 77 77 0 -1 4227076 0 0 0 0 0 0 0 0 20 0 1 0 100 0 -1 18446744073709551615\n",
+			want: ProcessStat{State: "Z", PPID: 1, Flags: 4227076, Nice: 0, RSS: 0},
+		},
+		{name: "no closing parenthesis", pid: 12, stat: "12 (bash S 1 12 12 0 -1 4194304", err: true},
+		{name: "truncated before rss", pid: 12, stat: "12 (bash) S 1 12 12 0 -1 4194304 0 0 0 0 0 0 0 0 20 0 1 0 100 0", err: true},
+		{name: "non-numeric nice", pid: 12, stat: "12 (bash) S 1 12 12 0 -1 4194304 0 0 0 0 0 0 0 0 20 x 1 0 100 0 310", err: true},
+		{name: "empty", pid: 12, stat: "", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stat, err := parseProcessStat(tt.pid, []byte(tt.stat))
+			if tt.err {
+				if !errors.Is(err, ErrParse) {
+					t.Fatalf("ошибка = %v, ожидалась ErrParse", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat != tt.want {
+				t.Errorf("parseProcessStat = %+v, ожидалось %+v", stat, tt.want)
+			}
+			if kernel := stat.kernelThread(tt.pid); kernel != tt.kernel {
+				t.Errorf("kernelThread = %v, ожидалось %v", kernel, tt.kernel)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Состояния процессов, на которые стоит обратить внимание во время инцидентов
+const (
+	StateZombie          = "Z"
+	StateUninterruptible = "D"
+)
+
+// StateReader реализуют источники данных, умеющие определять состояние процесса
+type StateReader interface {
+	//ReadProcessState возвращает однобуквенное состояние процесса: R, S, D, Z, T, I
+	ReadProcessState(pid int) (string, error)
+}
+
+func (l *LinuxMemoryReader) ReadProcessState(pid int) (string, error) {
+	stat, err := l.readProcessStat(pid)
+	if err != nil {
+		return "", err
+	}
+	return stat.State, nil
+}
+
+func (d *DarwinMemoryReader) ReadProcessState(pid int) (string, error) {
+	state, err := d.readProcessMemoryState(pid)
+	if errors.Is(err, errNoResidentMemory) {
+		return state.State, nil
+	}
+	return state.State, err
+}
+
+// isAlarmingState сообщает, что процесс — зомби или находится в непрерываемом ожидании
+func isAlarmingState(state string) bool {
+	return state == StateZombie || state == StateUninterruptible
+}
+
+// FormatStateCounts возвращает строку с количеством зомби и процессов в состоянии D
+// или пустую строку, если таких процессов нет
+func FormatStateCounts(processes []ProcessInfo) string {
+	zombies, blocked := 0, 0
+	for _, process := range processes {
+		switch process.State {
+		case StateZombie:
+			zombies++
+		case StateUninterruptible:
+			blocked++
+		}
+	}
+	if zombies == 0 && blocked == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Processes: %d zombie, %d uninterruptible (D)\n", zombies, blocked)
+}
+
+// colorizeStateRows выделяет красным зомби и процессы в состоянии D
+func colorizeStateRows(table string, processes []ProcessInfo) string {
+	lines := strings.Split(table, "\n")
+	for i, process := range processes {
+		idx := tableHeaderLines + i
+		if idx < len(lines) && isAlarmingState(process.State) {
+			lines[idx] = paint(activeTheme.Critical, lines[idx])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
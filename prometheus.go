@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func init() {
+	RegisterSinkType("prometheus", newPrometheusSink)
+}
+
+// prometheusSink публикует последний замер в текстовом формате Prometheus по адресу /metrics
+type prometheusSink struct {
+	addr     string
+	listener net.Listener
+	mu       sync.Mutex
+	sample   Sample
+}
+
+func newPrometheusSink(addr string) (Sink, error) {
+	s := &prometheusSink{addr: addr}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	listener, err := serveHTTP(addr, mux)
+	if err != nil {
+		return nil, err
+	}
+	s.listener = listener
+	return s, nil
+}
+
+func (s *prometheusSink) Name() string {
+	return "prometheus:" + s.addr
+}
+
+func (s *prometheusSink) Write(sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sample = sample
+	return nil
+}
+
+func (s *prometheusSink) Close() error {
+	return s.listener.Close()
+}
+
+func (s *prometheusSink) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sample := s.sample
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, FormatPrometheusMetrics(sample))
+}
+
+// promMetric — одна метрика в текстовом формате Prometheus
+type promMetric struct {
+	name, help string
+	values     []promValue
+}
+
+type promValue struct {
+	labels map[string]string
+	value  float64
+}
+
+// FormatPrometheusMetrics переводит замер в текстовый формат Prometheus
+func FormatPrometheusMetrics(sample Sample) string {
+	if sample.Time.IsZero() {
+		return ""
+	}
+	identity := exportLabels()
+	var res strings.Builder
+	for _, metric := range prometheusMetrics(sample) {
+		if len(metric.values) == 0 {
+			continue
+		}
+		res.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", metric.name, metric.help, metric.name))
+		for _, v := range metric.values {
+			res.WriteString(metric.name)
+			res.WriteString(formatPromLabels(withIdentityLabels(v.labels, identity)))
+			res.WriteString(" " + strconv.FormatFloat(v.value, 'f', -1, 64) + "\n")
+		}
+	}
+	return res.String()
+}
+
+// prometheusMetrics собирает метрики замера для /metrics и приемника remote_write
+//
+// Потребление памяти процессами суммируется по имени, чтобы короткоживущие PID
+// не порождали бесконечное число временных рядов
+func prometheusMetrics(sample Sample) []promMetric {
+	system := sample.System
+	metrics := []promMetric{
+		{"memory_analyzer_system_total_bytes", "Total physical memory.", []promValue{{value: float64(system.TotalMemory)}}},
+		{"memory_analyzer_system_free_bytes", "Free physical memory.", []promValue{{value: float64(system.FreeMemory)}}},
+		{"memory_analyzer_system_available_bytes", "Memory available for new allocations.", []promValue{{value: float64(system.AvailableMemory)}}},
+		{"memory_analyzer_swap_total_bytes", "Total swap.", []promValue{{value: float64(system.SwapTotal)}}},
+		{"memory_analyzer_swap_free_bytes", "Free swap.", []promValue{{value: float64(system.SwapFree)}}},
+	}
+
+	byName := make(map[string]uint64)
+	for _, process := range sample.Processes {
+		byName[process.Name] += process.MemoryUsage
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	processes := promMetric{name: "memory_analyzer_process_resident_bytes", help: "Resident memory of processes grouped by name."}
+	for _, name := range names {
+		processes.values = append(processes.values, promValue{labels: map[string]string{"name": name}, value: float64(byName[name])})
+	}
+	metrics = append(metrics, processes)
+
+	byLabel := make(map[[2]string]uint64)
+	for _, process := range sample.Processes {
+		for key, value := range process.Labels {
+			byLabel[[2]string{key, value}] += process.MemoryUsage
+		}
+	}
+	labelPairs := make([][2]string, 0, len(byLabel))
+	for pair := range byLabel {
+		labelPairs = append(labelPairs, pair)
+	}
+	sort.Slice(labelPairs, func(i, j int) bool {
+		if labelPairs[i][0] != labelPairs[j][0] {
+			return labelPairs[i][0] < labelPairs[j][0]
+		}
+		return labelPairs[i][1] < labelPairs[j][1]
+	})
+	labeled := promMetric{name: "memory_analyzer_label_resident_bytes", help: "Resident memory of processes grouped by business label."}
+	for _, pair := range labelPairs {
+		labeled.values = append(labeled.values, promValue{labels: map[string]string{"label": pair[0], "value": pair[1]}, value: float64(byLabel[pair])})
+	}
+	metrics = append(metrics, labeled)
+
+	collectorMetrics := promMetric{name: "memory_analyzer_collector_value", help: "Metrics reported by collectors and plugins."}
+	for _, result := range sample.Collectors {
+		for _, metric := range result.Metrics {
+			collectorMetrics.values = append(collectorMetrics.values, promValue{
+				labels: map[string]string{"collector": result.Name, "metric": metric.Name, "unit": metric.Unit},
+				value:  metric.Value,
+			})
+		}
+	}
+	metrics = append(metrics, collectorMetrics)
+
+	if sample.Forecast != nil {
+		metrics = append(metrics, promMetric{"memory_analyzer_exhaustion_eta_seconds", "Forecast time until available memory is exhausted.",
+			[]promValue{{value: sample.Forecast.ETASeconds}}})
+	}
+	alerts := promMetric{name: "memory_analyzer_alert_firing", help: "Alert rules currently firing."}
+	muted := promMetric{name: "memory_analyzer_alert_muted", help: "Firing alert rules that are acknowledged or silenced."}
+	for _, alert := range sample.Alerts {
+		alerts.values = append(alerts.values, promValue{labels: map[string]string{"rule": alert.Rule}, value: 1})
+		if alert.Muted() {
+			muted.values = append(muted.values, promValue{labels: map[string]string{"rule": alert.Rule}, value: 1})
+		}
+	}
+	degraded := promMetric{name: "memory_analyzer_degraded", help: "Parts of data collection that recovered from a panic since start."}
+	for _, component := range sample.Degraded {
+		degraded.values = append(degraded.values, promValue{labels: map[string]string{"component": component}, value: 1})
+	}
+	fallbacks := promMetric{name: "memory_analyzer_degraded_field", help: "Sample values read from a fallback source or unavailable because an external program is missing or failed."}
+	for _, field := range sample.DegradedFields {
+		fallbacks.values = append(fallbacks.values, promValue{labels: map[string]string{"field": field.Field, "reason": field.Reason}, value: 1})
+	}
+	metrics = append(metrics, alerts, muted, degraded, fallbacks)
+	if timings := sample.Timings; timings != nil {
+		stages := promMetric{name: "memory_analyzer_collect_duration_seconds", help: "Duration of the collection stages of the last sample; stages run concurrently."}
+		for _, stage := range []struct {
+			name  string
+			value float64
+		}{
+			{"system", timings.System},
+			{"process_list", timings.ProcessList},
+			{"process_details", timings.ProcessDetails},
+			{"collectors", timings.Collectors},
+			{"total", timings.Total},
+		} {
+			stages.values = append(stages.values, promValue{labels: map[string]string{"stage": stage.name}, value: stage.value})
+		}
+		metrics = append(metrics, stages, promMetric{"memory_analyzer_process_deep_reads", "Processes whose owner, namespace and cgroup were re-read instead of taken from the cache.",
+			[]promValue{{value: float64(timings.DeepReads)}}})
+	}
+	return metrics
+}
+
+// formatPromLabels форматирует метки в порядке имен с экранированием значений
+func formatPromLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[key])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, key, value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// withIdentityLabels дополняет метки значения метками identity; собственные метки
+// метрики важнее совпадающих по имени
+func withIdentityLabels(labels, identity map[string]string) map[string]string {
+	if len(identity) == 0 {
+		return labels
+	}
+	merged := make(map[string]string, len(labels)+len(identity))
+	for name, value := range identity {
+		merged[name] = value
+	}
+	for name, value := range labels {
+		merged[name] = value
+	}
+	return merged
+}
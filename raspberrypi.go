@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	if model := raspberryPiModel(); model != "" {
+		RegisterCollector(&raspberryPiCollector{model: model})
+	}
+}
+
+// raspberryPiModel возвращает модель платы Raspberry Pi из device tree или пустую строку
+func raspberryPiModel() string {
+	data, err := os.ReadFile("/proc/device-tree/model")
+	if err != nil {
+		return ""
+	}
+	model := strings.TrimRight(string(data), "\x00\n")
+	if !strings.HasPrefix(model, "Raspberry Pi") {
+		return ""
+	}
+	return model
+}
+
+// raspberryPiCollector показывает разделение оперативной памяти между CPU (ARM) и GPU
+//
+// Часть RAM резервируется под GPU и не входит в MemTotal, поэтому без этой поправки
+// общий объем памяти не совпадает с объемом, указанным для платы
+type raspberryPiCollector struct {
+	model string
+}
+
+func (c *raspberryPiCollector) Name() string {
+	return c.model
+}
+
+func (c *raspberryPiCollector) Collect() ([]Metric, error) {
+	arm, err := vcgencmdMemory("arm")
+	if err != nil {
+		return nil, err
+	}
+	gpu, err := vcgencmdMemory("gpu")
+	if err != nil {
+		return nil, err
+	}
+	physical := arm + gpu
+	if boardMemory, err := raspberryPiBoardMemory(); err == nil {
+		physical = boardMemory
+	}
+	return []Metric{
+		{Name: "cpu", Value: float64(arm), Unit: "bytes"},
+		{Name: "gpu", Value: float64(gpu), Unit: "bytes"},
+		{Name: "physical", Value: float64(physical), Unit: "bytes"},
+	}, nil
+}
+
+// vcgencmdMemory возвращает объем памяти, выделенный под arm или gpu, по выводу "vcgencmd get_mem"
+func vcgencmdMemory(part string) (uint64, error) {
+	output, err := exec.Command("vcgencmd", "get_mem", part).Output()
+	if err != nil {
+		return 0, fmt.Errorf("vcgencmd недоступен: %v", err)
+	}
+	_, value, found := strings.Cut(strings.TrimSpace(string(output)), "=")
+	if !found {
+		return 0, fmt.Errorf("Неверный формат вывода vcgencmd: %s", output)
+	}
+	return parseByteSize(value)
+}
+
+// raspberryPiBoardMemory определяет объем RAM платы по коду ревизии из /proc/cpuinfo
+//
+// В кодах ревизий нового формата (бит 23) объем памяти закодирован в битах 20-22
+func raspberryPiBoardMemory() (uint64, error) {
+	file, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(key) != "Revision" {
+			continue
+		}
+		revision, err := strconv.ParseUint(strings.TrimSpace(value), 16, 32)
+		if err != nil {
+			return 0, err
+		}
+		if revision&(1<<23) == 0 {
+			return 0, fmt.Errorf("Старый формат кода ревизии: %x", revision)
+		}
+		sizeCode := (revision >> 20) & 0x7
+		return (256 * 1024 * 1024) << sizeCode, nil
+	}
+	return 0, fmt.Errorf("Код ревизии не найден")
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reconciliation — сверка занятой памяти системы (MemTotal − MemAvailable) с суммой
+// известных потребителей: PSS процессов, неосвобождаемого slab ядра, таблиц страниц,
+// стеков ядра и пула huge pages. В отличие от суммы RSS, PSS делит общие страницы между
+// процессами, поэтому каждая страница учитывается ровно один раз
+type Reconciliation struct {
+	Used uint64 `json:"used_bytes"`
+	//Сумма PSS процессов и число процессов, PSS которых удалось прочитать
+	PSS       uint64 `json:"pss_bytes"`
+	Processes int    `json:"processes"`
+	//Процессы, PSS которых прочитать не удалось (обычно чужие процессы без root)
+	Unreadable int `json:"unreadable"`
+	//Неосвобождаемая часть slab (SUnreclaim); освобождаемая входит в MemAvailable
+	Slab        uint64 `json:"slab_unreclaimable_bytes"`
+	PageTables  uint64 `json:"page_tables_bytes"`
+	KernelStack uint64 `json:"kernel_stack_bytes"`
+	//Зарезервированный пул huge pages (HugePages_Total × Hugepagesize)
+	HugePages uint64 `json:"huge_pages_bytes"`
+	//Top процессов по PSS
+	Top []ProcessInfo `json:"top"`
+}
+
+// Accounted возвращает сумму учтенных потребителей памяти
+func (r Reconciliation) Accounted() uint64 {
+	return r.PSS + r.Slab + r.PageTables + r.KernelStack + r.HugePages
+}
+
+// Residual возвращает неучтенный остаток: положительный — память, не принадлежащая ни процессам,
+// ни учтенным структурам ядра (tmpfs и разделяемая память без отображений, vmalloc, драйверы,
+// непрочитанные процессы)
+func (r Reconciliation) Residual() int64 {
+	return int64(r.Used) - int64(r.Accounted())
+}
+
+// readProcessPSS читает PSS процесса из /proc/[pid]/smaps_rollup, а в ядрах до 4.14,
+// где этого файла нет, суммирует PSS всех отображений из /proc/[pid]/smaps
+func readProcessPSS(pid int) (uint64, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	file, err := os.Open(filepath.Join(dir, "smaps_rollup"))
+	if os.IsNotExist(err) {
+		file, err = os.Open(filepath.Join(dir, "smaps"))
+	}
+	if err != nil {
+		return 0, processError(pid, err)
+	}
+	defer file.Close()
+	var pss uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "Pss:" {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w Pss в %s: %q", ErrParse, file.Name(), fields[1])
+		}
+		pss += value * 1024
+	}
+	return pss, processError(pid, scanner.Err())
+}
+
+// readMemInfo читает значения /proc/meminfo (в килобайтах)
+func readMemInfo() (map[string]uint64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть /proc/meminfo: %w", permissionError(err))
+	}
+	defer file.Close()
+	return parseMemInfo(file)
+}
+
+// reconcileMemory сверяет занятую память системы по значениям /proc/meminfo
+// с PSS процессов и памятью ядра
+func reconcileMemory(stats map[string]uint64, top int) (Reconciliation, error) {
+	system, err := systemMemoryFromMemInfo(stats)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	r := Reconciliation{
+		Used:        system.TotalMemory - system.AvailableMemory,
+		Slab:        stats["SUnreclaim"] * 1024,
+		PageTables:  stats["PageTables"] * 1024,
+		KernelStack: stats["KernelStack"] * 1024,
+		HugePages:   stats["HugePages_Total"] * stats["Hugepagesize"] * 1024,
+	}
+
+	reader := &LinuxMemoryReader{}
+	pids, err := reader.GetProcessList()
+	if err != nil {
+		return r, err
+	}
+	var processes []ProcessInfo
+	for _, pid := range pids {
+		pss, err := readProcessPSS(pid)
+		// Для потоков ядра и завершившихся процессов чтение возвращает ESRCH
+		if errors.Is(err, ErrProcessGone) {
+			continue
+		}
+		if err != nil {
+			r.Unreadable++
+			continue
+		}
+		if pss == 0 {
+			continue
+		}
+		name, err := reader.ReadProcessName(pid)
+		if err != nil {
+			name = fmt.Sprintf("process-%d", pid)
+		}
+		r.PSS += pss
+		r.Processes++
+		processes = append(processes, ProcessInfo{PID: pid, Name: name, MemoryUsage: pss})
+	}
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].MemoryUsage > processes[j].MemoryUsage
+	})
+	if len(processes) > top {
+		processes = processes[:top]
+	}
+	r.Top = processes
+	return r, nil
+}
+
+// FormatReconciliation форматирует сверку: занятую память, ее учтенные составляющие и остаток
+func FormatReconciliation(r Reconciliation) string {
+	var res strings.Builder
+	row := func(label string, value uint64) {
+		res.WriteString(fmt.Sprintf("  %s%12s\n", fitLeft(label, 34), FormatMemorySize(value)))
+	}
+	res.WriteString("Memory reconciliation:\n")
+	row("Used (MemTotal - MemAvailable)", r.Used)
+	row(fmt.Sprintf("Process PSS (%d processes)", r.Processes), r.PSS)
+	row("Unreclaimable slab (SUnreclaim)", r.Slab)
+	row("Page tables", r.PageTables)
+	row("Kernel stacks", r.KernelStack)
+	if r.HugePages > 0 {
+		row("Huge pages pool", r.HugePages)
+	}
+	res.WriteString(fmt.Sprintf("  %s%12s\n", fitLeft("Residual", 34), formatSignedSize(r.Residual())))
+	if r.Residual() >= 0 {
+		res.WriteString("Residual: tmpfs and shared memory not mapped by any process, vmalloc, driver and GPU allocations\n")
+	} else {
+		res.WriteString("Residual: negative residual usually means reclaimable memory counted in PSS (file pages) that MemAvailable treats as free\n")
+	}
+	if r.Unreadable > 0 {
+		res.WriteString(fmt.Sprintf("%d processes could not be read; run as root for a complete PSS sum\n", r.Unreadable))
+	}
+	if len(r.Top) > 0 {
+		res.WriteString("\nTop processes by PSS:\n")
+		header := fmt.Sprintf("%-8s %s %10s", "PID", fitLeft("NAME", 15), "PSS")
+		res.WriteString(header + "\n")
+		res.WriteString(strings.Repeat("-", len(header)) + "\n")
+		for _, process := range r.Top {
+			res.WriteString(fmt.Sprintf("%-8d %s %10s\n", process.PID, fitLeft(getShortProcessName(process.Name), 15), FormatMemorySize(process.MemoryUsage)))
+		}
+	}
+	return res.String()
+}
+
+// runReconcileCommand — подкоманда "reconcile": отвечает на вопрос "куда делась память",
+// сверяя занятую память системы с PSS процессов и памятью ядра (Linux)
+func runReconcileCommand(args []string) error {
+	flags := newCommandFlags("reconcile")
+	top := flags.Int("top", 10, "number of processes with the largest PSS to list")
+	flags.Parse(args)
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("%w: сверка памяти поддерживается только в Linux", ErrUnsupportedPlatform)
+	}
+	stats, err := readMemInfo()
+	if err != nil {
+		return err
+	}
+	r, err := reconcileMemory(stats, *top)
+	if err != nil {
+		return err
+	}
+	fmt.Print(FormatReconciliation(r))
+	return nil
+}
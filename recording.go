@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Сжатие файлов записи выбирается по расширению: .gz — gzip, .zst — zstd (через утилиту zstd)
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressionForPath определяет сжатие файла записи по его расширению
+func compressionForPath(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return CompressionGzip
+	case strings.HasSuffix(path, ".zst"):
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// recordingExt возвращает расширение файла записи вместе с расширением сжатия, например ".jsonl.gz"
+func recordingExt(path string) string {
+	ext := filepath.Ext(path)
+	if compressionForPath(path) != CompressionNone {
+		ext = filepath.Ext(strings.TrimSuffix(path, ext)) + ext
+	}
+	return ext
+}
+
+// compressWriter оборачивает w в поток сжатия
+//
+// Каждое открытие файла для дозаписи начинает новый поток; gzip и zstd допускают
+// склейку нескольких потоков в одном файле, поэтому запись можно продолжать после перезапуска
+func compressWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		cmd := exec.Command("zstd", "-q", "-c")
+		cmd.Stdout = w
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("Не удалось запустить zstd: %v", err)
+		}
+		return &commandWriter{WriteCloser: stdin, cmd: cmd}, nil
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// commandWriter передает данные в stdin внешней команды и дожидается ее завершения при закрытии
+type commandWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (c *commandWriter) Close() error {
+	if err := c.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return c.cmd.Wait()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// countingWriter подсчитывает байты, записанные в файл, для ротации по размеру
+type countingWriter struct {
+	w     io.Writer
+	count uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += uint64(n)
+	return n, err
+}
+
+// openRecording открывает файл записи для чтения, распаковывая его при необходимости
+//
+// Сжатие определяется по сигнатуре файла, а не по расширению
+func openRecording(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть запись %s: %v", path, err)
+	}
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("Неверный формат gzip %s: %v", path, err)
+		}
+		return &readCloser{Reader: gz, closers: []io.Closer{gz, file}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		cmd := exec.Command("zstd", "-q", "-d", "-c")
+		cmd.Stdin = buffered
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("Не удалось запустить zstd: %v", err)
+		}
+		return &readCloser{Reader: stdout, closers: []io.Closer{file, waitCloser{cmd}}}, nil
+	}
+	return &readCloser{Reader: buffered, closers: []io.Closer{file}}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+type waitCloser struct {
+	cmd *exec.Cmd
+}
+
+func (w waitCloser) Close() error {
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+	return nil
+}
+
+// readRecordings читает замеры из файлов записи по порядку и передает их в fn
+//
+// Оборванный при аварийном завершении демона конец файла не считается ошибкой
+func readRecordings(paths []string, fn func(Sample) error) error {
+	for _, path := range paths {
+		if err := readRecording(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecording(path string, fn func(Sample) error) error {
+	r, err := openRecording(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	dec := json.NewDecoder(r)
+	for {
+		var sample Sample
+		err := dec.Decode(&sample)
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Неверный формат записи %s: %v", path, err)
+		}
+		if err := fn(sample); err != nil {
+			return err
+		}
+	}
+}
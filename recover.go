@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errPanic оборачивает паники, перехваченные при сборе данных
+var errPanic = errors.New("Паника")
+
+// PanicRecord — паника, перехваченная в одной части сбора данных
+type PanicRecord struct {
+	Component string    `json:"component"`
+	Count     int       `json:"count"`
+	Last      time.Time `json:"last"`
+	Message   string    `json:"message"`
+	Stack     string    `json:"stack"`
+}
+
+// panicLogOutput — куда записываются стеки вызовов перехваченных паник: stderr демона
+// или журнал --debug-log панели; nil — стеки только сохраняются в PanicRecord
+var panicLogOutput io.Writer
+
+// collectionPanics — перехваченные паники по частям сбора данных. После первой паники
+// программа продолжает работу в деградированном режиме до перезапуска: ошибка в
+// разборе одной записи /proc не должна завершать многодневный демон
+var collectionPanics = struct {
+	mu      sync.Mutex
+	records map[string]*PanicRecord
+}{records: make(map[string]*PanicRecord)}
+
+// recordPanic сохраняет перехваченную панику, записывает ее стек в panicLogOutput
+// и возвращает ее как ошибку
+func recordPanic(component string, value interface{}) error {
+	stack := string(debug.Stack())
+	now := time.Now()
+	collectionPanics.mu.Lock()
+	record := collectionPanics.records[component]
+	if record == nil {
+		record = &PanicRecord{Component: component}
+		collectionPanics.records[component] = record
+	}
+	record.Count++
+	record.Last, record.Message, record.Stack = now, fmt.Sprint(value), stack
+	collectionPanics.mu.Unlock()
+	if panicLogOutput != nil {
+		fmt.Fprintf(panicLogOutput, "%s panic in %s: %v\n%s\n", now.Format(time.RFC3339), component, value, stack)
+	}
+	return fmt.Errorf("%w в %s: %v", errPanic, component, value)
+}
+
+// recoverPanic перехватывает панику функции, возвращающей ошибку, и записывает ее в *err:
+//
+//	defer recoverPanic("processes", &err)
+func recoverPanic(component string, err *error) {
+	if value := recover(); value != nil {
+		*err = recordPanic(component, value)
+	}
+}
+
+// panicRecords возвращает копии перехваченных паник в порядке названий частей сбора
+func panicRecords() []PanicRecord {
+	collectionPanics.mu.Lock()
+	defer collectionPanics.mu.Unlock()
+	records := make([]PanicRecord, 0, len(collectionPanics.records))
+	for _, record := range collectionPanics.records {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Component < records[j].Component })
+	return records
+}
+
+// degradedComponents возвращает части сбора данных, в которых перехватывались паники
+func degradedComponents() []string {
+	var components []string
+	for _, record := range panicRecords() {
+		components = append(components, record.Component)
+	}
+	return components
+}
+
+// FormatDegraded сообщает о работе в деградированном режиме или возвращает пустую строку
+func FormatDegraded(degraded []string) string {
+	if len(degraded) == 0 {
+		return ""
+	}
+	return paint(activeTheme.Critical, "Degraded: recovered from a crash in "+strings.Join(degraded, ", ")) + "\n"
+}
+
+// formatPanicRecords перечисляет перехваченные паники для панели ошибок
+func formatPanicRecords() string {
+	var res strings.Builder
+	for _, record := range panicRecords() {
+		res.WriteString(fmt.Sprintf("  panic in %s (%d times, last at %s): %s\n",
+			record.Component, record.Count, record.Last.Format("15:04:05"), record.Message))
+	}
+	return res.String()
+}
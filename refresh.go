@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultSystemInterval — период обновления системной панели по умолчанию
+const defaultSystemInterval = 3 * time.Second
+
+// refreshTolerance — допуск при сравнении времени с периодом обновления панели, чтобы
+// неточность таймера не откладывала обновление до следующего срабатывания
+const refreshTolerance = 250 * time.Millisecond
+
+// RefreshConfig — периоды обновления панелей в конфигурационном файле ("1s", "30s")
+type RefreshConfig struct {
+	//Системная статистика: память, swap, параметры ядра; с этим периодом перерисовывается панель
+	System string `json:"system"`
+	//Таблица процессов вместе с дополнительными колонками и событиями cgroup
+	Processes string `json:"processes"`
+	//Подробные панели: окно просмотра процесса, файлы в памяти и коллекторы
+	Details string `json:"details"`
+}
+
+// RefreshIntervals — периоды обновления панелей; нулевой период таблицы процессов или
+// подробных панелей означает обновление вместе с системной панелью
+type RefreshIntervals struct {
+	System    time.Duration
+	Processes time.Duration
+	Details   time.Duration
+}
+
+// refreshIntervals объединяет периоды из конфигурации и флагов; флаги имеют приоритет
+func refreshIntervals(config RefreshConfig, system, processes, details time.Duration) (RefreshIntervals, error) {
+	intervals := RefreshIntervals{System: defaultSystemInterval}
+	for _, field := range []struct {
+		name   string
+		value  string
+		flag   time.Duration
+		target *time.Duration
+	}{
+		{"system", config.System, system, &intervals.System},
+		{"processes", config.Processes, processes, &intervals.Processes},
+		{"details", config.Details, details, &intervals.Details},
+	} {
+		if field.value != "" {
+			d, err := time.ParseDuration(field.value)
+			if err != nil || d < 0 {
+				return intervals, fmt.Errorf("Неверный период обновления refresh.%s: %q", field.name, field.value)
+			}
+			*field.target = d
+		}
+		if field.flag < 0 {
+			return intervals, fmt.Errorf("Период обновления не может быть отрицательным: %v", field.flag)
+		}
+		if field.flag > 0 {
+			*field.target = field.flag
+		}
+	}
+	if intervals.System <= 0 {
+		return intervals, fmt.Errorf("Период обновления системной панели должен быть положительным")
+	}
+	return intervals, nil
+}
+
+// panelRefresher собирает замеры для информационной панели, обновляя дорогие панели реже
+// системной: таблица процессов и подробные панели между своими обновлениями переносятся
+// из предыдущего замера
+type panelRefresher struct {
+	intervals RefreshIntervals
+
+	//Время последнего обновления таблицы процессов и подробных панелей
+	ProcessesAt time.Time
+	DetailsAt   time.Time
+	//Панели, обновленные последним вызовом collect
+	Processes bool
+	Details   bool
+	//Время между двумя последними обновлениями таблицы процессов (для скорости ввода-вывода)
+	ProcessElapsed time.Duration
+}
+
+func newPanelRefresher(intervals RefreshIntervals) *panelRefresher {
+	return &panelRefresher{intervals: intervals}
+}
+
+// due сообщает, пора ли обновить панель, обновленную в момент last
+func due(last time.Time, interval time.Duration, now time.Time) bool {
+	return last.IsZero() || interval <= 0 || now.Sub(last)+refreshTolerance >= interval
+}
+
+// collect собирает новый замер: системная статистика читается всегда, таблица процессов
+// и коллекторы — когда подошел их период, иначе берутся из prev
+func (r *panelRefresher) collect(reader MemoryReader, prev Sample, report *ErrorReport) (Sample, error) {
+	now := time.Now()
+	next := Sample{Time: now}
+	stampIdentity(&next)
+	r.Processes = prev.Time.IsZero() || due(r.ProcessesAt, r.intervals.Processes, now)
+	r.Details = prev.Time.IsZero() || due(r.DetailsAt, r.intervals.Details, now)
+	if err := collectStages(reader, &next, report, r.Processes, r.Details); err != nil {
+		return next, err
+	}
+	if r.Processes {
+		r.ProcessElapsed = now.Sub(r.ProcessesAt)
+		r.ProcessesAt = now
+	} else {
+		next.Processes = prev.Processes
+		next.CgroupEvents = prev.CgroupEvents
+	}
+	if r.Details {
+		r.DetailsAt = now
+	} else {
+		next.Collectors = prev.Collectors
+	}
+	return next, nil
+}
+
+// staggered сообщает, что панели обновляются с разными периодами
+func (r *panelRefresher) staggered() bool {
+	return r.intervals.Processes > r.intervals.System || r.intervals.Details > r.intervals.System
+}
+
+// formatPanelAges возвращает возраст данных панелей, обновляемых реже системной,
+// например "processes 4s ago, details 21s ago"
+func formatPanelAges(now, processes, details time.Time) string {
+	var parts []string
+	if !processes.IsZero() && now.Sub(processes) >= time.Second {
+		parts = append(parts, fmt.Sprintf("processes %s ago", now.Sub(processes).Round(time.Second)))
+	}
+	if !details.IsZero() && now.Sub(details) >= time.Second {
+		parts = append(parts, fmt.Sprintf("details %s ago", now.Sub(details).Round(time.Second)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//Ограничение времени одного запроса remote_write по умолчанию
+	defaultRemoteWriteTimeout = 30 * time.Second
+	//Число неотправленных замеров, после которого самые старые отбрасываются
+	remoteWriteMaxPending = 1000
+	//Наибольшая пауза между повторами при недоступности сервера
+	remoteWriteMaxBackoff = time.Minute
+)
+
+// RemoteWriteConfig — настройки приемника remote_write
+type RemoteWriteConfig struct {
+	//Учетная запись для basic auth; пароль лучше хранить в файле password_file с правами 0600
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"password_file"`
+	//Дополнительные заголовки запроса, например X-Scope-OrgID для Mimir и Cortex
+	Headers map[string]string `json:"headers"`
+	//Метки всех рядов; по умолчанию instance — имя хоста, job="memory-analyzer" и метки identity
+	Labels map[string]string `json:"labels"`
+	//Ограничение времени запроса, например "10s" (по умолчанию 30s)
+	Timeout string `json:"timeout"`
+}
+
+// remoteWriteSettings — проверенные настройки remote_write
+type remoteWriteSettings struct {
+	config   RemoteWriteConfig
+	password string
+	labels   map[string]string
+	timeout  time.Duration
+}
+
+// activeRemoteWrite — настройки из раздела remote_write конфигурации
+var activeRemoteWrite *remoteWriteSettings
+
+func init() {
+	RegisterSinkType("remote_write", newRemoteWriteSink)
+}
+
+// configureRemoteWrite проверяет раздел remote_write; пустой раздел означает запись
+// без авторизации с метками по умолчанию
+func configureRemoteWrite(config RemoteWriteConfig) error {
+	settings := &remoteWriteSettings{config: config, password: config.Password, timeout: defaultRemoteWriteTimeout}
+	if config.PasswordFile != "" {
+		data, err := os.ReadFile(config.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("Не удалось прочитать remote_write.password_file: %v", err)
+		}
+		settings.password = strings.TrimSpace(string(data))
+	}
+	if config.Timeout != "" {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("Неверное ограничение времени remote_write.timeout %q", config.Timeout)
+		}
+		settings.timeout = timeout
+	}
+	settings.labels = map[string]string{"instance": exportHostname(), "job": "memory-analyzer"}
+	for name, value := range exportIdentity.Labels {
+		settings.labels[name] = value
+	}
+	for name, value := range config.Labels {
+		if name == "__name__" || !validPromLabelName(name) {
+			return fmt.Errorf("Неверное имя метки remote_write.labels %q", name)
+		}
+		settings.labels[name] = value
+	}
+	activeRemoteWrite = settings
+	return nil
+}
+
+// validPromLabelName проверяет имя метки по правилам Prometheus: [a-zA-Z_][a-zA-Z0-9_]*
+func validPromLabelName(name string) bool {
+	for i, c := range name {
+		letter := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// promSeries — значение одного временного ряда в момент замера
+type promSeries struct {
+	//Метки, отсортированные по имени, включая __name__
+	labels [][2]string
+	value  float64
+	//Момент замера в миллисекундах
+	timestamp int64
+}
+
+// remoteWriteSink отправляет замеры на адрес Prometheus remote_write (Prometheus с
+// --web.enable-remote-write-receiver, Mimir, VictoriaMetrics и другие), когда сервер
+// не может сам опрашивать /metrics рабочей станции. Замеры отправляются в отдельной
+// горутине; пока сервер недоступен, они копятся и уходят одним запросом после восстановления
+type remoteWriteSink struct {
+	url      string
+	settings *remoteWriteSettings
+	client   *http.Client
+	queue    chan []promSeries
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// newRemoteWriteSink создает приемник по цели — адресу приема, например
+// http://prometheus:9090/api/v1/write
+func newRemoteWriteSink(target string) (Sink, error) {
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return nil, fmt.Errorf("Адрес remote_write должен начинаться с http:// или https://: %q", target)
+	}
+	settings := activeRemoteWrite
+	if settings == nil {
+		if err := configureRemoteWrite(RemoteWriteConfig{}); err != nil {
+			return nil, err
+		}
+		settings = activeRemoteWrite
+	}
+	s := &remoteWriteSink{
+		url:      target,
+		settings: settings,
+		client:   &http.Client{Timeout: settings.timeout},
+		queue:    make(chan []promSeries, notifyQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *remoteWriteSink) Name() string {
+	return "remote_write:" + s.url
+}
+
+func (s *remoteWriteSink) Write(sample Sample) error {
+	if sample.Time.IsZero() {
+		return nil
+	}
+	select {
+	case s.queue <- s.series(sample):
+	default:
+		return fmt.Errorf("Очередь отправки переполнена, замер пропущен")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.lastErr
+	s.lastErr = nil
+	return err
+}
+
+// Close отправляет накопленные замеры, ожидая не дольше одного запроса
+func (s *remoteWriteSink) Close() error {
+	close(s.queue)
+	select {
+	case <-s.done:
+	case <-time.After(s.settings.timeout):
+		close(s.stop)
+		<-s.done
+	}
+	return nil
+}
+
+// series переводит замер во временные ряды с метками из конфигурации
+func (s *remoteWriteSink) series(sample Sample) []promSeries {
+	timestamp := sample.Time.UnixMilli()
+	var series []promSeries
+	for _, metric := range prometheusMetrics(sample) {
+		for _, v := range metric.values {
+			labels := [][2]string{{"__name__", metric.name}}
+			for name, value := range s.settings.labels {
+				if _, ok := v.labels[name]; !ok {
+					labels = append(labels, [2]string{name, value})
+				}
+			}
+			for name, value := range v.labels {
+				labels = append(labels, [2]string{name, value})
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+			series = append(series, promSeries{labels: labels, value: v.value, timestamp: timestamp})
+		}
+	}
+	return series
+}
+
+// run отправляет замеры из очереди; пока сервер недоступен, замеры продолжают
+// приниматься из очереди и копятся до remoteWriteMaxPending
+func (s *remoteWriteSink) run() {
+	defer close(s.done)
+	queue := s.queue
+	var pending [][]promSeries
+	add := func(series []promSeries, ok bool) {
+		if !ok {
+			// Очередь закрыта: остается отправить накопленное
+			queue = nil
+			return
+		}
+		pending = append(pending, series)
+		if len(pending) > remoteWriteMaxPending {
+			s.setErr(fmt.Errorf("Сервер недоступен слишком долго, старые замеры отбрасываются"))
+			pending = pending[1:]
+		}
+	}
+	var backoff time.Duration
+	for {
+		if len(pending) == 0 {
+			if queue == nil {
+				return
+			}
+			series, ok := <-queue
+			add(series, ok)
+		}
+		// Замеры, накопившиеся за время предыдущего запроса, уходят вместе
+	drain:
+		for queue != nil {
+			select {
+			case series, ok := <-queue:
+				add(series, ok)
+			default:
+				break drain
+			}
+		}
+
+		retry, err := s.send(pending)
+		s.setErr(err)
+		if err == nil || !retry {
+			pending, backoff = nil, 0
+			continue
+		}
+		backoff = min(max(2*backoff, time.Second), remoteWriteMaxBackoff)
+		timer := time.NewTimer(backoff)
+	wait:
+		for {
+			select {
+			case <-timer.C:
+				break wait
+			case series, ok := <-queue:
+				add(series, ok)
+			case <-s.stop:
+				timer.Stop()
+				return
+			}
+		}
+	}
+}
+
+func (s *remoteWriteSink) setErr(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+// send отправляет замеры одним запросом и сообщает, имеет ли смысл повторить его:
+// ошибки сети, 429 и 5xx повторяются, остальные ответы 4xx означают, что сервер
+// отверг данные, и они отбрасываются
+func (s *remoteWriteSink) send(pending [][]promSeries) (retry bool, err error) {
+	body := snappyEncode(encodeWriteRequest(pending))
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "memory-analyzer/"+readBuildInfo().Version)
+	for name, value := range s.settings.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.settings.config.Username != "" {
+		req.SetBasicAuth(s.settings.config.Username, s.settings.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, firstLine(strings.TrimSpace(string(message))))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5, err
+}
+
+// encodeWriteRequest кодирует замеры в сообщение prometheus.WriteRequest:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+//
+// Значения одного ряда из нескольких замеров собираются в один TimeSeries в порядке времени
+func encodeWriteRequest(pending [][]promSeries) []byte {
+	type timeSeries struct {
+		labels  [][2]string
+		samples []promSeries
+	}
+	var order []string
+	byKey := make(map[string]*timeSeries)
+	for _, batch := range pending {
+		for _, series := range batch {
+			var key strings.Builder
+			for _, label := range series.labels {
+				key.WriteString(label[0] + "\xff" + label[1] + "\xff")
+			}
+			ts, ok := byKey[key.String()]
+			if !ok {
+				ts = &timeSeries{labels: series.labels}
+				byKey[key.String()] = ts
+				order = append(order, key.String())
+			}
+			ts.samples = append(ts.samples, series)
+		}
+	}
+
+	var request, message, field []byte
+	for _, key := range order {
+		ts := byKey[key]
+		message = message[:0]
+		for _, label := range ts.labels {
+			field = appendProtoString(field[:0], 1, label[0])
+			field = appendProtoString(field, 2, label[1])
+			message = appendProtoBytes(message, 1, field)
+		}
+		for _, sample := range ts.samples {
+			field = appendProtoTag(field[:0], 1, 1)
+			field = binary.LittleEndian.AppendUint64(field, math.Float64bits(sample.value))
+			field = appendProtoTag(field, 2, 0)
+			field = binary.AppendUvarint(field, uint64(sample.timestamp))
+			message = appendProtoBytes(message, 2, field)
+		}
+		request = appendProtoBytes(request, 1, message)
+	}
+	return request
+}
+
+// appendProtoTag записывает номер поля и тип кодирования protobuf
+func appendProtoTag(dst []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(dst, uint64(field<<3|wireType))
+}
+
+// appendProtoBytes записывает поле с длиной (вложенное сообщение или байты)
+func appendProtoBytes(dst []byte, field int, data []byte) []byte {
+	dst = appendProtoTag(dst, field, 2)
+	dst = binary.AppendUvarint(dst, uint64(len(data)))
+	return append(dst, data...)
+}
+
+func appendProtoString(dst []byte, field int, s string) []byte {
+	return appendProtoBytes(dst, field, []byte(s))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ProcessSummary — статистика потребления памяти процессами с одним именем за период записи
+type ProcessSummary struct {
+	Name string `json:"name"`
+	//Наибольшее и среднее суммарное потребление памяти процессами с этим именем
+	Peak    uint64 `json:"peak_bytes"`
+	Average uint64 `json:"average_bytes"`
+	//Число замеров, в которых процесс присутствовал
+	Samples int `json:"samples"`
+}
+
+// RecordingSummary — сводка по записанным замерам
+type RecordingSummary struct {
+	Start, End time.Time
+	Samples    int
+	//Наибольшее и среднее использование памяти системой
+	PeakUsed     uint64
+	PeakUsedTime time.Time
+	AverageUsed  uint64
+	Total        uint64
+	PeakSwap     uint64
+	Processes    []ProcessSummary
+}
+
+// recordingAccumulator накапливает статистику по замерам по мере чтения записи
+type recordingAccumulator struct {
+	summary   RecordingSummary
+	usedSum   float64
+	processes map[string]*processAccumulator
+}
+
+type processAccumulator struct {
+	peak    uint64
+	sum     float64
+	samples int
+}
+
+func newRecordingAccumulator() *recordingAccumulator {
+	return &recordingAccumulator{processes: make(map[string]*processAccumulator)}
+}
+
+func (a *recordingAccumulator) add(sample Sample) {
+	s := &a.summary
+	if s.Samples == 0 {
+		s.Start = sample.Time
+	}
+	s.End = sample.Time
+	s.Samples++
+
+	used := sample.System.TotalMemory - sample.System.AvailableMemory
+	if used > s.PeakUsed {
+		s.PeakUsed, s.PeakUsedTime, s.Total = used, sample.Time, sample.System.TotalMemory
+	}
+	a.usedSum += float64(used)
+	if swap := sample.System.SwapTotal - sample.System.SwapFree; swap > s.PeakSwap {
+		s.PeakSwap = swap
+	}
+
+	totals := make(map[string]uint64)
+	for _, process := range sample.Processes {
+		totals[process.Name] += process.MemoryUsage
+	}
+	for name, total := range totals {
+		acc, ok := a.processes[name]
+		if !ok {
+			acc = &processAccumulator{}
+			a.processes[name] = acc
+		}
+		if total > acc.peak {
+			acc.peak = total
+		}
+		acc.sum += float64(total)
+		acc.samples++
+	}
+}
+
+// result возвращает сводку; процессы сортируются по убыванию пикового потребления
+func (a *recordingAccumulator) result() RecordingSummary {
+	s := a.summary
+	if s.Samples > 0 {
+		s.AverageUsed = uint64(a.usedSum / float64(s.Samples))
+	}
+	s.Processes = nil
+	for name, acc := range a.processes {
+		s.Processes = append(s.Processes, ProcessSummary{
+			Name:    name,
+			Peak:    acc.peak,
+			Average: uint64(acc.sum / float64(acc.samples)),
+			Samples: acc.samples,
+		})
+	}
+	sort.Slice(s.Processes, func(i, j int) bool {
+		if s.Processes[i].Peak != s.Processes[j].Peak {
+			return s.Processes[i].Peak > s.Processes[j].Peak
+		}
+		return s.Processes[i].Name < s.Processes[j].Name
+	})
+	return s
+}
+
+// FormatRecordingSummary форматирует сводку по записи или сеансу работы, показывая top процессов;
+// title открывает первую строку сводки ("Recording", "Session")
+func FormatRecordingSummary(title string, s RecordingSummary, top int) string {
+	var res strings.Builder
+	if s.Samples == 0 {
+		return title + " contains no samples\n"
+	}
+	res.WriteString(fmt.Sprintf("%s: %s - %s (%s, %d samples)\n", title,
+		s.Start.Format("2006-01-02 15:04:05"), s.End.Format("2006-01-02 15:04:05"),
+		s.End.Sub(s.Start).Round(time.Second), s.Samples))
+	peakPercent := 0.0
+	if s.Total > 0 {
+		peakPercent = float64(s.PeakUsed) / float64(s.Total) * 100
+	}
+	res.WriteString(fmt.Sprintf("Peak used:    %s (%.1f%%) at %s\n",
+		FormatMemorySize(s.PeakUsed), peakPercent, s.PeakUsedTime.Format("2006-01-02 15:04:05")))
+	res.WriteString(fmt.Sprintf("Average used: %s\n", FormatMemorySize(s.AverageUsed)))
+	res.WriteString(fmt.Sprintf("Peak swap:    %s\n", FormatMemorySize(s.PeakSwap)))
+	res.WriteString("\nTop processes by peak memory:\n")
+	header := "NAME                  PEAK     AVERAGE  SAMPLES"
+	res.WriteString(header + "\n")
+	res.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for i, process := range s.Processes {
+		if top > 0 && i >= top {
+			break
+		}
+		res.WriteString(fmt.Sprintf("%s %10s  %10s  %7d\n",
+			fitLeft(getShortProcessName(process.Name), 15), FormatMemorySize(process.Peak), FormatMemorySize(process.Average), process.Samples))
+	}
+	return res.String()
+}
+
+// runReportCommand — подкоманда "report": сводка по файлам записи демона
+func runReportCommand(args []string) error {
+	flags := newCommandFlags("report")
+	top := flags.Int("top", 10, "number of processes to list")
+	heatmap := flags.Bool("heatmap", false, "also render a heatmap of process memory over time")
+	width := flags.Int("width", defaultHeatmapWidth, "heatmap `width` in columns")
+	exited := flags.Bool("exited", false, "also list lifetime summaries of processes that exited during the recording")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("Использование: memory-analyzer report [--top N] [--heatmap] [--exited] file...")
+	}
+
+	acc := newRecordingAccumulator()
+	var points []HistoryPoint
+	var lifetimes []ProcessLifetime
+	err := readRecordings(flags.Args(), func(sample Sample) error {
+		acc.add(sample)
+		if *heatmap {
+			points = append(points, newHistoryPoint(sample))
+		}
+		lifetimes = append(lifetimes, sample.Exited...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Print(FormatRecordingSummary("Recording", acc.result(), *top))
+	if *exited {
+		fmt.Println()
+		if len(lifetimes) == 0 {
+			fmt.Println("No processes exited during the recording")
+		}
+		fmt.Print(FormatLifetimes("Exited Processes", lifetimes))
+	}
+	if *heatmap {
+		fmt.Println()
+		fmt.Print(FormatHeatmap(BuildHeatmap(points, defaultHeatmapRows, *width), isTerminal(os.Stdout)))
+	}
+	return nil
+}
+
+// sessionSummaryTop — число процессов в сводке, выводимой при завершении программы
+const sessionSummaryTop = 5
+
+// printSessionSummary выводит сводку по сеансу работы, если за него был сделан хотя бы один замер
+func printSessionSummary(session *recordingAccumulator) {
+	if session.summary.Samples == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Print(FormatRecordingSummary("Session", session.result(), sessionSummaryTop))
+}
+
+// maxReplayDelay ограничивает паузу между кадрами воспроизведения, чтобы промежутки
+// между окнами записи не растягивали воспроизведение на часы
+const maxReplayDelay = 2 * time.Second
+
+// runReplayCommand — подкоманда "replay": воспроизводит запись на информационной панели
+func runReplayCommand(args []string) error {
+	flags := newCommandFlags("replay")
+	speed := flags.Float64("speed", 10, "playback speed relative to real time")
+	heatmap := flags.Bool("heatmap", false, "show a heatmap of the replayed period under the dashboard")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("Использование: memory-analyzer replay [--speed N] file...")
+	}
+	if *speed <= 0 {
+		return fmt.Errorf("Скорость воспроизведения должна быть положительной: %v", *speed)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	errStop := fmt.Errorf("stopped")
+
+	config := DisplayConfig{TopProcesses: 10}
+	state := NewViewState()
+	history := NewHistory(defaultHistorySize)
+	var previous Sample
+	err := readRecordings(flags.Args(), func(sample Sample) error {
+		if !previous.Time.IsZero() {
+			delay := time.Duration(float64(sample.Time.Sub(previous.Time)) / *speed)
+			if delay > maxReplayDelay {
+				delay = maxReplayDelay
+			}
+			select {
+			case <-sigChan:
+				return errStop
+			case <-time.After(delay):
+			}
+		}
+		history.Add(sample)
+		sample.Forecast = forecastExhaustion(history, sample.Time)
+		state.Exited = lastLifetimes(append(state.Exited, sample.Exited...), exitedHistorySize)
+		DisplayDashboard(sample, config, state)
+		if *heatmap {
+			fmt.Print(FormatHeatmap(BuildHeatmap(history.Since(time.Time{}), defaultHeatmapRows, defaultHeatmapWidth), isTerminal(os.Stdout)))
+		}
+		previous = sample
+		return nil
+	})
+	if err == errStop {
+		return nil
+	}
+	return err
+}
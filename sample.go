@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sample — результат одного цикла сбора данных
+type Sample struct {
+	Time time.Time `json:"time"`
+	//Имя хоста и метки из раздела identity конфигурации и флагов --hostname и --label
+	Host       string            `json:"host,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	System     SystemMemoryInfo  `json:"system"`
+	Processes  []ProcessInfo     `json:"processes"`
+	Collectors []CollectorResult `json:"collectors,omitempty"`
+
+	//Счетчики событий памяти cgroup, в которых есть процессы
+	CgroupEvents []CgroupEvents `json:"cgroup_events,omitempty"`
+
+	//Параметры памяти ядра, если источник данных умеет их читать
+	VM *VMTunables `json:"vm,omitempty"`
+
+	//Контейнер, в котором запущен анализатор; System тогда описывает память контейнера
+	Container *ContainerInfo `json:"container,omitempty"`
+
+	//Обмен со swap и признак thrashing, если источник данных умеет читать счетчики swap
+	Swap *SwapActivity `json:"swap,omitempty"`
+
+	//Длительность этапов сбора замера
+	Timings *StageTimings `json:"timings,omitempty"`
+
+	//Части сбора данных, в которых с запуска перехватывались паники (деградированный режим)
+	Degraded []string `json:"degraded,omitempty"`
+
+	//Значения, которые читаются из запасных источников или недоступны, потому что
+	//внешней программы нет или она завершилась с ошибкой
+	DegradedFields []DegradedField `json:"degraded_fields,omitempty"`
+
+	//Прогноз исчерпания памяти и сработавшие оповещения, вычисленные по истории замеров
+	Forecast *MemoryForecast `json:"forecast,omitempty"`
+	Alerts   []Alert         `json:"alerts,omitempty"`
+
+	//Запуски и завершения процессов с предыдущего замера, если включен монитор churn
+	Churn *ChurnStats `json:"churn,omitempty"`
+
+	//Сводки процессов, завершившихся с предыдущего замера
+	Exited []ProcessLifetime `json:"exited,omitempty"`
+
+	//Действия пользователя, изменившие систему с предыдущего замера (журнал аудита)
+	Audit []AuditEvent `json:"audit,omitempty"`
+}
+
+// collectSample собирает системную статистику, список процессов и данные коллекторов
+func collectSample(reader MemoryReader, report *ErrorReport) (Sample, error) {
+	sample := Sample{Time: time.Now()}
+	stampIdentity(&sample)
+	err := collectStages(reader, &sample, report, true, true)
+	return sample, err
+}
+
+// collectSystem заполняет в замере системную статистику: память, параметры ядра и обмен со swap
+func collectSystem(reader MemoryReader, sample *Sample) (err error) {
+	defer recoverPanic("system", &err)
+	info, err := reader.ReadSystemMemory()
+	if err != nil {
+		return fmt.Errorf("Не удалось прочитать системную память: %w", err)
+	}
+	sample.System = info
+	if sample.Container = collectContainer(reader); sample.Container != nil {
+		applyContainerLimits(&sample.System, sample.Container)
+	}
+	sample.VM = collectVMTunables(reader)
+	sample.Swap = collectSwapActivity(reader)
+	return nil
+}
+
+// collectProcessTable заполняет в замере список процессов и события памяти их cgroup
+func collectProcessTable(reader MemoryReader, sample *Sample, report *ErrorReport, timings *StageTimings) (err error) {
+	defer recoverPanic("process table", &err)
+	stage := time.Now()
+	var processes []ProcessInfo
+	if batch, ok := reader.(batchReader); ok {
+		processes, err = batch.CollectProcesses()
+		timings.ProcessList = time.Since(stage).Seconds()
+	} else {
+		var pids []int
+		pids, err = reader.GetProcessList()
+		timings.ProcessList = time.Since(stage).Seconds()
+		if err == nil {
+			stage = time.Now()
+			processes, timings.DeepReads = readProcesses(reader, pids, report)
+			timings.ProcessDetails = time.Since(stage).Seconds()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Не удалось получить список процессов: %w", err)
+	}
+	processLabeler.apply(processes)
+	sample.Processes = processes
+	sample.CgroupEvents = collectCgroupEvents(reader, processes)
+	return nil
+}
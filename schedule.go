@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RecordingWindow — ежедневное окно записи с собственным периодом замеров,
+// например "каждый день с 02:00 до 03:00 раз в 10 секунд"
+type RecordingWindow struct {
+	//Дни недели ("mon", "tue", ...); пустой список означает каждый день
+	Days []string `json:"days"`
+	//Начало и конец окна по местному времени в формате "15:04"; окно может переходить через полночь
+	Start string `json:"start"`
+	End   string `json:"end"`
+	//Период замеров внутри окна ("10s")
+	Interval string `json:"interval"`
+}
+
+// RecordingSchedule — разобранное расписание записи
+type RecordingSchedule struct {
+	windows []scheduleWindow
+}
+
+type scheduleWindow struct {
+	days       map[time.Weekday]bool
+	start, end time.Duration
+	interval   time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// NewRecordingSchedule проверяет окна записи из конфигурации
+func NewRecordingSchedule(windows []RecordingWindow) (*RecordingSchedule, error) {
+	schedule := &RecordingSchedule{}
+	for _, window := range windows {
+		parsed := scheduleWindow{}
+		if len(window.Days) > 0 {
+			parsed.days = make(map[time.Weekday]bool)
+			for _, day := range window.Days {
+				weekday, ok := weekdays[strings.ToLower(day)]
+				if !ok {
+					return nil, fmt.Errorf("Неизвестный день недели: %q", day)
+				}
+				parsed.days[weekday] = true
+			}
+		}
+		var err error
+		if parsed.start, err = parseClock(window.Start); err != nil {
+			return nil, err
+		}
+		if parsed.end, err = parseClock(window.End); err != nil {
+			return nil, err
+		}
+		if parsed.interval, err = time.ParseDuration(window.Interval); err != nil || parsed.interval <= 0 {
+			return nil, fmt.Errorf("Неверный период записи: %q", window.Interval)
+		}
+		schedule.windows = append(schedule.windows, parsed)
+	}
+	return schedule, nil
+}
+
+// parseClock переводит время суток "15:04" в смещение от полуночи
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("Неверное время %q, ожидается формат ЧЧ:ММ", clock)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Empty сообщает, что окна записи не заданы и запись ведется постоянно
+func (s *RecordingSchedule) Empty() bool {
+	return s == nil || len(s.windows) == 0
+}
+
+// Interval возвращает период замеров в момент now и false, если now не попадает ни в одно окно;
+// если окна пересекаются, используется наименьший период
+func (s *RecordingSchedule) Interval(now time.Time) (time.Duration, bool) {
+	var interval time.Duration
+	for _, window := range s.windows {
+		if window.contains(now) && (interval == 0 || window.interval < interval) {
+			interval = window.interval
+		}
+	}
+	return interval, interval > 0
+}
+
+// NextStart возвращает ближайшее начало окна записи после now
+func (s *RecordingSchedule) NextStart(now time.Time) time.Time {
+	var next time.Time
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, window := range s.windows {
+		for day := 0; day <= 7; day++ {
+			date := midnight.AddDate(0, 0, day)
+			start := date.Add(window.start)
+			if !start.After(now) || !window.onDay(date.Weekday()) {
+				continue
+			}
+			if next.IsZero() || start.Before(next) {
+				next = start
+			}
+			break
+		}
+	}
+	return next
+}
+
+func (w scheduleWindow) onDay(day time.Weekday) bool {
+	return w.days == nil || w.days[day]
+}
+
+// contains сообщает, попадает ли момент t в окно; окно, переходящее через полночь,
+// относится ко дню своего начала
+func (w scheduleWindow) contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if w.start <= w.end {
+		return w.onDay(t.Weekday()) && offset >= w.start && offset < w.end
+	}
+	if offset >= w.start {
+		return w.onDay(t.Weekday())
+	}
+	return offset < w.end && w.onDay(midnight.AddDate(0, 0, -1).Weekday())
+}
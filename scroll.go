@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minPaneRows — минимальная высота прокручиваемой области таблицы; если на экране не помещается
+// даже столько строк, таблица выводится целиком и прокручивается самим терминалом
+const minPaneRows = 3
+
+// terminalRows возвращает число строк терминала или 0, если его не удалось определить
+func terminalRows() int {
+	output, err := stty("size")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return 0
+	}
+	rows, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0
+	}
+	return rows
+}
+
+// PageSelection перемещает выделение на страницу таблицы из n строк вверх (pages < 0) или вниз
+func (s *ViewState) PageSelection(pages, n int) {
+	page := s.PaneRows
+	if page <= 0 {
+		page = 1
+	}
+	s.MoveSelection(pages*page, n)
+}
+
+// scrollPane оставляет в таблице FormatTable заголовок и не более height строк процессов так,
+// чтобы выделенная строка была видна, и добавляет под ними строку с положением в списке.
+// Смещение прокрутки сохраняется в state между отрисовками
+func scrollPane(table string, rows, height int, state *ViewState) string {
+	if rows <= height {
+		state.ScrollOffset = 0
+		return table
+	}
+	if state.Selected < state.ScrollOffset {
+		state.ScrollOffset = state.Selected
+	}
+	if state.Selected >= state.ScrollOffset+height {
+		state.ScrollOffset = state.Selected - height + 1
+	}
+	if state.ScrollOffset > rows-height {
+		state.ScrollOffset = rows - height
+	}
+	if state.ScrollOffset < 0 {
+		state.ScrollOffset = 0
+	}
+	lines := strings.Split(strings.TrimSuffix(table, "\n"), "\n")
+	var res strings.Builder
+	for _, line := range lines[:tableHeaderLines] {
+		res.WriteString(line + "\n")
+	}
+	for _, line := range lines[tableHeaderLines+state.ScrollOffset : tableHeaderLines+state.ScrollOffset+height] {
+		res.WriteString(line + "\n")
+	}
+	res.WriteString(fmt.Sprintf("Rows %d-%d of %d (PgUp/PgDn, Home/End to scroll)\n",
+		state.ScrollOffset+1, state.ScrollOffset+height, rows))
+	return res.String()
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyResize: SIGWINCH есть только в Unix, поэтому размер терминала читается один раз при запуске
+func notifyResize(c chan<- os.Signal) {}
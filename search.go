@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Выделение совпадения с поиском в имени процесса: жирный подчеркнутый текст.
+// Завершающая последовательность снимает только эти атрибуты, сохраняя цвет строки
+const (
+	matchStart = "\033[1;4m"
+	matchEnd   = "\033[22;24m"
+)
+
+// tableNameOffset и tableNameWidth — положение колонки NAME в строке FormatTable:
+// PID (8 символов), признак закрепления и имя
+const (
+	tableNameOffset = 9
+	tableNameWidth  = 15
+)
+
+// matchesSearch сообщает, содержит ли имя процесса строку поиска без учета регистра
+func matchesSearch(process ProcessInfo, search string) bool {
+	return search == "" || strings.Contains(strings.ToLower(process.Name), strings.ToLower(search))
+}
+
+// StartSearch включает ввод строки поиска клавишей /; поиск начинается заново
+func (s *ViewState) StartSearch() {
+	s.Searching = true
+	s.Search = ""
+	s.Selected = 0
+}
+
+// ClearSearch завершает ввод и снимает фильтр поиска
+func (s *ViewState) ClearSearch() {
+	s.Searching = false
+	s.Search = ""
+}
+
+// HandleSearchKey обрабатывает клавишу во время ввода строки поиска: печатные символы
+// дополняют строку, Backspace удаляет последний символ, Enter завершает ввод, оставляя
+// фильтр, Esc снимает фильтр
+func (s *ViewState) HandleSearchKey(key string) {
+	switch key {
+	case "enter":
+		s.Searching = false
+	case "esc":
+		s.ClearSearch()
+	case "backspace":
+		if s.Search != "" {
+			_, size := utf8.DecodeLastRuneInString(s.Search)
+			s.Search = s.Search[:len(s.Search)-size]
+		}
+	default:
+		if utf8.RuneCountInString(key) == 1 && key >= " " {
+			s.Search += key
+			s.Selected = 0
+		}
+	}
+}
+
+// FormatSearch возвращает строку ввода или действующего фильтра поиска для панели
+func FormatSearch(state *ViewState) string {
+	switch {
+	case state.Searching:
+		return "Search: /" + state.Search + "_ (Enter to keep, Esc to clear)\n"
+	case state.Search != "":
+		return "Filter: /" + state.Search + " (/ to change, Esc to clear)\n"
+	}
+	return ""
+}
+
+// highlightMatches выделяет совпадение со строкой поиска в колонке NAME строк таблицы
+func highlightMatches(table string, processes []ProcessInfo, search string) string {
+	if search == "" {
+		return table
+	}
+	lines := strings.Split(table, "\n")
+	for i := range processes {
+		idx := tableHeaderLines + i
+		if idx >= len(lines) || len(lines[idx]) < tableNameOffset {
+			continue
+		}
+		line := lines[idx]
+		cell := truncateWidth(line[tableNameOffset:], tableNameWidth)
+		lower := strings.ToLower(cell)
+		// Смещения в строке в нижнем регистре совпадают с исходными только при равной длине
+		pos := strings.Index(lower, strings.ToLower(search))
+		if pos < 0 || len(lower) != len(cell) {
+			continue
+		}
+		start, end := tableNameOffset+pos, tableNameOffset+pos+len(search)
+		lines[idx] = line[:start] + matchStart + line[start:end] + matchEnd + line[end:]
+	}
+	return strings.Join(lines, "\n")
+}
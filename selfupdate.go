@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Релизы публикуются на GitHub: для каждой платформы бинарный файл releaseAssetName
+// и общий файл контрольных сумм releaseChecksums в формате sha256sum (make release)
+const (
+	defaultReleaseRepo = "gulmix/Memory-analizer"
+	defaultReleaseAPI  = "https://api.github.com"
+	releaseChecksums   = "SHA256SUMS"
+)
+
+// releaseAssetName возвращает имя бинарного файла релиза для текущей платформы
+func releaseAssetName() string {
+	return fmt.Sprintf("memory-analyzer-%s-%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// githubRelease — нужная часть ответа GitHub API о релизе
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL возвращает адрес файла релиза по имени
+func (r githubRelease) assetURL(name string) (string, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL, true
+		}
+	}
+	return "", false
+}
+
+// updateClient — HTTP-клиент обновления; токен GITHUB_TOKEN, если задан, снимает низкий
+// лимит запросов к API без авторизации
+type updateClient struct {
+	http  *http.Client
+	token string
+}
+
+func (c updateClient) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" && strings.HasPrefix(url, defaultReleaseAPI) {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// latestRelease запрашивает последний опубликованный релиз репозитория
+func (c updateClient) latestRelease(api, repo string) (githubRelease, error) {
+	var release githubRelease
+	resp, err := c.get(fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(api, "/"), repo))
+	if err != nil {
+		return release, fmt.Errorf("Не удалось получить последний релиз: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return release, fmt.Errorf("Неверный ответ GitHub API: %v", err)
+	}
+	return release, nil
+}
+
+// expectedChecksum находит контрольную сумму файла name в файле контрольных сумм релиза
+func (c updateClient) expectedChecksum(url, name string) (string, error) {
+	resp, err := c.get(url)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось загрузить %s: %v", releaseChecksums, err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		// <sha256>  <имя> или <sha256> *<имя> (двоичный режим sha256sum)
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("В %s нет контрольной суммы %s", releaseChecksums, name)
+}
+
+// replaceExecutable загружает новый бинарный файл во временный файл рядом с текущим, проверяет
+// контрольную сумму и атомарно заменяет им текущий файл переименованием
+func (c updateClient) replaceExecutable(url, checksum string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(executable)
+	if err != nil {
+		return "", err
+	}
+	// Временный файл в том же каталоге, чтобы переименование не пересекало файловые системы
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".memory-analyzer-update-*")
+	if err != nil {
+		return "", fmt.Errorf("Нет прав на запись в %s: %v", filepath.Dir(executable), err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	resp, err := c.get(url)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось загрузить новую версию: %v", err)
+	}
+	defer resp.Body.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		return "", fmt.Errorf("Не удалось загрузить новую версию: %v", err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != checksum {
+		return "", fmt.Errorf("Контрольная сумма не совпадает: ожидалась %s, получена %s", checksum, sum)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), executable); err != nil {
+		return "", fmt.Errorf("Не удалось заменить %s: %v", executable, err)
+	}
+	return executable, nil
+}
+
+// runSelfUpdateCommand — подкоманда "self-update": проверяет последний релиз на GitHub
+// и заменяет им бинарный файл после проверки контрольной суммы
+func runSelfUpdateCommand(args []string) error {
+	flags := newCommandFlags("self-update")
+	check := flags.Bool("check", false, "only report whether a newer release is available")
+	force := flags.Bool("force", false, "install the latest release even if it is not newer than this build")
+	repo := flags.String("repo", defaultReleaseRepo, "GitHub `owner/name` to take releases from")
+	api := flags.String("api", defaultReleaseAPI, "GitHub API base `url` (for GitHub Enterprise or a mirror)")
+	flags.Parse(args)
+
+	client := updateClient{http: &http.Client{Timeout: 5 * time.Minute}, token: os.Getenv("GITHUB_TOKEN")}
+	current := readBuildInfo().Version
+	release, err := client.latestRelease(*api, *repo)
+	if err != nil {
+		return err
+	}
+	newer := current == "dev" || compareVersions(release.TagName, current) > 0
+	if !newer && !*force {
+		fmt.Printf("memory-analyzer %s is up to date (latest release %s)\n", current, release.TagName)
+		return nil
+	}
+	if *check {
+		fmt.Printf("memory-analyzer %s can be updated to %s: %s\n", current, release.TagName, release.HTMLURL)
+		return nil
+	}
+
+	asset := releaseAssetName()
+	assetURL, ok := release.assetURL(asset)
+	if !ok {
+		return fmt.Errorf("В релизе %s нет файла %s для этой платформы", release.TagName, asset)
+	}
+	checksumsURL, ok := release.assetURL(releaseChecksums)
+	if !ok {
+		return fmt.Errorf("В релизе %s нет файла %s: обновление без проверки контрольной суммы не выполняется", release.TagName, releaseChecksums)
+	}
+	checksum, err := client.expectedChecksum(checksumsURL, asset)
+	if err != nil {
+		return err
+	}
+	path, err := client.replaceExecutable(assetURL, checksum)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s from %s to %s\n", path, current, release.TagName)
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIServer — HTTP API демона: последний замер, сводки завершившихся процессов и управление конфигурацией
+//
+// Обработчики не изменяют состояние демона напрямую, а передают запросы
+// в его основной цикл через каналы
+type APIServer struct {
+	mu     sync.Mutex
+	sample Sample
+	//Ошибка последнего сбора данных; сбрасывается следующим удачным замером
+	sampleErr error
+	exited    []ProcessLifetime
+	//История замеров для источников данных Grafana
+	history HistorySnapshot
+	mux     *http.ServeMux
+
+	//Запросы на перечитывание конфигурации; основной цикл отвечает ошибкой или nil
+	reload chan chan error
+	//Действия над оповещениями; основной цикл выполняет их и отвечает состоянием оповещений
+	alerts chan alertRequest
+}
+
+// alertRequest — действие над оповещениями из API; пустое действие только запрашивает состояние
+type alertRequest struct {
+	command AlertCommand
+	reply   chan alertReply
+}
+
+// alertReply — ответ основного цикла на alertRequest
+type alertReply struct {
+	Rules    []string             `json:"rules,omitempty"`
+	Alerts   []Alert              `json:"alerts"`
+	Silences map[string]time.Time `json:"silences,omitempty"`
+	err      error
+}
+
+func NewAPIServer() *APIServer {
+	s := &APIServer{mux: http.NewServeMux(), reload: make(chan chan error), alerts: make(chan alertRequest)}
+	s.mux.HandleFunc("/api/sample", s.handleSample)
+	s.mux.HandleFunc("/api/reload", s.handleReload)
+	s.mux.HandleFunc("/api/exited", s.handleExited)
+	s.mux.HandleFunc("/api/alerts", s.handleAlerts)
+	s.mux.HandleFunc("/api/alerts/", s.handleAlerts)
+	s.mux.HandleFunc("/api/capabilities", s.handleCapabilities)
+	s.registerGrafana()
+	return s
+}
+
+// Listen начинает обслуживать запросы на адресе addr в отдельной горутине
+func (s *APIServer) Listen(addr string) error {
+	_, err := serveHTTP(addr, s.mux)
+	return err
+}
+
+// SetSample сохраняет последний замер для выдачи через API
+func (s *APIServer) SetSample(sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sample, s.sampleErr = sample, nil
+}
+
+// SetSampleError сохраняет ошибку сбора данных, которую /api/sample вернет вместо
+// устаревшего замера
+func (s *APIServer) SetSampleError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampleErr = err
+}
+
+// SetExited сохраняет сводки последних завершившихся процессов для выдачи через API
+func (s *APIServer) SetExited(exited []ProcessLifetime) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exited = exited
+}
+
+func (s *APIServer) handleExited(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	exited := s.exited
+	s.mu.Unlock()
+	if exited == nil {
+		exited = []ProcessLifetime{}
+	}
+	writeJSON(w, http.StatusOK, exited)
+}
+
+func (s *APIServer) handleSample(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sample, err := s.sample, s.sampleErr
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sample)
+}
+
+func (s *APIServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	reply := make(chan error)
+	select {
+	case s.reload <- reply:
+	case <-r.Context().Done():
+		return
+	}
+	if err := <-reply; err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+// handleAlerts: GET /api/alerts возвращает сработавшие оповещения и заглушения,
+// POST /api/alerts/{ack,silence,unsilence}?rule=имя&for=срок выполняет действие
+// (без rule — над всеми сработавшими оповещениями)
+func (s *APIServer) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	var command AlertCommand
+	if action := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/alerts"), "/"); action != "" {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+			return
+		}
+		command = AlertCommand{Action: action, Rule: r.URL.Query().Get("rule"), Duration: defaultSilenceDuration}
+		if value := r.URL.Query().Get("for"); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			command.Duration = duration
+		}
+	}
+	request := alertRequest{command: command, reply: make(chan alertReply)}
+	select {
+	case s.alerts <- request:
+	case <-r.Context().Done():
+		return
+	}
+	reply := <-request.reply
+	if reply.err != nil {
+		writeError(w, http.StatusBadRequest, reply.err)
+		return
+	}
+	if reply.Alerts == nil {
+		reply.Alerts = []Alert{}
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ServerSecurityConfig — защита HTTP-серверов программы (API демона, приемник prometheus):
+// замеры содержат имена и командные строки процессов, поэтому их нельзя отдавать по сети
+// без шифрования и проверки клиента
+type ServerSecurityConfig struct {
+	//Сертификат и ключ сервера в формате PEM; если они заданы, серверы работают по HTTPS
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+	//Сертификаты центров, которыми должны быть подписаны сертификаты клиентов (mTLS)
+	ClientCA string `json:"client_ca"`
+
+	//Токены, один из которых клиент передает в заголовке "Authorization: Bearer <токен>";
+	//TokenFile — файл с токенами по одному в строке, чтобы не хранить их в конфигурации
+	Tokens    []string `json:"tokens"`
+	TokenFile string   `json:"token_file"`
+
+	//Адреса и подсети клиентов, которым разрешен доступ, например "10.0.0.0/8" или "127.0.0.1"
+	Allow []string `json:"allow"`
+
+	//Разрешить сервер без TLS и без проверки клиентов на адресе, доступном не только с этой машины
+	Insecure bool `json:"insecure"`
+}
+
+// serverSecurity — проверенные настройки защиты, общие для всех HTTP-серверов
+type serverSecurity struct {
+	tls      *tls.Config
+	tokens   [][]byte
+	allow    []*net.IPNet
+	insecure bool
+}
+
+// activeServerSecurity применяется ко всем HTTP-серверам; по умолчанию защита выключена
+var activeServerSecurity = &serverSecurity{}
+
+// configureServerSecurity загружает сертификаты и токены и включает защиту HTTP-серверов;
+// вызывается до их запуска
+func configureServerSecurity(config ServerSecurityConfig) error {
+	security := &serverSecurity{insecure: config.Insecure}
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return fmt.Errorf("tls_cert и tls_key задаются вместе")
+	}
+	if config.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+		if err != nil {
+			return fmt.Errorf("Не удалось загрузить сертификат сервера: %v", err)
+		}
+		security.tls = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	if config.ClientCA != "" {
+		if security.tls == nil {
+			return fmt.Errorf("client_ca требует tls_cert и tls_key")
+		}
+		data, err := os.ReadFile(config.ClientCA)
+		if err != nil {
+			return fmt.Errorf("Не удалось прочитать client_ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("В %s нет сертификатов в формате PEM", config.ClientCA)
+		}
+		security.tls.ClientCAs = pool
+		security.tls.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	tokens := config.Tokens
+	if config.TokenFile != "" {
+		data, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return fmt.Errorf("Не удалось прочитать token_file: %v", err)
+		}
+		tokens = append(tokens, strings.Split(string(data), "\n")...)
+	}
+	for _, token := range tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			security.tokens = append(security.tokens, []byte(token))
+		}
+	}
+	if len(tokens) > 0 && len(security.tokens) == 0 {
+		return fmt.Errorf("Список токенов пуст")
+	}
+
+	for _, entry := range config.Allow {
+		network, err := parseAllowEntry(entry)
+		if err != nil {
+			return err
+		}
+		security.allow = append(security.allow, network)
+	}
+	activeServerSecurity = security
+	return nil
+}
+
+// parseAllowEntry разбирает подсеть "10.0.0.0/8" или отдельный адрес "192.168.1.5"
+func parseAllowEntry(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("Неверный адрес в allow: %q", entry)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(entry)
+	if err != nil {
+		return nil, fmt.Errorf("Неверная подсеть в allow: %q", entry)
+	}
+	return network, nil
+}
+
+// authenticated сообщает, проверяет ли сервер клиентов сертификатом или токеном
+func (s *serverSecurity) authenticated() bool {
+	return len(s.tokens) > 0 || (s.tls != nil && s.tls.ClientCAs != nil)
+}
+
+// listen открывает адрес addr с учетом TLS. Сервер без TLS и без проверки клиентов
+// разрешен только на loopback-адресе, если не задан insecure
+func (s *serverSecurity) listen(addr string) (net.Listener, error) {
+	if !s.insecure && (s.tls == nil || !s.authenticated()) && !loopbackAddress(addr) {
+		return nil, fmt.Errorf("Адрес %s доступен по сети: задайте в разделе server конфигурации tls_cert и tls_key вместе с client_ca или tokens, либо insecure: true", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть адрес %s: %v", addr, err)
+	}
+	if s.tls != nil {
+		listener = tls.NewListener(listener, s.tls)
+	}
+	return listener, nil
+}
+
+// loopbackAddress сообщает, что addr ("127.0.0.1:9100", "localhost:9100") доступен только
+// с этой машины; пустой хост (":9100") означает все интерфейсы
+func loopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handler пропускает к next только запросы из разрешенных подсетей с верным токеном
+func (s *serverSecurity) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowed(r.RemoteAddr) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "address not allowed"})
+			return
+		}
+		if len(s.tokens) > 0 && !s.validToken(r.Header.Get("Authorization")) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="memory-analyzer"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowed проверяет адрес клиента по списку allow; пустой список разрешает всех
+func (s *serverSecurity) allowed(remoteAddr string) bool {
+	if len(s.allow) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validToken сравнивает токен из заголовка Authorization с разрешенными за постоянное время
+func (s *serverSecurity) validToken(header string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	valid := false
+	for _, allowed := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), allowed) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// serveHTTP открывает адрес addr и обслуживает на нем запросы в отдельной горутине
+// с учетом настроек защиты
+func serveHTTP(addr string, handler http.Handler) (net.Listener, error) {
+	security := activeServerSecurity
+	listener, err := security.listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(listener, security.handler(handler))
+	return listener, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultSilenceDuration — срок заглушения оповещений клавишей S и запросом API без срока
+const defaultSilenceDuration = time.Hour
+
+// Действия над оповещениями
+const (
+	//Подтвердить: оповещение остается на панели, но не рассылается повторно, пока не снимется
+	AlertAck = "ack"
+	//Заглушить на срок, в том числе правило, которое еще не сработало (например, на время работ)
+	AlertSilence = "silence"
+	//Снять заглушение
+	AlertUnsilence = "unsilence"
+)
+
+// AlertCommand — действие пользователя над оповещениями из панели или API демона
+type AlertCommand struct {
+	//AlertAck, AlertSilence или AlertUnsilence
+	Action string `json:"action"`
+	//Имя правила; пустое — все сработавшие оповещения (заглушение правила, которое
+	//не срабатывает, снимается только по имени)
+	Rule string `json:"rule,omitempty"`
+	//Срок заглушения для AlertSilence
+	Duration time.Duration `json:"-"`
+}
+
+// Muted сообщает, что оповещение подтверждено или заглушено и не должно рассылаться повторно
+func (a Alert) Muted() bool {
+	return a.Acknowledged || a.SilencedUntil != nil
+}
+
+// Apply выполняет действие над оповещениями и возвращает имена затронутых правил
+func (e *AlertEngine) Apply(command AlertCommand, now time.Time) ([]string, error) {
+	if e == nil {
+		return nil, fmt.Errorf("Правила оповещений не заданы")
+	}
+	var rules []string
+	if command.Rule != "" {
+		if !e.hasRule(command.Rule) {
+			return nil, fmt.Errorf("Нет правила оповещения %q", command.Rule)
+		}
+		rules = []string{command.Rule}
+	} else {
+		for _, alert := range e.Active() {
+			rules = append(rules, alert.Rule)
+		}
+	}
+
+	switch command.Action {
+	case AlertAck:
+		var acknowledged []string
+		for _, rule := range rules {
+			if alert, ok := e.active[rule]; ok && !alert.Acknowledged {
+				alert.Acknowledged = true
+				acknowledged = append(acknowledged, rule)
+			}
+		}
+		return acknowledged, nil
+	case AlertSilence:
+		if command.Duration <= 0 {
+			return nil, fmt.Errorf("Срок заглушения должен быть больше нуля")
+		}
+		until := now.Add(command.Duration)
+		for _, rule := range rules {
+			e.silences[rule] = until
+			if alert, ok := e.active[rule]; ok {
+				alert.SilencedUntil = &until
+			}
+		}
+		return rules, nil
+	case AlertUnsilence:
+		var unsilenced []string
+		for _, rule := range rules {
+			if _, ok := e.silences[rule]; ok {
+				delete(e.silences, rule)
+				unsilenced = append(unsilenced, rule)
+			}
+			if alert, ok := e.active[rule]; ok {
+				alert.SilencedUntil = nil
+			}
+		}
+		return unsilenced, nil
+	}
+	return nil, fmt.Errorf("Неизвестное действие над оповещениями %q: поддерживаются ack, silence и unsilence", command.Action)
+}
+
+// Silences возвращает действующие заглушения: имя правила и момент окончания
+func (e *AlertEngine) Silences() map[string]time.Time {
+	if e == nil || len(e.silences) == 0 {
+		return nil
+	}
+	silences := make(map[string]time.Time, len(e.silences))
+	for rule, until := range e.silences {
+		silences[rule] = until
+	}
+	return silences
+}
+
+// applySilence отмечает оповещение заглушенным, если для его правила действует заглушение,
+// и удаляет истекшее заглушение
+func (e *AlertEngine) applySilence(alert *Alert, now time.Time) {
+	alert.SilencedUntil = nil
+	until, ok := e.silences[alert.Rule]
+	if !ok {
+		return
+	}
+	if !now.Before(until) {
+		delete(e.silences, alert.Rule)
+		return
+	}
+	alert.SilencedUntil = &until
+}
+
+func (e *AlertEngine) hasRule(name string) bool {
+	for _, rule := range e.rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// alertCommandStatus описывает результат действия над оповещениями для строки состояния
+func alertCommandStatus(command AlertCommand, rules []string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if len(rules) == 0 {
+		switch command.Action {
+		case AlertAck:
+			return "No unacknowledged alerts"
+		case AlertUnsilence:
+			return "No silenced alerts"
+		}
+		return "No firing alerts"
+	}
+	switch command.Action {
+	case AlertAck:
+		return fmt.Sprintf("Acknowledged %d alerts", len(rules))
+	case AlertSilence:
+		return fmt.Sprintf("Silenced %d alerts for %s", len(rules), command.Duration)
+	}
+	return fmt.Sprintf("Unsilenced %d alerts", len(rules))
+}
+
+// toggleSilenceCommand возвращает действие для клавиши S: заглушить сработавшие оповещения,
+// а если все они уже заглушены — снять заглушение
+func toggleSilenceCommand(alerts []Alert, duration time.Duration) AlertCommand {
+	silenced := len(alerts) > 0
+	for _, alert := range alerts {
+		silenced = silenced && alert.SilencedUntil != nil
+	}
+	if silenced {
+		return AlertCommand{Action: AlertUnsilence}
+	}
+	return AlertCommand{Action: AlertSilence, Duration: duration}
+}
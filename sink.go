@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Sink получает каждый собранный замер, например для записи в файл или публикации метрик
+//
+// Информационная панель остается основным выводом, а приемники добавляются к ней
+// флагом --sink и могут использоваться одновременно
+type Sink interface {
+	//Name возвращает описание приемника для сообщений об ошибках
+	Name() string
+	//Write передает приемнику очередной замер
+	Write(sample Sample) error
+}
+
+// sinkTypes сопоставляет типам приемников конструкторы, принимающие цель из спецификации
+var sinkTypes = map[string]func(target string) (Sink, error){}
+
+// RegisterSinkType добавляет тип приемника, доступный во флаге --sink
+func RegisterSinkType(name string, constructor func(target string) (Sink, error)) {
+	sinkTypes[name] = constructor
+}
+
+func init() {
+	RegisterSinkType("jsonl", newJSONLSink)
+}
+
+// configureSinks проверяет разделы конфигурации, которые используют приемники
+// (smtp, exec, remote_write); вызывается до создания приемников
+func configureSinks(fileConfig FileConfig) error {
+	if err := configureSMTP(fileConfig.SMTP); err != nil {
+		return err
+	}
+	if err := configureExecNotifiers(fileConfig.Exec); err != nil {
+		return err
+	}
+	return configureRemoteWrite(fileConfig.RemoteWrite)
+}
+
+// sinkTypeNames возвращает отсортированные имена зарегистрированных типов приемников
+func sinkTypeNames() []string {
+	names := make([]string, 0, len(sinkTypes))
+	for name := range sinkTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newSink создает приемник по спецификации вида "тип:цель", например "jsonl:/var/log/samples.jsonl.gz"
+func newSink(spec string) (Sink, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("Неверный приемник %q: ожидается тип:цель", spec)
+	}
+	constructor, ok := sinkTypes[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("Неизвестный тип приемника %q, доступны: %s", parts[0], strings.Join(sinkTypeNames(), ", "))
+	}
+	return constructor(parts[1])
+}
+
+// SinkSet рассылает замеры нескольким приемникам
+type SinkSet []Sink
+
+// NewSinkSet создает приемники по списку спецификаций
+func NewSinkSet(specs []string) (SinkSet, error) {
+	var set SinkSet
+	for _, spec := range specs {
+		sink, err := newSink(spec)
+		if err != nil {
+			set.Close()
+			return nil, err
+		}
+		set = append(set, sink)
+	}
+	return set, nil
+}
+
+// Write передает замер всем приемникам; ошибка одного приемника не мешает остальным
+func (s SinkSet) Write(sample Sample, report *ErrorReport) {
+	for _, sink := range s {
+		if err := sink.Write(sample); err != nil {
+			report.Add("sink write failures", fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+}
+
+// Close закрывает приемники, которые этого требуют, сохраняя их буферы
+func (s SinkSet) Close() {
+	for _, sink := range s {
+		if closer, ok := sink.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// jsonlSink записывает замеры в файл JSON Lines с тем же сжатием, что и демон
+type jsonlSink struct {
+	*SampleRecorder
+}
+
+func newJSONLSink(path string) (Sink, error) {
+	recorder, err := NewSampleRecorder(path, RotationPolicy{})
+	if err != nil {
+		return nil, err
+	}
+	return jsonlSink{recorder}, nil
+}
+
+func (s jsonlSink) Name() string {
+	return "jsonl:" + s.path
+}
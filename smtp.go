@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Режимы TLS для SMTP
+const (
+	//Соединение без шифрования, затем обязательная команда STARTTLS (порт 587)
+	SMTPStartTLS = "starttls"
+	//TLS с самого начала соединения (порт 465)
+	SMTPImplicitTLS = "tls"
+	//Без шифрования, например для локального ретранслятора на 127.0.0.1:25
+	SMTPNoTLS = "none"
+)
+
+// smtpTimeout ограничивает время отправки одного письма
+const smtpTimeout = 30 * time.Second
+
+const (
+	defaultSMTPSubject = `[memory-analyzer] {{if .Fired}}FIRING{{else}}RESOLVED{{end}}: {{.Rules}} on {{.Host}}`
+	defaultSMTPBody    = `Host: {{.Host}}
+Time: {{.Time.Format "2006-01-02 15:04:05 MST"}}
+{{range .Fired}}
+FIRING   {{.Rule}}: {{.Message}} (since {{.Since.Format "15:04:05"}})
+{{- end}}
+{{- range .Resolved}}
+RESOLVED {{.}}
+{{- end}}
+
+Memory: {{.Memory}}
+
+Top processes:
+{{.ProcessTable}}`
+)
+
+// SMTPConfig — настройки отправки уведомлений по электронной почте (приемник smtp)
+type SMTPConfig struct {
+	//Адрес сервера и порт (по умолчанию 587, для tls — 465)
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	//Режим TLS: starttls (по умолчанию), tls или none
+	TLS string `json:"tls"`
+	//Учетная запись; пароль лучше хранить в файле password_file с правами 0600
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"password_file"`
+	//Отправитель и получатели по умолчанию (цель приемника smtp:адреса их заменяет)
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	//Шаблоны text/template темы и текста письма; данные — Notification
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	//Число процессов в выдержке из таблицы (по умолчанию 10)
+	Top int `json:"top"`
+}
+
+// smtpSettings — проверенные настройки SMTP
+type smtpSettings struct {
+	config   SMTPConfig
+	password string
+	subject  *template.Template
+	body     *template.Template
+}
+
+// activeSMTP — настройки из конфигурации для приемников smtp или nil, если они не заданы
+var activeSMTP *smtpSettings
+
+func init() {
+	RegisterSinkType("smtp", newSMTPSink)
+}
+
+// configureSMTP проверяет настройки SMTP из конфигурации и делает их доступными приемникам smtp
+func configureSMTP(config SMTPConfig) error {
+	activeSMTP = nil
+	if config.Host == "" {
+		return nil
+	}
+	settings := &smtpSettings{config: config, password: config.Password}
+	switch settings.config.TLS {
+	case "":
+		settings.config.TLS = SMTPStartTLS
+	case SMTPStartTLS, SMTPImplicitTLS, SMTPNoTLS:
+	default:
+		return fmt.Errorf("Неверный режим smtp.tls %q: поддерживаются starttls, tls и none", config.TLS)
+	}
+	if settings.config.Port == 0 {
+		settings.config.Port = 587
+		if settings.config.TLS == SMTPImplicitTLS {
+			settings.config.Port = 465
+		}
+	}
+	if config.PasswordFile != "" {
+		data, err := os.ReadFile(config.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("Не удалось прочитать smtp.password_file: %v", err)
+		}
+		settings.password = strings.TrimSpace(string(data))
+	}
+	if config.From == "" {
+		return fmt.Errorf("Не задан отправитель smtp.from")
+	}
+	if settings.config.Top == 0 {
+		settings.config.Top = defaultNotifyTop
+	}
+	subject, body := config.Subject, config.Body
+	if subject == "" {
+		subject = defaultSMTPSubject
+	}
+	if body == "" {
+		body = defaultSMTPBody
+	}
+	var err error
+	if settings.subject, err = template.New("subject").Parse(subject); err != nil {
+		return fmt.Errorf("Неверный шаблон smtp.subject: %v", err)
+	}
+	if settings.body, err = template.New("body").Parse(body); err != nil {
+		return fmt.Errorf("Неверный шаблон smtp.body: %v", err)
+	}
+	activeSMTP = settings
+	return nil
+}
+
+// smtpSink отправляет письмо, когда оповещения срабатывают или перестают выполняться.
+// Письма отправляются из очереди в отдельной горутине
+type smtpSink struct {
+	settings *smtpSettings
+	to       []string
+	tracker  *alertTracker
+	queue    *notifyQueue
+}
+
+// newSMTPSink создает приемник по цели — списку получателей через запятую; "-" означает
+// получателей smtp.to из конфигурации
+func newSMTPSink(target string) (Sink, error) {
+	settings := activeSMTP
+	if settings == nil {
+		return nil, fmt.Errorf("Приемник smtp требует раздела \"smtp\" в конфигурации")
+	}
+	to := settings.config.To
+	if target != "-" {
+		to = strings.Split(target, ",")
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("Не заданы получатели: smtp:адрес[,адрес] или smtp.to в конфигурации")
+	}
+	s := &smtpSink{settings: settings, to: to, tracker: newAlertTracker()}
+	s.queue = newNotifyQueue("писем", s.send)
+	return s, nil
+}
+
+func (s *smtpSink) Name() string {
+	return "smtp:" + strings.Join(s.to, ",")
+}
+
+func (s *smtpSink) Write(sample Sample) error {
+	fired, resolved := s.tracker.Observe(sample.Alerts)
+	if len(fired) > 0 || len(resolved) > 0 {
+		return s.queue.Push(newNotification(sample, fired, resolved, s.settings.config.Top))
+	}
+	return s.queue.Err()
+}
+
+// Close дожидается отправки писем из очереди
+func (s *smtpSink) Close() error {
+	s.queue.Close()
+	return nil
+}
+
+// send формирует письмо по шаблонам и отправляет его
+func (s *smtpSink) send(notification Notification) error {
+	var subject, body bytes.Buffer
+	if err := s.settings.subject.Execute(&subject, notification); err != nil {
+		return fmt.Errorf("Ошибка шаблона smtp.subject: %v", err)
+	}
+	if err := s.settings.body.Execute(&body, notification); err != nil {
+		return fmt.Errorf("Ошибка шаблона smtp.body: %v", err)
+	}
+	config := s.settings.config
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&message, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+	return s.deliver(message.Bytes())
+}
+
+// deliver подключается к серверу в выбранном режиме TLS и передает письмо
+func (s *smtpSink) deliver(message []byte) error {
+	config := s.settings.config
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	tlsConfig := &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if config.TLS == SMTPImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("Не удалось подключиться к %s: %v", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("%s: %v", addr, err)
+	}
+	defer client.Close()
+	if config.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s не поддерживает STARTTLS; для сервера без шифрования задайте smtp.tls \"none\"", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS с %s: %v", addr, err)
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, s.settings.password, config.Host)); err != nil {
+			return fmt.Errorf("Ошибка авторизации на %s: %v", addr, err)
+		}
+	}
+	if err := client.Mail(config.From); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := client.Rcpt(strings.TrimSpace(to)); err != nil {
+			return fmt.Errorf("Получатель %s отклонен: %v", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,71 @@
+package main
+
+import "encoding/binary"
+
+// snappyEncode сжимает данные в блочном формате snappy, которого требует протокол
+// Prometheus remote_write. Совпадения ищутся по хэшу четырех байт, как в эталонной
+// реализации, но без ее ускорений: тела запросов невелики, и скорость сжатия не важна
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src)))
+	if len(src) < 4 {
+		return appendSnappyLiteral(dst, src)
+	}
+	const tableBits = 14
+	// Позиции последних вхождений четырехбайтовых последовательностей, увеличенные на 1
+	var table [1 << tableBits]int32
+	load := func(i int) uint32 { return binary.LittleEndian.Uint32(src[i:]) }
+	hash := func(v uint32) uint32 { return v * 0x1e35a7bd >> (32 - tableBits) }
+
+	literal := 0
+	for i := 0; i+4 <= len(src); {
+		h := hash(load(i))
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		// Копии с двухбайтовым смещением дотягиваются на 64 КБ назад
+		if candidate < 0 || i-candidate > 0xffff || load(candidate) != load(i) {
+			i++
+			continue
+		}
+		length := 4
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = appendSnappyLiteral(dst, src[literal:i])
+		dst = appendSnappyCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return appendSnappyLiteral(dst, src[literal:])
+}
+
+// appendSnappyLiteral записывает байты без сжатия
+func appendSnappyLiteral(dst, literal []byte) []byte {
+	if len(literal) == 0 {
+		return dst
+	}
+	n := uint32(len(literal) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, literal...)
+}
+
+// appendSnappyCopy записывает ссылку на length байт, встретившихся offset байт назад;
+// одна ссылка копирует не больше 64 байт
+func appendSnappyCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := min(length, 64)
+		dst = append(dst, byte(n-1)<<2|2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
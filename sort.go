@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Колонки, по которым можно сортировать таблицу процессов
+const (
+	SortByPID    = "pid"
+	SortByName   = "name"
+	SortByMemory = "memory"
+	SortByIO     = "io"
+	SortByState  = "state"
+)
+
+// defaultSort — сортировка таблицы по умолчанию: по убыванию потребления памяти
+const defaultSort = "-memory"
+
+// sortColumns сравнивают процессы по возрастанию значения колонки
+var sortColumns = map[string]func(a, b ProcessInfo) int{
+	SortByPID:    func(a, b ProcessInfo) int { return compareInts(a.PID, b.PID) },
+	SortByName:   func(a, b ProcessInfo) int { return strings.Compare(a.Name, b.Name) },
+	SortByMemory: func(a, b ProcessInfo) int { return compareUint64(a.MemoryUsage, b.MemoryUsage) },
+	SortByIO:     func(a, b ProcessInfo) int { return compareFloats(a.ioRate(), b.ioRate()) },
+	SortByState:  func(a, b ProcessInfo) int { return strings.Compare(a.State, b.State) },
+}
+
+// sortPresets — сортировки, между которыми переключает клавиша s, после сортировки из конфигурации
+var sortPresets = []string{"-memory", "-io,-memory", "name,-memory"}
+
+// SortField — одна колонка составного ключа сортировки
+type SortField struct {
+	Column     string
+	Descending bool
+}
+
+// SortSpec — составной ключ сортировки, например "name,-memory": по имени,
+// а процессы с одинаковым именем — по убыванию памяти
+//
+// Равные по всем колонкам процессы упорядочиваются по PID, поэтому порядок строк
+// не меняется между обновлениями без изменения значений
+type SortSpec []SortField
+
+// ParseSortSpec разбирает ключ сортировки из колонок через запятую; "-" перед колонкой
+// означает сортировку по убыванию
+func ParseSortSpec(spec string) (SortSpec, error) {
+	var fields SortSpec
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field := SortField{Column: strings.TrimPrefix(part, "-"), Descending: strings.HasPrefix(part, "-")}
+		if _, ok := sortColumns[field.Column]; !ok {
+			return nil, fmt.Errorf("Неизвестная колонка сортировки %q, доступны: pid, name, memory, io, state", field.Column)
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Пустой ключ сортировки")
+	}
+	return fields, nil
+}
+
+func (s SortSpec) String() string {
+	parts := make([]string, len(s))
+	for i, field := range s {
+		parts[i] = field.Column
+		if field.Descending {
+			parts[i] = "-" + field.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Sort упорядочивает процессы по ключу сортировки
+func (s SortSpec) Sort(processes []ProcessInfo) {
+	sort.SliceStable(processes, func(i, j int) bool {
+		for _, field := range s {
+			c := sortColumns[field.Column](processes[i], processes[j])
+			if field.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return processes[i].PID < processes[j].PID
+	})
+}
+
+// dampenOrder удерживает процессы на позициях previous, пока новая позиция отличается
+// от прежней не больше чем на hysteresis строк, чтобы процессы с близкими значениями
+// не менялись местами при каждом обновлении
+func dampenOrder(view []ProcessInfo, previous map[int]int, hysteresis int) {
+	if hysteresis <= 0 || len(previous) == 0 {
+		return
+	}
+	ranks := make(map[int]int, len(view))
+	for i, process := range view {
+		ranks[process.PID] = i
+		if old, ok := previous[process.PID]; ok && old-i <= hysteresis && i-old <= hysteresis {
+			ranks[process.PID] = old
+		}
+	}
+	sort.SliceStable(view, func(i, j int) bool {
+		return ranks[view[i].PID] < ranks[view[j].PID]
+	})
+}
+
+// UpdateRanks запоминает позиции процессов нового замера с учетом config.RowHysteresis;
+// вызывается один раз на каждый замер, а visibleProcesses использует запомненный порядок
+func (s *ViewState) UpdateRanks(processes []ProcessInfo, config DisplayConfig) {
+	if config.RowHysteresis <= 0 {
+		s.Ranks = nil
+		return
+	}
+	view := make([]ProcessInfo, len(processes))
+	copy(view, processes)
+	s.sortSpec(config).Sort(view)
+	dampenOrder(view, s.Ranks, config.RowHysteresis)
+	s.Ranks = make(map[int]int, len(view))
+	for i, process := range view {
+		s.Ranks[process.PID] = i
+	}
+}
+
+// CycleSort переключает сортировку на следующую из сортировки по умолчанию и sortPresets
+func (s *ViewState) CycleSort(config DisplayConfig) {
+	presets := []string{config.Sort.String()}
+	for _, preset := range sortPresets {
+		if preset != presets[0] {
+			presets = append(presets, preset)
+		}
+	}
+	current := s.sortSpec(config).String()
+	for i, preset := range presets {
+		if preset == current {
+			s.SortKey = presets[(i+1)%len(presets)]
+			s.Ranks = nil
+			return
+		}
+	}
+	s.SortKey = presets[0]
+	s.Ranks = nil
+}
+
+// sortSpec возвращает действующую сортировку таблицы
+func (s *ViewState) sortSpec(config DisplayConfig) SortSpec {
+	if s != nil && s.SortKey != "" {
+		if spec, err := ParseSortSpec(s.SortKey); err == nil {
+			return spec
+		}
+	}
+	if len(config.Sort) == 0 {
+		spec, _ := ParseSortSpec(defaultSort)
+		return spec
+	}
+	return config.Sort
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateSaveInterval — период сохранения состояния; при аварийном завершении теряется
+// не больше этого интервала накопленных данных
+const stateSaveInterval = time.Minute
+
+// RuntimeState — состояние, накопленное за время работы панели или демона и сохраняемое
+// в каталоге состояния, чтобы после перезапуска продолжить с того же места
+type RuntimeState struct {
+	Saved time.Time `json:"saved"`
+	//История замеров для прогнозов и правил оповещений
+	History []HistoryPoint `json:"history,omitempty"`
+	//Прореженные уровни истории со средними значениями
+	Tiers []HistoryTierPoints `json:"tiers,omitempty"`
+	//Сработавшие оповещения: после перезапуска сохраняются момент начала срабатывания
+	//и подтверждения, а также действующие заглушения правил
+	Alerts   []Alert              `json:"alerts,omitempty"`
+	Silences map[string]time.Time `json:"silences,omitempty"`
+	//Процессы, закрепленные из интерфейса, и имена из списка наблюдения
+	Pinned     []PinnedProcess `json:"pinned,omitempty"`
+	WatchNames []string        `json:"watch_names,omitempty"`
+	//Файл базовой линии, с которой сравнивалась панель
+	Baseline string `json:"baseline,omitempty"`
+}
+
+// PinnedProcess — закрепленный процесс; имя хранится, чтобы после перезапуска не закрепить
+// другой процесс, получивший тот же PID
+type PinnedProcess struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name"`
+}
+
+// LoadRuntimeState читает сохраненное состояние; отсутствие файла или пустой путь
+// не считаются ошибкой
+func LoadRuntimeState(path string) (RuntimeState, error) {
+	var state RuntimeState
+	if path == "" {
+		return state, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return state, fmt.Errorf("Поврежден файл состояния %s: %v", path, err)
+	}
+	if err := json.NewDecoder(gz).Decode(&state); err != nil {
+		return RuntimeState{}, fmt.Errorf("Поврежден файл состояния %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// SaveRuntimeState записывает состояние во временный файл и переименовывает его поверх
+// прежнего, поэтому при аварийном завершении на диске остается предыдущая целая копия
+func SaveRuntimeState(path string, state RuntimeState) error {
+	state.Saved = time.Now()
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	gz := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(gz).Encode(state); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// Переименование становится постоянным после синхронизации каталога
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// stateSaver сохраняет состояние не чаще stateSaveInterval; пустой путь отключает сохранение
+type stateSaver struct {
+	path  string
+	saved time.Time
+}
+
+// Due сообщает, пора ли сохранить состояние
+func (s *stateSaver) Due(now time.Time) bool {
+	return s.path != "" && now.Sub(s.saved) >= stateSaveInterval
+}
+
+// Save сохраняет состояние, если задан путь
+func (s *stateSaver) Save(state RuntimeState) error {
+	if s.path == "" {
+		return nil
+	}
+	s.saved = time.Now()
+	if err := SaveRuntimeState(s.path, state); err != nil {
+		return fmt.Errorf("Не удалось сохранить состояние в %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// pinnedProcesses возвращает процессы, закрепленные из интерфейса, с их именами из замера
+func (s *ViewState) pinnedProcesses(processes []ProcessInfo) []PinnedProcess {
+	var pinned []PinnedProcess
+	for _, process := range processes {
+		if s.PinnedPIDs[process.PID] {
+			pinned = append(pinned, PinnedProcess{PID: process.PID, Name: process.Name})
+		}
+	}
+	return pinned
+}
+
+// restorePins закрепляет процессы из сохраненного состояния, которые все еще работают
+// под тем же именем
+func (s *ViewState) restorePins(reader MemoryReader, pinned []PinnedProcess) {
+	for _, process := range pinned {
+		if name, err := reader.ReadProcessName(process.PID); err == nil && name == process.Name {
+			s.PinnedPIDs[process.PID] = true
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// AlertSwapThrash срабатывает, пока детектор считает, что система непрерывно перекачивает
+// страницы между памятью и swap
+const AlertSwapThrash = "swap_thrash"
+
+// Параметры детектора по умолчанию
+const (
+	defaultSwapThrashRate  = "1MB"
+	defaultSwapThrashTicks = 3
+)
+
+func init() {
+	alertTypes[AlertSwapThrash] = newSwapThrashCondition
+}
+
+// SwapActivity — обмен со swap: накопленные с загрузки счетчики и скорость между замерами
+type SwapActivity struct {
+	//Объем, прочитанный из swap и записанный в swap с загрузки системы
+	SwappedIn  uint64 `json:"swapped_in_bytes"`
+	SwappedOut uint64 `json:"swapped_out_bytes"`
+	//Скорость обмена со swap в байтах в секунду с предыдущего замера
+	InRate  float64 `json:"in_rate"`
+	OutRate float64 `json:"out_rate"`
+	//Система перекачивает страницы между памятью и swap (thrashing) с момента Since
+	Thrashing bool      `json:"thrashing,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+}
+
+// SwapActivityReader реализуют источники данных, умеющие читать счетчики обмена со swap
+type SwapActivityReader interface {
+	//ReadSwapActivity возвращает накопленные счетчики обмена со swap в байтах
+	ReadSwapActivity() (SwapActivity, error)
+}
+
+// ReadSwapActivity читает счетчики pswpin и pswpout из /proc/vmstat (в страницах)
+func (l *LinuxMemoryReader) ReadSwapActivity() (SwapActivity, error) {
+	file, err := os.Open(l.path("proc", "vmstat"))
+	if err != nil {
+		return SwapActivity{}, err
+	}
+	defer file.Close()
+	stats, err := parseKeyValues(file)
+	if err != nil {
+		return SwapActivity{}, err
+	}
+	in, hasIn := stats["pswpin"]
+	out, hasOut := stats["pswpout"]
+	if !hasIn || !hasOut {
+		return SwapActivity{}, fmt.Errorf("pswpin и pswpout не найдены в /proc/vmstat")
+	}
+	pageSize := uint64(os.Getpagesize())
+	return SwapActivity{SwappedIn: in * pageSize, SwappedOut: out * pageSize}, nil
+}
+
+// ReadSwapActivity читает счетчики Swapins и Swapouts (в страницах) из host_statistics64
+// или vm_stat
+func (d *DarwinMemoryReader) ReadSwapActivity() (SwapActivity, error) {
+	stats, pageSize, err := readVmStat()
+	if err != nil {
+		markDegraded(fieldSwapActivity, "vm_stat", err)
+		return SwapActivity{}, err
+	}
+	restoreField(fieldSwapActivity)
+	in, hasIn := stats["Swapins"]
+	out, hasOut := stats["Swapouts"]
+	if !hasIn || !hasOut {
+		return SwapActivity{}, fmt.Errorf("Swapins и Swapouts не найдены в выводе vm_stat")
+	}
+	return SwapActivity{SwappedIn: in * pageSize, SwappedOut: out * pageSize}, nil
+}
+
+// collectSwapActivity читает счетчики обмена со swap, если источник данных это поддерживает
+func collectSwapActivity(reader MemoryReader) *SwapActivity {
+	swapReader, ok := reader.(SwapActivityReader)
+	if !ok {
+		return nil
+	}
+	activity, err := swapReader.ReadSwapActivity()
+	if err != nil {
+		return nil
+	}
+	return &activity
+}
+
+// SwapThrashDetector вычисляет скорость обмена со swap и признак thrashing с гистерезисом:
+// состояние включается, когда суммарная скорость чтения и записи не ниже порога ticks замеров
+// подряд, и выключается, когда она ticks замеров подряд ниже половины порога. Так одиночные
+// всплески не поднимают тревогу, а колебания около порога не заставляют баннер мигать
+type SwapThrashDetector struct {
+	rate  float64
+	ticks int
+
+	prev      *SwapActivity
+	prevTime  time.Time
+	streak    int
+	thrashing bool
+	since     time.Time
+}
+
+// NewSwapThrashDetector проверяет порог скорости ("1MB" в секунду) и число замеров
+func NewSwapThrashDetector(rate string, ticks int) (*SwapThrashDetector, error) {
+	bytes, err := parseByteSize(rate)
+	if err != nil || bytes == 0 {
+		return nil, fmt.Errorf("Неверная скорость обмена со swap: %q", rate)
+	}
+	if ticks <= 0 {
+		return nil, fmt.Errorf("Число замеров должно быть положительным: %d", ticks)
+	}
+	return &SwapThrashDetector{rate: float64(bytes), ticks: ticks}, nil
+}
+
+// Observe заполняет скорость обмена и признак thrashing в счетчиках нового замера
+func (d *SwapThrashDetector) Observe(t time.Time, activity *SwapActivity) {
+	if d == nil || activity == nil {
+		return
+	}
+	prev, prevTime := d.prev, d.prevTime
+	current := *activity
+	d.prev, d.prevTime = &current, t
+	elapsed := t.Sub(prevTime).Seconds()
+	// После перезагрузки или смены источника счетчики могут уменьшиться
+	if prev == nil || elapsed <= 0 || activity.SwappedIn < prev.SwappedIn || activity.SwappedOut < prev.SwappedOut {
+		return
+	}
+	activity.InRate = float64(activity.SwappedIn-prev.SwappedIn) / elapsed
+	activity.OutRate = float64(activity.SwappedOut-prev.SwappedOut) / elapsed
+
+	rate := activity.InRate + activity.OutRate
+	if d.thrashing {
+		if rate < d.rate/2 {
+			d.streak++
+		} else {
+			d.streak = 0
+		}
+		if d.streak >= d.ticks {
+			d.thrashing, d.streak = false, 0
+		}
+	} else {
+		if rate >= d.rate {
+			d.streak++
+		} else {
+			d.streak = 0
+		}
+		if d.streak >= d.ticks {
+			d.thrashing, d.streak, d.since = true, 0, t
+		}
+	}
+	activity.Thrashing = d.thrashing
+	if d.thrashing {
+		activity.Since = d.since
+	}
+}
+
+// FormatSwapActivity форматирует скорость обмена со swap, если он идет
+func FormatSwapActivity(activity *SwapActivity) string {
+	if activity == nil || (activity.InRate == 0 && activity.OutRate == 0) {
+		return ""
+	}
+	return fmt.Sprintf("Swap I/O:  in %s, out %s\n", formatRate(activity.InRate), formatRate(activity.OutRate))
+}
+
+// FormatSwapThrashBanner возвращает заметный баннер, пока система перекачивает страницы со swap
+func FormatSwapThrashBanner(activity *SwapActivity, system SystemMemoryInfo) string {
+	if activity == nil || !activity.Thrashing {
+		return ""
+	}
+	swapUsed := system.SwapTotal - system.SwapFree
+	return paint(activeTheme.Critical, fmt.Sprintf("!!! SWAP THRASHING since %s: in %s, out %s, swap %.0f%% full — the system is busy moving pages to and from disk !!!",
+		activity.Since.Format("15:04:05"), formatRate(activity.InRate), formatRate(activity.OutRate), percentOf(swapUsed, system.SwapTotal))) + "\n\n"
+}
+
+// swapThrashCondition срабатывает, когда thrashing длится дольше порога; пустой порог — сразу
+type swapThrashCondition struct {
+	threshold time.Duration
+}
+
+func newSwapThrashCondition(threshold string) (alertCondition, error) {
+	if threshold == "" {
+		return &swapThrashCondition{}, nil
+	}
+	d, err := time.ParseDuration(threshold)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("Порог должен быть длительностью, например \"1m\", или пустым: %q", threshold)
+	}
+	return &swapThrashCondition{threshold: d}, nil
+}
+
+func (c *swapThrashCondition) check(sample Sample, history *History) (string, bool) {
+	swap := sample.Swap
+	if swap == nil || !swap.Thrashing || sample.Time.Sub(swap.Since) < c.threshold {
+		return "", false
+	}
+	return fmt.Sprintf("swap thrashing: in %s, out %s", formatRate(swap.InRate), formatRate(swap.OutRate)), true
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// isTerminal проверяет, подключен ли файл к терминалу
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// enableKeyboardInput переводит терминал в режим посимвольного чтения без эха
+//
+// Возвращает функцию восстановления исходных настроек терминала.
+// Сигналы (Ctrl+C) продолжают обрабатываться терминалом как обычно
+func enableKeyboardInput() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() {
+		stty(strings.TrimSpace(saved))
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return string(output), err
+}
+
+// readKeys читает нажатые клавиши из stdin и отправляет их в канал
+//
+// Обычные символы передаются как есть, управляющие последовательности
+// стрелок, Enter, Backspace и клавиш прокрутки преобразуются в имена "up", "down", "left", "right",
+// "enter", "backspace", "pgup", "pgdn", "home", "end",
+// а нажатия мыши — в имена вида "mouse:кнопка:x:y" (parseMouseKey)
+func readKeys(keys chan<- string) {
+	buf := make([]byte, 32)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, key := range decodeKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// tildeKeys — клавиши, которые терминал передает последовательностью "ESC [ цифра ~"
+var tildeKeys = map[byte]string{
+	'1': "home",
+	'4': "end",
+	'5': "pgup",
+	'6': "pgdn",
+	'7': "home",
+	'8': "end",
+}
+
+func decodeKeys(input []byte) []string {
+	var keys []string
+	for i := 0; i < len(input); i++ {
+		b := input[i]
+		if key, n, ok := decodeMouse(input[i:]); ok {
+			if key != "" {
+				keys = append(keys, key)
+			}
+			i += n - 1
+			continue
+		}
+		switch {
+		case b == 27 && i+3 < len(input) && input[i+1] == '[' && input[i+3] == '~':
+			// PgUp, PgDn, Home и End в виде "ESC [ цифра ~"
+			if key, ok := tildeKeys[input[i+2]]; ok {
+				keys = append(keys, key)
+			}
+			i += 3
+		case b == 27 && i+2 < len(input) && (input[i+1] == '[' || input[i+1] == 'O') && (input[i+2] == 'H' || input[i+2] == 'F'):
+			// Home и End в виде "ESC [ H" или "ESC O H" в зависимости от режима терминала
+			if input[i+2] == 'H' {
+				keys = append(keys, "home")
+			} else {
+				keys = append(keys, "end")
+			}
+			i += 2
+		case b == 27 && i+2 < len(input) && input[i+1] == '[':
+			switch input[i+2] {
+			case 'A':
+				keys = append(keys, "up")
+			case 'B':
+				keys = append(keys, "down")
+			case 'C':
+				keys = append(keys, "right")
+			case 'D':
+				keys = append(keys, "left")
+			}
+			i += 2
+		case b == 27:
+			keys = append(keys, "esc")
+		case b == '\r' || b == '\n':
+			keys = append(keys, "enter")
+		case b == 127 || b == 8:
+			keys = append(keys, "backspace")
+		default:
+			// Многобайтовые символы UTF-8 (кириллица в строке поиска) передаются целиком
+			r, size := utf8.DecodeRune(input[i:])
+			keys = append(keys, string(r))
+			i += size - 1
+		}
+	}
+	return keys
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultTheme — тема по умолчанию, рассчитанная на темный фон терминала
+const defaultTheme = "dark"
+
+// Theme — набор цветов информационной панели и отчетов
+type Theme struct {
+	//Критические значения: превышение бюджетов и лимитов, зомби, оповещения, ошибки хостов
+	Critical string
+	//Предупреждения: приближение к бюджету или лимиту, отклонение от базовой линии
+	Warning string
+	//Выделенная строка таблицы
+	Highlight string
+	//Уровни тепловой карты от низкого к высокому
+	Heat [4]string
+}
+
+// themes — встроенные темы, выбираемые полем "theme" конфигурации
+var themes = map[string]Theme{
+	"dark": {
+		Critical:  "\033[31m",
+		Warning:   "\033[33m",
+		Highlight: "\033[7m",
+		Heat:      [4]string{"\033[32m", "\033[33m", "\033[38;5;208m", "\033[31m"},
+	},
+	// Желтый и оранжевый заменены более темными оттенками, различимыми на светлом фоне
+	"light": {
+		Critical:  "\033[38;5;124m",
+		Warning:   "\033[38;5;130m",
+		Highlight: "\033[7m",
+		Heat:      [4]string{"\033[38;5;28m", "\033[38;5;136m", "\033[38;5;166m", "\033[38;5;124m"},
+	},
+	// Без цветов: важность передается начертанием, уровни тепловой карты — символами
+	"monochrome": {
+		Critical:  "\033[1m",
+		Warning:   "\033[4m",
+		Highlight: "\033[7m",
+	},
+	// Акцентные цвета палитры Solarized, читаемые и на темном, и на светлом ее фоне
+	"solarized": {
+		Critical:  "\033[38;5;160m",
+		Warning:   "\033[38;5;136m",
+		Highlight: "\033[7m",
+		Heat:      [4]string{"\033[38;5;64m", "\033[38;5;136m", "\033[38;5;166m", "\033[38;5;160m"},
+	},
+}
+
+// activeTheme — тема, которой раскрашиваются панель и отчеты
+var activeTheme = themes[defaultTheme]
+
+// themeNames возвращает имена встроенных тем по алфавиту
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyTheme делает активной тему с указанным именем; пустое имя выбирает тему по умолчанию
+func applyTheme(name string) error {
+	if name == "" {
+		name = defaultTheme
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("Неизвестная тема %q, доступны: %s", name, strings.Join(themeNames(), ", "))
+	}
+	activeTheme = theme
+	return nil
+}
+
+// paint окрашивает строку цветом color темы; пустой цвет оставляет строку без изменений
+func paint(color, s string) string {
+	if color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AlertTmpfs срабатывает, когда файлы в tmpfs или ramfs занимают больше порога памяти
+const AlertTmpfs = "tmpfs"
+
+// defaultTmpfsThreshold — порог, начиная с которого explain считает tmpfs раздувшейся
+const defaultTmpfsThreshold = "5%"
+
+// tmpfsTopEntries — сколько крупнейших файлов и каталогов раздувшейся tmpfs называется в explain
+const tmpfsTopEntries = 3
+
+func init() {
+	alertTypes[AlertTmpfs] = newTmpfsCondition
+}
+
+// TmpfsUsage — занятое место в смонтированной файловой системе в памяти (tmpfs или ramfs)
+type TmpfsUsage struct {
+	Mount  string
+	FSType string
+	Used   uint64
+	//Размер файловой системы или 0 для ramfs, размер которой не ограничен
+	Size uint64
+}
+
+// readTmpfsUsage возвращает занятое место во всех tmpfs и ramfs из /proc/mounts по убыванию
+func readTmpfsUsage() ([]TmpfsUsage, error) {
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var usage []TmpfsUsage
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// tmpfs /dev/shm tmpfs rw,nosuid,nodev 0 0
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || (fields[2] != "tmpfs" && fields[2] != "ramfs") || seen[fields[1]] {
+			continue
+		}
+		seen[fields[1]] = true
+		mount := TmpfsUsage{Mount: fields[1], FSType: fields[2]}
+		if mount.FSType == "ramfs" {
+			// ramfs не ведет учет места, и statfs возвращает нули
+			mount.Used = directorySize(mount.Mount)
+		} else {
+			used, size, err := filesystemUsage(mount.Mount)
+			if err != nil {
+				continue
+			}
+			mount.Used, mount.Size = used, size
+		}
+		if mount.Used > 0 {
+			usage = append(usage, mount)
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Used > usage[j].Used
+	})
+	return usage, scanner.Err()
+}
+
+// directorySize суммирует размеры файлов в каталоге, не выходя за пределы его файловой системы
+func directorySize(root string) uint64 {
+	var rootDev uint64
+	if info, err := os.Lstat(root); err == nil {
+		rootDev, _ = fileDevice(info)
+	}
+	var total uint64
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if dev, ok := fileDevice(info); ok && dev != rootDev {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			total += uint64(info.Size())
+		}
+		return nil
+	})
+	return total
+}
+
+// largestEntries возвращает крупнейшие файлы и каталоги верхнего уровня в каталоге
+func largestEntries(root string, n int) []TmpfsUsage {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var sizes []TmpfsUsage
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		if size := directorySize(path); size > 0 {
+			sizes = append(sizes, TmpfsUsage{Mount: path, Used: size})
+		}
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		return sizes[i].Used > sizes[j].Used
+	})
+	if len(sizes) > n {
+		sizes = sizes[:n]
+	}
+	return sizes
+}
+
+// sizeThreshold — порог в байтах или в процентах от всей памяти ("2GB" или "10%")
+type sizeThreshold struct {
+	bytes   uint64
+	percent float64
+}
+
+func parseSizeThreshold(s string) (sizeThreshold, error) {
+	if strings.HasSuffix(s, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || percent <= 0 {
+			return sizeThreshold{}, fmt.Errorf("Неверный порог в процентах: %q", s)
+		}
+		return sizeThreshold{percent: percent}, nil
+	}
+	bytes, err := parseByteSize(s)
+	if err != nil || bytes == 0 {
+		return sizeThreshold{}, fmt.Errorf("Порог должен быть размером (\"2GB\") или процентом памяти (\"10%%\"): %q", s)
+	}
+	return sizeThreshold{bytes: bytes}, nil
+}
+
+// exceeded сообщает, превышает ли value порог при общем объеме памяти total
+func (t sizeThreshold) exceeded(value, total uint64) bool {
+	if t.percent > 0 {
+		return percentOf(value, total) >= t.percent
+	}
+	return value >= t.bytes
+}
+
+// ballooningTmpfs возвращает файловые системы в памяти, занятое место в которых превышает порог
+func ballooningTmpfs(usage []TmpfsUsage, threshold sizeThreshold, total uint64) []TmpfsUsage {
+	var res []TmpfsUsage
+	for _, mount := range usage {
+		if threshold.exceeded(mount.Used, total) {
+			res = append(res, mount)
+		}
+	}
+	return res
+}
+
+// tmpfsCondition срабатывает, когда хотя бы одна tmpfs или ramfs заняла больше порога
+type tmpfsCondition struct {
+	threshold sizeThreshold
+}
+
+func newTmpfsCondition(threshold string) (alertCondition, error) {
+	t, err := parseSizeThreshold(threshold)
+	if err != nil {
+		return nil, err
+	}
+	return &tmpfsCondition{threshold: t}, nil
+}
+
+func (c *tmpfsCondition) check(sample Sample, history *History) (string, bool) {
+	usage, err := readTmpfsUsage()
+	if err != nil {
+		return "", false
+	}
+	mounts := ballooningTmpfs(usage, c.threshold, sample.System.TotalMemory)
+	if len(mounts) == 0 {
+		return "", false
+	}
+	parts := make([]string, 0, len(mounts))
+	for _, mount := range mounts {
+		parts = append(parts, fmt.Sprintf("%s %s", mount.Mount, FormatMemorySize(mount.Used)))
+	}
+	return "files in memory: " + strings.Join(parts, ", "), true
+}
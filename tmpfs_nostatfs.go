@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import "fmt"
+
+// filesystemUsage: statfs читается только в Linux, macOS и FreeBSD
+func filesystemUsage(path string) (uint64, uint64, error) {
+	return 0, 0, fmt.Errorf("%w: statfs доступен только в Linux, macOS и FreeBSD", ErrUnsupportedPlatform)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileDevice: номер устройства файла есть только в Unix
+func fileDevice(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
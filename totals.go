@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sumMemory возвращает суммарную память процессов
+func sumMemory(processes []ProcessInfo) uint64 {
+	var total uint64
+	for _, process := range processes {
+		total += process.MemoryUsage
+	}
+	return total
+}
+
+// FormatTotals возвращает итоговые строки под таблицей: сумму памяти показанных процессов,
+// сумму по всем процессам и ее расхождение с занятой памятью системы.
+// Сумма RSS процессов почти никогда не совпадает с "Used": общие страницы библиотек
+// и разделяемой памяти входят в RSS каждого процесса, а память ядра, таблицы страниц,
+// tmpfs и процессы, недоступные для чтения, не входят ни в чей RSS
+func FormatTotals(view, processes []ProcessInfo, stats SystemMemoryInfo) string {
+	var res strings.Builder
+	shown, all := sumMemory(view), sumMemory(processes)
+	res.WriteString(fmt.Sprintf("Total shown (%d processes): %s, all processes (%d): %s\n",
+		len(view), FormatMemorySize(shown), len(processes), FormatMemorySize(all)))
+	if stats.TotalMemory == 0 {
+		return res.String()
+	}
+	used := stats.TotalMemory - stats.AvailableMemory
+	gap := int64(used) - int64(all)
+	explanation := "kernel, page tables, shared memory and tmpfs, unreadable processes"
+	if gap < 0 {
+		explanation = "shared pages counted in the RSS of several processes"
+	}
+	res.WriteString(fmt.Sprintf("System used %s, gap %s (%s)\n", FormatMemorySize(used), formatSignedSize(gap), explanation))
+	return res.String()
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Форматы вывода подкоманды tray
+const (
+	//Плагин xbar/SwiftBar (строка меню macOS) или Argos (панель GNOME): один вывод за запуск
+	TrayXbar = "xbar"
+	//Значок в области уведомлений Linux через yad --notification
+	TrayYad = "yad"
+	//Строки JSON для пользовательского модуля waybar
+	TrayWaybar = "waybar"
+	//Строки текста для polybar, i3blocks и похожих панелей
+	TrayText = "text"
+)
+
+// trayTop — число процессов в меню и подсказке
+const trayTop = 5
+
+// trayStatus — состояние памяти для строки меню
+type trayStatus struct {
+	Used     uint64
+	Total    uint64
+	Percent  float64
+	SwapUsed uint64
+	//"ok", "warning" или "critical" по порогам --warning и --critical
+	Level     string
+	Processes []ProcessInfo
+}
+
+// trayOptions — настройки подкоманды tray
+type trayOptions struct {
+	warning  float64
+	critical float64
+	//Команда открытия информационной панели в терминале
+	dashboard []string
+	//Адрес веб-панели, например Grafana, открываемый из меню
+	url string
+}
+
+// runTrayCommand — подкоманда "tray": процент занятой памяти в строке меню macOS или области
+// уведомлений Linux; щелчок открывает полную информационную панель или веб-панель
+func runTrayCommand(args []string) error {
+	flags := newCommandFlags("tray")
+	format := flags.String("format", defaultTrayFormat(), "output format: xbar, yad, waybar or text")
+	interval := flags.Duration("interval", 5*time.Second, "update interval for the yad, waybar and text formats")
+	warning := flags.Float64("warning", 80, "used memory percent shown as a warning")
+	critical := flags.Float64("critical", 90, "used memory percent shown as critical")
+	terminal := flags.String("terminal", "x-terminal-emulator -e", "terminal command that opens the dashboard from the yad menu")
+	url := flags.String("url", "", "web dashboard URL to open from the menu")
+	flags.Parse(args)
+	if *interval <= 0 {
+		return fmt.Errorf("Интервал обновления должен быть больше нуля")
+	}
+
+	reader, err := newLocalReader()
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	options := trayOptions{warning: *warning, critical: *critical, url: *url,
+		dashboard: append(strings.Fields(*terminal), exe)}
+
+	switch *format {
+	case TrayXbar:
+		status, err := readTrayStatus(reader, options)
+		if err != nil {
+			return err
+		}
+		writeXbar(os.Stdout, status, exe, options)
+		return nil
+	case TrayWaybar, TrayText:
+		return trayLoop(reader, options, *interval, func(status trayStatus) error {
+			if *format == TrayWaybar {
+				return writeWaybar(os.Stdout, status)
+			}
+			_, err := fmt.Fprintf(os.Stdout, "MEM %.0f%%\n", status.Percent)
+			return err
+		})
+	case TrayYad:
+		return runYadTray(reader, options, *interval)
+	}
+	return fmt.Errorf("Неизвестный формат %q: поддерживаются xbar, yad, waybar и text", *format)
+}
+
+// defaultTrayFormat — xbar на macOS, yad на Linux, если он установлен, иначе text
+func defaultTrayFormat() string {
+	if runtime.GOOS == "darwin" {
+		return TrayXbar
+	}
+	if _, err := exec.LookPath("yad"); err == nil {
+		return TrayYad
+	}
+	return TrayText
+}
+
+// readTrayStatus собирает состояние памяти и процессы с наибольшим потреблением
+func readTrayStatus(reader MemoryReader, options trayOptions) (trayStatus, error) {
+	system, err := reader.ReadSystemMemory()
+	if err != nil {
+		return trayStatus{}, err
+	}
+	status := trayStatus{Used: system.TotalMemory - system.AvailableMemory, Total: system.TotalMemory, SwapUsed: system.SwapTotal - system.SwapFree}
+	status.Percent = percentOf(status.Used, status.Total)
+	switch {
+	case status.Percent >= options.critical:
+		status.Level = "critical"
+	case status.Percent >= options.warning:
+		status.Level = "warning"
+	default:
+		status.Level = "ok"
+	}
+	// Без списка процессов строка меню все равно показывает процент памяти
+	processes, err := collectProcesses(reader, nil)
+	if err != nil {
+		return status, nil
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].MemoryUsage > processes[j].MemoryUsage })
+	if len(processes) > trayTop {
+		processes = processes[:trayTop]
+	}
+	status.Processes = processes
+	return status, nil
+}
+
+// trayLoop передает состояние памяти write с периодом interval до SIGINT или SIGTERM
+func trayLoop(reader MemoryReader, options trayOptions, interval time.Duration, write func(trayStatus) error) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := readTrayStatus(reader, options)
+		if err != nil {
+			return err
+		}
+		if err := write(status); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+// trayTooltip описывает память и процессы несколькими строками
+func trayTooltip(status trayStatus) string {
+	lines := []string{
+		fmt.Sprintf("Memory: %s of %s (%.1f%%)", FormatMemorySize(status.Used), FormatMemorySize(status.Total), status.Percent),
+		fmt.Sprintf("Swap: %s", FormatMemorySize(status.SwapUsed)),
+	}
+	for _, process := range status.Processes {
+		lines = append(lines, fmt.Sprintf("%s %s", ellipsize(process.Name, 24), FormatMemorySize(process.MemoryUsage)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// xbarColors — цвета заголовка в строке меню по уровню
+var xbarColors = map[string]string{"warning": "#d29922", "critical": "#f85149"}
+
+// writeXbar выводит меню в формате плагинов xbar/SwiftBar: первая строка — заголовок в строке
+// меню, строки после "---" — пункты раскрывающегося меню
+func writeXbar(w io.Writer, status trayStatus, exe string, options trayOptions) {
+	title := fmt.Sprintf("MEM %.0f%%", status.Percent)
+	if color, ok := xbarColors[status.Level]; ok {
+		title += " | color=" + color
+	}
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, "---")
+	fmt.Fprintf(w, "Memory: %s of %s (%.1f%%)\n", FormatMemorySize(status.Used), FormatMemorySize(status.Total), status.Percent)
+	fmt.Fprintf(w, "Swap: %s\n", FormatMemorySize(status.SwapUsed))
+	fmt.Fprintln(w, "---")
+	for _, process := range status.Processes {
+		fmt.Fprintf(w, "%s %s | font=Menlo\n", ellipsize(process.Name, 24), FormatMemorySize(process.MemoryUsage))
+	}
+	fmt.Fprintln(w, "---")
+	fmt.Fprintf(w, "Open dashboard | bash=%q terminal=true\n", exe)
+	if options.url != "" {
+		fmt.Fprintf(w, "Open %s | href=%s\n", options.url, options.url)
+	}
+}
+
+// writeWaybar выводит строку JSON для модуля waybar с "return-type": "json"
+func writeWaybar(w io.Writer, status trayStatus) error {
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"text":       fmt.Sprintf("%.0f%%", status.Percent),
+		"tooltip":    trayTooltip(status),
+		"class":      status.Level,
+		"percentage": int(status.Percent + 0.5),
+	})
+}
+
+// yadIcons — значки области уведомлений по уровню
+var yadIcons = map[string]string{"ok": "dialog-information", "warning": "dialog-warning", "critical": "dialog-error"}
+
+// runYadTray запускает yad --notification и обновляет значок и подсказку командами на его
+// stdin; щелчок по значку открывает информационную панель в терминале. Подкоманда
+// завершается, когда пользователь выбирает Quit в меню значка
+func runYadTray(reader MemoryReader, options trayOptions, interval time.Duration) error {
+	dashboard := shellJoin(options.dashboard)
+	cmd := exec.Command("yad", "--notification", "--listen", "--image="+yadIcons["ok"], "--text=memory-analyzer", "--command="+dashboard)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Не удалось запустить yad: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	menu := "menu:Open dashboard!" + dashboard
+	if options.url != "" {
+		menu += "|Open " + options.url + "!" + shellJoin([]string{"xdg-open", options.url})
+	}
+	menu += "|Quit!quit\n"
+	if _, err := io.WriteString(stdin, menu); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := readTrayStatus(reader, options)
+		if err != nil {
+			stdin.Close()
+			return err
+		}
+		// yad читает по одной команде на строку, поэтому переводы строк в подсказке
+		// передаются как \n
+		tooltip := strings.ReplaceAll(trayTooltip(status), "\n", `\n`)
+		fmt.Fprintf(stdin, "icon:%s\ntooltip:%s\n", yadIcons[status.Level], tooltip)
+		select {
+		case <-ticker.C:
+		case err := <-exited:
+			return err
+		case <-signals:
+			stdin.Close()
+			cmd.Process.Kill()
+			<-exited
+			return nil
+		}
+	}
+}
+
+// shellJoin записывает команду строкой для yad, которая разбирает ее по правилам оболочки
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
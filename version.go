@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// version задается при сборке релиза: go build -ldflags "-X main.version=v1.2.3"; без него
+// версия берется из информации о сборке модуля
+var version string
+
+// BuildInfo — версия программы и сведения о сборке
+type BuildInfo struct {
+	Version string `json:"version"`
+	//Коммит, его время и признак незакоммиченных изменений, если сборка шла из репозитория git
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`
+	GoVersion  string `json:"go_version"`
+	Platform   string `json:"platform"`
+}
+
+// readBuildInfo собирает сведения о сборке из runtime/debug.ReadBuildInfo
+func readBuildInfo() BuildInfo {
+	info := BuildInfo{Version: version, GoVersion: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				info.CommitTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// String форматирует сведения о сборке одной строкой
+func (b BuildInfo) String() string {
+	var details []string
+	if b.Commit != "" {
+		commit := b.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if b.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, "commit "+commit)
+	}
+	if b.CommitTime != "" {
+		details = append(details, b.CommitTime)
+	}
+	details = append(details, b.GoVersion, b.Platform)
+	return fmt.Sprintf("memory-analyzer %s (%s)", b.Version, strings.Join(details, ", "))
+}
+
+// runVersionCommand — подкоманда "version": версия программы, коммит и версия Go
+func runVersionCommand(args []string) error {
+	flags := newCommandFlags("version")
+	asJSON := flags.Bool("json", false, "print the build information as JSON")
+	flags.Parse(args)
+	info := readBuildInfo()
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+	fmt.Println(info)
+	return nil
+}
+
+// compareVersions сравнивает версии вида v1.2.3: -1, 0 или 1. Суффикс предварительной версии
+// ("-rc1") делает версию меньше той же версии без суффикса
+func compareVersions(a, b string) int {
+	parse := func(v string) ([3]int, string) {
+		var parts [3]int
+		v = strings.TrimPrefix(v, "v")
+		v, pre, _ := strings.Cut(v, "-")
+		for i, part := range strings.SplitN(v, ".", 3) {
+			parts[i], _ = strconv.Atoi(part)
+		}
+		return parts, pre
+	}
+	aParts, aPre := parse(a)
+	bParts, bPre := parse(b)
+	for i := range aParts {
+		if aParts[i] != bParts[i] {
+			if aParts[i] < bParts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	}
+	return 1
+}
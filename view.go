@@ -0,0 +1,279 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	clearScreen = "\033[H\033[2J"
+	colorReset  = "\033[0m"
+)
+
+// budgetWarningPercent — доля бюджета, начиная с которой строка процесса подсвечивается предупреждением
+const budgetWarningPercent = 90
+
+// tableHeaderLines — количество строк заголовка, которые FormatTable выводит перед строками процессов
+const tableHeaderLines = 3
+
+// ViewState хранит интерактивное состояние информационной панели между обновлениями
+type ViewState struct {
+	//Индекс выделенной строки в отображаемой таблице
+	Selected int
+
+	//PID процессов, закрепленных из интерфейса
+	PinnedPIDs map[int]bool
+
+	//Сообщение о результате последнего действия, выводится под таблицей
+	Status string
+
+	//Действие администратора, ожидающее подтверждения клавишей y
+	PendingAction *AdminAction
+
+	//PID процессов, отмеченных для группового действия
+	Marked map[int]bool
+	//Процессы, которым после подтверждения клавишей y будет отправлен SIGTERM
+	PendingKill []ProcessInfo
+	//Имена процессов из списка наблюдения, закрепляемых в начале таблицы
+	WatchNames []string
+	//Процессы, приостановленные из интерфейса сигналом SIGSTOP: PID и имя
+	Frozen map[int]string
+	//Журнал аудита действий, изменяющих систему, или nil
+	Audit *AuditLog
+
+	//Время обновления таблицы процессов и подробных панелей, если они обновляются реже системной
+	ProcessesUpdated time.Time
+	DetailsUpdated   time.Time
+
+	//PID процесса, открытого в окне просмотра, или 0, если окно закрыто
+	InspectPID int
+
+	//Подробности о процессе из окна просмотра, прочитанные при последнем обновлении
+	Details *ProcessDetails
+
+	//Сводка ошибок сбора данных
+	Errors *ErrorReport
+
+	//Панель с подробностями последних ошибок открыта
+	ShowErrors bool
+
+	//Сводки последних завершившихся процессов
+	Exited []ProcessLifetime
+
+	//Показывать параметры памяти ядра под системной статистикой
+	ShowTunables bool
+
+	//Показывать колонки ввода-вывода
+	ShowIO bool
+
+	//Ключ сортировки таблицы в формате ParseSortSpec; пустой — сортировка из конфигурации
+	SortKey string
+
+	//Позиции процессов в таблице при последнем обновлении для config.RowHysteresis
+	Ranks map[int]int
+
+	//Показывать перечень отображенных в память файлов вместо таблицы процессов
+	ShowFiles bool
+	//Перечень отображенных файлов; обновляется на каждом замере, пока он показан
+	Files *FileCensus
+
+	//Имя выбранного набора настроек или пустая строка
+	Preset string
+
+	//Режим группировки таблицы, одно из значений groupModes
+	GroupBy string
+
+	//Строка поиска: таблица сужается до процессов, в имени которых она встречается
+	Search string
+	//Идет ввод строки поиска, клавиши дополняют ее, а не управляют панелью
+	Searching bool
+
+	//Горячие клавиши доступны, и выделенная строка подсвечивается
+	Interactive bool
+	//Действия, изменяющие систему (завершение и приостановка процессов), отключены и
+	//не показываются в подсказках (флаг --read-only)
+	ReadOnly bool
+
+	//Строка экрана (от 1) с заголовком таблицы процессов при последней отрисовке
+	//или 0, если таблица не показана, и сам заголовок — для щелчков мыши
+	TableHeaderRow int
+	TableHeader    string
+
+	//Высота терминала в строках или 0, если таблица не прокручивается
+	ScreenRows int
+	//Число строк процессов, помещающихся на экране при последней отрисовке,
+	//или 0, если таблица показана целиком
+	PaneRows int
+	//Индекс первой показанной строки прокручиваемой таблицы
+	ScrollOffset int
+
+	//Компьютер работает от батареи, и панель обновляется реже
+	OnBattery bool
+}
+
+func NewViewState() *ViewState {
+	return &ViewState{PinnedPIDs: make(map[int]bool), Marked: make(map[int]bool), Frozen: make(map[int]string)}
+}
+
+// MoveSelection смещает выделение на delta строк, не выходя за пределы таблицы из n строк
+func (s *ViewState) MoveSelection(delta, n int) {
+	s.Selected += delta
+	s.ClampSelection(n)
+}
+
+// ClampSelection удерживает выделение в пределах таблицы из n строк
+func (s *ViewState) ClampSelection(n int) {
+	if s == nil {
+		return
+	}
+	if s.Selected >= n {
+		s.Selected = n - 1
+	}
+	if s.Selected < 0 {
+		s.Selected = 0
+	}
+}
+
+// TogglePin закрепляет процесс или снимает закрепление, если он уже закреплен
+func (s *ViewState) TogglePin(process ProcessInfo) {
+	if s.PinnedPIDs[process.PID] {
+		delete(s.PinnedPIDs, process.PID)
+		return
+	}
+	s.PinnedPIDs[process.PID] = true
+}
+
+// ToggleInspect открывает окно просмотра процесса или закрывает его, если оно уже открыто
+func (s *ViewState) ToggleInspect(process ProcessInfo) {
+	if s.InspectPID == process.PID {
+		s.CloseInspect()
+		return
+	}
+	s.InspectPID = process.PID
+	s.Details = nil
+}
+
+func (s *ViewState) CloseInspect() {
+	s.InspectPID = 0
+	s.Details = nil
+}
+
+func (s *ViewState) isPinned(process ProcessInfo, config DisplayConfig) bool {
+	if s != nil && s.PinnedPIDs[process.PID] {
+		return true
+	}
+	names := config.PinnedNames
+	if s != nil {
+		names = append(names[:len(names):len(names)], s.WatchNames...)
+	}
+	for _, name := range names {
+		if matchesProcessName(process.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesProcessName сравнивает имя процесса с заданным пользователем именем
+// как целиком, так и по имени исполняемого файла без пути
+func matchesProcessName(processName, name string) bool {
+	return processName == name || filepath.Base(processName) == name
+}
+
+// visibleProcesses возвращает процессы в том виде, в котором они отображаются в таблице:
+// закрепленные процессы идут первыми, за ними первые config.TopProcesses процессов
+// в порядке сортировки
+func visibleProcesses(processes []ProcessInfo, config DisplayConfig, state *ViewState) []ProcessInfo {
+	view := make([]ProcessInfo, len(processes))
+	copy(view, processes)
+	state.sortSpec(config).Sort(view)
+	if state != nil {
+		dampenOrder(view, state.Ranks, config.RowHysteresis)
+	}
+	showIO, search := config.ShowIO, ""
+	if state != nil {
+		showIO, search = state.ShowIO, state.Search
+	}
+
+	var pinned, rest []ProcessInfo
+	for _, process := range view {
+		if !matchesSearch(process, search) {
+			continue
+		}
+		if !showIO {
+			process.IO = nil
+		}
+		process.Budget = processBudget(process, config)
+		if config.Baseline != nil {
+			base, _ := config.Baseline.lookup(process)
+			process.Baseline = &base
+		}
+		if state.isPinned(process, config) {
+			process.Pinned = true
+			pinned = append(pinned, process)
+		} else if matchesFilter(process, config) && !config.Ignore.Ignores(process) && showsKernelThread(process, config) {
+			rest = append(rest, process)
+		}
+	}
+	if config.TopProcesses > 0 && len(rest) > config.TopProcesses {
+		rest = rest[:config.TopProcesses]
+	}
+	return append(pinned, rest...)
+}
+
+func processBudget(process ProcessInfo, config DisplayConfig) uint64 {
+	for name, budget := range config.Budgets {
+		if matchesProcessName(process.Name, name) {
+			return budget
+		}
+	}
+	return 0
+}
+
+// colorizeBudgetRows окрашивает строки процессов, превысивших бюджет, в красный цвет,
+// а приблизившихся к нему — в желтый
+func colorizeBudgetRows(table string, processes []ProcessInfo) string {
+	lines := strings.Split(table, "\n")
+	for i, process := range processes {
+		idx := tableHeaderLines + i
+		if process.Budget == 0 || idx >= len(lines) {
+			continue
+		}
+		percent := process.BudgetPercent()
+		if percent > 100 {
+			lines[idx] = paint(activeTheme.Critical, lines[idx])
+		} else if percent >= budgetWarningPercent {
+			lines[idx] = paint(activeTheme.Warning, lines[idx])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// highlightRow выделяет инверсией цвета строку процесса с индексом row в таблице FormatTable
+func highlightRow(table string, row int) string {
+	lines := strings.Split(table, "\n")
+	idx := tableHeaderLines + row
+	if row < 0 || idx >= len(lines) || lines[idx] == "" {
+		return table
+	}
+	lines[idx] = paint(activeTheme.Highlight, lines[idx])
+	return strings.Join(lines, "\n")
+}
+
+// stringList реализует flag.Value для флагов, которые можно указывать несколько раз
+// или передавать список значений через запятую
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Виртуальную машину WSL2 описывает wslCollector: balloon в ней не используется
+	if hypervisor := detectHypervisor(); hypervisor != "" && wslVersion() == 0 {
+		RegisterCollector(&vmCollector{hypervisor: hypervisor})
+	}
+}
+
+// detectHypervisor возвращает название гипервизора, если программа запущена в виртуальной машине,
+// иначе пустую строку
+func detectHypervisor() string {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxHypervisor()
+	case "darwin":
+		if present, err := sysctlUint("kern.hv_vmm_present"); err == nil && present == 1 {
+			return "Apple Hypervisor"
+		}
+	}
+	return ""
+}
+
+func linuxHypervisor() string {
+	vendor := readSysfsValue("/sys/class/dmi/id/sys_vendor")
+	product := readSysfsValue("/sys/class/dmi/id/product_name")
+	switch {
+	case strings.Contains(vendor, "VMware") || strings.Contains(product, "VMware"):
+		return "VMware"
+	case vendor == "Microsoft Corporation" && strings.Contains(product, "Virtual Machine"):
+		return "Hyper-V"
+	case strings.Contains(vendor, "QEMU") || strings.Contains(product, "KVM") || strings.Contains(vendor, "KVM"):
+		return "KVM"
+	case strings.Contains(vendor, "innotek") || strings.Contains(product, "VirtualBox"):
+		return "VirtualBox"
+	case strings.Contains(vendor, "Xen") || readSysfsValue("/sys/hypervisor/type") == "xen":
+		return "Xen"
+	case strings.Contains(vendor, "Amazon EC2"):
+		return "KVM"
+	}
+	if cpuHasHypervisorFlag() {
+		return "unknown hypervisor"
+	}
+	return ""
+}
+
+func cpuHasHypervisorFlag() bool {
+	file, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "flags") {
+			return strings.Contains(line, " hypervisor")
+		}
+	}
+	return false
+}
+
+// vmCollector сообщает о памяти, занятой драйвером balloon, и о памяти, выделенной гипервизором
+//
+// При надутом balloon гостевая система видит заметно меньше памяти или считает ее занятой,
+// поэтому значения "used" без этой поправки могут сильно вводить в заблуждение
+type vmCollector struct {
+	hypervisor string
+}
+
+func (c *vmCollector) Name() string {
+	return fmt.Sprintf("vm (%s)", c.hypervisor)
+}
+
+func (c *vmCollector) Collect() ([]Metric, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+	reader := &LinuxMemoryReader{}
+	info, err := reader.ReadSystemMemory()
+	if err != nil {
+		return nil, err
+	}
+	used := info.TotalMemory - info.AvailableMemory
+
+	if c.hypervisor == "VMware" {
+		balloon, err := vmwareBalloonSize()
+		if err != nil {
+			return nil, err
+		}
+		// vmw_balloon занимает страницы внутри гостя, не уменьшая MemTotal
+		usedExclBalloon := uint64(0)
+		if used > balloon {
+			usedExclBalloon = used - balloon
+		}
+		return []Metric{
+			{Name: "balloon", Value: float64(balloon), Unit: "bytes"},
+			{Name: "used excl. balloon", Value: float64(usedExclBalloon), Unit: "bytes"},
+		}, nil
+	}
+
+	balloon, err := virtioBalloonSize()
+	if err != nil {
+		return nil, err
+	}
+	// virtio_balloon и hv_balloon уменьшают MemTotal на размер balloon
+	return []Metric{
+		{Name: "balloon", Value: float64(balloon), Unit: "bytes"},
+		{Name: "assigned by hypervisor", Value: float64(info.TotalMemory + balloon), Unit: "bytes"},
+	}, nil
+}
+
+// virtioBalloonSize вычисляет текущий размер balloon по счетчикам balloon_inflate/balloon_deflate из /proc/vmstat
+func virtioBalloonSize() (uint64, error) {
+	stats, err := readVMStat()
+	if err != nil {
+		return 0, err
+	}
+	inflate, deflate := stats["balloon_inflate"], stats["balloon_deflate"]
+	if deflate >= inflate {
+		return 0, nil
+	}
+	return (inflate - deflate) * uint64(os.Getpagesize()), nil
+}
+
+// readVMStat читает счетчики ядра из /proc/vmstat в формате "имя значение"
+func readVMStat() (map[string]uint64, error) {
+	file, err := os.Open("/proc/vmstat")
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть /proc/vmstat: %v", err)
+	}
+	defer file.Close()
+	return parseKeyValues(file)
+}
+
+// parseKeyValues разбирает строки вида "ключ значение", как в /proc/vmstat и memory.events
+func parseKeyValues(r io.Reader) (map[string]uint64, error) {
+	stats := make(map[string]uint64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		val, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		stats[fields[0]] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка чтения: %v", err)
+	}
+	return stats, nil
+}
+
+var vmwareSizePattern = regexp.MustCompile(`(\d+)\s*([KMG]?B)`)
+
+// vmwareBalloonSize читает размер balloon из vmware-toolbox-cmd
+func vmwareBalloonSize() (uint64, error) {
+	output, err := exec.Command("vmware-toolbox-cmd", "stat", "balloon").Output()
+	if err != nil {
+		return 0, fmt.Errorf("vmware-toolbox-cmd недоступен: %v", err)
+	}
+	match := vmwareSizePattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, fmt.Errorf("Неверный формат вывода vmware-toolbox-cmd")
+	}
+	return parseByteSize(match[1] + match[2])
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Режимы vm.overcommit_memory
+const (
+	OvercommitHeuristic = 0
+	OvercommitAlways    = 1
+	OvercommitNever     = 2
+)
+
+// minFreeWarningPercent — доля памяти, зарезервированная vm.min_free_kbytes, начиная
+// с которой резерв считается подозрительно большим
+const minFreeWarningPercent = 5
+
+// VMTunables — параметры ядра, влияющие на распределение памяти
+type VMTunables struct {
+	Swappiness       int    `json:"swappiness"`
+	OvercommitMemory int    `json:"overcommit_memory"`
+	OvercommitRatio  int    `json:"overcommit_ratio"`
+	MinFree          uint64 `json:"min_free_bytes"`
+	//Предел выделенной памяти в строгом режиме и объем уже выделенной памяти (CommitLimit, Committed_AS)
+	CommitLimit uint64 `json:"commit_limit_bytes"`
+	Committed   uint64 `json:"committed_bytes"`
+}
+
+// TunablesReader реализуют источники данных, умеющие читать параметры памяти ядра
+type TunablesReader interface {
+	ReadVMTunables() (VMTunables, error)
+}
+
+func (l *LinuxMemoryReader) ReadVMTunables() (VMTunables, error) {
+	var tunables VMTunables
+	var err error
+	if tunables.Swappiness, err = readSysctlInt(l.path("proc", "sys", "vm", "swappiness")); err != nil {
+		return tunables, err
+	}
+	if tunables.OvercommitMemory, err = readSysctlInt(l.path("proc", "sys", "vm", "overcommit_memory")); err != nil {
+		return tunables, err
+	}
+	tunables.OvercommitRatio, _ = readSysctlInt(l.path("proc", "sys", "vm", "overcommit_ratio"))
+	minFree, _ := readSysctlInt(l.path("proc", "sys", "vm", "min_free_kbytes"))
+	tunables.MinFree = uint64(minFree) * 1024
+
+	file, err := os.Open(l.path("proc", "meminfo"))
+	if err != nil {
+		return tunables, err
+	}
+	defer file.Close()
+	stats, err := parseMemInfo(file)
+	if err != nil {
+		return tunables, err
+	}
+	tunables.CommitLimit = stats["CommitLimit"] * 1024
+	tunables.Committed = stats["Committed_AS"] * 1024
+	return tunables, nil
+}
+
+func readSysctlInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// collectVMTunables читает параметры памяти ядра, если источник данных это поддерживает
+func collectVMTunables(reader MemoryReader) *VMTunables {
+	tunablesReader, ok := reader.(TunablesReader)
+	if !ok {
+		return nil
+	}
+	tunables, err := tunablesReader.ReadVMTunables()
+	if err != nil {
+		return nil
+	}
+	return &tunables
+}
+
+func overcommitModeName(mode int) string {
+	switch mode {
+	case OvercommitHeuristic:
+		return "heuristic"
+	case OvercommitAlways:
+		return "always overcommit"
+	case OvercommitNever:
+		return "never overcommit"
+	}
+	return "unknown"
+}
+
+// tunablesWarnings возвращает предупреждения о настройках, которые часто оказываются ошибочными
+func tunablesWarnings(tunables VMTunables, system SystemMemoryInfo) []string {
+	var warnings []string
+	switch tunables.OvercommitMemory {
+	case OvercommitAlways:
+		warnings = append(warnings, "overcommit_memory=1: allocations never fail, the OOM killer is the only limit")
+	case OvercommitNever:
+		if tunables.CommitLimit < system.TotalMemory && system.SwapTotal == 0 {
+			warnings = append(warnings, fmt.Sprintf("strict overcommit without swap: only %s of %s RAM can be allocated (overcommit_ratio=%d)",
+				FormatMemorySize(tunables.CommitLimit), FormatMemorySize(system.TotalMemory), tunables.OvercommitRatio))
+		}
+	}
+	if system.TotalMemory > 0 && float64(tunables.MinFree)/float64(system.TotalMemory)*100 >= minFreeWarningPercent {
+		warnings = append(warnings, fmt.Sprintf("min_free_kbytes reserves %.1f%% of memory",
+			float64(tunables.MinFree)/float64(system.TotalMemory)*100))
+	}
+	return warnings
+}
+
+// FormatVMTunables форматирует параметры памяти ядра с предупреждениями о подозрительных значениях
+func FormatVMTunables(tunables *VMTunables, system SystemMemoryInfo) string {
+	if tunables == nil {
+		return "Kernel memory settings are not available on this system\n"
+	}
+	var res strings.Builder
+	res.WriteString("Kernel Memory Settings:\n")
+	res.WriteString(fmt.Sprintf("vm.swappiness:        %d\n", tunables.Swappiness))
+	res.WriteString(fmt.Sprintf("vm.overcommit_memory: %d (%s)\n", tunables.OvercommitMemory, overcommitModeName(tunables.OvercommitMemory)))
+	res.WriteString(fmt.Sprintf("vm.overcommit_ratio:  %d%%\n", tunables.OvercommitRatio))
+	res.WriteString(fmt.Sprintf("vm.min_free_kbytes:   %s\n", FormatMemorySize(tunables.MinFree)))
+	res.WriteString(fmt.Sprintf("CommitLimit:          %s\n", FormatMemorySize(tunables.CommitLimit)))
+	res.WriteString(fmt.Sprintf("Committed_AS:         %s\n", FormatMemorySize(tunables.Committed)))
+	for _, warning := range tunablesWarnings(*tunables, system) {
+		res.WriteString(paint(activeTheme.Warning, "! "+warning) + "\n")
+	}
+	return res.String()
+}
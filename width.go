@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges — диапазоны символов, занимающих в терминале две колонки: CJK,
+// полноширинные формы, хангыль и эмодзи (по East Asian Width: W и F)
+var wideRanges = []struct{ from, to rune }{
+	{0x1100, 0x115F},   // хангыль: начальные согласные
+	{0x231A, 0x231B},   // часы
+	{0x2329, 0x232A},   // угловые скобки
+	{0x23E9, 0x23EC},   // кнопки воспроизведения
+	{0x23F0, 0x23F0},   // будильник
+	{0x23F3, 0x23F3},   // песочные часы
+	{0x25FD, 0x25FE},   // квадраты
+	{0x2614, 0x2615},   // зонт, горячий напиток
+	{0x2648, 0x2653},   // знаки зодиака
+	{0x267F, 0x267F},   // инвалидное кресло
+	{0x2693, 0x2693},   // якорь
+	{0x26A1, 0x26A1},   // молния
+	{0x26AA, 0x26AB},   // круги
+	{0x26BD, 0x26BE},   // мячи
+	{0x26C4, 0x26C5},   // снеговик, солнце за облаком
+	{0x26CE, 0x26CE},   // Змееносец
+	{0x26D4, 0x26D4},   // въезд запрещен
+	{0x26EA, 0x26EA},   // церковь
+	{0x26F2, 0x26F3},   // фонтан, флаг в лунке
+	{0x26F5, 0x26F5},   // парусник
+	{0x26FA, 0x26FA},   // палатка
+	{0x26FD, 0x26FD},   // заправка
+	{0x2705, 0x2705},   // галочка
+	{0x270A, 0x270B},   // кулак, ладонь
+	{0x2728, 0x2728},   // искры
+	{0x274C, 0x274C},   // крест
+	{0x274E, 0x274E},   // крест в квадрате
+	{0x2753, 0x2755},   // вопросительные и восклицательный знаки
+	{0x2757, 0x2757},   // восклицательный знак
+	{0x2795, 0x2797},   // плюс, минус, деление
+	{0x27B0, 0x27B0},   // петля
+	{0x27BF, 0x27BF},   // двойная петля
+	{0x2B1B, 0x2B1C},   // большие квадраты
+	{0x2B50, 0x2B50},   // звезда
+	{0x2B55, 0x2B55},   // большой круг
+	{0x2E80, 0x303E},   // CJK: радикалы, знаки препинания
+	{0x3041, 0x33FF},   // хирагана, катакана, бопомофо, CJK-совместимость
+	{0x3400, 0x4DBF},   // CJK: расширение A
+	{0x4E00, 0x9FFF},   // CJK: унифицированные иероглифы
+	{0xA000, 0xA4CF},   // И
+	{0xA960, 0xA97F},   // хангыль: расширение A
+	{0xAC00, 0xD7A3},   // хангыль: слоги
+	{0xF900, 0xFAFF},   // CJK: совместимые иероглифы
+	{0xFE10, 0xFE19},   // вертикальные формы
+	{0xFE30, 0xFE6F},   // CJK: совместимые формы
+	{0xFF00, 0xFF60},   // полноширинные формы
+	{0xFFE0, 0xFFE6},   // полноширинные знаки
+	{0x16FE0, 0x18CFF}, // тангутское письмо, кхитанское письмо
+	{0x1AFF0, 0x1B2FF}, // кана: дополнения
+	{0x1F004, 0x1F004}, // маджонг
+	{0x1F0CF, 0x1F0CF}, // джокер
+	{0x1F18E, 0x1F18E}, // AB
+	{0x1F191, 0x1F19A}, // квадратные буквы
+	{0x1F200, 0x1F2FF}, // иероглифы в квадратах
+	{0x1F300, 0x1F64F}, // пиктограммы, смайлики
+	{0x1F680, 0x1F6FF}, // транспорт и карты
+	{0x1F7E0, 0x1F7EB}, // цветные круги и квадраты
+	{0x1F90C, 0x1F9FF}, // дополнительные пиктограммы
+	{0x1FA70, 0x1FAFF}, // пиктограммы: расширение A
+	{0x20000, 0x3FFFD}, // CJK: расширения B–H
+}
+
+// runeWidth возвращает число колонок терминала, которое занимает символ:
+// 0 для комбинируемых и невидимых символов, 2 для широких, иначе 1
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || r == 0x200D || (r >= 0xFE00 && r <= 0xFE0F):
+		// Соединитель и селекторы вариантов в эмодзи не занимают места
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r < wide.from {
+			break
+		}
+		if r <= wide.to {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth возвращает ширину строки в колонках терминала; в отличие от len
+// учитывает многобайтовые, широкие и комбинируемые символы
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// truncateWidth обрезает строку до width колонок, не разрывая символы UTF-8
+func truncateWidth(s string, width int) string {
+	used := 0
+	for i, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			return s[:i]
+		}
+		used += w
+	}
+	return s
+}
+
+// ellipsize обрезает строку до width колонок, заменяя конец многоточием "..."
+func ellipsize(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	return truncateWidth(s, width-3) + "..."
+}
+
+// ellipsizeLeft обрезает строку до width колонок, заменяя начало многоточием "..."
+// (для путей, где важнее конец)
+func ellipsizeLeft(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	used, cut := 0, len(s)
+	for cut > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:cut])
+		if used+runeWidth(r) > width-3 {
+			break
+		}
+		used += runeWidth(r)
+		cut -= size
+	}
+	return "..." + s[cut:]
+}
+
+// fitLeft обрезает строку до width колонок или дополняет ее пробелами справа
+func fitLeft(s string, width int) string {
+	s = truncateWidth(s, width)
+	return s + strings.Repeat(" ", width-displayWidth(s))
+}
+
+// fitRight выравнивает строку по правому краю колонки шириной width, обрезая слишком длинные значения
+func fitRight(s string, width int) string {
+	s = truncateWidth(s, width)
+	return strings.Repeat(" ", width-displayWidth(s)) + s
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//Период опроса памяти Windows: запуск PowerShell через interop занимает секунды
+	wslHostInterval = 30 * time.Second
+	//Ограничение времени одного запроса к PowerShell
+	wslHostTimeout = 20 * time.Second
+)
+
+// wslQueryHost включает опрос памяти Windows через PowerShell (флаг --wsl-host)
+var wslQueryHost bool
+
+func init() {
+	if version := wslVersion(); version != 0 {
+		RegisterCollector(&wslCollector{version: version})
+	}
+}
+
+// wslVersion возвращает 2 в WSL2, 1 в WSL1 и 0 вне WSL. Ядро WSL2 собирается
+// Microsoft с версией вида "5.15.153.1-microsoft-standard-WSL2", в WSL1 ядра Linux
+// нет, а osrelease содержит "Microsoft"
+func wslVersion() int {
+	if runtime.GOOS != "linux" {
+		return 0
+	}
+	release := readSysfsValue("/proc/sys/kernel/osrelease")
+	switch {
+	case strings.Contains(release, "microsoft-standard"), strings.Contains(release, "WSL2"):
+		return 2
+	case strings.Contains(release, "Microsoft"):
+		return 1
+	}
+	return 0
+}
+
+// wslHostMemory — память Windows по данным PowerShell
+type wslHostMemory struct {
+	//Физическая память Windows и ее свободная часть
+	Total uint64
+	Free  uint64
+	//Рабочий набор процесса vmmem (vmmemWSL), которым виртуальная машина WSL2 видна
+	//в диспетчере задач
+	Vmmem uint64
+	//Режим autoMemoryReclaim из %UserProfile%\.wslconfig или пустая строка
+	Reclaim string
+}
+
+// wslCollector показывает память виртуальной машины WSL2 и, с флагом --wsl-host, память
+// Windows. Виртуальная машина берет память у Windows по мере надобности, а страничный кэш
+// Linux остается занятым с точки зрения Windows, пока его не вернет autoMemoryReclaim,
+// поэтому vmmem в диспетчере задач обычно больше "used" внутри WSL
+type wslCollector struct {
+	version int
+
+	mu       sync.Mutex
+	host     *wslHostMemory
+	hostErr  error
+	queried  time.Time
+	querying bool
+}
+
+func (c *wslCollector) Name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.host != nil && c.host.Reclaim != "" {
+		return fmt.Sprintf("wsl%d (autoMemoryReclaim %s)", c.version, c.host.Reclaim)
+	}
+	return fmt.Sprintf("wsl%d", c.version)
+}
+
+func (c *wslCollector) Collect() ([]Metric, error) {
+	if c.version == 1 {
+		// В WSL1 процессы Linux работают прямо в Windows, и /proc/meminfo показывает память Windows
+		return nil, nil
+	}
+	memInfo, err := readMemInfoFile("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	metrics := []Metric{
+		{Name: "vm limit", Value: float64(memInfo["MemTotal"] * 1024), Unit: "bytes"},
+		{Name: "page cache held from Windows", Value: float64((memInfo["Cached"] + memInfo["Buffers"]) * 1024), Unit: "bytes"},
+	}
+	if !wslQueryHost {
+		return metrics, nil
+	}
+	host, err := c.hostMemory()
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось получить память Windows через powershell.exe: %v", err)
+	}
+	if host != nil {
+		metrics = append(metrics,
+			Metric{Name: "windows total", Value: float64(host.Total), Unit: "bytes"},
+			Metric{Name: "windows free", Value: float64(host.Free), Unit: "bytes"},
+			Metric{Name: "vmmem", Value: float64(host.Vmmem), Unit: "bytes"},
+		)
+	}
+	return metrics, nil
+}
+
+// hostMemory возвращает последние данные о памяти Windows и раз в wslHostInterval
+// обновляет их в фоне, чтобы медленный запуск PowerShell не задерживал панель
+func (c *wslCollector) hostMemory() (*wslHostMemory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.querying && time.Since(c.queried) >= wslHostInterval {
+		c.querying = true
+		go func() {
+			host, err := queryWindowsMemory()
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.host, c.hostErr, c.queried, c.querying = host, err, time.Now(), false
+			if err != nil {
+				c.host = nil
+			}
+		}()
+	}
+	return c.host, c.hostErr
+}
+
+// wslHostScript выводит память Windows (в килобайтах, как ее отдает WMI), рабочий набор
+// vmmem в байтах и содержимое .wslconfig одной строкой JSON
+const wslHostScript = `$os = Get-CimInstance Win32_OperatingSystem
+$vm = Get-Process -Name vmmem,vmmemWSL -ErrorAction SilentlyContinue | Measure-Object WorkingSet64 -Sum
+$config = Get-Content -Raw (Join-Path $env:USERPROFILE '.wslconfig') -ErrorAction SilentlyContinue
+[pscustomobject]@{total_kb=[uint64]$os.TotalVisibleMemorySize; free_kb=[uint64]$os.FreePhysicalMemory; vmmem=[int64]$vm.Sum; config=[string]$config} | ConvertTo-Json -Compress`
+
+// queryWindowsMemory запрашивает память Windows через interop WSL
+func queryWindowsMemory() (*wslHostMemory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wslHostTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", wslHostScript).Output()
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		TotalKB uint64 `json:"total_kb"`
+		FreeKB  uint64 `json:"free_kb"`
+		Vmmem   uint64 `json:"vmmem"`
+		Config  string `json:"config"`
+	}
+	if err := json.Unmarshal(output, &response); err != nil {
+		return nil, fmt.Errorf("Неожиданный ответ PowerShell: %v", err)
+	}
+	return &wslHostMemory{
+		Total:   response.TotalKB * 1024,
+		Free:    response.FreeKB * 1024,
+		Vmmem:   response.Vmmem,
+		Reclaim: wslConfigValue(response.Config, "autoMemoryReclaim"),
+	}, nil
+}
+
+// wslConfigValue возвращает значение ключа из .wslconfig без учета раздела и регистра ключа
+func wslConfigValue(config, key string) string {
+	for _, line := range strings.Split(config, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(name), key) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// readMemInfoFile читает значения /proc/meminfo в килобайтах
+func readMemInfoFile(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseMemInfo(file)
+}